@@ -0,0 +1,754 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/agent/cost"
+	"gopilot-cli/internal/config"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/logger"
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/session"
+	"gopilot-cli/internal/tools"
+	wspath "gopilot-cli/internal/utils/path"
+	tw "gopilot-cli/internal/utils/terminal"
+
+	"golang.org/x/term"
+)
+
+//
+// 会话内命令：/model、/token-limit、/cost、/log、/logs、/plan、/dryplan、/compare、/apply、/discard、/rollback、/save、/load、/resume、/instruct、/retry、/config、/think、/choices
+//
+
+// tokenLimitForWindow 根据模型上下文窗口推算摘要阈值，预留 1/4 给输出与工具结果
+func tokenLimitForWindow(window int) int {
+	return window * 3 / 4
+}
+
+// switchModel 处理 /model [name]：无参数时显示当前模型；
+// 切换到已知模型时自动把 token limit 调整为其上下文窗口的 3/4
+func switchModel(client *llm.Client, ag *agent.Agent, args []string) {
+	if len(args) == 0 {
+		fmt.Printf("\n%sCurrent model: %s (token limit: %d)%s\n\n",
+			colors.BRIGHT_CYAN, client.Model(), ag.TokenLimit(), colors.RESET)
+		return
+	}
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /model <name>%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+
+	model := args[0]
+	client.SetModel(model)
+	fmt.Printf("%s✅ Switched model to %s%s\n", colors.GREEN, model, colors.RESET)
+
+	window, ok := llm.ContextWindow(model)
+	if !ok {
+		fmt.Printf("%sUnknown context window for %s; token limit stays at %d (use /token-limit <n> to change)%s\n\n",
+			colors.DIM, model, ag.TokenLimit(), colors.RESET)
+		return
+	}
+	if err := ag.SetTokenLimit(tokenLimitForWindow(window)); err != nil {
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Token limit set to %d (context window %d)%s\n\n",
+		colors.GREEN, ag.TokenLimit(), window, colors.RESET)
+}
+
+// setTokenLimit 处理 /token-limit [n]：无参数时显示当前值
+func setTokenLimit(ag *agent.Agent, args []string) {
+	if len(args) == 0 {
+		fmt.Printf("\n%sCurrent token limit: %d%s\n\n", colors.BRIGHT_CYAN, ag.TokenLimit(), colors.RESET)
+		return
+	}
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /token-limit <n>%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("%s❌ Invalid token limit %q: must be a positive integer%s\n\n", colors.RED, args[0], colors.RESET)
+		return
+	}
+	if err := ag.SetTokenLimit(n); err != nil {
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Token limit set to %d%s\n\n", colors.GREEN, n, colors.RESET)
+}
+
+// formatSpend 格式化累计花费与 token 用量
+func formatSpend(t *cost.Tracker) string {
+	prompt, completion := t.Tokens()
+	s := fmt.Sprintf("$%.4f (%d prompt + %d completion tokens)", t.Spent(), prompt, completion)
+	if n := t.Unpriced(); n > 0 {
+		s += fmt.Sprintf(", %d call(s) with unknown pricing", n)
+	}
+	return s
+}
+
+// printCost 处理 /cost：显示当前花费与预算
+func printCost(ag *agent.Agent) {
+	t := ag.CostTracker()
+	budget := t.Budget()
+
+	fmt.Printf("\n%sEstimated session cost:%s %s\n", colors.BRIGHT_CYAN, colors.RESET, formatSpend(t))
+	if budget.WarnUSD > 0 {
+		fmt.Printf("  Warn at:  $%.4f\n", budget.WarnUSD)
+	}
+	if t.NextCap() > 0 {
+		fmt.Printf("  Pause at: $%.4f\n", t.NextCap())
+	}
+	if budget.WarnUSD == 0 && budget.HardCapUSD == 0 {
+		fmt.Printf("  %sNo budget configured (cost.warn_usd / cost.hard_cap_usd)%s\n", colors.DIM, colors.RESET)
+	}
+	fmt.Println()
+}
+
+// listLogs 处理 /logs [n]：列出最近 n 个运行日志（默认 10 个）
+func listLogs(args []string) {
+	n := 10
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /logs [n]%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+	if len(args) == 1 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			fmt.Printf("%s❌ Invalid count %q: must be a positive integer%s\n\n", colors.RED, args[0], colors.RESET)
+			return
+		}
+		n = v
+	}
+
+	dir, err := logger.Dir()
+	if err != nil {
+		fmt.Printf("%s❌ Failed to list logs: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	logs, err := tools.ListRunLogs(dir, n)
+	if err != nil {
+		fmt.Printf("%s❌ Failed to list logs: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if len(logs) == 0 {
+		fmt.Printf("\n%sNo run logs in %s%s\n\n", colors.DIM, dir, colors.RESET)
+		return
+	}
+	fmt.Printf("\n%sRecent run logs (%s):%s\n%s\n\n", colors.BRIGHT_CYAN, dir, colors.RESET, tools.FormatRunLogs(logs))
+}
+
+// parseDelay 解析 /retry 的等待时间：纯数字按秒计（与配置文件一致），也接受 500ms、2s 这类写法
+func parseDelay(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return seconds(secs), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay %q (want seconds such as 1.5 or a duration such as 500ms)", s)
+	}
+	return d, nil
+}
+
+// formatRetry 一行描述重试配置，按类别的策略按类别名排序列在后面
+func formatRetry(rc *retry.Config) string {
+	if rc == nil || !rc.Enabled {
+		return "disabled"
+	}
+	s := fmt.Sprintf("max %d retries, initial delay %s, max delay %s", rc.MaxRetries, rc.InitialDelay, rc.MaxDelay)
+	if rc.HasPolicies() {
+		classes := make([]string, 0, len(rc.Policies))
+		for class := range rc.Policies {
+			classes = append(classes, string(class))
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			p := rc.Policies[retry.Class(class)]
+			s += fmt.Sprintf("; %s: max %d retries, initial delay %s, max delay %s", class, p.MaxRetries, p.InitialDelay, p.MaxDelay)
+		}
+	}
+	return s
+}
+
+// setRetry 处理 /retry [<class>] [<max> <initial> <max-delay>]：无参数时显示当前设置；
+// 否则校验后直接修改客户端持有的重试配置，之后的 LLM 请求立即按新设置重试。
+// 给出 class（network / server / rate_limit）时只修改该类别的策略，不带 class 时只修改默认策略
+func setRetry(client *llm.Client, args []string) {
+	rc := client.RetryConfig()
+	if len(args) == 0 {
+		fmt.Printf("\n%sLLM retry: %s%s\n\n", colors.BRIGHT_CYAN, formatRetry(rc), colors.RESET)
+		return
+	}
+	var class retry.Class
+	if len(args) == 4 {
+		var ok bool
+		if class, ok = retryClasses[args[0]]; !ok {
+			fmt.Printf("%s❌ Unknown retry class %q (want network, server or rate_limit)%s\n\n", colors.RED, args[0], colors.RESET)
+			return
+		}
+		args = args[1:]
+	}
+	if len(args) != 3 || rc == nil {
+		fmt.Printf("%s❌ Usage: /retry [network|server|rate_limit] <max> <initial> <max-delay> (delays in seconds or as 500ms, 2s)%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+
+	maxRetries, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("%s❌ Invalid max retries %q: must be a whole number%s\n\n", colors.RED, args[0], colors.RESET)
+		return
+	}
+	initial, err := parseDelay(args[1])
+	if err == nil {
+		var maxDelay time.Duration
+		if maxDelay, err = parseDelay(args[2]); err == nil {
+			if class == "" {
+				err = rc.SetLimits(maxRetries, initial, maxDelay)
+			} else {
+				if rc.Classifier == nil {
+					rc.Classifier = llm.ClassifyError
+				}
+				err = rc.SetClassLimits(class, maxRetries, initial, maxDelay)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ LLM retry: %s%s\n\n", colors.GREEN, formatRetry(rc), colors.RESET)
+}
+
+// formatReasoning 描述推理强度，空字符串表示不发送
+func formatReasoning(level string) string {
+	if level == "" {
+		return "off (model default)"
+	}
+	return level
+}
+
+// setThinking 处理 /think [on|off|<level>]：无参数时显示当前推理强度；
+// 修改作用于之后的每次请求。当前模型不在已知支持列表中时给出提示，服务端拒绝时请求会报出明确的错误
+func setThinking(client *llm.Client, args []string) {
+	if len(args) == 0 {
+		fmt.Printf("\n%sReasoning effort: %s%s\n\n", colors.BRIGHT_CYAN, formatReasoning(client.ReasoningEffort()), colors.RESET)
+		return
+	}
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /think on|off|%s%s\n\n", colors.RED, strings.Join(llm.ReasoningLevels, "|"), colors.RESET)
+		return
+	}
+	if err := client.SetReasoningEffort(args[0]); err != nil {
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	level := client.ReasoningEffort()
+	if level == "" {
+		fmt.Printf("%s✅ Reasoning effort off: reasoning_effort is no longer sent%s\n\n", colors.GREEN, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Reasoning effort set to %s%s\n", colors.GREEN, level, colors.RESET)
+	// Anthropic 把推理强度映射为 extended thinking 的 budget_tokens，SupportsReasoningEffort 只描述 OpenAI 的 reasoning_effort
+	if client.ProviderName() == llm.ProviderOpenAI && !llm.SupportsReasoningEffort(client.Model()) {
+		fmt.Printf("%s⚠️  %s is not known to support reasoning effort; the provider may reject the request (then use /think off)%s\n",
+			colors.BRIGHT_YELLOW, client.Model(), colors.RESET)
+	}
+	fmt.Println()
+}
+
+// showChoices 处理 /choices：显示最近一次响应中未被采用的候选回复
+func showChoices(ag *agent.Agent) {
+	alternatives := ag.Alternatives()
+	if len(alternatives) == 0 {
+		fmt.Printf("\n%sNo alternative replies (set llm.choices > 1 to request several per call)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	for _, c := range alternatives {
+		fmt.Printf("\n%s── Choice %d (%s) ──%s\n", colors.BRIGHT_CYAN, c.Index+1, c.FinishReason, colors.RESET)
+		if c.Content != "" {
+			fmt.Println(c.Content)
+		}
+		for _, tc := range c.ToolCalls {
+			fmt.Printf("%s🔧 would call %s%s\n", colors.DIM, tc.Function.Name, colors.RESET)
+		}
+	}
+	fmt.Println()
+}
+
+// printConfig 处理 /config：显示当前生效的设置，其中模型、token limit 与重试配置反映会话内的修改
+func printConfig(cfg *config.Config, client *llm.Client, ag *agent.Agent, workspace string, overlay *tools.Overlay) {
+	row := func(name, value string) {
+		fmt.Printf("  %-13s %s\n", name+":", value)
+	}
+	fmt.Printf("\n%sCurrent settings:%s\n", colors.BRIGHT_CYAN, colors.RESET)
+	row("Provider", client.ProviderName())
+	row("Model", client.Model())
+	row("API base", cfg.LLM.APIBase)
+	row("Workspace", workspace)
+	row("Token limit", strconv.Itoa(ag.TokenLimit()))
+	row("Max steps", strconv.Itoa(cfg.Agent.MaxSteps))
+	row("LLM retry", formatRetry(client.RetryConfig()))
+	row("Reasoning", formatReasoning(client.ReasoningEffort()))
+	if overlay != nil {
+		row("Overlay", overlay.Dir())
+	}
+	fmt.Println()
+}
+
+// confirm 显示问题并读取 y/N 回答，默认否
+func confirm(question string) bool {
+	fmt.Printf("%s›%s %s [y/N]: ", colors.BRIGHT_GREEN, colors.RESET, question)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// confirmOverBudget 花费达到硬上限时询问用户是否继续
+func confirmOverBudget(spent, limit float64) bool {
+	return confirm("Continue and allow further spending?")
+}
+
+// largeOutputDecider 前台命令输出过大时的处理方式：ask 为 true 时询问用户，直接回车或不询问时使用 fallback
+func largeOutputDecider(ask bool, fallback agent.LargeOutputAction) agent.LargeOutputFunc {
+	return func(command string, chars, lines int) agent.LargeOutputAction {
+		if !ask {
+			return fallback
+		}
+		fmt.Printf("\n%s⚠️  `%s` produced %d characters (%d lines).%s\n",
+			colors.BRIGHT_YELLOW, tw.TruncateWithEllipsis(command, 60, "..."), chars, lines, colors.RESET)
+		for {
+			fmt.Printf("%s›%s Send it to the model? [f]eed / [s]ummarize / [t]runcate (default: %s): ",
+				colors.BRIGHT_GREEN, colors.RESET, fallback)
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			answer := strings.ToLower(strings.TrimSpace(line))
+			switch {
+			case answer == "" || err != nil:
+				return fallback
+			case strings.HasPrefix("feed", answer):
+				return agent.LargeOutputFeed
+			case strings.HasPrefix("summarize", answer):
+				return agent.LargeOutputSummarize
+			case strings.HasPrefix("truncate", answer):
+				return agent.LargeOutputTruncate
+			}
+		}
+	}
+}
+
+// runPlanned 处理 /plan <task>（以及开启 agent.plan_mode 时的普通任务）：
+// 先只读分析并给出计划，用户批准后再开放全部工具执行
+func runPlanned(ctx context.Context, ag *agent.Agent, task string) {
+	ag.AddUserMessage(task)
+
+	fmt.Printf("%s📋 Planning phase: read-only tools only%s\n", colors.BRIGHT_CYAN, colors.RESET)
+	plan, err := ag.RunPlan(ctx)
+	if err != nil {
+		fmt.Printf("\n%s❌ Planning failed: %v%s\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if ag.LastOutcome() == agent.OutcomeNeedsInput {
+		printAwaitingAnswer(plan)
+		return
+	}
+	if strings.TrimSpace(plan) == "" {
+		fmt.Printf("\n%s⚠️  The model returned an empty plan%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+		ag.DiscardPlan()
+		return
+	}
+
+	fmt.Println()
+	if !confirm("Approve this plan and execute it?") {
+		ag.DiscardPlan()
+		fmt.Printf("%sPlan discarded; nothing was changed%s\n", colors.DIM, colors.RESET)
+		return
+	}
+
+	fmt.Printf("\n%s🚀 Executing approved plan%s\n", colors.BRIGHT_CYAN, colors.RESET)
+	out, err := ag.ExecutePlan(ctx)
+	if err != nil {
+		fmt.Printf("\n%s❌ Error: %v%s\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if ag.LastOutcome() == agent.OutcomeNeedsInput {
+		printAwaitingAnswer(out)
+	}
+}
+
+// showDryPlan 处理 /dryplan <task>：只让模型描述思路（tool_choice=none），不执行工具、不写入历史
+func showDryPlan(ctx context.Context, ag *agent.Agent, task string) {
+	fmt.Printf("%s📝 Dry plan: no tools will run and the session history is unchanged%s\n", colors.BRIGHT_CYAN, colors.RESET)
+	plan, err := ag.DryPlan(ctx, task)
+	if err != nil {
+		fmt.Printf("\n%s❌ Dry plan failed: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("\n%s🤖 Proposed approach:%s\n%s\n\n", colors.BOLD+colors.BRIGHT_BLUE, colors.RESET, plan)
+}
+
+// compareModels 处理 /compare <model>：用临时客户端让另一个模型回答最近一条用户消息，
+// 与当前会话的回复并排显示；不修改会话，也不切换当前模型
+func compareModels(ctx context.Context, ag *agent.Agent, currentModel string, newClient func(model string) *llm.Client, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("%s❌ Usage: /compare <model>%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+	model := args[0]
+	fmt.Printf("%s🔀 Asking %s the last request again (no tools run, session unchanged)...%s\n", colors.BRIGHT_CYAN, model, colors.RESET)
+	cmp, err := ag.Compare(ctx, newClient(model))
+	if err != nil {
+		fmt.Printf("\n%s❌ Compare with %s failed: %v%s\n\n", colors.RED, model, err, colors.RESET)
+		return
+	}
+	current := cmp.Current
+	if current == "" {
+		current = "(no reply yet)"
+	}
+	fmt.Println()
+	printSideBySide(currentModel, current, model, cmp.Alternate)
+	fmt.Println()
+}
+
+// printSideBySide 按终端宽度分两栏显示两段文本
+func printSideBySide(leftTitle, left, rightTitle, right string) {
+	width := 100
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		width = w
+	}
+	col := max((width-3)/2, 20)
+
+	l, r := wrapText(left, col), wrapText(right, col)
+	fmt.Printf("%s%s │ %s%s\n", colors.BOLD+colors.BRIGHT_BLUE,
+		tw.PadToWidth(tw.TruncateWithEllipsis(leftTitle, col), col, "left", ' '),
+		tw.TruncateWithEllipsis(rightTitle, col), colors.RESET)
+	fmt.Printf("%s%s┼%s%s\n", colors.DIM, strings.Repeat("─", col+1), strings.Repeat("─", col+1), colors.RESET)
+	for i := range max(len(l), len(r)) {
+		var a, b string
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(r) {
+			b = r[i]
+		}
+		fmt.Printf("%s %s│%s %s\n", tw.PadToWidth(a, col, "left", ' '), colors.DIM, colors.RESET, b)
+	}
+}
+
+// wrapText 按显示宽度把文本折成不超过 width 的行（保留原有换行，长行硬折）
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		var cur strings.Builder
+		w := 0
+		for _, r := range line {
+			rw := tw.CalculateDisplayWidth(string(r))
+			if w+rw > width {
+				lines = append(lines, cur.String())
+				cur.Reset()
+				w = 0
+			}
+			cur.WriteRune(r)
+			w += rw
+		}
+		lines = append(lines, cur.String())
+	}
+	return lines
+}
+
+// applyOverlay 处理 /apply：把 overlay 中的修改写回 workspace
+func applyOverlay(overlay *tools.Overlay) {
+	if overlay == nil {
+		fmt.Printf("%sOverlay mode is off (enable tools.overlay or start with --overlay)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	applied, err := overlay.Apply()
+	for _, rel := range applied {
+		fmt.Printf("  %s✓%s %s\n", colors.GREEN, colors.RESET, rel)
+	}
+	if err != nil {
+		fmt.Printf("%s❌ Apply failed: %v (unapplied changes stay in the overlay)%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if len(applied) == 0 {
+		fmt.Printf("%sNo pending overlay changes%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Applied %d file(s) to the workspace%s\n\n", colors.GREEN, len(applied), colors.RESET)
+}
+
+// discardOverlay 处理 /discard：丢弃 overlay 中的修改
+func discardOverlay(overlay *tools.Overlay) {
+	if overlay == nil {
+		fmt.Printf("%sOverlay mode is off (enable tools.overlay or start with --overlay)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	discarded, err := overlay.Discard()
+	if err != nil {
+		fmt.Printf("%s❌ Discard failed: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if len(discarded) == 0 {
+		fmt.Printf("%sNo pending overlay changes%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Discarded changes to %d file(s): %s%s\n\n",
+		colors.GREEN, len(discarded), strings.Join(discarded, ", "), colors.RESET)
+}
+
+// rollbackSession 处理 /rollback：把本会话中文件工具修改过的文件恢复为修改前的内容，删除新建的文件
+func rollbackSession(backup *tools.SessionBackup) {
+	if backup == nil {
+		fmt.Printf("%sSession backups are off (enable tools.backup.enabled; not used in overlay mode)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	report, err := backup.Rollback()
+	for _, rel := range report.Restored {
+		fmt.Printf("  %s↺%s %s\n", colors.GREEN, colors.RESET, rel)
+	}
+	for _, rel := range report.Removed {
+		fmt.Printf("  %s✗%s %s %s(created this session)%s\n", colors.GREEN, colors.RESET, rel, colors.DIM, colors.RESET)
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Printf("%s⚠️  Not restored (no backup was taken, e.g. tools.backup.max_bytes was reached): %s%s\n",
+			colors.BRIGHT_YELLOW, strings.Join(report.Skipped, ", "), colors.RESET)
+	}
+	if err != nil {
+		fmt.Printf("%s❌ Rollback failed: %v (run /rollback again to retry the remaining files)%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if len(report.Restored)+len(report.Removed) == 0 {
+		fmt.Printf("%sNo file changes to roll back%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Rolled back %d file(s): %d restored, %d removed%s\n\n",
+		colors.GREEN, len(report.Restored)+len(report.Removed), len(report.Restored), len(report.Removed), colors.RESET)
+}
+
+// saveSession 处理 /save [-f] <name>：把完整的消息历史、工作空间与当前模型保存到 ~/.gopilot/sessions/<name>.json。
+// 覆盖其他工作空间的同名会话或使用自动保存的名称前先确认，-f 跳过确认
+func saveSession(store *session.Store, ag *agent.Agent, client *llm.Client, workspace string, args []string) {
+	if store == nil {
+		fmt.Printf("%sSession store is unavailable (no home directory)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	force := len(args) > 0 && (args[0] == "-f" || args[0] == "--force")
+	if force {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		fmt.Printf("%s❌ Usage: /save [-f] <name>%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+	if conflict := store.OverwriteConflict(args[0], workspace); conflict != "" && !force {
+		fmt.Printf("%s⚠️  %s%s\n", colors.BRIGHT_YELLOW, conflict, colors.RESET)
+		if !confirm(fmt.Sprintf("Save as %q anyway?", args[0])) {
+			fmt.Printf("%sNot saved; choose another name or use /save -f %s%s\n\n", colors.DIM, args[0], colors.RESET)
+			return
+		}
+	}
+	path, err := store.Save(snapshotSession(args[0], ag, client, workspace))
+	if err != nil {
+		fmt.Printf("%s❌ Save failed: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("%s✅ Saved session %q (%d messages) to %s%s\n\n",
+		colors.GREEN, args[0], len(ag.History()), path, colors.RESET)
+}
+
+// loadSession 处理 /load [name]：无参数时列出保存的会话；否则用保存的历史替换当前历史并切换到保存时的模型。
+// 工具绑定在启动时的工作空间上，保存于其他工作空间的会话需要以 --workspace 重新启动后再加载
+func loadSession(store *session.Store, ag *agent.Agent, client *llm.Client, workspace string, args []string) {
+	if store == nil {
+		fmt.Printf("%sSession store is unavailable (no home directory)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	if len(args) == 0 {
+		listSessions(store)
+		return
+	}
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /load <name>%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+	sess, err := store.Load(args[0])
+	if err != nil {
+		fmt.Printf("%s❌ Load failed: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if sess.Workspace != "" && wspath.NormalizeWorkspace(sess.Workspace) != wspath.NormalizeWorkspace(workspace) {
+		fmt.Printf("%s❌ Session %q was saved in %s, but the current workspace is %s.%s\n",
+			colors.RED, sess.Name, sess.Workspace, workspace, colors.RESET)
+		fmt.Printf("%s   Restart with --workspace %s and run /load %s again.%s\n\n",
+			colors.DIM, sess.Workspace, sess.Name, colors.RESET)
+		return
+	}
+
+	restoreSession(sess, ag, client)
+}
+
+// snapshotSession 当前会话的历史、模型与后台进程
+func snapshotSession(name string, ag *agent.Agent, client *llm.Client, workspace string) *session.Session {
+	return &session.Session{
+		Name:      name,
+		Workspace: workspace,
+		Model:     client.Model(),
+		Messages:  ag.History(),
+		Shells:    tools.SnapshotBackgroundShells(),
+	}
+}
+
+// restoreSession 用保存的历史替换当前历史，重新登记其后台进程，并切换到保存时的模型
+func restoreSession(sess *session.Session, ag *agent.Agent, client *llm.Client) {
+	ag.LoadHistory(sess.Messages)
+	fmt.Printf("%s✅ Loaded session %q (%d messages, saved %s)%s\n",
+		colors.GREEN, sess.Name, len(ag.History()), sess.SavedAt.Local().Format("2006-01-02 15:04"), colors.RESET)
+	if n := tools.RestoreBackgroundShells(sess.Shells); n > 0 {
+		fmt.Printf("%s   Restored %d background shell(s); processes still running at save time were stopped and are marked terminated%s\n",
+			colors.DIM, n, colors.RESET)
+	}
+	if sess.Model != "" && sess.Model != client.Model() {
+		switchModel(client, ag, []string{sess.Model})
+		return
+	}
+	fmt.Println()
+}
+
+// resumeSession 处理 --resume 与 /resume：恢复当前工作空间中最近保存的会话（包括自动保存的）
+func resumeSession(store *session.Store, ag *agent.Agent, client *llm.Client, workspace string) {
+	if store == nil {
+		fmt.Printf("%sSession store is unavailable (no home directory)%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	sess, err := store.Latest(workspace)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("%sNo saved session for this workspace yet, starting a new one%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	if err != nil {
+		fmt.Printf("%s❌ Resume failed: %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	restoreSession(sess, ag, client)
+}
+
+// autosaveSession 把会话保存为工作空间的 autosave 会话（agent.autosave_session）；
+// 只有系统提示时不保存，避免打开后直接退出覆盖上一次的会话
+func autosaveSession(store *session.Store, ag *agent.Agent, client *llm.Client, workspace string) {
+	if store == nil || len(ag.History()) <= 1 {
+		return
+	}
+	if _, err := store.Save(snapshotSession(session.AutosaveName(workspace), ag, client, workspace)); err != nil {
+		fmt.Printf("%s⚠️  Autosave failed: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	}
+}
+
+// listSessions 列出保存的会话，最近保存的在前
+func listSessions(store *session.Store) {
+	infos, err := store.List()
+	if err != nil {
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	if len(infos) == 0 {
+		fmt.Printf("%sNo saved sessions in %s (use /save <name>)%s\n\n", colors.DIM, store.Dir(), colors.RESET)
+		return
+	}
+	fmt.Printf("\n%sSaved sessions (%s):%s\n", colors.BRIGHT_CYAN, store.Dir(), colors.RESET)
+	for _, info := range infos {
+		fmt.Printf("  %s%-20s%s %s  %3d messages  %s  %s%s%s\n",
+			colors.BRIGHT_GREEN, info.Name, colors.RESET,
+			info.SavedAt.Local().Format("2006-01-02 15:04"), info.Messages, info.Model,
+			colors.DIM, info.Workspace, colors.RESET)
+	}
+	fmt.Printf("%sLoad one with /load <name>%s\n\n", colors.DIM, colors.RESET)
+}
+
+// printAwaitingAnswer 模型通过 ask_user 提问时，提示用户下一条输入即为回答
+func printAwaitingAnswer(question string) {
+	fmt.Printf("\n%s❓ The agent needs your input:%s\n%s\n", colors.BRIGHT_YELLOW, colors.RESET, question)
+	fmt.Printf("%sType your answer at the prompt to continue.%s\n", colors.DIM, colors.RESET)
+}
+
+// showLog 处理 /log [n]：显示当前日志文件路径，带 n 时再输出最后 n 行
+func showLog(ag *agent.Agent, args []string) {
+	if len(args) > 1 {
+		fmt.Printf("%s❌ Usage: /log [n]%s\n\n", colors.RED, colors.RESET)
+		return
+	}
+	n := 0
+	if len(args) == 1 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			fmt.Printf("%s❌ Invalid line count %q: must be a positive integer%s\n\n", colors.RED, args[0], colors.RESET)
+			return
+		}
+		n = v
+	}
+
+	path := ag.LogFilePath()
+	if path == "" {
+		fmt.Printf("\n%sNo log file yet: logging starts with the first task of this session%s\n\n", colors.DIM, colors.RESET)
+		return
+	}
+	fmt.Printf("\n%sLog file:%s %s\n", colors.BRIGHT_CYAN, colors.RESET, path)
+	if n == 0 {
+		fmt.Println()
+		return
+	}
+
+	lines, err := ag.TailLog(n)
+	if err != nil {
+		if errors.Is(err, logger.ErrNoLogFile) {
+			fmt.Printf("%sLog file is no longer available%s\n\n", colors.DIM, colors.RESET)
+			return
+		}
+		fmt.Printf("%s❌ %v%s\n\n", colors.RED, err, colors.RESET)
+		return
+	}
+	fmt.Printf("%s── last %d line(s) ──%s\n", colors.DIM, len(lines), colors.RESET)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Println()
+}
+
+// setInstruction 处理 /instruct：无参数时显示待发送的临时指令，
+// "clear" 取消，其余文本作为只作用于下一次请求的指令
+func setInstruction(ag *agent.Agent, text string) {
+	switch text {
+	case "":
+		if pending := ag.PendingInstruction(); pending != "" {
+			fmt.Printf("\n%sPending instruction for the next request:%s %s\n\n", colors.BRIGHT_CYAN, colors.RESET, pending)
+		} else {
+			fmt.Printf("\n%sNo pending instruction. Usage: /instruct <text> (applies to the next request only)%s\n\n", colors.DIM, colors.RESET)
+		}
+	case "clear":
+		ag.SetInstruction("")
+		fmt.Printf("%s✅ Pending instruction cleared%s\n\n", colors.GREEN, colors.RESET)
+	default:
+		ag.SetInstruction(text)
+		fmt.Printf("%s✅ Instruction will be sent with the next request only%s\n\n", colors.GREEN, colors.RESET)
+	}
+}
+
+// openTailLog 打开 --tail-log 的输出目标："-" 表示 stderr，其余按文件追加写入
+// （可以是另一个终端，如 /dev/pts/3）
+func openTailLog(dest string) (io.Writer, error) {
+	if dest == "-" {
+		return os.Stderr, nil
+	}
+	return os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+//
+// 空闲超时：一段时间没有任何输入时自动退出会话
+//
+
+// idleWatcher 空闲计时器：Touch 重新计时，Pause / Resume 用于任务执行期间暂停计时。
+// timeout <= 0 时返回 nil，所有方法对 nil 安全。
+type idleWatcher struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	d      time.Duration
+	paused bool
+}
+
+// newIdleWatcher 创建并启动计时器，超时后在独立 goroutine 中调用 onIdle
+func newIdleWatcher(d time.Duration, onIdle func()) *idleWatcher {
+	if d <= 0 {
+		return nil
+	}
+	w := &idleWatcher{d: d}
+	w.timer = time.AfterFunc(d, onIdle)
+	return w
+}
+
+// Touch 有输入活动：重新开始计时
+func (w *idleWatcher) Touch() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.paused {
+		w.timer.Reset(w.d)
+	}
+}
+
+// Pause 任务执行中不算空闲
+func (w *idleWatcher) Pause() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = true
+	w.timer.Stop()
+}
+
+// Resume 任务结束，重新开始计时
+func (w *idleWatcher) Resume() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paused = false
+	w.timer.Reset(w.d)
+}
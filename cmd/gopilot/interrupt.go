@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/colors"
+)
+
+//
+// 任务运行期间的 Ctrl-C：先中断正在执行的工具（如卡住的前台命令），模型收到中断结果后继续；
+// 没有工具在执行、或工具已被中断过时（如等待模型响应、工具不响应取消）取消整个任务，会话本身不退出。
+// 任务取消后仍未结束时再按 Ctrl-C 恢复默认的信号处理，之后的 Ctrl-C 直接结束进程
+//
+
+// interruptible 返回任务使用的 context；stop 在任务结束后调用，恢复默认的信号处理
+func interruptible(ag *agent.Agent) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	done := make(chan struct{})
+
+	go func() {
+		cancelled := false
+		for {
+			select {
+			case <-sig:
+				if cancelled {
+					signal.Stop(sig)
+					fmt.Printf("\n%s⏹  Still stopping; press Ctrl-C again to quit gopilot%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+					return
+				}
+				if name, ok := ag.InterruptTool(); ok {
+					fmt.Printf("\n%s⏹  Interrupted %s; the model continues with the next step (press Ctrl-C again to cancel the task)%s\n",
+						colors.BRIGHT_YELLOW, name, colors.RESET)
+					continue
+				}
+				fmt.Printf("\n%s⏹  Cancelling the task...%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+				cancel()
+				cancelled = true
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sig)
+		close(done)
+		cancel()
+	}
+}
@@ -3,75 +3,106 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	prompt "github.com/c-bata/go-prompt"
+	"golang.org/x/term"
 
 	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/agent/cost"
 	"gopilot-cli/internal/config"
 	"gopilot-cli/internal/llm"
-	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/logger"
+	"gopilot-cli/internal/session"
 	"gopilot-cli/internal/tools"
+	wspath "gopilot-cli/internal/utils/path"
 	tw "gopilot-cli/internal/utils/terminal"
 )
 
-//
-// ANSI Colors（和之前版本保持一致）
-//
-
-const (
-	ColorReset  = "\033[0m"
-	ColorBold   = "\033[1m"
-	ColorDim    = "\033[2m"
-	ColorRed    = "\033[31m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
-	ColorCyan   = "\033[36m"
-
-	ColorBrightBlack   = "\033[90m"
-	ColorBrightRed     = "\033[91m"
-	ColorBrightGreen   = "\033[92m"
-	ColorBrightYellow  = "\033[93m"
-	ColorBrightBlue    = "\033[94m"
-	ColorBrightMagenta = "\033[95m"
-	ColorBrightCyan    = "\033[96m"
-	ColorBrightWhite   = "\033[97m"
-)
-
 //
 // CLI 参数解析
 //
 
 type CLIArgs struct {
-	Workspace string
+	Workspace  string
+	ConfigPath string
+	APIKey     string
+	APIBase    string
+	Model      string
+	NoSetup    bool
+	TailLog    string
+	// DebugPayload 覆盖配置 llm.debug_payload（stderr / log）
+	DebugPayload string
+	// BashDryRun 开启 tools.bash.dry_run
+	BashDryRun bool
+	// Overlay 开启 tools.overlay
+	Overlay bool
+	// Quiet 开启 ui.quiet
+	Quiet bool
+	// StrictConfig 配置文件中有未知的键时拒绝启动，而不只是警告
+	StrictConfig bool
+	// ReadOnly 整个会话只提供只读工具
+	ReadOnly bool
+	// Resume 启动时恢复当前工作空间中最近保存的会话
+	Resume bool
+	// Prompt 非交互运行：执行该任务后退出（"-" 表示从 stdin 读取）
+	Prompt string
 }
 
 func parseArgs() *CLIArgs {
-	var workspace string
-
-	flag.StringVar(&workspace, "workspace", "", "Workspace directory (default: current directory)")
-	flag.StringVar(&workspace, "w", workspace, "Workspace directory (shorthand)")
+	args := &CLIArgs{}
+
+	flag.StringVar(&args.Workspace, "workspace", "", "Workspace directory (default: current directory)")
+	flag.StringVar(&args.Workspace, "w", "", "Workspace directory (shorthand)")
+	flag.StringVar(&args.ConfigPath, "config", "", "Config file path (default: configs/config.yaml, then ~/.gopilot/config.yaml)")
+	flag.StringVar(&args.APIKey, "api-key", "", "API key (overrides config)")
+	flag.StringVar(&args.APIBase, "api-base", "", "API base URL (overrides config)")
+	flag.StringVar(&args.Model, "model", "", "Model name (overrides config)")
+	flag.BoolVar(&args.NoSetup, "no-setup", false, "Skip the first-run setup wizard when no config is found")
+	flag.StringVar(&args.TailLog, "tail-log", "", "Also stream log entries to this file or terminal as they are written (- for stderr)")
+	flag.StringVar(&args.DebugPayload, "debug-payload", "", "Dump each raw request body (API key redacted) to stderr or log")
+	flag.BoolVar(&args.BashDryRun, "bash-dry-run", false, "Echo bash commands instead of running them")
+	flag.BoolVar(&args.Overlay, "overlay", false, "Write file changes to a shadow copy; review with /apply or /discard")
+	flag.BoolVar(&args.Quiet, "quiet", false, "Print only final answers and errors (no banners, step boxes or tool calls)")
+	flag.BoolVar(&args.Quiet, "q", false, "Quiet mode (shorthand)")
+	flag.BoolVar(&args.StrictConfig, "strict-config", false, "Fail on unknown keys in the config file instead of warning")
+	flag.StringVar(&args.Prompt, "prompt", "", "Run this task non-interactively and exit with a status code (- reads it from stdin)")
+	flag.StringVar(&args.Prompt, "p", "", "Non-interactive task (shorthand)")
+	flag.BoolVar(&args.ReadOnly, "read-only", false, "Offer only read-only tools (no file changes, commands or memory writes)")
+	flag.BoolVar(&args.Resume, "resume", false, "Restore the most recent session saved in this workspace (history and background shells)")
 
 	flag.Parse()
 
-	return &CLIArgs{
-		Workspace: workspace,
-	}
+	return args
 }
 
 //
 // Banner & 帮助 & Session Info & Stats
 //
 
+// quiet 安静模式（--quiet / ui.quiet）：启动信息、加载提示、分隔线等装饰性输出全部省略，
+// 只保留最终回答、错误与警告
+var quiet bool
+
+// chromef 打印装饰性输出；安静模式下不输出
+func chromef(format string, args ...any) {
+	if !quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
 func printBanner() {
 	const boxWidth = 58
-	text := fmt.Sprintf("%s🤖 Gopilot - Multi-turn Interactive Session%s", ColorBold, ColorReset)
+	text := fmt.Sprintf("%s🤖 Gopilot - Multi-turn Interactive Session%s", colors.BOLD, colors.RESET)
 	width := tw.CalculateDisplayWidth(text)
 
 	totalPadding := boxWidth - width
@@ -82,44 +113,97 @@ func printBanner() {
 	right := totalPadding - left
 
 	fmt.Println()
-	fmt.Printf("%s%s╔%s╗%s\n", ColorBold, ColorBrightCyan, strings.Repeat("═", boxWidth), ColorReset)
+	fmt.Printf("%s%s╔%s╗%s\n", colors.BOLD, colors.BRIGHT_CYAN, strings.Repeat("═", boxWidth), colors.RESET)
 	fmt.Printf("%s%s║%s%s%s%s║%s\n",
-		ColorBold, ColorBrightCyan,
+		colors.BOLD, colors.BRIGHT_CYAN,
 		strings.Repeat(" ", left),
 		text,
 		strings.Repeat(" ", right),
-		ColorBrightCyan,
-		ColorReset,
+		colors.BRIGHT_CYAN,
+		colors.RESET,
 	)
-	fmt.Printf("%s%s╚%s╝%s\n", ColorBold, ColorBrightCyan, strings.Repeat("═", boxWidth), ColorReset)
+	fmt.Printf("%s%s╚%s╝%s\n", colors.BOLD, colors.BRIGHT_CYAN, strings.Repeat("═", boxWidth), colors.RESET)
 	fmt.Println()
 }
 
+// toolProgressPrinter 把工具执行期间的进度消息逐行打印到 w（暗色、带竖线前缀，与最终结果区分）；
+// 多个工具并发报告时按行串行化
+func toolProgressPrinter(w io.Writer) func(tool, message string) {
+	var mu sync.Mutex
+	return func(tool, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s  │ %s%s\n", colors.DIM, message, colors.RESET)
+	}
+}
+
 func printHelp() {
 	fmt.Printf(`
 %s%sAvailable Commands:%s
   %s/help%s      - Show this help message
   %s/clear%s     - Clear session history (keep system prompt)
+  %s/compact%s   - Drop assistant replies and tool output, keep your messages
   %s/history%s   - Show current session message count
   %s/stats%s     - Show session statistics
+  %s/tree%s      - Show workspace directory tree
+  %s/model%s     - Show or switch model (/model <name>, adjusts token limit)
+  %s/token-limit%s - Show or set summarization token limit (/token-limit <n>)
+  %s/cost%s      - Show estimated session cost and budget
+  %s/log%s       - Show current log file path (/log <n> tails the last n lines)
+  %s/logs%s      - List recent run logs (/logs <n>)
+  %s/plan%s      - Plan a task read-only first, execute after approval (/plan <task>)
+  %s/dryplan%s   - Describe the approach to a task without running tools or changing history (/dryplan <task>)
+  %s/compare%s   - Rerun the last request on another model and show both replies side by side (/compare <model>)
+  %s/apply%s     - Overlay mode: copy pending file changes into the workspace
+  %s/discard%s   - Overlay mode: throw pending file changes away
+  %s/rollback%s  - Restore all files changed by file tools this session (tools.backup)
+  %s/save%s      - Save the conversation, workspace and model (/save <name>; -f overwrites another workspace's session)
+  %s/load%s      - Restore a saved conversation of this workspace (/load <name>; no name lists saved sessions)
+  %s/resume%s    - Restore the most recent session saved in this workspace (including the autosave)
+  %s/instruct%s  - One-shot instruction for the next request only (/instruct <text>, /instruct clear)
+  %s/retry%s     - Show or change LLM retry settings (/retry [class] <max> <initial> <max-delay>)
+  %s/config%s    - Show current settings
+  %s/think%s     - Show or change reasoning effort (/think on|off|minimal|low|medium|high)
+  %s/choices%s   - Show the alternative replies of the last response (llm.choices > 1)
   %s/exit%s      - Exit program (also: exit, quit, q)
 
 %s%sNotes (Go version):%s
   - 直接输入任务回车即可
   - 使用 Tab 可以补全 /help /exit 等命令
 `,
-		ColorBold, ColorBrightYellow, ColorReset,
-		ColorBrightGreen, ColorReset,
-		ColorBrightGreen, ColorReset,
-		ColorBrightGreen, ColorReset,
-		ColorBrightGreen, ColorReset,
-		ColorBrightGreen, ColorReset,
-
-		ColorBold, ColorBrightYellow, ColorReset,
+		colors.BOLD, colors.BRIGHT_YELLOW, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+		colors.BRIGHT_GREEN, colors.RESET,
+
+		colors.BOLD, colors.BRIGHT_YELLOW, colors.RESET,
 	)
 }
 
-func printSessionInfo(ag *agent.Agent, workspaceDir string, model string, toolCount int) {
+func printSessionInfo(ag *agent.Agent, workspaceDir string, client *llm.Client, toolCount int) {
 	const boxWidth = 58
 
 	printInfoLine := func(text string) {
@@ -129,15 +213,15 @@ func printSessionInfo(ag *agent.Agent, workspaceDir string, model string, toolCo
 			padding = 0
 		}
 		fmt.Printf("%s│%s %s%s%s│%s\n",
-			ColorDim, ColorReset,
+			colors.DIM, colors.RESET,
 			text,
 			strings.Repeat(" ", padding),
-			ColorDim, ColorReset)
+			colors.DIM, colors.RESET)
 	}
 
-	fmt.Printf("%s┌%s┐%s\n", ColorDim, strings.Repeat("─", boxWidth), ColorReset)
+	fmt.Printf("%s┌%s┐%s\n", colors.DIM, strings.Repeat("─", boxWidth), colors.RESET)
 
-	header := fmt.Sprintf("%sSession Info%s", ColorBrightCyan, ColorReset)
+	header := fmt.Sprintf("%sSession Info%s", colors.BRIGHT_CYAN, colors.RESET)
 	headerWidth := tw.CalculateDisplayWidth(header)
 	totalPad := boxWidth - 1 - headerWidth
 	if totalPad < 0 {
@@ -147,24 +231,28 @@ func printSessionInfo(ag *agent.Agent, workspaceDir string, model string, toolCo
 	right := totalPad - left
 
 	fmt.Printf("%s│%s %s%s%s%s│%s\n",
-		ColorDim, ColorReset,
+		colors.DIM, colors.RESET,
 		strings.Repeat(" ", left),
 		header,
 		strings.Repeat(" ", right),
-		ColorDim, ColorReset)
+		colors.DIM, colors.RESET)
 
-	fmt.Printf("%s├%s┤%s\n", ColorDim, strings.Repeat("─", boxWidth), ColorReset)
+	fmt.Printf("%s├%s┤%s\n", colors.DIM, strings.Repeat("─", boxWidth), colors.RESET)
 
 	history := ag.History()
-	printInfoLine(fmt.Sprintf("Model: %s", model))
+	printInfoLine(fmt.Sprintf("Model: %s", client.Model()))
+	if level := client.ReasoningEffort(); level != "" {
+		printInfoLine(fmt.Sprintf("Reasoning: %s", level))
+	}
 	printInfoLine(fmt.Sprintf("Workspace: %s", workspaceDir))
+	printInfoLine(fmt.Sprintf("Token Limit: %d", ag.TokenLimit()))
 	printInfoLine(fmt.Sprintf("Message History: %d messages", len(history)))
 	printInfoLine(fmt.Sprintf("Available Tools: %d tools", toolCount))
 
-	fmt.Printf("%s└%s┘%s\n", ColorDim, strings.Repeat("─", boxWidth), ColorReset)
+	fmt.Printf("%s└%s┘%s\n", colors.DIM, strings.Repeat("─", boxWidth), colors.RESET)
 	fmt.Println()
 	fmt.Printf("%sType %s/help%s for help, %s/exit%s to quit%s\n",
-		ColorDim, ColorBrightGreen, ColorDim, ColorBrightGreen, ColorDim, ColorReset)
+		colors.DIM, colors.BRIGHT_GREEN, colors.DIM, colors.BRIGHT_GREEN, colors.DIM, colors.RESET)
 	fmt.Println()
 }
 
@@ -188,15 +276,16 @@ func printStats(ag *agent.Agent, start time.Time, totalTools int) {
 		}
 	}
 
-	fmt.Printf("\n%s%sSession Statistics:%s\n", ColorBold, ColorBrightCyan, ColorReset)
-	fmt.Printf("%s%s%s\n", ColorDim, strings.Repeat("─", 40), ColorReset)
+	fmt.Printf("\n%s%sSession Statistics:%s\n", colors.BOLD, colors.BRIGHT_CYAN, colors.RESET)
+	fmt.Printf("%s%s%s\n", colors.DIM, strings.Repeat("─", 40), colors.RESET)
 	fmt.Printf("  Session Duration: %02d:%02d:%02d\n", hours, minutes, seconds)
 	fmt.Printf("  Total Messages: %d\n", len(history))
-	fmt.Printf("    - User Messages: %s%d%s\n", ColorBrightGreen, userCount, ColorReset)
-	fmt.Printf("    - Assistant Replies: %s%d%s\n", ColorBrightBlue, assistantCount, ColorReset)
-	fmt.Printf("    - Tool Calls: %s%d%s\n", ColorBrightYellow, toolMsgCount, ColorReset)
+	fmt.Printf("    - User Messages: %s%d%s\n", colors.BRIGHT_GREEN, userCount, colors.RESET)
+	fmt.Printf("    - Assistant Replies: %s%d%s\n", colors.BRIGHT_BLUE, assistantCount, colors.RESET)
+	fmt.Printf("    - Tool Calls: %s%d%s\n", colors.BRIGHT_YELLOW, toolMsgCount, colors.RESET)
 	fmt.Printf("  Available Tools: %d\n", totalTools)
-	fmt.Printf("%s%s%s\n\n", ColorDim, strings.Repeat("─", 40), ColorReset)
+	fmt.Printf("  Estimated Cost: %s\n", formatSpend(ag.CostTracker()))
+	fmt.Printf("%s%s%s\n\n", colors.DIM, strings.Repeat("─", 40), colors.RESET)
 }
 
 //
@@ -214,6 +303,22 @@ func loadSystemPrompt(path string) string {
 	return string(data)
 }
 
+//
+// Project Tree
+//
+
+// buildProjectTree 渲染工作空间目录树，并按配置的 token 上限截断
+func buildProjectTree(workspace string, treeCfg config.ProjectTreeConfig) (string, error) {
+	tree, err := tools.RenderTree(workspace, treeCfg.Depth)
+	if err != nil {
+		return "", err
+	}
+	if treeCfg.MaxTokens > 0 {
+		tree = tools.TruncateTextByTokens(tree, treeCfg.MaxTokens)
+	}
+	return tree, nil
+}
+
 func defaultSystemPrompt() string {
 	return `You are a coding agent running in a CLI environment.
 
@@ -227,84 +332,422 @@ func defaultSystemPrompt() string {
 // runAgent
 //
 
-func runAgent(workspaceDir string) error {
+func runAgent(args *CLIArgs) error {
 	sessionStart := time.Now()
 
-	// 1. 加载配置
-	cfg, err := config.LoadFromFile("configs/config.yaml")
+	// 1. 加载配置（找不到时进入首次运行向导）
+	cfg, err := loadConfig(args)
 	if err != nil {
-		fmt.Printf("%s❌ Failed to load config: %v%s\n", ColorRed, err, ColorReset)
+		fmt.Printf("%s❌ Failed to load config: %v%s\n", colors.RED, err, colors.RESET)
+		return err
+	}
+	theme := cfg.UI.Theme
+	if os.Getenv("NO_COLOR") != "" {
+		theme = "mono"
+	}
+	if err := colors.Apply(theme, cfg.UI.Colors); err != nil {
+		fmt.Printf("%s❌ Invalid config: ui: %v%s\n", colors.RED, err, colors.RESET)
 		return err
 	}
+	quiet = cfg.UI.Quiet || args.Quiet
 
 	// 2. 初始化重试配置 + LLM client
-	rc := &retry.Config{
-		Enabled:         cfg.LLM.Retry.Enabled,
-		MaxRetries:      cfg.LLM.Retry.MaxRetries,
-		InitialDelay:    time.Duration(cfg.LLM.Retry.InitialDelay * float64(time.Second)),
-		MaxDelay:        time.Duration(cfg.LLM.Retry.MaxDelay * float64(time.Second)),
-		ExponentialBase: cfg.LLM.Retry.ExponentialBase,
+	rc, err := buildRetryConfig(cfg.LLM.Retry)
+	if err != nil {
+		fmt.Printf("%s❌ Invalid config: %v%s\n", colors.RED, err, colors.RESET)
+		return err
 	}
 
 	onRetry := func(err error, attempt int) {
 		fmt.Printf("\n%s⚠️  LLM call failed (attempt %d): %s%s\n",
-			ColorBrightYellow, attempt, err.Error(), ColorReset)
-		delay := rc.CalculateDelay(attempt - 1)
+			colors.BRIGHT_YELLOW, attempt, err.Error(), colors.RESET)
+		delay := rc.Delay(err, attempt-1)
 		fmt.Printf("%s   Retrying in %s (attempt %d)...%s\n",
-			ColorDim, delay.String(), attempt+1, ColorReset)
+			colors.DIM, delay.String(), attempt+1, colors.RESET)
 	}
 
+	if !llm.ValidProvider(cfg.LLM.Provider) {
+		fmt.Printf("%s❌ Invalid config: unknown llm.provider %q (want openai or anthropic)%s\n", colors.RED, cfg.LLM.Provider, colors.RESET)
+		return fmt.Errorf("unknown llm provider %q", cfg.LLM.Provider)
+	}
+	keyEnv := "OPENAI_API_KEY"
+	if cfg.LLM.Provider == llm.ProviderAnthropic {
+		keyEnv = "ANTHROPIC_API_KEY"
+	}
 	apiKey := cfg.LLM.APIKey
 	if apiKey == "" {
-		apiKey = os.Getenv("OPENAI_API_KEY")
+		apiKey = os.Getenv(keyEnv)
 	}
 	if apiKey == "" {
-		fmt.Printf("%s❌ No API key provided (config.llm.api_key or OPENAI_API_KEY)%s\n", ColorRed, ColorReset)
+		fmt.Printf("%s❌ No API key provided (config.llm.api_key or %s)%s\n", colors.RED, keyEnv, colors.RESET)
 		return fmt.Errorf("no api key")
 	}
 
-	llmClient := llm.NewClient(
-		apiKey,
-		cfg.LLM.APIBase,
-		cfg.LLM.Model,
+	clientOpts := []llm.ClientOption{
+		llm.WithProvider(cfg.LLM.Provider),
+		llm.WithMaxTokens(cfg.LLM.MaxTokens),
 		llm.WithRetryConfig(rc),
 		llm.WithRetryCallback(onRetry),
-	)
+		llm.WithExtraParams(cfg.LLM.ExtraParams),
+		llm.WithStrictTools(cfg.LLM.StrictTools),
+		llm.WithResendThinking(cfg.LLM.Thinking.Resend),
+	}
+	if cfg.LLM.Seed != nil {
+		clientOpts = append(clientOpts, llm.WithSeed(*cfg.LLM.Seed))
+	}
+	if cfg.LLM.Choices > 1 {
+		clientOpts = append(clientOpts, llm.WithChoices(cfg.LLM.Choices))
+	}
+	if _, err := llm.ParseReasoningLevel(cfg.LLM.ReasoningEffort); err == nil {
+		clientOpts = append(clientOpts, llm.WithReasoningEffort(cfg.LLM.ReasoningEffort))
+	} else {
+		fmt.Printf("%s⚠️  %v, ignoring llm.reasoning_effort%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	}
+	if llm.ValidPromptCaching(cfg.LLM.PromptCaching) {
+		clientOpts = append(clientOpts, llm.WithPromptCaching(cfg.LLM.PromptCaching))
+	} else {
+		fmt.Printf("%s⚠️  Unknown prompt_caching mode %q (want off, auto or anthropic), ignoring%s\n",
+			colors.BRIGHT_YELLOW, cfg.LLM.PromptCaching, colors.RESET)
+	}
+
+	// 调试导出请求体："log" 需要等 Agent 创建出日志文件，先经 payloadLog 间接转发
+	var payloadLog func([]byte)
+	debugPayload := cfg.LLM.DebugPayload
+	if args.DebugPayload != "" {
+		debugPayload = args.DebugPayload
+	}
+	switch strings.ToLower(strings.TrimSpace(debugPayload)) {
+	case "":
+	case "stderr":
+		clientOpts = append(clientOpts, llm.WithPayloadDump(func(p []byte) {
+			fmt.Fprintf(os.Stderr, "\n=== request payload ===\n%s\n", p)
+		}))
+		fmt.Printf("%s⚠️  Debug payload dump enabled (stderr)%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+	case "log":
+		clientOpts = append(clientOpts, llm.WithPayloadDump(func(p []byte) {
+			if payloadLog != nil {
+				payloadLog(p)
+			}
+		}))
+		fmt.Printf("%s⚠️  Debug payload dump enabled (log file)%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+	default:
+		fmt.Printf("%s⚠️  Unknown debug_payload target %q (want stderr or log), ignoring%s\n",
+			colors.BRIGHT_YELLOW, debugPayload, colors.RESET)
+	}
+
+	// newClient 按同一套配置创建客户端；/compare 用它为其他模型创建临时客户端
+	newClient := func(model string) *llm.Client {
+		return llm.NewClient(apiKey, cfg.LLM.APIBase, model, clientOpts...)
+	}
+	llmClient := newClient(cfg.LLM.Model)
 
 	if cfg.LLM.Retry.Enabled {
-		fmt.Printf("%s✅ LLM retry enabled (max %d retries)%s\n",
-			ColorGreen, cfg.LLM.Retry.MaxRetries, ColorReset)
+		chromef("%s✅ LLM retry enabled (max %d retries)%s\n",
+			colors.GREEN, cfg.LLM.Retry.MaxRetries, colors.RESET)
 	}
 
-	// 3. 初始化工具
-	absWs, err := filepath.Abs(workspaceDir)
+	// 3. 确定工作空间（--workspace > agent.workspace_dir > 当前目录）并初始化工具
+	absWs, err := wspath.ResolveWorkspace(args.Workspace, cfg.Agent.WorkspaceDir)
 	if err != nil {
+		fmt.Printf("%s❌ Invalid workspace: %v%s\n", colors.RED, err, colors.RESET)
 		return err
 	}
 	if err := os.MkdirAll(absWs, 0o755); err != nil {
+		fmt.Printf("%s❌ Failed to create workspace dir: %v%s\n", colors.RED, err, colors.RESET)
+		return err
+	}
+	// 只读文件系统（或没有写权限）：以只读模式启动，而不是让之后的每次写入都失败
+	readOnlyWs := false
+	if err := wspath.CheckWritable(absWs); err != nil {
+		readOnlyWs = true
+		fmt.Printf("%s⚠️  Workspace is not writable (%v).%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		fmt.Printf("%s   Starting in read-only mode: %s are disabled. Fix the mount or permissions, or pass --workspace to use another directory.%s\n",
+			colors.BRIGHT_YELLOW, strings.Join(workspaceWriteTools, ", "), colors.RESET)
+	}
+	if args.ReadOnly {
+		fmt.Printf("%s⚠️  Read-only mode: only tools that cannot modify the workspace are offered%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+	}
+
+	stderrPolicy, err := tools.ParseStderrPolicy(cfg.Tools.Bash.StderrIsError)
+	if err != nil {
+		fmt.Printf("%s❌ Invalid config: %v%s\n", colors.RED, err, colors.RESET)
+		return err
+	}
+	bashOpts := []tools.BashOption{
+		tools.WithMaxOutputLines(cfg.Tools.Bash.MaxOutputLines),
+		tools.WithStderrPolicy(stderrPolicy),
+		tools.WithDiagnostics(cfg.Tools.Bash.Diagnostics),
+		tools.WithExecInfo(cfg.Tools.Bash.ExecInfo),
+		tools.WithMaxConcurrent(cfg.Tools.Bash.MaxConcurrent),
+		tools.WithDryRun(cfg.Tools.Bash.DryRun || args.BashDryRun),
+		tools.WithNonInteractive(cfg.Tools.Bash.NonInteractive),
+	}
+	if cfg.Tools.Bash.ConfineToWorkspace {
+		bashOpts = append(bashOpts, tools.WithWorkspaceConfinement(absWs))
+	}
+
+	newlinePolicy, err := tools.ParseNewlinePolicy(cfg.Tools.Write.TrailingNewline)
+	if err != nil {
+		fmt.Printf("%s❌ Invalid config: %v%s\n", colors.RED, err, colors.RESET)
+		return err
+	}
+	encoding, err := tools.ParseEncoding(cfg.Tools.Write.Encoding)
+	if err != nil {
+		fmt.Printf("%s❌ Invalid config: %v%s\n", colors.RED, err, colors.RESET)
 		return err
 	}
 
 	var toolList []tools.Tool
 	toolList = append(toolList,
-		tools.NewBashTool(),
-		tools.NewBashOutputTool(),
-		tools.NewBashKillTool(),
+		tools.NewBashTool(bashOpts...),
+		tools.NewBashOutputTool(bashOpts...),
+		tools.NewBashKillTool(bashOpts...),
+	)
+	chromef("%s✅ Loaded Bash tools%s\n", colors.GREEN, colors.RESET)
+	if cfg.Tools.Bash.DryRun || args.BashDryRun {
+		fmt.Printf("%s⚠️  Bash dry-run: commands are echoed, not executed%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+	}
+
+	var readOpts []tools.ReadOption
+	for _, root := range cfg.Tools.ReadRoots {
+		expanded, err := wspath.Expand(root)
+		if err != nil {
+			fmt.Printf("%s❌ Invalid config: tools.read_roots: %v%s\n", colors.RED, err, colors.RESET)
+			return err
+		}
+		readOpts = append(readOpts, tools.WithExtraReadRoots(expanded))
+	}
+
+	// overlay：文件修改先写入影子目录，用 /apply 写回或 /discard 丢弃
+	var overlay *tools.Overlay
+	writeOpts := []tools.WriteOption{tools.WithNewlinePolicy(newlinePolicy), tools.WithEncoding(encoding)}
+	var editOpts []tools.EditOption
+	var copyOpts []tools.CopyOption
+	var formatOpts []tools.FormatOption
+	fileReadOpts := readOpts
+	if cfg.Tools.Overlay || args.Overlay {
+		overlay, err = tools.NewOverlay(absWs)
+		if err != nil {
+			fmt.Printf("%s❌ %v%s\n", colors.RED, err, colors.RESET)
+			return err
+		}
+		writeOpts = append(writeOpts, tools.WithWriteOverlay(overlay))
+		editOpts = append(editOpts, tools.WithEditOverlay(overlay))
+		copyOpts = append(copyOpts, tools.WithCopyOverlay(overlay))
+		fileReadOpts = append(fileReadOpts, tools.WithReadOverlay(overlay))
+		fmt.Printf("%s⚠️  Overlay mode: file changes go to %s until you /apply them (bash still sees the real workspace)%s\n",
+			colors.BRIGHT_YELLOW, overlay.Dir(), colors.RESET)
+	}
+	// 读取后被外部修改的文件：write_file 不覆盖，edit_file 提示重新读取
+	if cfg.Tools.DetectExternalChanges {
+		tracker := tools.NewReadTracker()
+		fileReadOpts = append(fileReadOpts, tools.WithReadTracker(tracker))
+		writeOpts = append(writeOpts, tools.WithWriteTracker(tracker))
+		editOpts = append(editOpts, tools.WithEditTracker(tracker))
+		copyOpts = append(copyOpts, tools.WithCopyTracker(tracker))
+		formatOpts = append(formatOpts, tools.WithFormatTracker(tracker))
+	}
+	// 会话存储：/save、/load 使用的 ~/.gopilot/sessions
+	var sessions *session.Store
+	if dir, err := session.DefaultDir(); err != nil {
+		fmt.Printf("%s⚠️  /save and /load disabled: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	} else {
+		sessions = session.NewStore(dir)
+	}
+	// 会话备份：修改前保存原始文件，/rollback 一次恢复（overlay 模式下修改不直接写入 workspace，不需要）
+	var backup *tools.SessionBackup
+	if cfg.Tools.Backup.Enabled && overlay == nil {
+		backup, err = tools.NewSessionBackup(absWs, cfg.Tools.Backup.MaxBytes)
+		if err != nil {
+			fmt.Printf("%s⚠️  Session backups disabled: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			writeOpts = append(writeOpts, tools.WithWriteBackup(backup))
+			editOpts = append(editOpts, tools.WithEditBackup(backup))
+			copyOpts = append(copyOpts, tools.WithCopyBackup(backup))
+			chromef("%s✅ Session backups in %s (/rollback restores them)%s\n", colors.GREEN, backup.Dir(), colors.RESET)
+		}
+	}
+
+	toolList = append(toolList,
+		tools.NewReadTool(absWs, fileReadOpts...),
+		tools.NewWriteTool(absWs, writeOpts...),
+		tools.NewEditTool(absWs, editOpts...),
+		tools.NewCopyFileTool(absWs, copyOpts...),
+		tools.NewListDirTool(absWs, readOpts...),
+		tools.NewStatsTool(absWs, readOpts...),
+		tools.NewGitDiffTool(absWs),
+		tools.NewGitShowTool(absWs),
+		tools.NewAskUserTool(),
 	)
-	fmt.Printf("%s✅ Loaded Bash tools%s\n", ColorGreen, ColorReset)
+	chromef("%s✅ Loaded file tools (workspace: %s)%s\n", colors.GREEN, absWs, colors.RESET)
+
+	if cfg.Tools.RunLogs {
+		if dir, err := logger.Dir(); err != nil {
+			fmt.Printf("%s⚠️  run_logs disabled: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			toolList = append(toolList, tools.NewRunLogsTool(dir))
+			chromef("%s✅ Loaded run_logs tool (%s)%s\n", colors.GREEN, dir, colors.RESET)
+		}
+	}
 
+	// Go 工具只在 go 命令可用时提供；go_mod 直接改写工作空间中的 go.mod/go.sum，与 format_code 一样不用于 overlay 模式
+	if overlay == nil && tools.GoAvailable() {
+		toolList = append(toolList, tools.NewGoModTool(absWs))
+		chromef("%s✅ Loaded Go tools%s\n", colors.GREEN, colors.RESET)
+	}
+	// format_code 直接改写工作空间中的文件，overlay 模式下不提供（待定的修改在影子目录中，格式化不到）
+	formatTool := tools.NewFormatTool(absWs, append(formatOpts,
+		tools.WithFormatters(cfg.Tools.Format.Formatters),
+		tools.WithFormatTimeout(cfg.Tools.Format.Timeout),
+	)...)
+	if overlay == nil && formatTool.Available() {
+		toolList = append(toolList, formatTool)
+		chromef("%s✅ Loaded format tool (%s)%s\n", colors.GREEN, strings.Join(formatTool.Extensions(), " "), colors.RESET)
+	}
+
+	watcher := tools.NewFileWatcher(absWs)
 	toolList = append(toolList,
-		tools.NewReadTool(absWs),
-		tools.NewWriteTool(absWs),
-		tools.NewEditTool(absWs),
+		tools.NewWatchFileTool(watcher),
+		tools.NewPollFileChangesTool(watcher),
 	)
-	fmt.Printf("%s✅ Loaded file tools (workspace: %s)%s\n", ColorGreen, absWs, ColorReset)
+
+	var memoryStore *tools.MemoryStore
+	if cfg.Tools.Memory.Enabled {
+		memoryStore, err = tools.NewMemoryStore(absWs, tools.WithMemoryMaxBytes(cfg.Tools.Memory.MaxBytes))
+		if err != nil {
+			fmt.Printf("%s⚠️  Memory disabled: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			toolList = append(toolList, tools.NewMemoryTool(memoryStore))
+			chromef("%s✅ Loaded memory tool (%s)%s\n", colors.GREEN, memoryStore.Path(), colors.RESET)
+		}
+	}
+
+	// 外部命令直接在真实 workspace 中运行，overlay 模式下只提供声明为只读的
+	var overlayBlocked []string
+	if len(cfg.Tools.External) > 0 {
+		external, err := tools.LoadExternalTools(absWs, externalToolSpecs(cfg.Tools.External), toolNames(toolList))
+		if err != nil {
+			fmt.Printf("%s❌ Invalid config: tools.external: %v%s\n", colors.RED, err, colors.RESET)
+			return err
+		}
+		if overlay != nil {
+			var kept []tools.Tool
+			for _, t := range external {
+				if tools.IsReadOnly(t) {
+					kept = append(kept, t)
+				} else {
+					overlayBlocked = append(overlayBlocked, t.Name())
+				}
+			}
+			external = kept
+			if len(overlayBlocked) > 0 {
+				fmt.Printf("%s⚠️  External tools not declared read_only are unavailable in overlay mode: %s%s\n",
+					colors.BRIGHT_YELLOW, strings.Join(overlayBlocked, ", "), colors.RESET)
+			}
+		}
+		if len(external) > 0 {
+			toolList = append(toolList, external...)
+			chromef("%s✅ Loaded %d external tool(s): %s%s\n", colors.GREEN, len(external), strings.Join(toolNames(external), ", "), colors.RESET)
+		}
+	}
+
+	toolList, disabledTools := disableTools(toolList, cfg.Tools.Disabled)
+	if memoryStore == nil {
+		disabledTools = append(disabledTools, "memory")
+	}
+	var readOnlyTools []string
+	if readOnlyWs {
+		toolList, readOnlyTools = removeTools(toolList, workspaceWriteTools)
+	}
 
 	// 4. System Prompt
 	systemPrompt := loadSystemPrompt(cfg.Agent.SystemPromptPath)
-	fmt.Printf("%s✅ System prompt loaded%s\n", ColorGreen, ColorReset)
+	chromef("%s✅ System prompt loaded%s\n", colors.GREEN, colors.RESET)
+
+	if memoryStore != nil {
+		summary, err := memoryStore.Summary()
+		if err != nil {
+			fmt.Printf("%s⚠️  Failed to read memory: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else if summary != "" {
+			systemPrompt += "\n\n## Memory (notes saved in earlier sessions)\n" + summary
+			chromef("%s✅ Memory injected%s\n", colors.GREEN, colors.RESET)
+		}
+	}
+
+	if cfg.Agent.ProjectTree.Enabled {
+		tree, err := buildProjectTree(absWs, cfg.Agent.ProjectTree)
+		if err != nil {
+			fmt.Printf("%s⚠️  Failed to build project tree: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			systemPrompt += "\n\n## Project Structure\n```\n" + tree + "\n```"
+			chromef("%s✅ Project tree injected (depth %d)%s\n", colors.GREEN, cfg.Agent.ProjectTree.Depth, colors.RESET)
+		}
+	}
 
 	// 5. 创建 Agent
+	logOpts := []logger.Option{logger.WithMaxEntryBytes(cfg.Log.MaxEntryBytes)}
+	if args.TailLog != "" {
+		w, err := openTailLog(args.TailLog)
+		if err != nil {
+			fmt.Printf("%s⚠️  Cannot open --tail-log target: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			logOpts = append(logOpts, logger.WithTail(w))
+			chromef("%s✅ Streaming log entries to %s%s\n", colors.GREEN, args.TailLog, colors.RESET)
+		}
+	}
+
+	agentOpts := []agent.Option{
+		agent.WithLoggerOptions(logOpts...),
+		agent.WithVision(cfg.LLM.Vision),
+		agent.WithRetryBudget(cfg.LLM.Retry.RunBudget),
+		agent.WithToolTimeout(cfg.Agent.ToolTimeout),
+		agent.WithToolRateLimits(cfg.Agent.ToolRateLimits),
+		agent.WithCompactToolResults(cfg.Agent.CompactToolResults),
+		agent.WithDedupeToolResults(cfg.Agent.DedupeToolResults),
+		agent.WithMaxConsecutiveToolFailures(cfg.Agent.MaxConsecutiveToolFailures),
+		agent.WithToolResultSpill(cfg.Agent.SpillThresholdChars),
+		agent.WithStreaming(cfg.LLM.Stream),
+		agent.WithThinking(cfg.LLM.Thinking.Display, cfg.LLM.Thinking.Store || cfg.LLM.Thinking.Resend),
+		agent.WithSummaryPreserveCode(cfg.Agent.SummaryPreserveCode),
+		agent.WithSummaryMaxMessageChars(cfg.Agent.SummaryMaxMessageChars),
+		agent.WithCostBudget(cost.Budget{WarnUSD: cfg.Cost.WarnUSD, HardCapUSD: cfg.Cost.HardCapUSD}),
+		agent.WithPricing(cost.Pricing{InputPerMTok: cfg.Cost.InputPerMTok, OutputPerMTok: cfg.Cost.OutputPerMTok}),
+		agent.WithBudgetConfirm(confirmOverBudget),
+		agent.WithDisabledTools(disabledTools...),
+		agent.WithUnavailableTools("unavailable because the workspace is on a read-only filesystem", readOnlyTools...),
+		agent.WithUnavailableTools("unavailable in overlay mode because it would change the real workspace", overlayBlocked...),
+		agent.WithQuiet(quiet),
+		agent.WithReadOnly(args.ReadOnly),
+		agent.WithStepWarning(cfg.Agent.StepWarningRatio),
+		agent.WithSystemPromptLimit(cfg.Agent.SystemPromptMaxTokens, cfg.Agent.TruncateSystemPrompt),
+	}
+	if lo := cfg.Tools.Bash.LargeOutput; lo.ThresholdChars > 0 {
+		fallback, err := agent.ParseLargeOutputAction(lo.Default)
+		if err != nil {
+			fmt.Printf("%s❌ Invalid config: tools.bash.large_output: %v%s\n", colors.RED, err, colors.RESET)
+			return err
+		}
+		ask := lo.Ask && term.IsTerminal(int(os.Stdin.Fd()))
+		agentOpts = append(agentOpts, agent.WithLargeOutputPolicy(lo.ThresholdChars, largeOutputDecider(ask, fallback)))
+	}
+	if cfg.Agent.ScratchDir && overlay == nil && !readOnlyWs {
+		scratch, err := tools.NewScratchDir(absWs)
+		if err != nil {
+			fmt.Printf("%s⚠️  Scratch directory disabled: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else {
+			agentOpts = append(agentOpts, agent.WithScratchDir(scratch))
+			chromef("%s✅ Scratch directory %s%s\n", colors.GREEN, scratch.Rel(), colors.RESET)
+		}
+	}
+	// 只在交互式终端中显示等待模型响应的 spinner
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		agentOpts = append(agentOpts, agent.WithProgressIndicator(os.Stdout))
+	}
+	// 工具进度（如前台 bash 命令的输出）实时回显同样只在交互终端中有意义
+	if cfg.Tools.Bash.StreamOutput && !quiet && term.IsTerminal(int(os.Stdout.Fd())) {
+		agentOpts = append(agentOpts, agent.WithToolProgress(toolProgressPrinter(os.Stdout)))
+	}
+
 	ag, err := agent.NewAgent(
 		llmClient,
 		systemPrompt,
@@ -312,14 +755,54 @@ func runAgent(workspaceDir string) error {
 		cfg.Agent.MaxSteps,
 		absWs,
 		cfg.Agent.TokenLimit,
+		agentOpts...,
 	)
 	if err != nil {
 		return err
 	}
+	payloadLog = ag.LogPayload
+
+	// 自动保存（agent.autosave_session）：每轮对话后与退出前保存，供 --resume / /resume 恢复
+	autosave := func() {}
+	if sessions != nil {
+		if removed, err := sessions.PruneAutosaves(cfg.Agent.AutosaveMaxAge); err != nil {
+			fmt.Printf("%s⚠️  Pruning old autosaves failed: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+		} else if len(removed) > 0 {
+			chromef("%sRemoved %d old autosaved session(s) (agent.autosave_max_age)%s\n", colors.DIM, len(removed), colors.RESET)
+		}
+	}
+	if cfg.Agent.AutosaveSession && sessions != nil {
+		autosave = func() { autosaveSession(sessions, ag, llmClient, absWs) }
+		chromef("%s💾 Autosaving this session to %s (agent.autosave_session)%s\n",
+			colors.DIM, sessions.Path(session.AutosaveName(absWs)), colors.RESET)
+	}
+
+	// 非交互运行：执行 --prompt 的任务后退出，退出码表示任务是否成功
+	if args.Prompt != "" {
+		if args.Resume {
+			resumeSession(sessions, ag, llmClient, absWs)
+		}
+		code := agent.ExitError
+		if task, err := readTask(args.Prompt); err != nil {
+			fmt.Printf("%s❌ %v%s\n", colors.RED, err, colors.RESET)
+		} else {
+			code = runOneShot(ag, task, cfg.Agent.MaxToolFailureRatio)
+		}
+		shutdown(ag, overlay, backup, autosave)
+		if code != agent.ExitOK {
+			return &exitCodeError{code: code}
+		}
+		return nil
+	}
 
 	// 6. 打印欢迎信息
-	printBanner()
-	printSessionInfo(ag, absWs, cfg.LLM.Model, len(toolList))
+	if !quiet {
+		printBanner()
+		printSessionInfo(ag, absWs, llmClient, len(toolList))
+	}
+	if args.Resume {
+		resumeSession(sessions, ag, llmClient, absWs)
+	}
 
 	// 7. go-prompt：补全器
 	completer := func(d prompt.Document) []prompt.Suggest {
@@ -329,8 +812,29 @@ func runAgent(workspaceDir string) error {
 			suggestions := []prompt.Suggest{
 				{Text: "/help", Description: "Show help message"},
 				{Text: "/clear", Description: "Clear session history"},
+				{Text: "/compact", Description: "Drop assistant and tool messages, keep user turns"},
 				{Text: "/history", Description: "Show message count"},
 				{Text: "/stats", Description: "Show session statistics"},
+				{Text: "/tree", Description: "Show workspace directory tree"},
+				{Text: "/model", Description: "Show or switch model"},
+				{Text: "/token-limit", Description: "Show or set summarization token limit"},
+				{Text: "/cost", Description: "Show estimated session cost and budget"},
+				{Text: "/log", Description: "Show or tail the current log file"},
+				{Text: "/logs", Description: "List recent run logs"},
+				{Text: "/plan", Description: "Plan a task read-only, then execute after approval"},
+				{Text: "/dryplan", Description: "Describe the approach to a task without running tools"},
+				{Text: "/compare", Description: "Rerun the last request on another model for comparison"},
+				{Text: "/apply", Description: "Copy overlay changes into the workspace"},
+				{Text: "/discard", Description: "Throw overlay changes away"},
+				{Text: "/rollback", Description: "Restore every file changed this session from backups"},
+				{Text: "/save", Description: "Save the conversation under a name"},
+				{Text: "/load", Description: "Restore a saved conversation of this workspace (no name lists them)"},
+				{Text: "/resume", Description: "Restore the most recent session of this workspace"},
+				{Text: "/instruct", Description: "Add a one-shot instruction to the next request"},
+				{Text: "/retry", Description: "Show or change LLM retry settings"},
+				{Text: "/config", Description: "Show current settings"},
+				{Text: "/think", Description: "Show or change reasoning effort"},
+				{Text: "/choices", Description: "Show alternative replies of the last response"},
 				{Text: "/exit", Description: "Exit program"},
 			}
 			return prompt.FilterHasPrefix(suggestions, text, true)
@@ -338,21 +842,46 @@ func runAgent(workspaceDir string) error {
 		return []prompt.Suggest{}
 	}
 
-	// 8. go-prompt：执行器
+	// 8. 空闲超时：go-prompt 处于 raw 模式，退出前先恢复启动时的终端状态
+	stdinFd := int(os.Stdin.Fd())
+	termState, _ := term.GetState(stdinFd)
+	idle := newIdleWatcher(cfg.UI.IdleTimeout, func() {
+		if termState != nil {
+			_ = term.Restore(stdinFd, termState)
+		}
+		fmt.Printf("\n\n%s⏱️  No input for %s, exiting idle session%s\n\n",
+			colors.BRIGHT_YELLOW, cfg.UI.IdleTimeout, colors.RESET)
+		if !quiet {
+			printStats(ag, sessionStart, len(toolList))
+		}
+		shutdown(ag, overlay, backup, autosave)
+		os.Exit(0)
+	})
+
+	// 9. go-prompt：执行器
 	executor := func(in string) {
 		input := strings.TrimSpace(in)
 		if input == "" {
 			return
 		}
 
+		// 执行命令 / 任务期间不计空闲
+		idle.Pause()
+		defer idle.Resume()
+
 		// 命令（以 / 开头）
 		if strings.HasPrefix(input, "/") {
-			cmd := strings.ToLower(input)
+			fields := strings.Fields(input)
+			cmd := strings.ToLower(fields[0])
+			cmdArgs := fields[1:]
 
 			switch cmd {
 			case "/exit", "/quit", "/q":
-				fmt.Printf("\n%s👋 Goodbye! Thanks for using Gopilot-CLI%s\n\n", ColorBrightYellow, ColorReset)
-				printStats(ag, sessionStart, len(toolList))
+				chromef("\n%s👋 Goodbye! Thanks for using Gopilot-CLI%s\n\n", colors.BRIGHT_YELLOW, colors.RESET)
+				if !quiet {
+					printStats(ag, sessionStart, len(toolList))
+				}
+				shutdown(ag, overlay, backup, autosave)
 				os.Exit(0)
 			case "/help":
 				printHelp()
@@ -360,31 +889,102 @@ func runAgent(workspaceDir string) error {
 			case "/clear":
 				oldCount := len(ag.History())
 				fmt.Printf("%s✅ Cleared %d messages, starting new session%s\n\n",
-					ColorGreen, oldCount-1, ColorReset)
-
-				var err error
-				ag, err = agent.NewAgent(
-					llmClient,
-					systemPrompt,
-					toolList,
-					cfg.Agent.MaxSteps,
-					absWs,
-					cfg.Agent.TokenLimit,
-				)
-				if err != nil {
-					fmt.Printf("%s❌ Failed to reset agent: %v%s\n", ColorRed, err, ColorReset)
-				}
+					colors.GREEN, oldCount-1, colors.RESET)
+
+				// 仅重置历史，保留 /model、/token-limit 等运行时设置
+				ag.Reset()
+				return
+			case "/compact":
+				dropped := ag.CompactToUserTurns()
+				fmt.Printf("%s✅ Dropped %d assistant/tool messages, kept %d user messages%s\n\n",
+					colors.GREEN, dropped, len(ag.History())-1, colors.RESET)
 				return
 			case "/history":
 				fmt.Printf("\n%sCurrent session message count: %d%s\n\n",
-					ColorBrightCyan, len(ag.History()), ColorReset)
+					colors.BRIGHT_CYAN, len(ag.History()), colors.RESET)
 				return
 			case "/stats":
 				printStats(ag, sessionStart, len(toolList))
 				return
+			case "/tree":
+				tree, err := buildProjectTree(absWs, cfg.Agent.ProjectTree)
+				if err != nil {
+					fmt.Printf("%s❌ Failed to build tree: %v%s\n\n", colors.RED, err, colors.RESET)
+					return
+				}
+				fmt.Printf("\n%s\n\n", tree)
+				return
+			case "/model":
+				switchModel(llmClient, ag, cmdArgs)
+				return
+			case "/token-limit":
+				setTokenLimit(ag, cmdArgs)
+				return
+			case "/cost":
+				printCost(ag)
+				return
+			case "/log":
+				showLog(ag, cmdArgs)
+				return
+			case "/logs":
+				listLogs(cmdArgs)
+				return
+			case "/instruct":
+				setInstruction(ag, strings.TrimSpace(strings.TrimPrefix(input, fields[0])))
+				return
+			case "/plan":
+				task := strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+				if task == "" {
+					fmt.Printf("%s❌ Usage: /plan <task>%s\n\n", colors.RED, colors.RESET)
+					return
+				}
+				runPlanned(context.Background(), ag, task)
+				chromef("\n%s%s%s\n\n", colors.DIM, strings.Repeat("─", 60), colors.RESET)
+				return
+			case "/dryplan":
+				task := strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+				if task == "" {
+					fmt.Printf("%s❌ Usage: /dryplan <task>%s\n\n", colors.RED, colors.RESET)
+					return
+				}
+				showDryPlan(context.Background(), ag, task)
+				return
+			case "/apply":
+				applyOverlay(overlay)
+				return
+			case "/discard":
+				discardOverlay(overlay)
+				return
+			case "/rollback":
+				rollbackSession(backup)
+				return
+			case "/save":
+				saveSession(sessions, ag, llmClient, absWs, cmdArgs)
+				return
+			case "/load":
+				loadSession(sessions, ag, llmClient, absWs, cmdArgs)
+				return
+			case "/resume":
+				resumeSession(sessions, ag, llmClient, absWs)
+				return
+			case "/compare":
+				compareModels(context.Background(), ag, llmClient.Model(), newClient, cmdArgs)
+				return
+			case "/retry":
+				setRetry(llmClient, cmdArgs)
+				return
+			case "/config":
+				printConfig(cfg, llmClient, ag, absWs, overlay)
+				return
+			case "/think":
+				setThinking(llmClient, cmdArgs)
+				return
+			case "/choices":
+				showChoices(ag)
+				return
 			default:
-				fmt.Printf("%s❌ Unknown command: %s%s\n", ColorRed, input, ColorReset)
-				fmt.Printf("%sType /help to see available commands%s\n\n", ColorDim, ColorReset)
+				fmt.Printf("%s❌ Unknown command: %s%s\n", colors.RED, input, colors.RESET)
+				fmt.Printf("%sType /help to see available commands%s\n\n", colors.DIM, colors.RESET)
 				return
 			}
 		}
@@ -392,39 +992,100 @@ func runAgent(workspaceDir string) error {
 		// 非 / 命令：允许 exit/quit/q
 		lower := strings.ToLower(input)
 		if lower == "exit" || lower == "quit" || lower == "q" {
-			fmt.Printf("\n%s👋 Goodbye! Thanks for using Gopilot-CLI%s\n\n", ColorBrightYellow, ColorReset)
-			printStats(ag, sessionStart, len(toolList))
+			chromef("\n%s👋 Goodbye! Thanks for using Gopilot-CLI%s\n\n", colors.BRIGHT_YELLOW, colors.RESET)
+			if !quiet {
+				printStats(ag, sessionStart, len(toolList))
+			}
+			shutdown(ag, overlay, backup, autosave)
 			os.Exit(0)
 		}
 
 		// 普通对话：丢给 Agent
-		fmt.Printf("\n%sAgent%s %s›%s %sThinking...%s\n\n",
-			ColorBrightBlue, ColorReset, ColorDim, ColorReset, ColorDim, ColorReset)
-
-		ag.AddUserMessage(input)
-
-		ctx := context.Background()
-		_, err := ag.Run(ctx)
-		if err != nil {
-			fmt.Printf("\n%s❌ Error: %v%s\n", ColorRed, err, ColorReset)
+		chromef("\n%sAgent%s %s›%s %sThinking...%s\n\n",
+			colors.BRIGHT_BLUE, colors.RESET, colors.DIM, colors.RESET, colors.DIM, colors.RESET)
+
+		ctx, stop := interruptible(ag)
+		defer stop()
+		if cfg.Agent.PlanMode {
+			runPlanned(ctx, ag, input)
+		} else {
+			ag.AddUserMessage(input)
+			out, err := ag.Run(ctx)
+			if err != nil {
+				fmt.Printf("\n%s❌ Error: %v%s\n", colors.RED, err, colors.RESET)
+			} else if ag.LastOutcome() == agent.OutcomeNeedsInput {
+				printAwaitingAnswer(out)
+			}
 		}
+		autosave()
 
-		fmt.Printf("\n%s%s%s\n\n", ColorDim, strings.Repeat("─", 60), ColorReset)
+		chromef("\n%s%s%s\n\n", colors.DIM, strings.Repeat("─", 60), colors.RESET)
 	}
 
-	// 9. 启动 go-prompt
-	p := prompt.New(
-		executor,
-		completer,
+	// 10. 启动 go-prompt（每次按键都视为活动，重置空闲计时）
+	promptOpts := append([]prompt.Option{
 		prompt.OptionPrefix("You › "),
 		prompt.OptionTitle("gopilot-cli"),
-		prompt.OptionInputTextColor(prompt.Yellow),
-	)
+		prompt.OptionSetExitCheckerOnInput(func(string, bool) bool {
+			idle.Touch()
+			return false
+		}),
+	}, promptColorOptions(theme)...)
+	p := prompt.New(executor, completer, promptOpts...)
 	p.Run()
+	shutdown(ag, overlay, backup, autosave)
 
 	return nil
 }
 
+// promptColorOptions 输入框的配色，跟随当前主题
+func promptColorOptions(theme string) []prompt.Option {
+	switch {
+	case !colors.Enabled():
+		return []prompt.Option{
+			prompt.OptionPrefixTextColor(prompt.DefaultColor),
+			prompt.OptionInputTextColor(prompt.DefaultColor),
+			prompt.OptionPreviewSuggestionTextColor(prompt.DefaultColor),
+			prompt.OptionSuggestionTextColor(prompt.DefaultColor),
+			prompt.OptionSuggestionBGColor(prompt.DefaultColor),
+			prompt.OptionSelectedSuggestionTextColor(prompt.DefaultColor),
+			prompt.OptionSelectedSuggestionBGColor(prompt.DefaultColor),
+			prompt.OptionDescriptionTextColor(prompt.DefaultColor),
+			prompt.OptionDescriptionBGColor(prompt.DefaultColor),
+			prompt.OptionSelectedDescriptionTextColor(prompt.DefaultColor),
+			prompt.OptionSelectedDescriptionBGColor(prompt.DefaultColor),
+			prompt.OptionScrollbarThumbColor(prompt.DefaultColor),
+			prompt.OptionScrollbarBGColor(prompt.DefaultColor),
+		}
+	case theme == "light":
+		return []prompt.Option{prompt.OptionInputTextColor(prompt.Brown)}
+	default:
+		return []prompt.Option{prompt.OptionInputTextColor(prompt.Yellow)}
+	}
+}
+
+// shutdown 退出前自动保存会话（agent.autosave_session），释放 Agent 持有的资源（文件监听等），删除本会话的备份
+func shutdown(ag *agent.Agent, overlay *tools.Overlay, backup *tools.SessionBackup, autosave func()) {
+	// 先保存：Close 会终止后台进程，保存的快照应记录它们退出前的状态
+	autosave()
+	if err := ag.Close(); err != nil {
+		fmt.Printf("%s⚠️  Cleanup failed: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	}
+	if err := backup.Close(); err != nil {
+		fmt.Printf("%s⚠️  Cleanup failed: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	}
+	if overlay == nil {
+		return
+	}
+	if pending := overlay.Changes(); len(pending) > 0 {
+		fmt.Printf("%s⚠️  %d overlay change(s) were not applied; they are kept in %s%s\n",
+			colors.BRIGHT_YELLOW, len(pending), overlay.Dir(), colors.RESET)
+	}
+	if err := overlay.Close(); err != nil {
+		fmt.Printf("%s⚠️  Cleanup failed: %v%s\n", colors.BRIGHT_YELLOW, err, colors.RESET)
+	}
+}
+
 //
 // main：CLI 入口
 //
@@ -432,24 +1093,74 @@ func runAgent(workspaceDir string) error {
 func main() {
 	args := parseArgs()
 
-	var workspaceDir string
-	if args.Workspace != "" {
-		workspaceDir = args.Workspace
-	} else {
-		wd, err := os.Getwd()
-		if err != nil {
-			fmt.Printf("%s❌ Failed to get current directory: %v%s\n", ColorRed, err, ColorReset)
-			os.Exit(1)
+	if err := runAgent(args); err != nil {
+		var exit *exitCodeError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
 		}
-		workspaceDir = wd
+		os.Exit(agent.ExitError)
 	}
+}
+
+// workspaceWriteTools 会写入工作空间的工具，工作空间不可写时移除
+var workspaceWriteTools = []string{"write_file", "edit_file", "copy_file", "go_mod", "format_code"}
 
-	if err := os.MkdirAll(workspaceDir, 0o755); err != nil {
-		fmt.Printf("%s❌ Failed to create workspace dir: %v%s\n", ColorRed, err, ColorReset)
-		os.Exit(1)
+// removeTools 从工具列表中移除 names 中的工具，返回剩余工具与实际移除的工具名
+func removeTools(toolList []tools.Tool, names []string) ([]tools.Tool, []string) {
+	off := map[string]bool{}
+	for _, name := range names {
+		off[strings.TrimSpace(name)] = true
 	}
+	kept := make([]tools.Tool, 0, len(toolList))
+	var removed []string
+	for _, t := range toolList {
+		if off[t.Name()] {
+			removed = append(removed, t.Name())
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, removed
+}
+
+// disableTools 从工具列表中移除配置关闭的工具，返回剩余工具与实际关闭的工具名；未知的名称只给出警告
+func disableTools(toolList []tools.Tool, names []string) ([]tools.Tool, []string) {
+	if len(names) == 0 {
+		return toolList, nil
+	}
+	kept, disabled := removeTools(toolList, names)
+	for _, name := range names {
+		if name = strings.TrimSpace(name); !slices.Contains(disabled, name) {
+			fmt.Printf("%s⚠️  tools.disabled: unknown tool %q%s\n", colors.BRIGHT_YELLOW, name, colors.RESET)
+		}
+	}
+	if len(disabled) > 0 {
+		chromef("%s✅ Disabled tools: %s%s\n", colors.GREEN, strings.Join(disabled, ", "), colors.RESET)
+	}
+	return kept, disabled
+}
+
+// toolNames 返回工具列表中的名称
+func toolNames(toolList []tools.Tool) []string {
+	names := make([]string, len(toolList))
+	for i, t := range toolList {
+		names[i] = t.Name()
+	}
+	return names
+}
 
-	if err := runAgent(workspaceDir); err != nil {
-		os.Exit(1)
+// externalToolSpecs 把配置中的外部工具转换为 tools.ExternalToolSpec
+func externalToolSpecs(cfgs []config.ExternalToolConfig) []tools.ExternalToolSpec {
+	specs := make([]tools.ExternalToolSpec, len(cfgs))
+	for i, c := range cfgs {
+		specs[i] = tools.ExternalToolSpec{
+			Name:        c.Name,
+			Description: c.Description,
+			Command:     c.Command,
+			Parameters:  c.Parameters,
+			Timeout:     c.Timeout,
+			ReadOnly:    c.ReadOnly,
+		}
 	}
+	return specs
 }
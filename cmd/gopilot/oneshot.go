@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/colors"
+)
+
+//
+// 非交互运行（--prompt）：执行一个任务后退出，退出码表示任务是否成功（见 agent.RunResult.ExitCode）
+//
+
+// exitCodeError 让 runAgent 以指定的退出码结束进程
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+// readTask 取得 --prompt 的任务；"-" 表示从 stdin 读取
+func readTask(p string) (string, error) {
+	if p != "-" {
+		return p, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("read task from stdin: %w", err)
+	}
+	return string(data), nil
+}
+
+// runOneShot 执行单个任务并返回退出码；Ctrl-C 先中断正在执行的工具，工具之间按下时取消运行（退出码 130）。
+// 计划模式需要交互确认，这里不使用
+func runOneShot(ag *agent.Agent, task string, maxFailureRatio float64) int {
+	task = strings.TrimSpace(task)
+	if task == "" {
+		fmt.Printf("%s❌ --prompt: the task is empty%s\n", colors.RED, colors.RESET)
+		return agent.ExitError
+	}
+
+	ctx, stop := interruptible(ag)
+	defer stop()
+
+	ag.AddUserMessage(task)
+	out, err := ag.Run(ctx)
+	result := ag.LastResult()
+	code := result.ExitCode(err, maxFailureRatio)
+
+	switch {
+	case err != nil:
+		fmt.Printf("\n%s❌ Error: %v%s\n", colors.RED, err, colors.RESET)
+	case result.Outcome == agent.OutcomeNeedsInput:
+		printAwaitingAnswer(out)
+	case result.Outcome == agent.OutcomeDone && code == agent.ExitToolFailures:
+		fmt.Printf("\n%s⚠️  %d of %d tool calls failed (more than %.0f%%); treating the task as failed%s\n",
+			colors.BRIGHT_YELLOW, result.ToolFailures, result.ToolCalls, maxFailureRatio*100, colors.RESET)
+	}
+	return code
+}
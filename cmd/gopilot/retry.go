@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/config"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/retry"
+)
+
+//
+// 重试配置：config.RetryConfig → retry.Config
+//
+
+// retryClasses 配置中允许的错误类别
+var retryClasses = map[string]retry.Class{
+	"network":    retry.ClassNetwork,
+	"server":     retry.ClassServer,
+	"rate_limit": retry.ClassRateLimit,
+}
+
+// seconds 将配置中的秒数转为 time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// exponentialBase 校验指数退避基数：不大于 1 时退避不会增长，提示后改用 fallback
+func exponentialBase(base, fallback float64, field string) float64 {
+	if retry.ValidBase(base) {
+		return base
+	}
+	fmt.Printf("%s⚠️  %s must be greater than 1 (got %g); using %g%s\n",
+		colors.BRIGHT_YELLOW, field, base, fallback, colors.RESET)
+	return fallback
+}
+
+// buildRetryConfig 构造 LLM 调用的重试配置；配置了 policies 时启用按错误类别的策略
+func buildRetryConfig(c config.RetryConfig) (*retry.Config, error) {
+	rc := &retry.Config{
+		Enabled:         c.Enabled,
+		MaxRetries:      c.MaxRetries,
+		InitialDelay:    seconds(c.InitialDelay),
+		MaxDelay:        seconds(c.MaxDelay),
+		ExponentialBase: exponentialBase(c.ExponentialBase, retry.DefaultExponentialBase, "llm.retry.exponential_base"),
+	}
+	if len(c.Policies) == 0 {
+		return rc, nil
+	}
+
+	names := make([]string, 0, len(c.Policies))
+	for name := range c.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rc.Classifier = llm.ClassifyError
+	rc.Policies = make(map[retry.Class]retry.Policy, len(names))
+	for _, name := range names {
+		class, ok := retryClasses[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown retry policy class %q (want network, server or rate_limit)", name)
+		}
+		p := c.Policies[name]
+		policy := retry.Policy{
+			MaxRetries:        rc.MaxRetries,
+			InitialDelay:      rc.InitialDelay,
+			MaxDelay:          rc.MaxDelay,
+			ExponentialBase:   rc.ExponentialBase,
+			RespectRetryAfter: p.RespectRetryAfter,
+		}
+		if p.MaxRetries != nil {
+			policy.MaxRetries = *p.MaxRetries
+		}
+		if p.InitialDelay > 0 {
+			policy.InitialDelay = seconds(p.InitialDelay)
+		}
+		if p.MaxDelay > 0 {
+			policy.MaxDelay = seconds(p.MaxDelay)
+		}
+		if p.ExponentialBase != 0 {
+			policy.ExponentialBase = exponentialBase(p.ExponentialBase, rc.ExponentialBase,
+				fmt.Sprintf("llm.retry.policies.%s.exponential_base", name))
+		}
+		rc.Policies[class] = policy
+	}
+	return rc, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/config"
+)
+
+//
+// 配置加载 & 首次运行向导
+//
+
+// loadConfig 查找并加载配置文件。
+// 找不到配置时：
+//   - 若指定了 --no-setup 或通过 --api-key/--api-base/--model 提供了参数，则跳过交互，
+//     后者会把参数写入 ~/.gopilot/config.yaml（便于脚本化安装）；
+//   - 否则启动交互式向导，询问 API 地址、模型和密钥并写入 ~/.gopilot/config.yaml。
+//
+// 命令行参数始终覆盖配置文件中的对应值。
+func loadConfig(args *CLIArgs) (*config.Config, error) {
+	path, found := config.FindConfigFile(args.ConfigPath)
+
+	var cfg *config.Config
+	switch {
+	case found:
+		loaded, err := config.LoadFromFile(path,
+			config.WithStrict(args.StrictConfig),
+			config.WithUnknownKeyHandler(func(k config.UnknownKey) {
+				fmt.Printf("%s⚠️  Config %s, %s; it is ignored (use --strict-config to fail instead)%s\n",
+					colors.BRIGHT_YELLOW, path, k, colors.RESET)
+			}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	case args.ConfigPath != "":
+		return nil, fmt.Errorf("config file not found: %s", args.ConfigPath)
+	case args.NoSetup:
+		cfg = config.DefaultConfig()
+	case args.hasLLMOverrides():
+		cfg = config.DefaultConfig()
+		applyLLMOverrides(cfg, args)
+		if err := saveUserConfig(cfg); err != nil {
+			return nil, err
+		}
+	default:
+		wizard, err := runFirstRunSetup(bufio.NewReader(os.Stdin))
+		if err != nil {
+			return nil, err
+		}
+		cfg = wizard
+	}
+
+	applyLLMOverrides(cfg, args)
+	return cfg, nil
+}
+
+func (a *CLIArgs) hasLLMOverrides() bool {
+	return a.APIKey != "" || a.APIBase != "" || a.Model != ""
+}
+
+// applyLLMOverrides 用命令行参数覆盖 LLM 配置
+func applyLLMOverrides(cfg *config.Config, args *CLIArgs) {
+	if args.APIKey != "" {
+		cfg.LLM.APIKey = args.APIKey
+	}
+	if args.APIBase != "" {
+		cfg.LLM.APIBase = args.APIBase
+	}
+	if args.Model != "" {
+		cfg.LLM.Model = args.Model
+	}
+}
+
+// saveUserConfig 写入 ~/.gopilot/config.yaml
+func saveUserConfig(cfg *config.Config) error {
+	path, err := config.UserConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := config.SaveToFile(cfg, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("%s✅ Config written to %s%s\n", colors.GREEN, path, colors.RESET)
+	return nil
+}
+
+// runFirstRunSetup 交互式首次配置：询问 API 地址、模型和密钥并保存
+func runFirstRunSetup(in *bufio.Reader) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+
+	fmt.Printf("\n%s%s👋 No config found — let's set up Gopilot%s\n", colors.BOLD, colors.BRIGHT_CYAN, colors.RESET)
+	fmt.Printf("%sPress Enter to accept the value in [brackets]. Skip this with --no-setup.%s\n\n", colors.DIM, colors.RESET)
+
+	cfg.LLM.APIBase = ask(in, "API base URL", "https://api.openai.com/v1")
+	cfg.LLM.Model = ask(in, "Model", "gpt-4.1")
+
+	keyDefault := ""
+	if os.Getenv("OPENAI_API_KEY") != "" {
+		keyDefault = "$OPENAI_API_KEY"
+	}
+	key := ask(in, "API key", keyDefault)
+	if key != "$OPENAI_API_KEY" {
+		cfg.LLM.APIKey = key
+	}
+
+	if err := saveUserConfig(cfg); err != nil {
+		return nil, err
+	}
+	fmt.Println()
+	return cfg, nil
+}
+
+// ask 打印提示并读取一行输入，空输入（或 EOF）返回默认值
+func ask(in *bufio.Reader, label, def string) string {
+	if def != "" {
+		fmt.Printf("%s›%s %s [%s]: ", colors.BRIGHT_GREEN, colors.RESET, label, def)
+	} else {
+		fmt.Printf("%s›%s %s: ", colors.BRIGHT_GREEN, colors.RESET, label)
+	}
+
+	line, _ := in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def
+	}
+	return line
+}
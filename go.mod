@@ -4,10 +4,12 @@ go 1.25.2
 
 require (
 	github.com/c-bata/go-prompt v0.2.6
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/google/uuid v1.6.0
 	github.com/openai/openai-go/v3 v3.8.1
 	github.com/pkoukk/tiktoken-go v0.1.8
 	github.com/stretchr/testify v1.11.1
+	golang.org/x/term v0.37.0
 	golang.org/x/text v0.31.0
 )
 
@@ -3,18 +3,29 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"log/slog"
 
 	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/agent/cost"
+	"gopilot-cli/internal/agent/history"
 	"gopilot-cli/internal/agent/summarizer"
 	"gopilot-cli/internal/llm"
 	"gopilot-cli/internal/logger"
+	"gopilot-cli/internal/retry"
 	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tokenizer"
 	"gopilot-cli/internal/tools"
 	terminal "gopilot-cli/internal/utils/terminal"
 )
@@ -25,16 +36,268 @@ import (
 // ============================================================
 //
 
+// resultPreviewWidth 控制台打印工具结果时的最大显示宽度
+const resultPreviewWidth = 300
+
+// panicStackLines 工具 panic 时写入日志的调用栈行数
+const panicStackLines = 40
+
 type Agent struct {
 	llm          *llm.Client
 	systemPrompt string
-	tools        map[string]tools.Tool
+	tools        *tools.ToolRegistry
 	maxSteps     int
 	tokenLimit   int
 	workspace    string
+	vision       bool
+
+	// toolTimeout 未声明 TimeoutHint 的工具的默认执行超时（0 表示不限制）
+	toolTimeout time.Duration
+
+	// stepWarnRatio 运行步数达到 maxSteps 的该比例时提示即将到达上限（0 表示不提示）
+	stepWarnRatio float64
+
+	// quiet 只输出最终回答与错误，不打印日志路径、Step 框、工具调用等过程信息
+	quiet bool
+
+	// progress 等待模型响应期间显示 spinner 与耗时的输出（nil 表示不显示）
+	progress io.Writer
+
+	// promptMaxTokens system prompt 的大小上限（0 表示不检查）；promptTruncate 超过时截断而不只是警告
+	promptMaxTokens int
+	promptTruncate  bool
+
+	// toolProgress 接收工具执行期间的进度消息（nil 表示不接收，工具照常执行）
+	toolProgress func(tool, message string)
+
+	// spillThreshold 超过该字符数的工具结果写入 workspace 下的文件，只把预览与路径发给模型（0 表示不启用）
+	spillThreshold int
+	// spilled 本会话保存的工具结果文件，Close 时删除
+	spilled []string
+
+	// compactResults 发给模型的工具结果使用紧凑格式（见 tools.CompactContent）
+	compactResults bool
+	// dedupeResults 相同的较早工具结果替换为引用（见 WithDedupeToolResults）；resultKeys 为 tool_call_id -> 结果哈希
+	dedupeResults bool
+	resultKeys    map[string]string
+	// stream 使用流式 API，回复在到达时逐段打印（见 WithStreaming）
+	stream bool
+	// maxConsecutiveFailures 连续失败的工具调用超过该次数时结束运行（见 WithMaxConsecutiveToolFailures），0 表示不限制
+	maxConsecutiveFailures int
+
+	// disabledTools 本会话不可用的工具名 → 原因，用于区分"已关闭"与"未知工具"
+	disabledTools map[string]string
+
+	// rateLimiter 按工具限制调用频率（nil 表示不限制）
+	rateLimiter *toolRateLimiter
+
+	// showThinking 是否打印模型的思考内容；storeThinking 是否把它保存在会话历史中
+	showThinking  bool
+	storeThinking bool
+
+	// retryBudget 单次 Run 内所有 LLM 调用共享的重试次数（0 表示不限制）
+	retryBudget int
+
+	// summaryPreserveCode 摘要时原样保留代码块与文件路径
+	summaryPreserveCode bool
+	// summaryMaxMessageChars 送入摘要的每条消息的最大字符数（0 表示不限制）
+	summaryMaxMessageChars int
+
+	// 计划 / 执行两阶段：planning 期间只开放只读工具，plan 为待批准的计划
+	planning bool
+	plan     string
+	// readOnly 整个会话只开放只读工具（见 WithReadOnly）
+	readOnly bool
+
+	// instruction 只作用于下一次请求的临时指令，发送后清空
+	instruction string
+
+	// busy 是否有运行正在进行，防止并发调用 Run 等方法破坏会话历史
+	busy atomic.Bool
+	// running 正在执行的工具，InterruptTool 只取消它
+	running toolTracker
+
+	// outcome 最近一次 Run 的结束原因
+	outcome Outcome
+
+	// changes 当前 Run 的文件变更清单；result 最近一次 Run 的结构化结果
+	changes *changeLedger
+	result  RunResult
+
+	// lastFingerprint 上一次响应的 system_fingerprint，用于发现后端变化
+	lastFingerprint string
+
+	// largeOutputChars 前台 bash 输出超过该字符数时由 decideLargeOutput 决定如何处理（0 表示不检查）
+	largeOutputChars  int
+	decideLargeOutput LargeOutputFunc
+
+	// scratch 会话的临时目录，Close 时删除
+	scratch *tools.ScratchDir
+
+	// lastChoices 上一次响应的全部候选（请求了多个候选时），见 LastChoices
+	lastChoices []schema.Choice
+
+	// 会话花费追踪（跨 Run、跨 /clear 累计）
+	cost          *cost.Tracker
+	pricing       cost.Pricing
+	confirmBudget BudgetConfirmFunc
 
 	messages []schema.Message
 	log      *logger.AgentLogger
+	logOpts  []logger.Option
+}
+
+// Option Agent 可选配置
+type Option func(*Agent)
+
+// WithLoggerOptions 设置运行日志选项
+func WithLoggerOptions(opts ...logger.Option) Option {
+	return func(a *Agent) {
+		a.logOpts = append(a.logOpts, opts...)
+	}
+}
+
+// BudgetConfirmFunc 花费达到硬上限时询问是否继续，返回 false 则停止本次运行
+type BudgetConfirmFunc func(spent, limit float64) bool
+
+// WithCostBudget 设置会话花费预算：越过 WarnUSD 时提示，达到 HardCapUSD 时暂停并请求确认
+func WithCostBudget(budget cost.Budget) Option {
+	return func(a *Agent) {
+		a.cost = cost.NewTracker(budget)
+	}
+}
+
+// WithPricing 覆盖模型价格（每百万 token 美元），未设置时按内置价格表估算
+func WithPricing(p cost.Pricing) Option {
+	return func(a *Agent) {
+		a.pricing = p
+	}
+}
+
+// WithBudgetConfirm 设置达到硬上限时的确认回调；未设置时直接停止
+func WithBudgetConfirm(fn BudgetConfirmFunc) Option {
+	return func(a *Agent) {
+		a.confirmBudget = fn
+	}
+}
+
+// WithRetryBudget 设置每次 Run 共享的 LLM 重试预算：整次运行累计重试 n 次后不再重试，
+// 避免不稳定的服务端让每一步都完整重试一轮。0 表示不限制（仅受单次调用的 MaxRetries 约束）
+func WithRetryBudget(n int) Option {
+	return func(a *Agent) {
+		a.retryBudget = n
+	}
+}
+
+// WithToolTimeout 设置工具的默认执行超时；实现了 tools.TimeoutHinter 的工具使用自己声明的超时。
+// d <= 0 表示不限制
+func WithToolTimeout(d time.Duration) Option {
+	return func(a *Agent) {
+		a.toolTimeout = d
+	}
+}
+
+// WithToolRateLimits 按工具名限制每秒调用次数（如 {"bash": 2}），key "*" 适用于其余工具。
+// 超过频率时在执行前等待，而不是拒绝调用
+func WithToolRateLimits(limits map[string]float64) Option {
+	return func(a *Agent) {
+		a.rateLimiter = newToolRateLimiter(limits)
+	}
+}
+
+// WithStepWarning 运行步数达到 maxSteps 的 ratio（如 0.8）时打印一次警告，提示任务接近步数上限；
+// ratio <= 0 或大于 1 时不提示
+func WithStepWarning(ratio float64) Option {
+	return func(a *Agent) {
+		a.stepWarnRatio = ratio
+	}
+}
+
+// WithQuiet 安静模式：只打印最终回答与错误，适合脚本与管道使用
+func WithQuiet(enabled bool) Option {
+	return func(a *Agent) {
+		a.quiet = enabled
+	}
+}
+
+// WithProgressIndicator 等待模型响应期间在 w 上显示 spinner 与已耗时（如 "Thinking... (12s)"）；
+// 只应在交互式终端中开启，nil 表示不显示
+func WithProgressIndicator(w io.Writer) Option {
+	return func(a *Agent) {
+		a.progress = w
+	}
+}
+
+// WithToolProgress 工具执行期间把进度消息（如 bash 前台命令的每行输出）交给 fn，
+// 只对实现了 tools.ProgressReporter 的工具生效；fn 可能被多个工具并发调用
+func WithToolProgress(fn func(tool, message string)) Option {
+	return func(a *Agent) {
+		a.toolProgress = fn
+	}
+}
+
+// WithToolResultSpill 超过 chars 个字符的工具结果保存到 workspace 的 .gopilot/spill 目录，
+// 模型收到首尾预览与文件路径，需要时再用 read_file 读取；文件在 Close 时删除；chars <= 0 表示不启用
+func WithToolResultSpill(chars int) Option {
+	return func(a *Agent) {
+		a.spillThreshold = chars
+	}
+}
+
+// WithCompactToolResults 发给模型的工具结果去掉装饰性的标题与多余空白；日志与终端输出不受影响
+func WithCompactToolResults(enabled bool) Option {
+	return func(a *Agent) {
+		a.compactResults = enabled
+	}
+}
+
+// WithDisabledTools 记录被配置关闭的工具；模型请求它们时返回说明原因的错误而不是 "Unknown tool"
+func WithDisabledTools(names ...string) Option {
+	return WithUnavailableTools("disabled by configuration", names...)
+}
+
+// WithUnavailableTools 记录因 reason（如 "unavailable because the workspace is read-only"）
+// 不可用的工具；模型请求它们时返回包含 reason 的错误
+func WithUnavailableTools(reason string, names ...string) Option {
+	return func(a *Agent) {
+		if a.disabledTools == nil {
+			a.disabledTools = map[string]string{}
+		}
+		for _, name := range names {
+			a.disabledTools[name] = reason
+		}
+	}
+}
+
+// WithThinking 控制模型思考内容（reasoning）的处理：display 为是否打印，store 为是否保存到会话历史。
+// 默认只打印不保存；不保存时它既不计入上下文 token，也不会被发回模型（发回另需 llm.WithResendThinking）
+func WithThinking(display, store bool) Option {
+	return func(a *Agent) {
+		a.showThinking = display
+		a.storeThinking = store
+	}
+}
+
+// WithSummaryMaxMessageChars 历史摘要时每条消息（如很长的工具输出）最多取 n 个字符交给摘要模型，
+// 让摘要更便宜、更快；n <= 0 表示不限制
+func WithSummaryMaxMessageChars(n int) Option {
+	return func(a *Agent) {
+		a.summaryMaxMessageChars = n
+	}
+}
+
+// WithSummaryPreserveCode 历史摘要时原样保留代码块与工具操作过的文件路径，只概括其余文字
+func WithSummaryPreserveCode(enabled bool) Option {
+	return func(a *Agent) {
+		a.summaryPreserveCode = enabled
+	}
+}
+
+// WithVision 声明模型支持图片输入：工具返回的图片附件将以多模态消息发送
+func WithVision(enabled bool) Option {
+	return func(a *Agent) {
+		a.vision = enabled
+	}
 }
 
 func NewAgent(
@@ -44,6 +307,7 @@ func NewAgent(
 	maxSteps int,
 	workspace string,
 	tokenLimit int,
+	opts ...Option,
 ) (*Agent, error) {
 
 	wp := workspace
@@ -51,8 +315,13 @@ func NewAgent(
 		wp = "./workspace"
 	}
 
-	abs, _ := filepath.Abs(wp)
-	_ = os.MkdirAll(abs, 0755)
+	abs, err := filepath.Abs(wp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace %s: %w", wp, err)
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create workspace %s: %w", abs, err)
+	}
 
 	// 向系统提示注入 workspace 信息
 	if !strings.Contains(systemPrompt, "Current Workspace") {
@@ -62,24 +331,36 @@ func NewAgent(
 		)
 	}
 
-	toolMap := map[string]tools.Tool{}
+	// 启动前检查每个工具的 schema，格式错误时直接失败并指出工具名
+	reg := tools.NewToolRegistry()
 	for _, t := range toolList {
-		toolMap[t.Name()] = t
+		if err := tools.ValidateTool(t); err != nil {
+			return nil, err
+		}
+		reg.Register(t)
 	}
 
 	ag := &Agent{
 		llm:          client,
 		systemPrompt: systemPrompt,
-		tools:        toolMap,
+		tools:        reg,
 		maxSteps:     maxSteps,
 		tokenLimit:   tokenLimit,
 		workspace:    abs,
 		messages: []schema.Message{
 			{Role: "system", Content: systemPrompt},
 		},
+		cost:         cost.NewTracker(cost.Budget{}),
+		showThinking: true,
 	}
 
-	log, err := logger.NewAgentLogger()
+	for _, opt := range opts {
+		opt(ag)
+	}
+	ag.injectScratchDir()
+	ag.guardSystemPrompt()
+
+	log, err := logger.NewAgentLogger(ag.logOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +369,23 @@ func NewAgent(
 	return ag, nil
 }
 
+// LogFilePath 当前运行的日志文件路径；尚未开始运行时返回空字符串
+func (a *Agent) LogFilePath() string {
+	return a.log.GetLogFilePath()
+}
+
+// TailLog 返回当前日志文件的最后 n 行
+func (a *Agent) TailLog(n int) ([]string, error) {
+	return a.log.Tail(n)
+}
+
+// LogPayload 把原始请求体写入当前日志文件（配合 llm.WithPayloadDump 使用）
+func (a *Agent) LogPayload(payload []byte) {
+	if err := a.log.LogPayload(payload); err != nil {
+		slog.Warn("Failed to log request payload", slog.String("error", err.Error()))
+	}
+}
+
 func (a *Agent) AddUserMessage(content string) {
 	a.messages = append(a.messages, schema.Message{
 		Role:    "user",
@@ -101,63 +399,129 @@ func (a *Agent) AddUserMessage(content string) {
 // ============================================================
 //
 
+// Run 执行任务直到模型给出最终回答（或提问、达到步数上限等），返回最终内容；
+// 结束原因与本次变更的文件见 LastResult。已有运行在进行时立即返回 ErrBusy
 func (a *Agent) Run(ctx context.Context) (string, error) {
+	if err := a.begin(); err != nil {
+		return "", err
+	}
+	defer a.end()
+	return a.runTracked(ctx)
+}
+
+// runTracked 执行一次运行并记录结构化结果；调用方须已通过 begin 占用 Agent
+func (a *Agent) runTracked(ctx context.Context) (string, error) {
+	a.changes = newChangeLedger()
+	content, err := a.run(ctx)
+
+	a.result = RunResult{
+		Content:      content,
+		Outcome:      a.outcome,
+		ChangedFiles: a.changes.files(),
+		ToolCalls:    a.changes.calls,
+		ToolFailures: a.changes.failures,
+	}
+	if !a.quiet {
+		printChangedFiles(a.result.ChangedFiles)
+	}
+	return content, err
+}
+
+func (a *Agent) run(ctx context.Context) (string, error) {
 	// 新建日志会话
 	if err := a.log.StartNewRun(); err != nil {
 		return "", err
 	}
 
-	fmt.Printf("%s📝 Log file: %s%s\n",
+	a.printf("%s📝 Log file: %s%s\n",
 		colors.DIM, a.log.GetLogFilePath(), colors.RESET)
 
+	// 本次运行的重试预算（摘要调用同样计入）
+	if a.retryBudget > 0 {
+		ctx = retry.WithBudget(ctx, retry.NewBudget(a.retryBudget))
+	}
+
 	step := 0
-	msgSummarizer := summarizer.NewSummarizer(a.llm, a.tokenLimit)
+	msgSummarizer := summarizer.NewSummarizer(a.llm, a.tokenLimit,
+		summarizer.WithPreserveCode(a.summaryPreserveCode),
+		summarizer.WithMaxMessageChars(a.summaryMaxMessageChars),
+		summarizer.WithQuiet(a.quiet))
 
 	for step < a.maxSteps {
 
 		// 触发摘要
 		newMsgs, err := msgSummarizer.SummarizeMessages(ctx, a.messages)
+		if errors.Is(err, summarizer.ErrCancelled) {
+			fmt.Printf("\n%s⚠️ Cancelled during summarization%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+			a.outcome = OutcomeCancelled
+			return "", err
+		}
 		if err != nil {
 			slog.Warn("Summarization failed", slog.String("err", err.Error()))
 		} else {
 			a.messages = newMsgs
 		}
+		a.ensureSystemPinned()
 
 		// 打印 Step 框
-		stepText := fmt.Sprintf("%s%s💭 Step %d/%d%s",
-			colors.BOLD, colors.BRIGHT_CYAN, step+1, a.maxSteps, colors.RESET)
+		// 标题附带当前上下文的 token 估算，便于观察上下文的增长
+		stepText := fmt.Sprintf("%s%s💭 Step %d/%d%s %s· ~%s/%s tokens%s",
+			colors.BOLD, colors.BRIGHT_CYAN, step+1, a.maxSteps, colors.RESET,
+			colors.DIM, formatTokenCount(tokenizer.EstimateTokens(a.messages)), formatTokenCount(a.tokenLimit), colors.RESET)
 		width := terminal.CalculateDisplayWidth(stepText)
-		box := 58
+		box := max(58, width+2)
 		padding := box - 1 - width
 
-		fmt.Printf("\n%s╭%s╮%s\n", colors.DIM, strings.Repeat("─", box), colors.RESET)
-		fmt.Printf("%s│%s %s%s%s│%s\n",
+		a.printf("\n%s╭%s╮%s\n", colors.DIM, strings.Repeat("─", box), colors.RESET)
+		a.printf("%s│%s %s%s%s│%s\n",
 			colors.DIM, colors.RESET,
 			stepText,
 			strings.Repeat(" ", padding),
 			colors.DIM, colors.RESET)
-		fmt.Printf("%s╰%s╯%s\n",
+		a.printf("%s╰%s╯%s\n",
 			colors.DIM, strings.Repeat("─", box), colors.RESET)
 
-		toolList := []tools.Tool{}
-		for _, t := range a.tools {
-			toolList = append(toolList, t)
+		if step+1 == a.stepWarnThreshold() {
+			fmt.Printf("%s⚠️ Step %d of %d: approaching the step limit; the run stops after step %d (agent.max_steps)%s\n",
+				colors.BRIGHT_YELLOW, step+1, a.maxSteps, a.maxSteps, colors.RESET)
 		}
-		reg := tools.NewToolRegistry()
-		for _, t := range toolList {
-			reg.Register(t)
+
+		// 花费达到硬上限：确认后才继续调用模型
+		if a.cost.Exceeded() && !a.approveOverBudget() {
+			msg := fmt.Sprintf("Stopped: estimated session cost $%.4f reached the budget cap of $%.4f.",
+				a.cost.Spent(), a.cost.NextCap())
+			fmt.Printf("\n%s⚠️ %s%s\n", colors.BRIGHT_YELLOW, msg, colors.RESET)
+			a.outcome = OutcomeStopped
+			return msg, nil
 		}
 
 		// 日志：请求
-		a.log.LogRequest(a.messages, toolList)
+		active := a.activeTools()
+		reqMsgs := a.requestMessages()
+		a.log.LogRequest(reqMsgs, active.List())
 
 		// 调用模型
-		resp, err := a.llm.Generate(ctx, a.messages, reg)
+		spin := a.startProgress()
+		resp, printed, err := a.generate(ctx, reqMsgs, active, spin)
+		spin.Stop()
 		if err != nil {
 			fmt.Printf("\n%s❌ LLM Error: %s%s\n", colors.BRIGHT_RED, err.Error(), colors.RESET)
+			a.outcome = OutcomeFailed
 			return err.Error(), err
 		}
 
+		a.recordUsage(resp.Usage)
+		a.lastChoices = resp.Choices
+
+		if fp := resp.SystemFingerprint; fp != "" {
+			if a.lastFingerprint != "" && fp != a.lastFingerprint {
+				slog.Warn("LLM system fingerprint changed; outputs may differ even with the same seed",
+					slog.String("previous", a.lastFingerprint),
+					slog.String("current", fp))
+			}
+			a.lastFingerprint = fp
+		}
+
 		// 日志：响应
 		a.log.LogResponse(
 			resp.Content,
@@ -166,28 +530,39 @@ func (a *Agent) Run(ctx context.Context) (string, error) {
 			resp.FinishReason,
 		)
 
-		// 加入 assistant 消息
-		a.messages = append(a.messages, schema.Message{
+		// 加入 assistant 消息（思考内容按配置决定是否保存）
+		assistantMsg := schema.Message{
 			Role:      "assistant",
 			Content:   resp.Content,
-			Thinking:  resp.Thinking,
 			ToolCalls: resp.ToolCalls,
-		})
+		}
+		if a.storeThinking {
+			assistantMsg.Thinking = resp.Thinking
+		}
+		a.messages = append(a.messages, assistantMsg)
 
-		// 打印思考
-		if resp.Thinking != "" {
-			fmt.Printf("\n%s🧠 Thinking:%s\n", colors.BOLD+colors.MAGENTA, colors.RESET)
-			fmt.Println("%s%s%s\n", colors.DIM, resp.Thinking, colors.RESET)
+		// 打印思考（流式输出时已经显示过）
+		if a.showThinking && resp.Thinking != "" && !printed.thinking {
+			a.printf("\n%s🧠 Thinking:%s\n", colors.BOLD+colors.MAGENTA, colors.RESET)
+			a.printf("%s%s%s\n", colors.DIM, resp.Thinking, colors.RESET)
 		}
 
-		// 打印模型输出
-		if resp.Content != "" {
+		// 打印模型输出（安静模式下只打印不再调用工具的最终回答，且不带标题）
+		switch {
+		case resp.Content == "" || printed.content:
+		case !a.quiet:
 			fmt.Printf("\n%s🤖 Assistant:%s\n", colors.BOLD+colors.BRIGHT_BLUE, colors.RESET)
 			fmt.Println(resp.Content)
+		case len(resp.ToolCalls) == 0:
+			fmt.Println(resp.Content)
 		}
 
 		// 若无工具调用，任务结束
 		if len(resp.ToolCalls) == 0 {
+			if n := len(a.Alternatives()); n > 0 {
+				a.printf("%s💡 %d alternative replies (/choices to show them)%s\n", colors.DIM, n, colors.RESET)
+			}
+			a.outcome = OutcomeDone
 			return resp.Content, nil
 		}
 
@@ -195,36 +570,39 @@ func (a *Agent) Run(ctx context.Context) (string, error) {
 		// 工具调用处理
 		// =========================
 
+		var images []schema.Image
+		var question string
 		for _, tc := range resp.ToolCalls {
 			fname := tc.Function.Name
 			args := tc.Function.Arguments
 
-			fmt.Printf("\n%s🔧 Tool Call:%s %s%s%s\n",
+			a.printf("\n%s🔧 Tool Call:%s %s%s%s\n",
 				colors.BRIGHT_YELLOW, colors.RESET, colors.BOLD, colors.CYAN, fname)
 
 			// 打印参数
-			fmt.Printf("%s   Arguments:%s\n", colors.DIM, colors.RESET)
+			a.printf("%s   Arguments:%s\n", colors.DIM, colors.RESET)
 			b, _ := json.MarshalIndent(args, "", "  ")
 			for _, line := range strings.Split(string(b), "\n") {
-				fmt.Printf("   %s%s%s\n", colors.DIM, line, colors.RESET)
+				a.printf("   %s%s%s\n", colors.DIM, line, colors.RESET)
 			}
 
-			tool, ok := a.tools[fname]
+			tool, ok := a.tools.Get(fname)
 			var result *tools.ToolResult
 
-			if !ok {
+			if reason, disabled := a.disabledTools[fname]; !ok && disabled {
+				result = &tools.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("Tool %s is %s and cannot be used in this session; continue without it", fname, reason),
+				}
+			} else if !ok {
 				result = &tools.ToolResult{
 					Success: false,
 					Error:   fmt.Sprintf("Unknown tool: %s", fname),
 				}
+			} else if denied := a.notPermitted(tool); denied != nil {
+				result = denied
 			} else {
-				result, err = tool.Execute(ctx, args)
-				if err != nil {
-					result = &tools.ToolResult{
-						Success: false,
-						Error:   err.Error(),
-					}
-				}
+				result = a.executeTool(ctx, tool, args)
 			}
 
 			// 日志：工具调用
@@ -236,42 +614,367 @@ func (a *Agent) Run(ctx context.Context) (string, error) {
 				result.Error,
 			)
 
-			// 打印执行结果
+			// 打印执行结果（按显示宽度截断，不会切断多字节字符）
 			if result.Success {
-				text := result.Content
-				if len(text) > 300 {
-					text = text[:300] + colors.DIM + "..." + colors.RESET
-				}
-				fmt.Printf("%s✓ Result:%s %s\n", colors.BRIGHT_GREEN, colors.RESET, text)
+				text := terminal.TruncateWithEllipsis(result.Content, resultPreviewWidth, colors.DIM+"..."+colors.RESET)
+				a.printf("%s✓ Result:%s %s\n", colors.BRIGHT_GREEN, colors.RESET, text)
 			} else {
-				fmt.Printf("%s✗ Error:%s %s%s%s\n",
+				a.printf("%s✗ Error:%s %s%s%s\n",
 					colors.BRIGHT_RED, colors.RESET, colors.RED, result.Error, colors.RESET)
 			}
 
+			a.changes.recordCall(result)
+			a.changes.recordStreak(fname, result)
+
 			// 添加到消息历史
-			retval := result.Content
+			retval := a.spillToolResult(fname, tc.ID, a.limitLargeOutput(fname, args, result))
+			if a.compactResults {
+				retval = tools.CompactContent(retval)
+			}
 			if !result.Success {
 				retval = "Error: " + result.Error
+				if result.ErrorKind != "" {
+					retval = fmt.Sprintf("Error [%s]: %s", result.ErrorKind, result.Error)
+				}
+			}
+			if result.Question != "" {
+				question = result.Question
+			}
+			if len(result.Attachments) > 0 {
+				note, imgs := a.prepareAttachments(result.Attachments)
+				retval += note
+				images = append(images, imgs...)
 			}
 
+			a.dedupeToolResult(fname, args, tc.ID, retval)
 			a.messages = append(a.messages, schema.Message{
 				Role:       "tool",
 				Content:    retval,
 				ToolCallID: tc.ID,
 				Name:       fname,
+				IsError:    !result.Success,
 			})
 		}
 
+		// 图片不能放进 tool 消息，统一在本轮工具结果之后追加一条 user 消息
+		if len(images) > 0 {
+			a.messages = append(a.messages, schema.Message{
+				Role:    "user",
+				Content: toolImagesNote,
+				Images:  images,
+			})
+		}
+
+		// 工具调用接连失败：继续下去多半只会重复同样的失败，结束运行并汇总失败原因
+		if msg, abort := a.tooManyFailures(); abort {
+			fmt.Printf("\n%s⚠️ %s%s\n", colors.BRIGHT_YELLOW, msg, colors.RESET)
+			a.outcome = OutcomeToolFailures
+			return msg, nil
+		}
+
+		// 模型向用户提问：本轮结束，问题作为结果返回，回答由下一条用户消息提供
+		if question != "" {
+			a.outcome = OutcomeNeedsInput
+			return question, nil
+		}
+
 		step++
 	}
 
 	msg := fmt.Sprintf("Task could not complete in %d steps.", a.maxSteps)
 	fmt.Printf("\n%s⚠️ %s%s\n", colors.BRIGHT_YELLOW, msg, colors.RESET)
+	a.outcome = OutcomeStepLimit
 	return msg, nil
 }
 
+// stepWarnThreshold 需要打印接近上限警告的步数（从 1 开始），未开启时返回 0
+func (a *Agent) stepWarnThreshold() int {
+	if a.stepWarnRatio <= 0 || a.stepWarnRatio > 1 || a.maxSteps <= 0 {
+		return 0
+	}
+	return max(int(math.Ceil(a.stepWarnRatio*float64(a.maxSteps))), 1)
+}
+
+// CostTracker 返回会话花费追踪器
+func (a *Agent) CostTracker() *cost.Tracker {
+	return a.cost
+}
+
+// currentPricing 配置覆盖的价格优先，否则按当前模型查内置价格表；未知时返回零值
+func (a *Agent) currentPricing() cost.Pricing {
+	if !a.pricing.IsZero() {
+		return a.pricing
+	}
+	if in, out, ok := llm.ModelPricing(a.llm.Model()); ok {
+		return cost.Pricing{InputPerMTok: in, OutputPerMTok: out}
+	}
+	return cost.Pricing{}
+}
+
+// recordUsage 累计用量并在越过警告阈值时提示
+func (a *Agent) recordUsage(usage *schema.Usage) {
+	if usage == nil {
+		return
+	}
+
+	pricing := a.currentPricing()
+	if pricing.IsZero() && a.cost.Unpriced() == 0 && a.cost.Budget() != (cost.Budget{}) {
+		slog.Warn("No pricing known for model; cost budget cannot be enforced",
+			slog.String("model", a.llm.Model()))
+	}
+
+	if a.cost.Add(usage.PromptTokens, usage.CompletionTokens, pricing) == cost.StatusWarn {
+		a.printf("\n%s💰 Estimated session cost $%.4f crossed the warning threshold of $%.4f%s\n",
+			colors.BRIGHT_YELLOW, a.cost.Spent(), a.cost.Budget().WarnUSD, colors.RESET)
+	}
+}
+
+// approveOverBudget 达到硬上限时请求确认；确认后放宽到下一个上限
+func (a *Agent) approveOverBudget() bool {
+	fmt.Printf("\n%s💰 Estimated session cost $%.4f reached the budget cap of $%.4f%s\n",
+		colors.BRIGHT_YELLOW, a.cost.Spent(), a.cost.NextCap(), colors.RESET)
+	if a.confirmBudget == nil || !a.confirmBudget(a.cost.Spent(), a.cost.NextCap()) {
+		return false
+	}
+	a.cost.Approve()
+	return true
+}
+
+// ensureSystemPinned 校验系统提示仍位于 messages[0]，被破坏时恢复并记录警告
+func (a *Agent) ensureSystemPinned() {
+	if err := history.Validate(a.messages); err != nil {
+		slog.Warn("System prompt invariant violated; restoring", slog.String("err", err.Error()))
+		a.messages = history.PinSystem(a.messages, schema.Message{Role: "system", Content: a.systemPrompt})
+	}
+}
+
+// executeTool 执行单个工具调用；被 InterruptTool 中断时返回 tools.ErrorKindInterrupted 结果，运行继续
+func (a *Agent) executeTool(ctx context.Context, tool tools.Tool, args map[string]any) *tools.ToolResult {
+	ctx, finish := a.trackTool(ctx, tool.Name())
+	res := a.executeWithTimeout(ctx, tool, args)
+	if finish() {
+		slog.Info("Tool interrupted by user", slog.String("tool", tool.Name()))
+		return interruptedResult(tool.Name(), res)
+	}
+	return res
+}
+
+// toolCancelGrace 外部取消（用户中断、取消任务）后等待工具收尾的时间，
+// 以便带回中断前的输出；超过后不再等待不响应取消的工具
+const toolCancelGrace = time.Second
+
+// executeWithTimeout 超过工具超时（见 tools.ToolTimeout）或 ctx 被取消时返回失败结果，
+// 不等待不响应取消的工具
+func (a *Agent) executeWithTimeout(ctx context.Context, tool tools.Tool, args map[string]any) *tools.ToolResult {
+	// 限流等待不计入工具超时
+	if err := a.rateLimiter.wait(ctx, tool.Name()); err != nil {
+		return &tools.ToolResult{Success: false, Error: fmt.Sprintf("tool %s was not run: %v", tool.Name(), err)}
+	}
+
+	timeout := tools.ToolTimeout(tool, a.toolTimeout)
+	var tctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		tctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		tctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	done := make(chan *tools.ToolResult, 1)
+	go func() {
+		done <- a.runTool(tctx, tool, args)
+	}()
+
+	select {
+	case res := <-done:
+		return res
+	case <-tctx.Done():
+		if ctx.Err() != nil {
+			// 外部取消（如用户中断）：短暂等待工具收尾，不响应取消的工具留在后台
+			select {
+			case res := <-done:
+				return res
+			case <-time.After(toolCancelGrace):
+				slog.Warn("Tool did not stop after cancellation", slog.String("tool", tool.Name()))
+				return &tools.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("tool %s did not stop after cancellation: %v", tool.Name(), ctx.Err()),
+				}
+			}
+		}
+		slog.Warn("Tool timed out", slog.String("tool", tool.Name()), slog.Duration("timeout", timeout))
+		return &tools.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("tool %s timed out after %s", tool.Name(), timeout),
+		}
+	}
+}
+
+// runTool 调用工具：错误、nil 结果和 panic 都转换为失败的 ToolResult，
+// 保证单个工具的缺陷不会让整个会话崩溃
+func (a *Agent) runTool(ctx context.Context, tool tools.Tool, args map[string]any) (result *tools.ToolResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Tool panicked",
+				slog.String("tool", tool.Name()),
+				slog.Any("panic", r),
+				slog.String("stack", stackSnippet(panicStackLines)),
+			)
+			result = &tools.ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("tool %s panicked: %v", tool.Name(), r),
+			}
+		}
+	}()
+
+	var res *tools.ToolResult
+	var err error
+	if pr, ok := tool.(tools.ProgressReporter); ok && a.toolProgress != nil {
+		name := tool.Name()
+		res, err = pr.ExecuteWithProgress(ctx, args, func(message string) {
+			a.toolProgress(name, message)
+		})
+	} else {
+		res, err = tool.Execute(ctx, args)
+	}
+	if err != nil {
+		return &tools.ToolResult{Success: false, Error: err.Error()}
+	}
+	if res == nil {
+		return &tools.ToolResult{Success: false, Error: fmt.Sprintf("tool %s returned no result", tool.Name())}
+	}
+	return res
+}
+
+// stackSnippet 返回当前 goroutine 调用栈的前 n 行
+func stackSnippet(n int) string {
+	lines := strings.Split(string(debug.Stack()), "\n")
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prepareAttachments 处理工具附件：支持视觉时图片作为多模态内容返回，
+// 其余附件（或不支持视觉时）降级为追加到工具结果的文字描述
+func (a *Agent) prepareAttachments(atts []tools.Attachment) (string, []schema.Image) {
+	var sb strings.Builder
+	var images []schema.Image
+
+	for _, att := range atts {
+		data, mimeType, err := att.Load()
+		if err != nil {
+			slog.Warn("Failed to load tool attachment",
+				slog.String("name", att.DisplayName()),
+				slog.String("err", err.Error()))
+			fmt.Fprintf(&sb, "\n%s (not sent: %v)", att.Describe(), err)
+			continue
+		}
+
+		att.MIMEType = mimeType
+		if a.vision && tools.IsImage(mimeType) {
+			images = append(images, schema.Image{Name: att.DisplayName(), MIMEType: mimeType, Data: data})
+			fmt.Fprintf(&sb, "\n%s (sent as image in the next message)", att.Describe())
+			continue
+		}
+		sb.WriteString("\n" + att.Describe())
+	}
+
+	return sb.String(), images
+}
+
+// Close 释放工具持有的资源（实现了 io.Closer 的工具，如 watch_file 的文件监听），并删除会话的临时目录
+func (a *Agent) Close() error {
+	var errs []error
+	for _, t := range a.tools.List() {
+		if c, ok := t.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close %s: %w", t.Name(), err))
+			}
+		}
+	}
+	if a.scratch != nil {
+		if err := a.scratch.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := a.removeSpilled(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// TokenLimit 返回触发历史摘要的 token 阈值
+func (a *Agent) TokenLimit() int {
+	return a.tokenLimit
+}
+
+// formatTokenCount 以紧凑形式显示 token 数：950、12.4k、80k、1.2M
+func formatTokenCount(n int) string {
+	switch {
+	case n < 1000:
+		return strconv.Itoa(n)
+	case n < 999_950: // 四舍五入后仍小于 1000k
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000), ".0") + "k"
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1_000_000), ".0") + "M"
+	}
+}
+
+// SetTokenLimit 更新触发历史摘要的 token 阈值（下一次 Run 起生效）
+func (a *Agent) SetTokenLimit(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("token limit must be > 0, got %d", n)
+	}
+	a.tokenLimit = n
+	return nil
+}
+
+// Reset 清空会话历史，仅保留系统提示
+func (a *Agent) Reset() {
+	a.messages = []schema.Message{
+		{Role: "system", Content: a.systemPrompt},
+	}
+	a.plan = ""
+	a.instruction = ""
+	a.resultKeys = nil
+}
+
+// LoadHistory 用保存的会话历史替换当前历史（/load）：系统提示保留当前的版本，
+// 计划、临时指令等会话状态与 Reset 一样清空
+func (a *Agent) LoadHistory(messages []schema.Message) {
+	system := a.messages[0]
+	a.Reset()
+	a.messages = history.PinSystem(messages, system)
+}
+
+// CompactToUserTurns 丢弃 assistant 回复与工具结果，只保留系统提示和用户消息：
+// 与摘要不同，被丢弃的内容不会以任何形式保留。返回丢弃的消息数
+func (a *Agent) CompactToUserTurns() int {
+	msgs, dropped := history.KeepUserTurns(a.messages)
+	a.messages = msgs
+	a.ensureSystemPinned()
+	return dropped
+}
+
 func (a *Agent) History() []schema.Message {
 	out := make([]schema.Message, len(a.messages))
 	copy(out, a.messages)
 	return out
 }
+
+// startProgress 开启等待模型响应的 spinner；未开启进度显示时返回 nil（Stop 对 nil 安全）
+func (a *Agent) startProgress() *terminal.Spinner {
+	if a.progress == nil || a.quiet {
+		return nil
+	}
+	return terminal.StartSpinner(a.progress, colors.DIM+"Thinking..."+colors.RESET, 0)
+}
+
+// printf 打印过程信息（Step 框、工具调用、日志路径等）；安静模式下不输出
+func (a *Agent) printf(format string, args ...any) {
+	if !a.quiet {
+		fmt.Printf(format, args...)
+	}
+}
@@ -0,0 +1,25 @@
+package agent
+
+import "errors"
+
+// ErrBusy 已有一次运行（Run / RunPlan / ExecutePlan / DryPlan / Compare）正在进行。
+// Agent 的会话历史不支持并发修改，嵌入方应等待上一次运行结束后再调用
+var ErrBusy = errors.New("agent busy: another run is in progress")
+
+// begin 标记运行开始；已有运行时返回 ErrBusy，且不修改任何状态
+func (a *Agent) begin() error {
+	if !a.busy.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	return nil
+}
+
+// end 标记运行结束
+func (a *Agent) end() {
+	a.busy.Store(false)
+}
+
+// Busy 是否有运行正在进行
+func (a *Agent) Busy() bool {
+	return a.busy.Load()
+}
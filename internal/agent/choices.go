@@ -0,0 +1,17 @@
+package agent
+
+import "gopilot-cli/internal/schema"
+
+// LastChoices 返回最近一次模型响应的全部候选（第一个即 Agent 采用并写入历史的回复）；
+// 只有客户端请求了多个候选（llm.WithChoices）时才会多于一个
+func (a *Agent) LastChoices() []schema.Choice {
+	return a.lastChoices
+}
+
+// Alternatives 返回最近一次响应中未被采用的候选回复（不含第一个）
+func (a *Agent) Alternatives() []schema.Choice {
+	if len(a.lastChoices) <= 1 {
+		return nil
+	}
+	return a.lastChoices[1:]
+}
@@ -1,7 +1,15 @@
 package colors
 
-// Terminal color and style codes used by the agent.
-const (
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Terminal color and style codes used by the agent and the CLI.
+// 取值由当前主题决定（默认 default 主题），调用 Apply 切换；mono 主题下全部为空字符串。
+var (
 	RESET = "\033[0m"
 	BOLD  = "\033[1m"
 	DIM   = "\033[2m"
@@ -13,11 +21,138 @@ const (
 	MAGENTA = "\033[35m"
 	CYAN    = "\033[36m"
 
+	BRIGHT_BLACK   = "\033[90m"
 	BRIGHT_RED     = "\033[91m"
 	BRIGHT_GREEN   = "\033[92m"
 	BRIGHT_YELLOW  = "\033[93m"
 	BRIGHT_BLUE    = "\033[94m"
 	BRIGHT_MAGENTA = "\033[95m"
 	BRIGHT_CYAN    = "\033[96m"
+	BRIGHT_WHITE   = "\033[97m"
 )
 
+// slots 颜色名 → 对应的变量，主题与覆盖配置都按这些名字引用颜色
+var slots = map[string]*string{
+	"reset": &RESET, "bold": &BOLD, "dim": &DIM,
+	"red": &RED, "green": &GREEN, "yellow": &YELLOW, "blue": &BLUE, "magenta": &MAGENTA, "cyan": &CYAN,
+	"bright_black": &BRIGHT_BLACK, "bright_red": &BRIGHT_RED, "bright_green": &BRIGHT_GREEN,
+	"bright_yellow": &BRIGHT_YELLOW, "bright_blue": &BRIGHT_BLUE, "bright_magenta": &BRIGHT_MAGENTA,
+	"bright_cyan": &BRIGHT_CYAN, "bright_white": &BRIGHT_WHITE,
+}
+
+// Theme 颜色名 → SGR 参数（如 "33"、"38;5;130"），空字符串表示不输出转义序列
+type Theme map[string]string
+
+var defaultTheme = Theme{
+	"reset": "0", "bold": "1", "dim": "2",
+	"red": "31", "green": "32", "yellow": "33", "blue": "34", "magenta": "35", "cyan": "36",
+	"bright_black": "90", "bright_red": "91", "bright_green": "92", "bright_yellow": "93",
+	"bright_blue": "94", "bright_magenta": "95", "bright_cyan": "96", "bright_white": "97",
+}
+
+// themes 内置主题；未列出的颜色沿用 default
+var themes = map[string]Theme{
+	"default": {},
+	// light 浅色背景：亮色换成深色，黄色 / 白色换成在白底上可读的颜色
+	"light": {
+		"yellow": "38;5;130", "cyan": "38;5;30",
+		"bright_red": "31", "bright_green": "32", "bright_yellow": "38;5;130", "bright_blue": "34",
+		"bright_magenta": "35", "bright_cyan": "38;5;30", "bright_white": "30",
+	},
+	// mono 不输出任何颜色与样式
+	"mono": monoTheme(),
+}
+
+func monoTheme() Theme {
+	t := Theme{}
+	for name := range defaultTheme {
+		t[name] = ""
+	}
+	return t
+}
+
+var sgrParams = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// ThemeNames 返回内置主题名（已排序）
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply 切换到内置主题 name（空字符串等同 default），再应用按颜色名的覆盖。
+// 覆盖值可以是另一个颜色名（如 "blue"）、SGR 参数（如 "38;5;208"），或 "none" 表示不着色
+func Apply(name string, overrides map[string]string) error {
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+
+	params := Theme{}
+	for color, p := range defaultTheme {
+		params[color] = p
+	}
+	for color, p := range theme {
+		params[color] = p
+	}
+	for color, value := range overrides {
+		if _, ok := slots[color]; !ok {
+			return fmt.Errorf("unknown color %q in theme overrides", color)
+		}
+		p, err := resolveOverride(value, params)
+		if err != nil {
+			return fmt.Errorf("color %q: %w", color, err)
+		}
+		params[color] = p
+	}
+
+	for color, slot := range slots {
+		*slot = code(params[color])
+	}
+	// 没有任何颜色时 reset 也不需要输出
+	if allEmpty(params) {
+		RESET = ""
+	}
+	return nil
+}
+
+// Enabled 当前主题是否会输出转义序列
+func Enabled() bool {
+	return RESET != ""
+}
+
+func resolveOverride(value string, params Theme) (string, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	switch {
+	case value == "none" || value == "":
+		return "", nil
+	case sgrParams.MatchString(value):
+		return value, nil
+	}
+	if _, ok := slots[value]; ok {
+		return params[value], nil
+	}
+	return "", fmt.Errorf("invalid value %q (want a color name, SGR parameters such as \"38;5;208\", or none)", value)
+}
+
+func code(params string) string {
+	if params == "" {
+		return ""
+	}
+	return "\033[" + params + "m"
+}
+
+func allEmpty(params Theme) bool {
+	for color, p := range params {
+		if color != "reset" && p != "" {
+			return false
+		}
+	}
+	return true
+}
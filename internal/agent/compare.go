@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+// toolImagesNote 工具返回图片时追加的 user 消息内容，它不是用户输入
+const toolImagesNote = "[Images returned by the tool calls above]"
+
+// ErrNothingToCompare 会话中还没有用户消息
+var ErrNothingToCompare = errors.New("no previous request to compare; send a message first")
+
+// Comparison /compare 的结果
+type Comparison struct {
+	// Prompt 最近一条用户消息
+	Prompt string
+	// Current 本会话对该消息的最终回复（尚未回复时为空）
+	Current string
+	// Alternate 另一个模型的回复
+	Alternate string
+}
+
+// Compare 用 client（通常是另一个模型）重新回答最近一条用户消息：上下文为截至该消息的历史，
+// 请求设置 tool_choice=none，不执行工具；会话历史、计划与成本统计都不受影响
+func (a *Agent) Compare(ctx context.Context, client *llm.Client) (*Comparison, error) {
+	if err := a.begin(); err != nil {
+		return nil, err
+	}
+	defer a.end()
+	a.ensureSystemPinned()
+	idx := a.lastUserTurn()
+	if idx < 0 {
+		return nil, ErrNothingToCompare
+	}
+
+	cmp := &Comparison{Prompt: a.messages[idx].Content}
+	for _, m := range a.messages[idx+1:] {
+		if m.Role == "assistant" && m.Content != "" && len(m.ToolCalls) == 0 {
+			cmp.Current = m.Content
+		}
+	}
+
+	msgs := make([]schema.Message, idx+1)
+	copy(msgs, a.messages[:idx+1])
+	resp, err := client.Generate(ctx, msgs, a.tools, llm.WithToolChoice(llm.ToolChoiceNone))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.ToolCalls) > 0 {
+		slog.Warn("Ignoring tool calls returned for a comparison", slog.Int("count", len(resp.ToolCalls)))
+	}
+	if resp.Content == "" {
+		return nil, errors.New("the model returned an empty reply")
+	}
+	cmp.Alternate = resp.Content
+	return cmp, nil
+}
+
+// lastUserTurn 最近一条由用户输入的消息下标，没有时返回 -1
+func (a *Agent) lastUserTurn() int {
+	for i := len(a.messages) - 1; i > 0; i-- {
+		if m := a.messages[i]; m.Role == "user" && m.Content != toolImagesNote {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package cost
+
+// Pricing 每百万 token 的价格（美元）
+type Pricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// IsZero 未设置价格
+func (p Pricing) IsZero() bool {
+	return p.InputPerMTok == 0 && p.OutputPerMTok == 0
+}
+
+// Cost 按价格估算一次调用的花费
+func (p Pricing) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputPerMTok + float64(completionTokens)*p.OutputPerMTok) / 1e6
+}
+
+// Budget 会话花费预算（美元），0 表示不启用对应阈值
+type Budget struct {
+	WarnUSD    float64
+	HardCapUSD float64
+}
+
+// Status 记录一次用量后的预算状态
+type Status int
+
+const (
+	// StatusOK 未触及任何阈值（或阈值已处理过）
+	StatusOK Status = iota
+	// StatusWarn 首次越过警告阈值
+	StatusWarn
+	// StatusHardCap 达到硬上限，需要确认后才能继续
+	StatusHardCap
+)
+
+// Tracker 累计一次会话的 token 用量与估算花费，并检查预算。
+// 达到硬上限且用户确认继续后（Approve），下一次确认点提高一个 HardCapUSD。
+type Tracker struct {
+	budget Budget
+
+	promptTokens     int
+	completionTokens int
+	spent            float64
+	unpriced         int // 无法定价（模型价格未知）的调用次数
+
+	warned  bool
+	nextCap float64
+}
+
+// NewTracker 创建花费追踪器
+func NewTracker(budget Budget) *Tracker {
+	return &Tracker{budget: budget, nextCap: budget.HardCapUSD}
+}
+
+// Add 记录一次调用的用量；pricing 为零值表示价格未知，只累计 token
+func (t *Tracker) Add(promptTokens, completionTokens int, pricing Pricing) Status {
+	t.promptTokens += promptTokens
+	t.completionTokens += completionTokens
+	if pricing.IsZero() {
+		t.unpriced++
+	} else {
+		t.spent += pricing.Cost(promptTokens, completionTokens)
+	}
+
+	if t.Exceeded() {
+		t.warned = true
+		return StatusHardCap
+	}
+	if !t.warned && t.budget.WarnUSD > 0 && t.spent >= t.budget.WarnUSD {
+		t.warned = true
+		return StatusWarn
+	}
+	return StatusOK
+}
+
+// Exceeded 是否已达到硬上限、需要确认后才能继续
+func (t *Tracker) Exceeded() bool {
+	return t.nextCap > 0 && t.spent >= t.nextCap
+}
+
+// Approve 用户确认超出硬上限后继续：下一个确认点为当前花费再加一个 HardCapUSD
+func (t *Tracker) Approve() {
+	if t.budget.HardCapUSD > 0 {
+		t.nextCap = t.spent + t.budget.HardCapUSD
+	}
+}
+
+// Spent 已估算的累计花费（美元）
+func (t *Tracker) Spent() float64 {
+	return t.spent
+}
+
+// Tokens 累计的 prompt / completion token 数
+func (t *Tracker) Tokens() (prompt, completion int) {
+	return t.promptTokens, t.completionTokens
+}
+
+// Unpriced 价格未知、未计入花费的调用次数
+func (t *Tracker) Unpriced() int {
+	return t.unpriced
+}
+
+// Budget 当前预算配置
+func (t *Tracker) Budget() Budget {
+	return t.budget
+}
+
+// NextCap 下一次需要确认的花费点（0 表示未设置硬上限）
+func (t *Tracker) NextCap() float64 {
+	return t.nextCap
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+)
+
+//
+// 重复工具结果去重：同一个大文件读了多次时，历史中堆积多份相同的内容，既占上下文也增加摘要的负担。
+// 追加新的工具结果时，工具名、参数与内容都相同的较早结果被替换为简短的引用，只保留最新的一份
+//
+
+const (
+	// dedupeMinChars 只对不短于该字符数的结果去重，短结果替换为引用省不了多少
+	dedupeMinChars = 200
+	// dedupeArgsChars 引用说明中参数摘要的最大字符数
+	dedupeArgsChars = 120
+)
+
+// WithDedupeToolResults 开启后，工具名、参数与结果都相同的较早工具结果在历史中被替换为指向最新结果的引用
+func WithDedupeToolResults(enabled bool) Option {
+	return func(a *Agent) {
+		a.dedupeResults = enabled
+	}
+}
+
+// toolResultKey 工具名 + 参数 + 内容的哈希（json.Marshal 按键排序，参数顺序不影响结果）
+func toolResultKey(tool string, args map[string]any, content string) string {
+	argsJSON, _ := json.Marshal(args)
+	h := sha256.New()
+	h.Write([]byte(tool))
+	h.Write([]byte{0})
+	h.Write(argsJSON)
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dedupeToolResult 在追加 callID 的工具结果之前调用：把历史中与之完全相同的较早 tool 消息替换为引用，
+// 并记录本次结果的哈希，供之后的相同结果使用
+func (a *Agent) dedupeToolResult(tool string, args map[string]any, callID, content string) {
+	if !a.dedupeResults || utf8.RuneCountInString(content) < dedupeMinChars {
+		return
+	}
+	key := toolResultKey(tool, args, content)
+	if a.resultKeys == nil {
+		a.resultKeys = make(map[string]string)
+	}
+
+	for i, m := range a.messages {
+		if m.Role != "tool" || a.resultKeys[m.ToolCallID] != key {
+			continue
+		}
+		a.messages[i].Content = dedupeNote(tool, args, utf8.RuneCountInString(m.Content))
+		delete(a.resultKeys, m.ToolCallID)
+		slog.Debug("Elided duplicate tool result", slog.String("tool", tool), slog.String("call_id", m.ToolCallID))
+	}
+	a.resultKeys[callID] = key
+}
+
+// dedupeNote 替换较早结果的引用说明
+func dedupeNote(tool string, args map[string]any, chars int) string {
+	desc, _ := json.Marshal(args)
+	summary := string(desc)
+	if r := []rune(summary); len(r) > dedupeArgsChars {
+		summary = string(r[:dedupeArgsChars]) + "..."
+	}
+	return fmt.Sprintf("[Duplicate result elided (%d characters): a later %s call with the same arguments %s returned identical output; see that later result.]",
+		chars, tool, summary)
+}
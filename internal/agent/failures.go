@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"gopilot-cli/internal/tools"
+)
+
+//
+// 连续失败的工具调用：工作空间不对、没有权限、环境缺少命令时，每次工具调用都会失败，
+// 模型往往反复尝试直到用完 max_steps。连续失败超过阈值时结束运行，并汇总这些失败供用户排查
+//
+
+// failureErrorChars 诊断中每条错误保留的最大字符数
+const failureErrorChars = 200
+
+// failedCall 一次失败的工具调用
+type failedCall struct {
+	tool string
+	err  string
+}
+
+// WithMaxConsecutiveToolFailures 连续 n 次以上工具调用失败（中间没有成功的调用）时结束运行（OutcomeToolFailures）；0 表示不限制
+func WithMaxConsecutiveToolFailures(n int) Option {
+	return func(a *Agent) {
+		a.maxConsecutiveFailures = max(n, 0)
+	}
+}
+
+// recordStreak 更新连续失败记录：成功的调用清空记录
+func (l *changeLedger) recordStreak(tool string, result *tools.ToolResult) {
+	if result.Success {
+		l.streak = nil
+		return
+	}
+	l.streak = append(l.streak, failedCall{tool: tool, err: result.Error})
+}
+
+// tooManyFailures 连续失败次数超过阈值时返回诊断说明
+func (a *Agent) tooManyFailures() (string, bool) {
+	if a.maxConsecutiveFailures <= 0 || len(a.changes.streak) <= a.maxConsecutiveFailures {
+		return "", false
+	}
+	return failureDiagnostic(a.changes.streak, a.maxConsecutiveFailures), true
+}
+
+// failureDiagnostic 汇总连续失败的调用：相同工具与错误只列一次并标注次数
+func failureDiagnostic(streak []failedCall, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Aborted: the last %d tool calls all failed (agent.max_consecutive_tool_failures is %d). "+
+		"This usually means the workspace, permissions or environment are wrong rather than the task.\n\nFailures:\n",
+		len(streak), limit)
+
+	type group struct {
+		failedCall
+		count int
+	}
+	var groups []*group
+	index := map[failedCall]*group{}
+	for _, f := range streak {
+		f.err = firstLine(f.err)
+		if r := []rune(f.err); len(r) > failureErrorChars {
+			f.err = string(r[:failureErrorChars]) + "..."
+		}
+		if g, ok := index[f]; ok {
+			g.count++
+			continue
+		}
+		g := &group{failedCall: f, count: 1}
+		index[f] = g
+		groups = append(groups, g)
+	}
+	for _, g := range groups {
+		fmt.Fprintf(&b, "- %s", g.tool)
+		if g.count > 1 {
+			fmt.Fprintf(&b, " (%d×)", g.count)
+		}
+		fmt.Fprintf(&b, ": %s\n", g.err)
+	}
+	b.WriteString("\nFix the cause and ask again; the conversation so far is kept.")
+	return b.String()
+}
+
+// firstLine 返回文本的第一行（去掉首尾空白）
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
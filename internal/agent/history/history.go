@@ -0,0 +1,61 @@
+package history
+
+import (
+	"fmt"
+
+	"gopilot-cli/internal/schema"
+)
+
+// 消息历史的不变量：messages[0] 始终是系统提示，且历史中没有其他 system 消息。
+// 所有裁剪、摘要路径在修改历史后都应通过 PinSystem 恢复该不变量。
+
+// Validate 检查消息历史是否满足系统提示不变量
+func Validate(messages []schema.Message) error {
+	if len(messages) == 0 {
+		return fmt.Errorf("message history is empty: missing system prompt")
+	}
+	if messages[0].Role != "system" {
+		return fmt.Errorf("messages[0] must be the system prompt, got role %q", messages[0].Role)
+	}
+	for i, m := range messages[1:] {
+		if m.Role == "system" {
+			return fmt.Errorf("unexpected system message at index %d", i+1)
+		}
+	}
+	return nil
+}
+
+// PinSystem 返回以 system 为首条消息的历史：
+// 原有的 system 消息（无论位于何处）都会被移除，再把 system 放在 index 0。
+// 不修改传入的切片。
+func PinSystem(messages []schema.Message, system schema.Message) []schema.Message {
+	out := make([]schema.Message, 0, len(messages)+1)
+	out = append(out, system)
+	for _, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// KeepUserTurns 丢弃 assistant 与 tool 消息（连同其中的 tool_calls），只保留系统提示和用户消息，
+// 返回新的历史与丢弃的消息数。不修改传入的切片。
+func KeepUserTurns(messages []schema.Message) ([]schema.Message, int) {
+	out := make([]schema.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == "system" || m.Role == "user" {
+			out = append(out, m)
+		}
+	}
+	return out, len(messages) - len(out)
+}
+
+// SystemPrompt 返回历史中的系统提示；不满足不变量时返回 false
+func SystemPrompt(messages []schema.Message) (schema.Message, bool) {
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return schema.Message{}, false
+	}
+	return messages[0], true
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+
+	"gopilot-cli/internal/schema"
+)
+
+// instructionPrefix 临时指令消息的前缀，便于模型区分它与常驻 system prompt
+const instructionPrefix = "[Instruction for this request only]\n"
+
+// SetInstruction 设置只作用于下一次模型请求的临时指令（如 "be concise"）。
+// 指令以 system 消息附加在该次请求末尾，发送后即移除，不写入会话历史；传空字符串取消。
+func (a *Agent) SetInstruction(text string) {
+	a.instruction = strings.TrimSpace(text)
+}
+
+// PendingInstruction 返回尚未发送的临时指令（没有时为空）
+func (a *Agent) PendingInstruction() string {
+	return a.instruction
+}
+
+// requestMessages 返回本次请求实际发送的消息：有临时指令时附加在末尾并将其清空
+func (a *Agent) requestMessages() []schema.Message {
+	if a.instruction == "" {
+		return a.messages
+	}
+	msgs := append(slices.Clip(a.messages), schema.Message{
+		Role:    "system",
+		Content: instructionPrefix + a.instruction,
+	})
+	a.instruction = ""
+	return msgs
+}
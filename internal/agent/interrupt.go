@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gopilot-cli/internal/tools"
+)
+
+//
+// 中断单个工具：交互模式下前台命令运行太久时，用户可以只取消当前工具（如按 Ctrl-C），
+// 模型收到"被用户中断"的结果后继续本次运行，而不是结束整个会话
+//
+
+// runningTool 正在执行的工具及其取消函数
+type runningTool struct {
+	name        string
+	cancel      context.CancelFunc
+	interrupted atomic.Bool
+}
+
+// toolTracker 记录当前正在执行的工具，供其他 goroutine（信号处理、输入处理）中断
+type toolTracker struct {
+	mu      sync.Mutex
+	current *runningTool
+}
+
+// InterruptTool 取消正在执行的工具（只影响这一个工具，运行继续），返回工具名；
+// 没有工具在执行、或当前工具已被中断过（仍在收尾）时返回 false，调用方可以改为取消整个任务。
+// 可以在任意 goroutine 中调用
+func (a *Agent) InterruptTool() (string, bool) {
+	a.running.mu.Lock()
+	defer a.running.mu.Unlock()
+	t := a.running.current
+	if t == nil || !t.interrupted.CompareAndSwap(false, true) {
+		return "", false
+	}
+	t.cancel()
+	return t.name, true
+}
+
+// trackTool 为一次工具调用创建可单独取消的 context，并登记为当前工具；
+// 返回的 finish 注销登记，并报告该工具是否被 InterruptTool 中断
+func (a *Agent) trackTool(ctx context.Context, name string) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &runningTool{name: name, cancel: cancel}
+
+	a.running.mu.Lock()
+	a.running.current = t
+	a.running.mu.Unlock()
+
+	return ctx, func() bool {
+		a.running.mu.Lock()
+		if a.running.current == t {
+			a.running.current = nil
+		}
+		a.running.mu.Unlock()
+		cancel()
+		return t.interrupted.Load()
+	}
+}
+
+// interruptedResult 被用户中断的工具调用的结果；保留中断前已有的输出
+func interruptedResult(name string, partial *tools.ToolResult) *tools.ToolResult {
+	msg := fmt.Sprintf("Tool %s was interrupted by the user before it finished. "+
+		"Do not simply retry it; choose a faster or narrower approach, or ask the user how to proceed.", name)
+	if partial != nil {
+		if out := partial.Stdout + partial.Stderr; out != "" {
+			msg += "\n\nOutput before the interrupt:\n" + tools.TruncateTextByTokens(out, interruptedOutputTokens)
+		}
+	}
+	return &tools.ToolResult{Success: false, Error: msg, ErrorKind: tools.ErrorKindInterrupted}
+}
+
+// interruptedOutputTokens 中断结果中保留的输出 token 上限
+const interruptedOutputTokens = 4000
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/tools"
+)
+
+// LargeOutputAction 前台 bash 命令的输出超过阈值时如何交给模型
+type LargeOutputAction string
+
+const (
+	// LargeOutputFeed 照常发送（仍受 bash 自身的行数与 token 截断约束）
+	LargeOutputFeed LargeOutputAction = "feed"
+	// LargeOutputSummarize 只发送大小、行数与首尾若干行
+	LargeOutputSummarize LargeOutputAction = "summarize"
+	// LargeOutputTruncate 只发送开头 largeOutputTruncateLines 行
+	LargeOutputTruncate LargeOutputAction = "truncate"
+)
+
+// largeOutputTruncateLines truncate 保留的行数
+const largeOutputTruncateLines = 30
+
+// ParseLargeOutputAction 解析配置中的处理方式（feed / summarize / truncate）
+func ParseLargeOutputAction(s string) (LargeOutputAction, error) {
+	switch a := LargeOutputAction(strings.ToLower(strings.TrimSpace(s))); a {
+	case LargeOutputFeed, LargeOutputSummarize, LargeOutputTruncate:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid large output action %q (want feed, summarize or truncate)", s)
+}
+
+// LargeOutputFunc 决定如何处理超过阈值的命令输出；chars、lines 为捕获的 stdout + stderr 的大小
+type LargeOutputFunc func(command string, chars, lines int) LargeOutputAction
+
+// WithLargeOutputPolicy 前台 bash 命令捕获的输出超过 thresholdChars 个字符时，由 decide 选择
+// 照常发送、摘要或大幅截断（交互模式下通常询问用户）。thresholdChars <= 0 或 decide 为 nil 时不检查
+func WithLargeOutputPolicy(thresholdChars int, decide LargeOutputFunc) Option {
+	return func(a *Agent) {
+		a.largeOutputChars = thresholdChars
+		a.decideLargeOutput = decide
+	}
+}
+
+// limitLargeOutput 返回发给模型的工具结果内容：前台 bash 输出超过阈值时按选择的方式处理
+func (a *Agent) limitLargeOutput(tool string, args map[string]any, result *tools.ToolResult) string {
+	if a.largeOutputChars <= 0 || a.decideLargeOutput == nil || tool != "bash" || result.BashID != "" {
+		return result.Content
+	}
+	output := result.Stdout + result.Stderr
+	chars := utf8.RuneCountInString(output)
+	if chars <= a.largeOutputChars {
+		return result.Content
+	}
+	lines := strings.Count(output, "\n")
+	if !strings.HasSuffix(output, "\n") {
+		lines++
+	}
+
+	command, _ := args["command"].(string)
+	action := a.decideLargeOutput(command, chars, lines)
+	switch action {
+	case LargeOutputSummarize:
+		a.printf("%s📉 Output of %d characters summarized for the model%s\n", colors.DIM, chars, colors.RESET)
+		return summarizeOutput(result, chars, lines)
+	case LargeOutputTruncate:
+		a.printf("%s📉 Output of %d characters truncated to %d lines for the model%s\n", colors.DIM, chars, largeOutputTruncateLines, colors.RESET)
+		return truncateOutput(result, chars, lines)
+	}
+	return result.Content
+}
+
+// summarizeOutput 大小、行数、退出码与首尾各 spillPreviewLines 行
+func summarizeOutput(result *tools.ToolResult, chars, lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Output summarized: %d characters, %d lines, exit code %d. "+
+		"Only the first and last lines are shown; re-run a narrower command (filters, grep, head) if you need more.]\n",
+		chars, lines, result.ExitCode)
+	for _, part := range []struct{ name, text string }{{"stdout", result.Stdout}, {"stderr", result.Stderr}} {
+		if part.text == "" {
+			continue
+		}
+		all := strings.Split(strings.TrimSuffix(part.text, "\n"), "\n")
+		if len(all) <= 2*spillPreviewLines {
+			fmt.Fprintf(&b, "\n%s:\n", part.name)
+			writePreview(&b, all)
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s, first %d lines:\n", part.name, spillPreviewLines)
+		writePreview(&b, all[:spillPreviewLines])
+		fmt.Fprintf(&b, "... (%d lines not shown) ...\n", len(all)-2*spillPreviewLines)
+		fmt.Fprintf(&b, "%s, last %d lines:\n", part.name, spillPreviewLines)
+		writePreview(&b, all[len(all)-spillPreviewLines:])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// truncateOutput 只保留输出的开头 largeOutputTruncateLines 行
+func truncateOutput(result *tools.ToolResult, chars, lines int) string {
+	all := strings.Split(strings.TrimSuffix(result.Stdout+result.Stderr, "\n"), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Output truncated: showing the first %d of %d lines (%d characters), exit code %d. "+
+		"Re-run a narrower command (filters, grep, head) if you need more.]\n",
+		min(largeOutputTruncateLines, len(all)), lines, chars, result.ExitCode)
+	writePreview(&b, all[:min(largeOutputTruncateLines, len(all))])
+	return strings.TrimSuffix(b.String(), "\n")
+}
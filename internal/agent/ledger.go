@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/tools"
+)
+
+// RunResult 一次 Run 的结构化结果
+type RunResult struct {
+	// Content 最终回答（与 Run 的返回值相同）
+	Content string
+	// Outcome 结束原因
+	Outcome Outcome
+	// ChangedFiles 本次运行中工具创建 / 修改 / 删除的文件（按路径排序）
+	ChangedFiles []tools.ChangedFile
+	// ToolCalls、ToolFailures 本次运行的工具调用次数与其中失败的次数
+	ToolCalls    int
+	ToolFailures int
+}
+
+// changeLedger 记录单次 Run 中每个文件的净变更，以及工具调用的成功 / 失败次数
+type changeLedger struct {
+	ops      map[string]tools.ChangeOp
+	calls    int
+	failures int
+	// streak 最近一次成功之后连续失败的调用
+	streak []failedCall
+}
+
+func newChangeLedger() *changeLedger {
+	return &changeLedger{ops: map[string]tools.ChangeOp{}}
+}
+
+// recordCall 记录一次工具调用的结果
+func (l *changeLedger) recordCall(result *tools.ToolResult) {
+	l.calls++
+	if !result.Success {
+		l.failures++
+	}
+	l.record(result.Changes)
+}
+
+// record 合并一次变更：先创建后修改仍记为 created，先创建后删除视为没有变更，删除后重新写入记为 modified
+func (l *changeLedger) record(changes []tools.ChangedFile) {
+	for _, c := range changes {
+		prev, seen := l.ops[c.Path]
+		switch {
+		case !seen:
+			l.ops[c.Path] = c.Op
+		case prev == tools.ChangeCreated && c.Op == tools.ChangeDeleted:
+			delete(l.ops, c.Path)
+		case prev == tools.ChangeCreated:
+			// 仍然是新文件
+		case prev == tools.ChangeDeleted && c.Op != tools.ChangeDeleted:
+			l.ops[c.Path] = tools.ChangeModified
+		default:
+			l.ops[c.Path] = c.Op
+		}
+	}
+}
+
+func (l *changeLedger) files() []tools.ChangedFile {
+	out := make([]tools.ChangedFile, 0, len(l.ops))
+	for path, op := range l.ops {
+		out = append(out, tools.ChangedFile{Path: path, Op: op})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// LastResult 返回最近一次 Run 的结构化结果（尚未运行时为零值）
+func (a *Agent) LastResult() RunResult {
+	return a.result
+}
+
+// printChangedFiles 运行结束时打印变更文件摘要
+func printChangedFiles(files []tools.ChangedFile) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("\n%s📄 Files changed (%d):%s\n", colors.BOLD, len(files), colors.RESET)
+	for _, f := range files {
+		mark, color := "~", colors.YELLOW
+		switch f.Op {
+		case tools.ChangeCreated:
+			mark, color = "+", colors.GREEN
+		case tools.ChangeDeleted:
+			mark, color = "-", colors.RED
+		}
+		fmt.Printf("   %s%s %s%s %s(%s)%s\n", color, mark, f.Path, colors.RESET, colors.DIM, f.Op, colors.RESET)
+	}
+}
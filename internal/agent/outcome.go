@@ -0,0 +1,70 @@
+package agent
+
+// Outcome 描述 Run 为何返回，便于 CLI 区分"任务完成"与"等待用户回答"
+type Outcome string
+
+const (
+	// OutcomeDone 模型不再调用工具，任务完成
+	OutcomeDone Outcome = "done"
+	// OutcomeNeedsInput 模型通过 ask_user 提问，返回值是问题，等待用户回答
+	OutcomeNeedsInput Outcome = "needs_input"
+	// OutcomeStepLimit 达到最大步数仍未完成
+	OutcomeStepLimit Outcome = "step_limit"
+	// OutcomeStopped 达到花费上限且未获准继续
+	OutcomeStopped Outcome = "stopped"
+	// OutcomeFailed 模型调用出错
+	OutcomeFailed Outcome = "failed"
+	// OutcomeToolFailures 连续失败的工具调用超过上限而结束（见 WithMaxConsecutiveToolFailures）
+	OutcomeToolFailures Outcome = "tool_failures"
+	// OutcomeCancelled 运行被取消（如用户中断），返回的错误包装 context.Canceled
+	OutcomeCancelled Outcome = "cancelled"
+)
+
+// LastOutcome 返回最近一次 Run 的结束原因（尚未运行时为空）
+func (a *Agent) LastOutcome() Outcome {
+	return a.outcome
+}
+
+// 非交互运行（--prompt）的进程退出码，供脚本判断任务是否成功
+const (
+	// ExitOK 任务完成
+	ExitOK = 0
+	// ExitError 启动失败或模型调用出错
+	ExitError = 1
+	// ExitStepLimit 达到最大步数仍未完成
+	ExitStepLimit = 2
+	// ExitNeedsInput 模型需要用户回答才能继续
+	ExitNeedsInput = 3
+	// ExitStopped 达到花费上限而停止
+	ExitStopped = 4
+	// ExitToolFailures 工具调用失败过多：连续失败超过上限而结束，或模型给出了回答但失败的比例超过阈值
+	ExitToolFailures = 5
+	// ExitCancelled 运行被中断（与 shell 对 SIGINT 的约定一致）
+	ExitCancelled = 130
+)
+
+// ExitCode 把一次 Run 的结果映射为进程退出码。maxFailureRatio > 0 时，
+// 失败的工具调用占比超过它的"完成"也视为失败（返回 ExitToolFailures）
+func (r RunResult) ExitCode(err error, maxFailureRatio float64) int {
+	switch r.Outcome {
+	case OutcomeDone:
+		if err == nil && maxFailureRatio > 0 && r.ToolCalls > 0 &&
+			float64(r.ToolFailures)/float64(r.ToolCalls) > maxFailureRatio {
+			return ExitToolFailures
+		}
+		if err == nil {
+			return ExitOK
+		}
+	case OutcomeStepLimit:
+		return ExitStepLimit
+	case OutcomeNeedsInput:
+		return ExitNeedsInput
+	case OutcomeStopped:
+		return ExitStopped
+	case OutcomeToolFailures:
+		return ExitToolFailures
+	case OutcomeCancelled:
+		return ExitCancelled
+	}
+	return ExitError
+}
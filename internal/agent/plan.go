@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+//
+// ============================================================
+// Plan / Act 两阶段执行
+// ============================================================
+//
+
+// planPhasePrompt 计划阶段追加在任务之后的说明
+const planPhasePrompt = `[Planning phase]
+Analyze the task above and produce a plan before changing anything.
+- Only read-only tools are available; do not try to modify files or run commands.
+- Inspect whatever you need, then reply with a concise numbered plan: the files to change, what to change in each, and how to verify the result.
+- The user will review the plan; it is executed only after approval.`
+
+// executePlanPrompt 计划获批后进入执行阶段的说明，其后接计划内容
+const executePlanPrompt = "The plan was approved. Execute it now, step by step, then summarize what changed.\n\nApproved plan:\n"
+
+// ErrNoPlan 没有待执行的计划
+var ErrNoPlan = errors.New("no approved plan to execute; run the planning phase first")
+
+// activeTools 当前阶段可用的工具：计划阶段与只读模式只包含只读工具
+func (a *Agent) activeTools() *tools.ToolRegistry {
+	if a.ReadOnly() {
+		return a.tools.ReadOnly()
+	}
+	return a.tools
+}
+
+// RunPlan 以只读方式运行计划阶段：对最近添加的任务只开放只读工具，
+// 模型的最终回复作为待批准的计划返回（也可通过 Plan 获取）
+func (a *Agent) RunPlan(ctx context.Context) (string, error) {
+	if err := a.begin(); err != nil {
+		return "", err
+	}
+	defer a.end()
+	a.AddUserMessage(planPhasePrompt)
+
+	a.planning = true
+	defer func() { a.planning = false }()
+
+	plan, err := a.runTracked(ctx)
+	if err != nil || a.outcome == OutcomeNeedsInput {
+		// 出错或模型先向用户提问时没有可批准的计划
+		return plan, err
+	}
+	a.plan = plan
+	return plan, nil
+}
+
+// ExecutePlan 计划获批后开放全部工具并按计划执行
+func (a *Agent) ExecutePlan(ctx context.Context) (string, error) {
+	if err := a.begin(); err != nil {
+		return "", err
+	}
+	defer a.end()
+	if a.plan == "" {
+		return "", ErrNoPlan
+	}
+	a.AddUserMessage(executePlanPrompt + a.plan)
+	a.plan = ""
+	return a.runTracked(ctx)
+}
+
+// Plan 返回待批准的计划（没有时为空）
+func (a *Agent) Plan() string {
+	return a.plan
+}
+
+// DiscardPlan 放弃待批准的计划
+func (a *Agent) DiscardPlan() {
+	a.plan = ""
+}
+
+// dryPlanPrompt /dryplan 追加在任务之后的说明
+const dryPlanPrompt = `[Explain your approach only]
+Describe how you would carry out the task above as a concise numbered plan: what you would inspect, the files you would change, and how you would verify the result.
+Do not call any tools and do not claim to have done anything.`
+
+// DryPlan 只请求模型描述完成 task 的思路：本次请求设置 tool_choice=none，不执行任何工具，
+// 也不改变会话历史（任务与回复都不会写入历史）
+func (a *Agent) DryPlan(ctx context.Context, task string) (string, error) {
+	if err := a.begin(); err != nil {
+		return "", err
+	}
+	defer a.end()
+	a.ensureSystemPinned()
+	msgs := make([]schema.Message, len(a.messages), len(a.messages)+1)
+	copy(msgs, a.messages)
+	msgs = append(msgs, schema.Message{Role: "user", Content: task + "\n\n" + dryPlanPrompt})
+
+	spin := a.startProgress()
+	resp, err := a.llm.Generate(ctx, msgs, a.tools, llm.WithToolChoice(llm.ToolChoiceNone))
+	spin.Stop()
+	if err != nil {
+		return "", err
+	}
+	a.recordUsage(resp.Usage)
+
+	if len(resp.ToolCalls) > 0 {
+		// 个别服务端不支持 tool_choice=none：工具调用一律忽略
+		slog.Warn("Ignoring tool calls returned for a dry plan", slog.Int("count", len(resp.ToolCalls)))
+	}
+	if resp.Content == "" {
+		return "", errors.New("the model returned no plan")
+	}
+	return resp.Content, nil
+}
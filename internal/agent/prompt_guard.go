@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tokenizer"
+	"gopilot-cli/internal/tools"
+)
+
+// charsPerToken 编码器不可用时估算 token 数使用的字符比例（与 tokenizer.EstimateTokensFallback 一致）
+const charsPerToken = 2.5
+
+// WithSystemPromptLimit system prompt 超过 maxTokens 时在创建 Agent 时警告：它在每次请求中都会发送，
+// 并在任何对话消息之前就占用 token limit。truncate 为 true 时保留首尾、把它截断到 maxTokens。
+// maxTokens <= 0 表示不检查
+func WithSystemPromptLimit(maxTokens int, truncate bool) Option {
+	return func(a *Agent) {
+		a.promptMaxTokens = maxTokens
+		a.promptTruncate = truncate
+	}
+}
+
+// systemPromptTokens 估算文本作为 system prompt 的 token 数
+func systemPromptTokens(prompt string) int {
+	return tokenizer.EstimateTokens([]schema.Message{{Role: "system", Content: prompt}})
+}
+
+// guardSystemPrompt 检查 system prompt 的大小：超过上限时警告，开启截断时替换为截断后的版本
+func (a *Agent) guardSystemPrompt() {
+	if a.promptMaxTokens <= 0 {
+		return
+	}
+	tokens := systemPromptTokens(a.systemPrompt)
+	if tokens <= a.promptMaxTokens {
+		return
+	}
+
+	slog.Warn("System prompt exceeds the configured size",
+		slog.Int("tokens", tokens), slog.Int("max_tokens", a.promptMaxTokens), slog.Bool("truncate", a.promptTruncate))
+
+	if !a.promptTruncate {
+		fmt.Printf("%s⚠️  System prompt is ~%d tokens, above agent.system_prompt_max_tokens (%d); "+
+			"it takes %d%% of the token limit (%d) before any messages. "+
+			"Shorten the prompt (e.g. a large AGENTS.md) or set agent.truncate_system_prompt%s\n",
+			colors.BRIGHT_YELLOW, tokens, a.promptMaxTokens, tokens*100/max(a.tokenLimit, 1), a.tokenLimit, colors.RESET)
+		return
+	}
+
+	truncated := tools.TruncateTextByTokens(a.systemPrompt, a.promptMaxTokens)
+	if truncated == a.systemPrompt {
+		// 编码器不可用时 TruncateTextByTokens 不截断，按估算的字符数截断
+		truncated = truncateChars(a.systemPrompt, int(float64(a.promptMaxTokens)*charsPerToken), tokens, a.promptMaxTokens)
+	}
+	a.systemPrompt = truncated
+	a.messages[0] = schema.Message{Role: "system", Content: truncated}
+
+	fmt.Printf("%s⚠️  System prompt truncated from ~%d to ~%d tokens (agent.system_prompt_max_tokens); "+
+		"the middle of the prompt was dropped%s\n",
+		colors.BRIGHT_YELLOW, tokens, systemPromptTokens(truncated), colors.RESET)
+}
+
+// truncateChars 保留 text 的开头与结尾各 maxChars/2 个字符，中间替换为与 TruncateTextByTokens 相同格式的说明
+func truncateChars(text string, maxChars, tokens, maxTokens int) string {
+	runes := []rune(text)
+	half := max(maxChars/2, 1)
+	if len(runes) <= 2*half {
+		return text
+	}
+	note := fmt.Sprintf("\n\n... [Content truncated: %d tokens -> ~%d tokens limit] ...\n\n", tokens, maxTokens)
+	return string(runes[:half]) + note + string(runes[len(runes)-half:])
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitKey tool_rate_limits 中适用于所有未单独配置工具的 key
+const defaultRateLimitKey = "*"
+
+// toolRateLimiter 按工具名限制调用频率（令牌桶，容量为 1：调用之间至少间隔 1/rate 秒）。
+// 超过频率时等待而不是拒绝，防止模型在循环里连续调用工具冲击外部服务或本机
+type toolRateLimiter struct {
+	mu       sync.Mutex
+	interval map[string]time.Duration
+	next     map[string]time.Time
+}
+
+// newToolRateLimiter 由「工具名 → 每秒调用次数」创建限流器；rate <= 0 的项忽略，全部无效时返回 nil
+func newToolRateLimiter(limits map[string]float64) *toolRateLimiter {
+	interval := make(map[string]time.Duration, len(limits))
+	for name, rate := range limits {
+		if rate > 0 {
+			interval[name] = time.Duration(float64(time.Second) / rate)
+		}
+	}
+	if len(interval) == 0 {
+		return nil
+	}
+	return &toolRateLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+// reserve 为 name 的一次调用预留时间片，返回需要等待的时长
+func (l *toolRateLimiter) reserve(name string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	interval, ok := l.interval[name]
+	if !ok {
+		if interval, ok = l.interval[defaultRateLimitKey]; !ok {
+			return 0
+		}
+	}
+	now := time.Now()
+	at := l.next[name]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[name] = at.Add(interval)
+	return at.Sub(now)
+}
+
+// wait 按限流等待到 name 可以调用；ctx 取消时返回其错误
+func (l *toolRateLimiter) wait(ctx context.Context, name string) error {
+	if l == nil {
+		return nil
+	}
+	d := l.reserve(name)
+	if d <= 0 {
+		return nil
+	}
+	slog.Debug("Tool call throttled", slog.String("tool", name), slog.Duration("wait", d))
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
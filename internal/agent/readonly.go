@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"fmt"
+
+	"gopilot-cli/internal/tools"
+)
+
+//
+// 只读模式：计划阶段与 --read-only 会话只开放声明为只读的工具（见 tools.Mutator），
+// 模型仍请求修改类工具时在这里统一拒绝，新工具无需各自检查
+//
+
+// WithReadOnly 整个会话以只读模式运行：不向模型提供修改类工具，请求时返回 tools.ErrorKindReadOnly 错误
+func WithReadOnly(on bool) Option {
+	return func(a *Agent) {
+		a.readOnly = on
+	}
+}
+
+// ReadOnly 当前是否处于只读上下文（只读模式或计划阶段）
+func (a *Agent) ReadOnly() bool {
+	return a.readOnly || a.planning
+}
+
+// notPermitted 只读上下文中拒绝修改类工具，返回带 tools.ErrorKindReadOnly 的结果；允许执行时返回 nil
+func (a *Agent) notPermitted(tool tools.Tool) *tools.ToolResult {
+	if !a.ReadOnly() || tools.IsReadOnly(tool) {
+		return nil
+	}
+	msg := fmt.Sprintf("Tool %s can modify the workspace and is not permitted in read-only mode; use read-only tools, or describe the change for the user instead", tool.Name())
+	if a.planning {
+		msg = fmt.Sprintf("Tool %s can modify the workspace and is not permitted during the planning phase (read-only); describe this step in the plan instead", tool.Name())
+	}
+	return &tools.ToolResult{Success: false, Error: msg, ErrorKind: tools.ErrorKindReadOnly}
+}
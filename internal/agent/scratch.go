@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"fmt"
+
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+// WithScratchDir 把会话的临时目录告诉模型（追加到 system prompt），并在 Close 时删除它
+func WithScratchDir(dir *tools.ScratchDir) Option {
+	return func(a *Agent) {
+		a.scratch = dir
+	}
+}
+
+// ScratchDir 返回会话的临时目录（未设置时为 nil）
+func (a *Agent) ScratchDir() *tools.ScratchDir {
+	return a.scratch
+}
+
+// injectScratchDir 在 system prompt 中说明临时目录的位置与用途
+func (a *Agent) injectScratchDir() {
+	if a.scratch == nil {
+		return
+	}
+	a.systemPrompt += fmt.Sprintf(
+		"\n\n## Scratch Directory\nUse `%s` (absolute: `%s`) for temporary files such as intermediate output, "+
+			"test inputs or throwaway scripts instead of writing them into the project. "+
+			"It is deleted when the session ends, so never put anything there that the user should keep.",
+		a.scratch.Rel(), a.scratch.Path(),
+	)
+	a.messages[0] = schema.Message{Role: "system", Content: a.systemPrompt}
+}
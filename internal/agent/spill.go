@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// spillDir 超大工具结果的保存目录（相对 workspace）
+	spillDir = ".gopilot/spill"
+	// spillPreviewLines 发给模型的预览保留首尾各多少行
+	spillPreviewLines = 20
+	// spillPreviewLineChars 预览中每行的最大字符数
+	spillPreviewLineChars = 200
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// spillToolResult 把超过 a.spillThreshold 个字符的工具结果写入 workspace 下的文件，
+// 返回发给模型的预览与文件路径说明；未开启、未超过阈值或写入失败时返回原内容
+func (a *Agent) spillToolResult(tool, callID, content string) string {
+	if a.spillThreshold <= 0 || utf8.RuneCountInString(content) <= a.spillThreshold {
+		return content
+	}
+
+	dir := filepath.Join(a.workspace, filepath.FromSlash(spillDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Warn("cannot create spill directory, sending tool result inline", "dir", dir, "error", err)
+		return content
+	}
+	// 目录内放一个 .gitignore，避免保存的输出被提交
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		_ = os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.txt", unsafeFileChars.ReplaceAllString(tool, "_"),
+		time.Now().Format("20060102_150405"), unsafeFileChars.ReplaceAllString(callID, "_"))
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		slog.Warn("cannot spill tool result, sending it inline", "tool", tool, "error", err)
+		return content
+	}
+	a.spilled = append(a.spilled, filepath.Join(dir, name))
+	return spillNote(content, spillDir+"/"+name)
+}
+
+// removeSpilled 删除本会话保存的工具结果文件；目录中只剩 .gitignore 时一并删除目录（以及空的 .gopilot）
+func (a *Agent) removeSpilled() error {
+	if len(a.spilled) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, path := range a.spilled {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("remove spilled tool result: %w", err))
+		}
+	}
+	a.spilled = nil
+
+	dir := filepath.Join(a.workspace, filepath.FromSlash(spillDir))
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) <= 1 {
+		if len(entries) == 0 || entries[0].Name() == ".gitignore" {
+			// os.Remove 只删除空目录，不会误删其他会话仍在使用的文件
+			_ = os.Remove(filepath.Join(dir, ".gitignore"))
+			_ = os.Remove(dir)
+			_ = os.Remove(filepath.Dir(dir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// spillNote 生成替代完整结果的说明：大小、保存路径、读取方式，以及首尾若干行预览
+func spillNote(content, path string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Output too large for context: %d characters, %d lines. The full output was saved to %s. "+
+		"Use read_file on that path (with offset/limit or context_pattern) to inspect the parts you need.]\n",
+		utf8.RuneCountInString(content), len(lines), path)
+
+	if len(lines) <= 2*spillPreviewLines {
+		b.WriteString("\nPreview:\n")
+		writePreview(&b, lines)
+	} else {
+		fmt.Fprintf(&b, "\nFirst %d lines:\n", spillPreviewLines)
+		writePreview(&b, lines[:spillPreviewLines])
+		fmt.Fprintf(&b, "... (%d lines not shown) ...\n", len(lines)-2*spillPreviewLines)
+		fmt.Fprintf(&b, "Last %d lines:\n", spillPreviewLines)
+		writePreview(&b, lines[len(lines)-spillPreviewLines:])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writePreview(b *strings.Builder, lines []string) {
+	for _, line := range lines {
+		if r := []rune(line); len(r) > spillPreviewLineChars {
+			line = string(r[:spillPreviewLineChars]) + "..."
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+}
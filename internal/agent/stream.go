@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+	"gopilot-cli/internal/utils/terminal"
+)
+
+//
+// 流式输出：开启后回复与思考内容在到达时逐段打印，不必等整个回复生成完毕。
+// 安静模式下不使用（只打印最终回答，而回复是否为最终回答要等工具调用到齐才知道）
+//
+
+// WithStreaming 开启流式输出（llm.Client.GenerateStream）
+func WithStreaming(enabled bool) Option {
+	return func(a *Agent) {
+		a.stream = enabled
+	}
+}
+
+// streamPrinter 记录流式打印到了哪一部分，结束后补齐换行，并告知调用方哪些内容已经显示过
+type streamPrinter struct {
+	spin     *terminal.Spinner
+	show     bool // 是否显示思考内容
+	section  string
+	thinking bool // 已打印思考内容
+	content  bool // 已打印回复
+}
+
+func (p *streamPrinter) delta(d llm.StreamDelta) {
+	// 第一段内容到达时停止 spinner，避免与输出交错
+	p.spin.Stop()
+	if d.Thinking != "" && p.show {
+		if p.section != "thinking" {
+			p.end()
+			fmt.Printf("\n%s🧠 Thinking:%s\n", colors.BOLD+colors.MAGENTA, colors.RESET)
+			p.section = "thinking"
+			p.thinking = true
+		}
+		fmt.Printf("%s%s%s", colors.DIM, d.Thinking, colors.RESET)
+	}
+	if d.Content != "" {
+		if p.section != "content" {
+			p.end()
+			fmt.Printf("\n%s🤖 Assistant:%s\n", colors.BOLD+colors.BRIGHT_BLUE, colors.RESET)
+			p.section = "content"
+			p.content = true
+		}
+		fmt.Print(d.Content)
+	}
+}
+
+// end 结束当前部分（补一个换行）
+func (p *streamPrinter) end() {
+	if p.section != "" {
+		fmt.Println()
+		p.section = ""
+	}
+}
+
+// generate 调用模型；开启流式输出时边接收边打印，返回的 streamPrinter 说明思考与回复是否已经显示
+func (a *Agent) generate(ctx context.Context, msgs []schema.Message, active *tools.ToolRegistry, spin *terminal.Spinner) (*schema.LLMResponse, *streamPrinter, error) {
+	printed := &streamPrinter{spin: spin, show: a.showThinking}
+	if !a.stream || a.quiet {
+		resp, err := a.llm.Generate(ctx, msgs, active)
+		return resp, printed, err
+	}
+	resp, err := a.llm.GenerateStream(ctx, msgs, active, printed.delta)
+	printed.end()
+	return resp, printed, err
+}
@@ -0,0 +1,84 @@
+package summarizer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopilot-cli/internal/schema"
+)
+
+// maxPreservedBytes 原样保留的代码块总字节上限；超出时优先保留较新的代码块
+const maxPreservedBytes = 16000
+
+// fencedBlockRe 匹配 ``` 或 ~~~ 围起来的代码块（含围栏行）
+var fencedBlockRe = regexp.MustCompile("(?ms)^[ \t]*```[^\n]*\n.*?^[ \t]*```[ \t]*$|^[ \t]*~~~[^\n]*\n.*?^[ \t]*~~~[ \t]*$")
+
+// pathArgs 工具参数中表示文件路径的字段
+var pathArgs = []string{"path", "file_path", "source", "destination"}
+
+// preserved 收集需要原样保留的代码块与文件路径。
+// 代码块在发给模型的文本中替换为 [code block N] 占位符，摘要只处理其余文字。
+type preserved struct {
+	blocks []string
+	paths  []string
+	seen   map[string]bool
+}
+
+func newPreserved() *preserved {
+	return &preserved{seen: map[string]bool{}}
+}
+
+// stash 把 text 中的代码块替换为占位符并记录原文
+func (p *preserved) stash(text string) string {
+	return fencedBlockRe.ReplaceAllStringFunc(text, func(block string) string {
+		p.blocks = append(p.blocks, strings.TrimSpace(block))
+		return fmt.Sprintf("[code block %d]", len(p.blocks))
+	})
+}
+
+// notePaths 记录工具调用参数中的文件路径（去重，保持出现顺序）
+func (p *preserved) notePaths(calls []schema.ToolCall) {
+	for _, tc := range calls {
+		for _, key := range pathArgs {
+			path, _ := tc.Function.Arguments[key].(string)
+			if path == "" || p.seen[path] {
+				continue
+			}
+			p.seen[path] = true
+			p.paths = append(p.paths, path)
+		}
+	}
+}
+
+// appendix 生成附加在摘要之后的原样内容；没有可保留的内容时返回空字符串
+func (p *preserved) appendix() string {
+	if len(p.blocks) == 0 && len(p.paths) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Preserved verbatim from this round:\n")
+	if len(p.paths) > 0 {
+		b.WriteString("Files: " + strings.Join(p.paths, ", ") + "\n")
+	}
+
+	// 从最新的代码块往前保留，直到达到上限
+	keep := make([]bool, len(p.blocks))
+	size := 0
+	for i := len(p.blocks) - 1; i >= 0; i-- {
+		if size+len(p.blocks[i]) > maxPreservedBytes {
+			break
+		}
+		size += len(p.blocks[i])
+		keep[i] = true
+	}
+	for i, block := range p.blocks {
+		if !keep[i] {
+			fmt.Fprintf(&b, "\n[code block %d] (omitted: preserved code limit reached)\n", i+1)
+			continue
+		}
+		fmt.Fprintf(&b, "\n[code block %d]\n%s\n", i+1, block)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -2,58 +2,109 @@ package summarizer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"log/slog"
 
 	"gopilot-cli/internal/agent/colors"
-	"gopilot-cli/internal/agent/tokenizer"
+	"gopilot-cli/internal/agent/history"
 	"gopilot-cli/internal/llm"
 	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tokenizer"
 )
 
+// ErrCancelled 摘要过程中 context 被取消；返回的错误同时包装 ctx.Err()，
+// 调用方应停止运行，而不是带着未摘要（可能超长）的历史继续
+var ErrCancelled = errors.New("summarization cancelled")
+
 // Summarizer 用于对较长的 agent 消息历史进行摘要，
 // 以保证消息内容不会超过设定的 token 限制。
 type Summarizer struct {
-	client     *llm.Client
-	tokenLimit int
+	client       *llm.Client
+	tokenLimit   int
+	preserveCode bool
+
+	// maxMessageChars 每条消息交给摘要模型的最大字符数（0 表示不限制）
+	maxMessageChars int
+
+	// quiet 不打印摘要进度
+	quiet bool
+}
+
+// Option Summarizer 可选配置
+type Option func(*Summarizer)
+
+// WithPreserveCode 摘要时原样保留代码块（``` / ~~~ 围栏）与工具操作过的文件路径，
+// 只让模型概括其余文字，避免摘要后丢失仍需要的精确代码
+func WithPreserveCode(enabled bool) Option {
+	return func(s *Summarizer) {
+		s.preserveCode = enabled
+	}
+}
+
+// WithMaxMessageChars 每条消息（主要是工具输出）在送入摘要提示前最多保留 n 个字符，
+// 超出部分从中间截去并留下标记；n <= 0 表示不限制
+func WithMaxMessageChars(n int) Option {
+	return func(s *Summarizer) {
+		s.maxMessageChars = max(n, 0)
+	}
+}
+
+// WithQuiet 不打印 token 估算与摘要进度
+func WithQuiet(enabled bool) Option {
+	return func(s *Summarizer) {
+		s.quiet = enabled
+	}
 }
 
 // 新建 Summarizer 实例
-func NewSummarizer(client *llm.Client, tokenLimit int) *Summarizer {
-	return &Summarizer{
+func NewSummarizer(client *llm.Client, tokenLimit int, opts ...Option) *Summarizer {
+	s := &Summarizer{
 		client:     client,
 		tokenLimit: tokenLimit,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // SummarizeMessages 当消息历史的 token 估算值超过限制时，
 // 对消息历史进行摘要，返回可能已更新的消息切片。
+// 单轮摘要失败时保留该轮原文继续；ctx 被取消时返回原消息与 ErrCancelled。
 func (s *Summarizer) SummarizeMessages(ctx context.Context, messages []schema.Message) ([]schema.Message, error) {
 	tokens := tokenizer.EstimateTokens(messages)
 	if tokens <= s.tokenLimit {
 		return messages, nil
 	}
 
-	fmt.Printf("\n%s📊 Token estimate: %d/%d%s\n",
+	s.printf("\n%s📊 Token estimate: %d/%d%s\n",
 		colors.BRIGHT_YELLOW, tokens, s.tokenLimit, colors.RESET)
-	fmt.Printf("%s🔄 Summarizing message history...%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+	s.printf("%s🔄 Summarizing message history...%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+
+	// 系统提示必须位于 index 0，否则拒绝摘要，避免把它当成普通消息丢弃
+	system, ok := history.SystemPrompt(messages)
+	if !ok {
+		return messages, fmt.Errorf("cannot summarize: %w", history.Validate(messages))
+	}
 
 	// Collect all user message indices (skip system)
 	userIdx := []int{}
 	for i, m := range messages {
-		if i > 0 && m.Role == "user" {
+		// 携带图片的 user 消息是工具附件的后续消息，属于执行过程而非新的一轮
+		if i > 0 && m.Role == "user" && len(m.Images) == 0 {
 			userIdx = append(userIdx, i)
 		}
 	}
 	if len(userIdx) == 0 {
-		fmt.Printf("%s⚠️ No user messages to summarize%s\n", colors.BRIGHT_YELLOW, colors.RESET)
+		s.printf("%s⚠️ No user messages to summarize%s\n", colors.BRIGHT_YELLOW, colors.RESET)
 		return messages, nil
 	}
 
 	var newMsgs []schema.Message
-	newMsgs = append(newMsgs, messages[0]) // system
+	newMsgs = append(newMsgs, system)
 
 	for i, ui := range userIdx {
 		newMsgs = append(newMsgs, messages[ui])
@@ -72,6 +123,9 @@ func (s *Summarizer) SummarizeMessages(ctx context.Context, messages []schema.Me
 
 		// Create summary text
 		summary, err := s.createSummary(ctx, execMsgs, i+1)
+		if err != nil && ctx.Err() != nil {
+			return messages, fmt.Errorf("%w: %w", ErrCancelled, ctx.Err())
+		}
 		if err != nil {
 			slog.Warn("Summary failed", slog.String("err", err.Error()))
 			continue
@@ -83,8 +137,11 @@ func (s *Summarizer) SummarizeMessages(ctx context.Context, messages []schema.Me
 		})
 	}
 
+	// 去掉混入历史中的其他 system 消息，保证系统提示唯一且位于首位
+	newMsgs = history.PinSystem(newMsgs, system)
+
 	newTokens := tokenizer.EstimateTokens(newMsgs)
-	fmt.Printf("%s✓ Summary complete (tokens %d → %d)%s\n",
+	s.printf("%s✓ Summary complete (tokens %d → %d)%s\n",
 		colors.BRIGHT_GREEN, tokens, newTokens, colors.RESET)
 
 	return newMsgs, nil
@@ -94,10 +151,20 @@ func (s *Summarizer) createSummary(ctx context.Context, msgs []schema.Message, r
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Round %d execution process:\n\n", round))
 
+	// 开启保留时，代码块替换为占位符后再交给模型
+	keep := newPreserved()
+	text := func(content string) string {
+		if s.preserveCode {
+			content = keep.stash(content)
+		}
+		return truncateMiddle(content, s.maxMessageChars)
+	}
+
 	for _, m := range msgs {
 		switch m.Role {
 		case "assistant":
-			sb.WriteString("Assistant: " + m.Content + "\n")
+			sb.WriteString("Assistant: " + text(m.Content) + "\n")
+			keep.notePaths(m.ToolCalls)
 			if len(m.ToolCalls) > 0 {
 				names := []string{}
 				for _, tc := range m.ToolCalls {
@@ -106,7 +173,9 @@ func (s *Summarizer) createSummary(ctx context.Context, msgs []schema.Message, r
 				sb.WriteString("  → Called tools: " + strings.Join(names, ", ") + "\n")
 			}
 		case "tool":
-			sb.WriteString("  ← Tool returned: " + m.Content + "\n")
+			sb.WriteString("  ← Tool returned: " + text(m.Content) + "\n")
+		case "user":
+			sb.WriteString(fmt.Sprintf("  ← Tools returned %d image(s)\n", len(m.Images)))
 		}
 	}
 
@@ -120,6 +189,9 @@ Rules:
 - Concise, English, < 800 words
 - Summarize execution only (no user content)
 `, sb.String())
+	if s.preserveCode {
+		prompt += "- Code blocks were replaced by placeholders like [code block 1]; refer to them by placeholder and do not reproduce code\n"
+	}
 
 	req := []schema.Message{
 		{Role: "system", Content: "You summarize agent execution processes."},
@@ -131,6 +203,29 @@ Rules:
 		return sb.String(), err
 	}
 
+	if s.preserveCode {
+		if extra := keep.appendix(); extra != "" {
+			return resp.Content + "\n\n" + extra, nil
+		}
+	}
 	return resp.Content, nil
 }
 
+// truncateMiddle 超过 limit 个字符时保留开头 2/3 与结尾 1/3（结尾常是错误信息），中间替换为截断标记
+func truncateMiddle(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+	head := limit * 2 / 3
+	tail := limit - head
+	return fmt.Sprintf("%s\n[... %d characters truncated ...]\n%s",
+		string(runes[:head]), len(runes)-limit, string(runes[len(runes)-tail:]))
+}
+
+// printf 打印摘要进度；安静模式下不输出
+func (s *Summarizer) printf(format string, args ...any) {
+	if !s.quiet {
+		fmt.Printf(format, args...)
+	}
+}
@@ -1,11 +1,21 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultConfigPath 项目内配置文件路径（相对当前目录）
+const DefaultConfigPath = "configs/config.yaml"
+
 // RetryConfig 重试配置
 type RetryConfig struct {
 	Enabled         bool    `yaml:"enabled"`
@@ -13,28 +23,270 @@ type RetryConfig struct {
 	InitialDelay    float64 `yaml:"initial_delay"`
 	MaxDelay        float64 `yaml:"max_delay"`
 	ExponentialBase float64 `yaml:"exponential_base"`
+	RunBudget       int     `yaml:"run_budget"` // 单次运行内所有调用累计的重试上限，0 表示不限制
+
+	// Policies 按错误类别覆盖重试策略，key 为 network / server（5xx）/ rate_limit（429）
+	Policies map[string]RetryPolicyConfig `yaml:"policies"`
+}
+
+// RetryPolicyConfig 某一类错误的重试策略，未设置的字段沿用 retry 顶层的默认值
+type RetryPolicyConfig struct {
+	MaxRetries        *int    `yaml:"max_retries"`         // 该类错误的最大重试次数（0 表示不重试）
+	InitialDelay      float64 `yaml:"initial_delay"`       // 秒
+	MaxDelay          float64 `yaml:"max_delay"`           // 秒
+	ExponentialBase   float64 `yaml:"exponential_base"`    // 指数退避基数
+	RespectRetryAfter bool    `yaml:"respect_retry_after"` // 按服务端 Retry-After 等待（不超过 max_delay）
 }
 
 // LLMConfig LLM 配置
 type LLMConfig struct {
+	// Provider 服务端 API："openai"（OpenAI 兼容的 Chat Completions，默认）或 "anthropic"（Messages API）
+	Provider string `yaml:"provider"`
+
 	APIKey  string      `yaml:"api_key"`
 	APIBase string      `yaml:"api_base"`
 	Model   string      `yaml:"model"`
+	Vision  bool        `yaml:"vision"` // 模型是否支持图片输入
+	Seed    *int64      `yaml:"seed"`   // 采样随机种子（可复现输出），不设置则不发送
 	Retry   RetryConfig `yaml:"retry"`
+
+	// ExtraParams 原样合并到请求体顶层的额外参数（如 seed、frequency_penalty）
+	ExtraParams map[string]any `yaml:"extra_params"`
+
+	// StrictTools 以 strict 模式发送工具 schema（需服务端支持）
+	StrictTools bool `yaml:"strict_tools"`
+
+	// MaxTokens 单次回复的 token 上限；anthropic 必填（0 使用默认 8192），openai 不发送
+	MaxTokens int `yaml:"max_tokens"`
+
+	// Choices 每次请求的候选回复数（n 参数）；Agent 采用第一个，其余可用 /choices 查看。<= 1 表示单个候选
+	Choices int `yaml:"choices"`
+
+	// ReasoningEffort 推理强度："" 或 "off"（不发送，默认）、"on"、"minimal"、"low"、"medium"、"high"；会话中可用 /think 修改
+	ReasoningEffort string `yaml:"reasoning_effort"`
+
+	// PromptCaching 提示缓存标记："off"（默认）、"auto"（按模型识别需要标记的服务端）或 "anthropic"（总是发送）
+	PromptCaching string `yaml:"prompt_caching"`
+
+	// DebugPayload 调用前导出原始请求体（API key 已脱敏）：""（关闭）、"stderr" 或 "log"
+	DebugPayload string `yaml:"debug_payload"`
+
+	// Stream 使用流式 API，回复文字与思考内容边生成边打印（--quiet 时不生效）
+	Stream bool `yaml:"stream"`
+
+	// Thinking 模型思考内容（reasoning）的显示、保存与回传
+	Thinking ThinkingConfig `yaml:"thinking"`
+}
+
+// ThinkingConfig 模型思考内容的处理方式，默认只显示、不保存也不发回
+type ThinkingConfig struct {
+	Display bool `yaml:"display"` // 在终端打印
+	Store   bool `yaml:"store"`   // 保存到会话历史（计入上下文 token）
+	Resend  bool `yaml:"resend"`  // 以 reasoning_content 发回模型（隐含 store）
+}
+
+// ProjectTreeConfig 启动时注入工作空间目录树的配置
+type ProjectTreeConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	Depth     int  `yaml:"depth"`
+	MaxTokens int  `yaml:"max_tokens"`
 }
 
 // AgentConfig Agent 配置
 type AgentConfig struct {
-	MaxSteps         int    `yaml:"max_steps"`
-	WorkspaceDir     string `yaml:"workspace_dir"`
-	SystemPromptPath string `yaml:"system_prompt_path"`
-	TokenLimit       int    `yaml:"token_limit"`
+	MaxSteps         int               `yaml:"max_steps"`
+	WorkspaceDir     string            `yaml:"workspace_dir"`
+	SystemPromptPath string            `yaml:"system_prompt_path"`
+	TokenLimit       int               `yaml:"token_limit"`
+	ProjectTree      ProjectTreeConfig `yaml:"project_tree"`
+
+	// SummaryPreserveCode 摘要时原样保留代码块与文件路径
+	SummaryPreserveCode bool `yaml:"summary_preserve_code"`
+
+	// SummaryMaxMessageChars 摘要时每条消息（如工具输出）最多取多少字符交给模型，0 表示不限制
+	SummaryMaxMessageChars int `yaml:"summary_max_message_chars"`
+
+	// PlanMode 普通任务先走只读规划阶段，用户批准计划后再执行
+	PlanMode bool `yaml:"plan_mode"`
+
+	// StepWarningRatio 步数达到 max_steps 的该比例时提示即将到达上限（如 0.8），0 表示不提示
+	StepWarningRatio float64 `yaml:"step_warning_ratio"`
+
+	// SystemPromptMaxTokens system prompt（含注入的记忆、目录树等）超过该 token 数时启动时警告，0 表示不检查
+	SystemPromptMaxTokens int `yaml:"system_prompt_max_tokens"`
+	// TruncateSystemPrompt 超过 SystemPromptMaxTokens 时保留首尾截断，而不只是警告
+	TruncateSystemPrompt bool `yaml:"truncate_system_prompt"`
+
+	// ToolTimeout 工具的默认执行超时（如 "5m"）；自行声明超时的工具（bash、read_file）不受影响，0 表示不限制
+	ToolTimeout time.Duration `yaml:"tool_timeout"`
+
+	// ToolRateLimits 按工具名限制每秒调用次数（如 bash: 2），"*" 适用于其余工具；超过时等待而不是拒绝
+	ToolRateLimits map[string]float64 `yaml:"tool_rate_limits"`
+
+	// CompactToolResults 发给模型的工具结果去掉行号对齐、段标题与多余空白，日志与终端仍显示完整格式
+	CompactToolResults bool `yaml:"compact_tool_results"`
+
+	// MaxToolFailureRatio 非交互运行（--prompt）中失败的工具调用占比超过该值时，即使模型给出回答也以退出码 5 结束；0 表示不检查
+	MaxToolFailureRatio float64 `yaml:"max_tool_failure_ratio"`
+
+	// MaxConsecutiveToolFailures 连续失败的工具调用超过该次数（中间没有成功的调用）时结束运行并汇总失败原因；0 表示不限制
+	MaxConsecutiveToolFailures int `yaml:"max_consecutive_tool_failures"`
+
+	// DedupeToolResults 工具名、参数与结果都相同时，历史中较早的结果替换为指向最新结果的简短引用。
+	// 改写较早的消息会使服务端的 prompt 缓存从该消息起失效，因此默认关闭
+	DedupeToolResults bool `yaml:"dedupe_tool_results"`
+
+	// SpillThresholdChars 超过该字符数的工具结果保存到 workspace/.gopilot/spill，模型只收到预览与路径，会话结束时删除；0 表示不启用
+	SpillThresholdChars int `yaml:"spill_threshold_chars"`
+
+	// ScratchDir 为每个会话创建临时目录 workspace/.gopilot/tmp/<id>，告知模型并在会话结束时删除
+	ScratchDir bool `yaml:"scratch_dir"`
+
+	// AutosaveSession 每轮对话后与退出时把会话自动保存为 autosave-<工作空间>，供 --resume / /resume 恢复
+	AutosaveSession bool `yaml:"autosave_session"`
+
+	// AutosaveMaxAge 启动时删除超过该时长未更新的自动保存会话（如 "720h"），0 表示不按时间删除
+	AutosaveMaxAge time.Duration `yaml:"autosave_max_age"`
+}
+
+// LogConfig 运行日志配置
+type LogConfig struct {
+	MaxEntryBytes int `yaml:"max_entry_bytes"`
+}
+
+// BashToolConfig bash 工具配置
+type BashToolConfig struct {
+	MaxOutputLines int    `yaml:"max_output_lines"` // 0 表示不限制
+	StderrIsError  string `yaml:"stderr_is_error"`  // never / nonzero-exit / always
+	Diagnostics    bool   `yaml:"diagnostics"`      // 解析 Go 编译 / 测试错误为紧凑诊断列表
+	ExecInfo       bool   `yaml:"exec_info"`        // 结果末尾附加命令的工作目录与耗时
+
+	// ConfineToWorkspace 命令固定在 workspace 下执行，并拒绝 cd 到其外部（best-effort）
+	ConfineToWorkspace bool `yaml:"confine_to_workspace"`
+
+	// MaxConcurrent 同时运行的前台命令上限，超出的排队等待；0 表示不限制
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// DryRun 只回显命令不执行（审查 Agent 打算运行的命令）
+	DryRun bool `yaml:"dry_run"`
+
+	// StreamOutput 交互模式下把前台命令的输出实时回显到终端（结果照常完整返回给模型）
+	StreamOutput bool `yaml:"stream_output"`
+
+	// NonInteractive 为命令设置 DEBIAN_FRONTEND=noninteractive、GIT_TERMINAL_PROMPT=0、GIT_EDITOR=true，避免等待输入
+	NonInteractive bool `yaml:"non_interactive"`
+
+	// LargeOutput 前台命令输出过大时如何交给模型
+	LargeOutput LargeOutputConfig `yaml:"large_output"`
+}
+
+// LargeOutputConfig 前台命令输出超过阈值时的处理：交互模式下询问，否则使用 Default
+type LargeOutputConfig struct {
+	ThresholdChars int    `yaml:"threshold_chars"` // 捕获的 stdout + stderr 超过该字符数时触发，0 表示不检查
+	Ask            bool   `yaml:"ask"`             // 交互终端中询问用户
+	Default        string `yaml:"default"`         // feed / summarize / truncate：不询问时（或直接回车）使用
+}
+
+// WriteToolConfig write_file 工具配置
+type WriteToolConfig struct {
+	TrailingNewline string `yaml:"trailing_newline"` // leave / ensure / strip
+	Encoding        string `yaml:"encoding"`         // utf-8 / utf-8-bom / utf-16le / utf-16be
+}
+
+// MemoryToolConfig memory 工具配置（跨会话记忆，存于 ~/.gopilot/memory）
+type MemoryToolConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	MaxBytes int  `yaml:"max_bytes"` // 所有 key + value 的总字节上限
+}
+
+// BackupToolConfig 会话备份配置：write_file / edit_file / copy_file 修改前备份原始文件，/rollback 一次恢复
+type BackupToolConfig struct {
+	Enabled  bool  `yaml:"enabled"`
+	MaxBytes int64 `yaml:"max_bytes"` // 单个会话备份的总字节上限，超出后不再备份
+}
+
+// FormatToolConfig format_code 工具配置
+type FormatToolConfig struct {
+	// Formatters 按扩展名覆盖格式化命令（文件路径追加在末尾），如 ".py": ["black", "-q"]；
+	// 未列出的扩展名沿用默认（".go": gofmt -w），空列表表示不格式化该类文件
+	Formatters map[string][]string `yaml:"formatters"`
+	// Timeout 单次格式化的超时（如 "1m"）
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ToolsConfig 工具配置
+type ToolsConfig struct {
+	Bash   BashToolConfig   `yaml:"bash"`
+	Write  WriteToolConfig  `yaml:"write"`
+	Memory MemoryToolConfig `yaml:"memory"`
+	Format FormatToolConfig `yaml:"format"`
+	Backup BackupToolConfig `yaml:"backup"`
+
+	// ReadRoots read_file / list_dir / workspace_stats 额外可以访问的目录（相对路径基于 workspace），写入仍只限 workspace
+	ReadRoots []string `yaml:"read_roots"`
+
+	// Overlay write_file / edit_file / copy_file 先写入影子目录，由 /apply 写回或 /discard 丢弃
+	Overlay bool `yaml:"overlay"`
+
+	// DetectExternalChanges 记录 read_file 读取时的文件状态：write_file 拒绝覆盖之后被外部修改的文件，edit_file 在结果中提示
+	DetectExternalChanges bool `yaml:"detect_external_changes"`
+
+	// RunLogs 提供 run_logs 工具，模型可以列出并读取之前运行的日志（~/.gopilot/log，所有工作空间共用）
+	RunLogs bool `yaml:"run_logs"`
+
+	// External 用户定义的外部工具：运行命令，参数以 JSON 从 stdin 传入，stdout 作为结果
+	External []ExternalToolConfig `yaml:"external"`
+
+	// Disabled 按名称关闭的工具；模型仍请求这些工具时会被告知它们在本会话中被配置关闭
+	Disabled []string `yaml:"disabled"`
+}
+
+// ExternalToolConfig 一个外部工具的定义
+type ExternalToolConfig struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Command 命令与参数（不经过 shell），在 workspace 下运行，如 ["python3", "tools/lint.py"]
+	Command []string `yaml:"command"`
+	// Parameters 参数的 JSON Schema（顶层 type: object），省略时工具不接受参数
+	Parameters map[string]any `yaml:"parameters"`
+	// Timeout 单次运行的超时（如 "30s"），默认 1m
+	Timeout time.Duration `yaml:"timeout"`
+	// ReadOnly 命令不修改工作空间：计划阶段与 --read-only 下也提供
+	ReadOnly bool `yaml:"read_only"`
+}
+
+// CostConfig 会话花费预算（美元），0 表示不启用
+type CostConfig struct {
+	WarnUSD    float64 `yaml:"warn_usd"`
+	HardCapUSD float64 `yaml:"hard_cap_usd"`
+
+	// 覆盖内置价格表（每百万 token 美元），0 表示按模型名查表
+	InputPerMTok  float64 `yaml:"input_per_mtok"`
+	OutputPerMTok float64 `yaml:"output_per_mtok"`
+}
+
+// UIConfig 交互界面配置
+type UIConfig struct {
+	// IdleTimeout 无输入超过该时长自动退出会话（如 "30m"），0 表示不启用
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	// Theme 配色主题：default / light（浅色背景）/ mono（不输出颜色）；设置了 NO_COLOR 环境变量时强制 mono
+	Theme string `yaml:"theme"`
+	// Colors 按颜色名覆盖主题（如 bright_yellow: "38;5;130"、cyan: blue、dim: none）
+	Colors map[string]string `yaml:"colors"`
+
+	// Quiet 安静模式：不打印启动信息、Step 框、工具调用等过程输出，只显示最终回答、错误与警告（也可用 --quiet）
+	Quiet bool `yaml:"quiet"`
 }
 
 // Config 主配置
 type Config struct {
 	LLM   LLMConfig   `yaml:"llm"`
 	Agent AgentConfig `yaml:"agent"`
+	Tools ToolsConfig `yaml:"tools"`
+	Cost  CostConfig  `yaml:"cost"`
+	Log   LogConfig   `yaml:"log"`
+	UI    UIConfig    `yaml:"ui"`
 }
 
 // DefaultConfig 返回默认配置
@@ -50,26 +302,160 @@ func DefaultConfig() *Config {
 				MaxDelay:        60.0,
 				ExponentialBase: 2.0,
 			},
+			Thinking: ThinkingConfig{Display: true},
 		},
 		Agent: AgentConfig{
-			MaxSteps:     50,
-			WorkspaceDir: "./workspace",
-			TokenLimit:   80000,
+			MaxSteps:                   50,
+			StepWarningRatio:           0.8,
+			SystemPromptMaxTokens:      8000,
+			TokenLimit:                 80000,
+			ToolTimeout:                5 * time.Minute,
+			SummaryMaxMessageChars:     4000,
+			ScratchDir:                 true,
+			AutosaveSession:            true,
+			AutosaveMaxAge:             30 * 24 * time.Hour,
+			DedupeToolResults:          false,
+			MaxConsecutiveToolFailures: 10,
+			ProjectTree: ProjectTreeConfig{
+				Enabled:   false,
+				Depth:     2,
+				MaxTokens: 2000,
+			},
 		},
+		Tools: ToolsConfig{
+			DetectExternalChanges: true,
+			Bash: BashToolConfig{
+				StderrIsError:  "nonzero-exit",
+				MaxConcurrent:  4,
+				NonInteractive: true,
+				LargeOutput: LargeOutputConfig{
+					Ask:     true,
+					Default: "summarize",
+				},
+			},
+			Write: WriteToolConfig{
+				TrailingNewline: "leave",
+				Encoding:        "utf-8",
+			},
+			Backup: BackupToolConfig{
+				MaxBytes: 50 << 20,
+			},
+			Memory: MemoryToolConfig{
+				MaxBytes: 8192,
+			},
+			Format: FormatToolConfig{
+				Timeout: time.Minute,
+			},
+		},
+	}
+}
+
+// LoadOption 加载配置文件的可选行为
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	strict    bool
+	onUnknown func(UnknownKey)
+}
+
+// WithStrict 配置文件包含未知的键时返回 *UnknownKeysError，而不是忽略它们
+func WithStrict(enabled bool) LoadOption {
+	return func(o *loadOptions) {
+		o.strict = enabled
 	}
 }
 
-// LoadFromFile 从 YAML 文件加载配置
-func LoadFromFile(path string) (*Config, error) {
+// WithUnknownKeyHandler 非严格模式下对每个未知的键调用 fn（如打印警告）；未设置时写入 slog 警告
+func WithUnknownKeyHandler(fn func(UnknownKey)) LoadOption {
+	return func(o *loadOptions) {
+		o.onUnknown = fn
+	}
+}
+
+// LoadFromFile 从 YAML 文件加载配置。
+// 未知的键（多为拼写错误，如 maxsteps）默认作为警告报告并忽略，WithStrict 时作为错误返回
+func LoadFromFile(path string, opts ...LoadOption) (*Config, error) {
+	o := loadOptions{onUnknown: func(k UnknownKey) {
+		slog.Warn("Unknown config key", slog.String("path", path), slog.String("key", k.String()))
+	}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	cfg := DefaultConfig()
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if errors.Is(err, io.EOF) {
+		return cfg, nil // 空文件
 	}
 
+	// KnownFields 下未知字段与类型错误一起收集在 TypeError 中，其余字段照常解码
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		if err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	unknown, rest := splitUnknownKeys(typeErr.Errors)
+	if len(rest) > 0 {
+		return nil, &yaml.TypeError{Errors: rest}
+	}
+	if o.strict {
+		return nil, &UnknownKeysError{Path: path, Keys: unknown}
+	}
+	if o.onUnknown != nil {
+		for _, k := range unknown {
+			o.onUnknown(k)
+		}
+	}
 	return cfg, nil
 }
+
+// UserConfigPath 返回用户级配置文件路径 (~/.gopilot/config.yaml)
+func UserConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".gopilot", "config.yaml"), nil
+}
+
+// FindConfigFile 查找配置文件。
+// explicit 非空时直接使用；否则依次尝试 configs/config.yaml 和 ~/.gopilot/config.yaml。
+// 第二个返回值表示文件是否存在。
+func FindConfigFile(explicit string) (string, bool) {
+	if explicit != "" {
+		_, err := os.Stat(explicit)
+		return explicit, err == nil
+	}
+
+	candidates := []string{DefaultConfigPath}
+	if p, err := UserConfigPath(); err == nil {
+		candidates = append(candidates, p)
+	}
+	for _, p := range candidates {
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// SaveToFile 将配置写入 YAML 文件（文件包含 API 密钥，权限为 0600）
+func SaveToFile(cfg *Config, path string) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
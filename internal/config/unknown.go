@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// 未知的配置键：yaml 默认忽略无法识别的键，拼写错误（如 maxsteps）会悄悄退回默认值
+//
+
+// UnknownKey 配置文件中无法识别的键
+type UnknownKey struct {
+	Line int
+	// Key 完整路径，如 "agent.maxsteps"
+	Key string
+	// Suggestion 同一节中最接近的已知键（可能为空）
+	Suggestion string
+}
+
+func (k UnknownKey) String() string {
+	s := fmt.Sprintf("line %d: unknown key %q", k.Line, k.Key)
+	if k.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %q?)", k.Suggestion)
+	}
+	return s
+}
+
+// UnknownKeysError 严格模式下配置文件包含未知的键
+type UnknownKeysError struct {
+	Path string
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	lines := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		lines[i] = "  " + k.String()
+	}
+	return fmt.Sprintf("config %s has unknown keys:\n%s", e.Path, strings.Join(lines, "\n"))
+}
+
+// unknownFieldError yaml.v3 在 KnownFields(true) 下报告未知字段的格式
+var unknownFieldError = regexp.MustCompile(`^line (\d+): field (.+) not found in type (\S+)$`)
+
+// splitUnknownKeys 从解码错误中分离出未知键，其余错误（如类型不匹配）原样保留
+func splitUnknownKeys(errs []string) ([]UnknownKey, []string) {
+	sections := configSections()
+	var keys []UnknownKey
+	var rest []string
+	for _, e := range errs {
+		m := unknownFieldError.FindStringSubmatch(e)
+		if m == nil {
+			rest = append(rest, e)
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		sec := sections[m[3]]
+		key := m[2]
+		if sec.path != "" {
+			key = sec.path + "." + key
+		}
+		keys = append(keys, UnknownKey{Line: line, Key: key, Suggestion: suggestKey(m[2], sec.fields)})
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Line < keys[j].Line })
+	return keys, rest
+}
+
+// section 配置结构体在 YAML 中的位置与它的键
+type section struct {
+	path   string
+	fields []string
+}
+
+// configSections 遍历 Config 的结构，返回 Go 类型名（如 "config.AgentConfig"）→ 所在的节
+func configSections() map[string]section {
+	out := map[string]section{}
+	var walk func(t reflect.Type, path string)
+	walk = func(t reflect.Type, path string) {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice:
+			suffix := ""
+			if t.Kind() == reflect.Map {
+				suffix = ".<name>"
+			}
+			walk(t.Elem(), path+suffix)
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if _, seen := out[t.String()]; seen || t.PkgPath() != reflect.TypeOf(Config{}).PkgPath() {
+			return
+		}
+		sec := section{path: path}
+		out[t.String()] = sec
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			sec.fields = append(sec.fields, name)
+			child := name
+			if path != "" {
+				child = path + "." + name
+			}
+			walk(t.Field(i).Type, child)
+		}
+		out[t.String()] = sec
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return out
+}
+
+// suggestKey 返回与 key 最接近的已知键：忽略大小写与 "_" / "-" 后相同，或编辑距离不超过 2
+func suggestKey(key string, known []string) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	best, bestDist := "", 3
+	for _, k := range known {
+		if normalize(k) == normalize(key) {
+			return k
+		}
+		if d := editDistance(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance 两个字符串的 Levenshtein 距离
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
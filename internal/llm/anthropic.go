@@ -0,0 +1,665 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+//
+// ---------------------------------------------------------
+// Anthropic Messages API（llm.provider: anthropic）
+// ---------------------------------------------------------
+//
+// 与 Chat Completions 的主要差异：开头的 system 消息放在顶层 system 字段；工具调用与结果是
+// assistant / user 消息中的 tool_use / tool_result 内容块，相邻的同角色消息合并为一条；max_tokens 必填；
+// 推理强度对应 extended thinking 的 budget_tokens。seed、logprobs、多个候选（n）与 strict 工具不适用，被忽略。
+
+const (
+	// AnthropicBaseURL llm.api_base 为空时使用的 Anthropic API 地址
+	AnthropicBaseURL = "https://api.anthropic.com/v1"
+	// DefaultMaxTokens 未设置 llm.max_tokens 时单次回复的 token 上限（Anthropic 要求必填）
+	DefaultMaxTokens = 8192
+
+	anthropicVersion = "2023-06-01"
+)
+
+// thinkingBudgets 推理强度对应的 extended thinking budget_tokens（API 要求至少 1024）
+var thinkingBudgets = map[string]int{
+	"minimal": 1024,
+	"low":     4096,
+	"medium":  10000,
+	"high":    32000,
+}
+
+// WithMaxTokens 设置单次回复的 token 上限：Anthropic 要求必填（<= 0 时使用 DefaultMaxTokens）；
+// OpenAI 兼容 API 不发送（需要时使用 extra_params.max_tokens）
+func WithMaxTokens(n int) ClientOption {
+	return func(c *Client) {
+		c.maxTokens = n
+	}
+}
+
+// anthropicProvider Anthropic Messages API
+type anthropicProvider struct {
+	c    *Client
+	http *http.Client
+
+	// 开启 extended thinking 时，API 要求把最后一个工具调用回合的思考块（带签名）原样发回。
+	// 签名不属于 schema.Message，这里保存最近一次带工具调用的回复的思考块，按其第一个工具调用 ID 对应
+	mu           sync.Mutex
+	thinkingCall string
+	thinking     []map[string]any
+}
+
+func newAnthropicProvider(c *Client) *anthropicProvider {
+	var ignored []string
+	if c.seed != nil {
+		ignored = append(ignored, "seed")
+	}
+	if c.logprobs {
+		ignored = append(ignored, "logprobs")
+	}
+	if c.choices > 1 {
+		ignored = append(ignored, "choices")
+	}
+	if c.strictTools {
+		ignored = append(ignored, "strict_tools")
+	}
+	if len(ignored) > 0 {
+		slog.Warn("Settings not supported by the anthropic provider are ignored", slog.String("settings", strings.Join(ignored, ", ")))
+	}
+	return &anthropicProvider{c: c, http: newAnthropicHTTPClient()}
+}
+
+// newAnthropicHTTPClient 带连接与响应头超时的 HTTP 客户端：连接卡住时请求失败并按网络错误重试，
+// 而不是一直挂起到用户按 Ctrl-C。非流式请求在回复生成完后才返回响应头（长回复、extended thinking
+// 可能需要数分钟），因此响应头超时较宽
+func newAnthropicHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 15 * time.Second
+	transport.ResponseHeaderTimeout = 10 * time.Minute
+	return &http.Client{Transport: transport}
+}
+
+// baseURL 返回 API 地址（不带结尾的 /）
+func (p *anthropicProvider) baseURL() string {
+	if p.c.baseURL == "" {
+		return AnthropicBaseURL
+	}
+	return strings.TrimRight(p.c.baseURL, "/")
+}
+
+func (p *anthropicProvider) Generate(ctx context.Context, req *Request) (*schema.LLMResponse, error) {
+	body := p.body(req, false)
+	p.c.dumpBody(body)
+
+	resp, err := p.post(ctx, "/messages", body)
+	if err != nil {
+		return nil, p.wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	var msg anthropicMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, p.wrapError(fmt.Errorf("decode response: %w", err))
+	}
+	return p.parseMessage(&msg), nil
+}
+
+func (p *anthropicProvider) GenerateStream(ctx context.Context, req *Request, emit func(StreamDelta)) (*schema.LLMResponse, error) {
+	body := p.body(req, true)
+	p.c.dumpBody(body)
+
+	resp, err := p.post(ctx, "/messages", body)
+	if err != nil {
+		return nil, p.wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	msg, err := readAnthropicStream(resp.Body, emit)
+	if err != nil {
+		return nil, p.wrapError(err)
+	}
+	return p.parseMessage(msg), nil
+}
+
+// CountTokens 使用 count_tokens 接口由服务端计算（不产生费用）
+func (p *anthropicProvider) CountTokens(ctx context.Context, req *Request) (int, error) {
+	resp, err := p.post(ctx, "/messages/count_tokens", p.params(req))
+	if err != nil {
+		return 0, p.wrapError(err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("decode count_tokens response: %w", err)
+	}
+	return out.InputTokens, nil
+}
+
+// params 构造请求中与生成参数无关的部分（Messages 与 count_tokens 共用）
+func (p *anthropicProvider) params(req *Request) map[string]any {
+	c := p.c
+	system, messages := p.convertMessages(req.Messages)
+	if c.cacheMarkers() {
+		markAnthropicCache(system, messages)
+	}
+
+	body := map[string]any{
+		"model":    c.model,
+		"messages": messages,
+	}
+	if len(system) > 0 {
+		body["system"] = system
+	}
+
+	hasTools := req.Tools != nil && len(req.Tools.List()) > 0
+	if hasTools {
+		body["tools"] = anthropicTools(req.Tools)
+		if req.ToolChoice != "" {
+			body["tool_choice"] = anthropicToolChoice(req.ToolChoice)
+		}
+	}
+
+	// 强制调用工具（required 或指定工具名）时 API 不允许开启 thinking
+	forced := hasTools && req.ToolChoice != "" && req.ToolChoice != ToolChoiceAuto && req.ToolChoice != ToolChoiceNone
+	if budget, ok := thinkingBudgets[c.reasoningEffort]; ok && !forced {
+		body["thinking"] = map[string]any{"type": "enabled", "budget_tokens": budget}
+	}
+	return body
+}
+
+// body 构造完整的 Messages 请求体：max_tokens、stream 与 extra params
+func (p *anthropicProvider) body(req *Request, stream bool) map[string]any {
+	body := p.params(req)
+
+	maxTokens := p.c.maxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	// max_tokens 包含思考预算，必须大于 budget_tokens：预算不小于上限时在预算之上留出回复的空间
+	if thinking, ok := body["thinking"].(map[string]any); ok {
+		if budget := thinking["budget_tokens"].(int); budget >= maxTokens {
+			maxTokens += budget
+		}
+	}
+	body["max_tokens"] = maxTokens
+
+	if stream {
+		body["stream"] = true
+	}
+	for k, v := range p.c.extraParams {
+		body[k] = v
+	}
+	return body
+}
+
+// post 发送 JSON 请求；非 2xx 响应转换为 AnthropicError
+func (p *anthropicProvider) post(ctx context.Context, path string, body map[string]any) (*http.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, retry.Permanent(fmt.Errorf("serialize request: %w", err))
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL()+path, bytes.NewReader(raw))
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", p.c.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := p.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, anthropicAPIError(resp)
+	}
+	return resp, nil
+}
+
+// anthropicAPIError 解析错误响应体 {"type": "error", "error": {"type": ..., "message": ...}}
+func anthropicAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &AnthropicError{StatusCode: resp.StatusCode, Response: resp}
+
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		apiErr.Type = body.Error.Type
+		apiErr.Message = body.Error.Message
+		return apiErr
+	}
+	apiErr.Message = strings.TrimSpace(string(data))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// wrapError 与 Chat Completions 相同：配置类错误（模型不存在、不支持 thinking）标记为不可重试
+func (p *anthropicProvider) wrapError(err error) error {
+	c := p.c
+	var apiErr *AnthropicError
+	if c.reasoningEffort != "" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(apiErr.Message), "thinking") {
+		return retry.Permanent(&ReasoningUnsupportedError{Model: c.model, Level: c.reasoningEffort, Err: err})
+	}
+	if isModelNotFound(err) {
+		return retry.Permanent(&ModelNotFoundError{Model: c.model, BaseURL: p.baseURL(), Err: err})
+	}
+	return fmt.Errorf("anthropic messages request failed: %w", err)
+}
+
+//
+// 请求转换
+//
+
+// convertMessages 转换消息：开头的 system 消息组成顶层 system，之后的 system 消息（如会话中追加的指令）作为 user 文本；
+// tool 结果作为 user 消息中的 tool_result 块；相邻的同角色消息合并为一条（API 要求 user / assistant 交替）
+func (p *anthropicProvider) convertMessages(messages []schema.Message) (system, result []map[string]any) {
+	add := func(role string, blocks ...map[string]any) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1]["role"] == role {
+			result[n-1]["content"] = append(result[n-1]["content"].([]map[string]any), blocks...)
+			return
+		}
+		result = append(result, map[string]any{"role": role, "content": blocks})
+	}
+
+	leading := true
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			leading = false
+		}
+		// API 拒绝空白的文本块
+		hasText := strings.TrimSpace(msg.Content) != ""
+
+		switch msg.Role {
+		case "system":
+			if !hasText {
+				continue
+			}
+			if leading {
+				system = append(system, textBlock(msg.Content))
+				continue
+			}
+			add("user", textBlock(msg.Content))
+
+		case "user":
+			var blocks []map[string]any
+			if hasText {
+				blocks = append(blocks, textBlock(msg.Content))
+			}
+			for _, img := range msg.Images {
+				blocks = append(blocks, map[string]any{
+					"type": "image",
+					"source": map[string]any{
+						"type":       "base64",
+						"media_type": img.MIMEType,
+						"data":       base64.StdEncoding.EncodeToString(img.Data),
+					},
+				})
+			}
+			add("user", blocks...)
+
+		case "assistant":
+			var blocks []map[string]any
+			if len(msg.ToolCalls) > 0 {
+				// 思考块必须位于 assistant 内容的开头
+				blocks = append(blocks, p.signedThinking(msg.ToolCalls[0].ID)...)
+			}
+			if hasText {
+				blocks = append(blocks, textBlock(msg.Content))
+			}
+			for _, tc := range msg.ToolCalls {
+				args := tc.Function.Arguments
+				if args == nil {
+					args = map[string]any{}
+				}
+				blocks = append(blocks, map[string]any{
+					"type":  "tool_use",
+					"id":    tc.ID,
+					"name":  tc.Function.Name,
+					"input": args,
+				})
+			}
+			add("assistant", blocks...)
+
+		case "tool":
+			block := map[string]any{"type": "tool_result", "tool_use_id": msg.ToolCallID}
+			if hasText {
+				block["content"] = msg.Content
+			}
+			if msg.IsError {
+				block["is_error"] = true
+			}
+			add("user", block)
+		}
+	}
+	return system, result
+}
+
+func textBlock(text string) map[string]any {
+	return map[string]any{"type": "text", "text": text}
+}
+
+// markAnthropicCache 与 Chat Completions 相同的两个缓存断点：系统提示的最后一块、最后一条 user 消息的最后一块
+func markAnthropicCache(system, messages []map[string]any) {
+	if len(system) > 0 {
+		system[len(system)-1]["cache_control"] = cacheControl
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] == "user" {
+			blocks := messages[i]["content"].([]map[string]any)
+			blocks[len(blocks)-1]["cache_control"] = cacheControl
+			return
+		}
+	}
+}
+
+// anthropicTools 转换工具定义（strict 模式不适用）
+func anthropicTools(registry *tools.ToolRegistry) []map[string]any {
+	toolList := registry.List()
+	result := make([]map[string]any, 0, len(toolList))
+	for _, tool := range toolList {
+		params := tool.Parameters()
+		if params == nil {
+			params = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		result = append(result, map[string]any{
+			"name":         tool.Name(),
+			"description":  tool.Description(),
+			"input_schema": params,
+		})
+	}
+	return result
+}
+
+// anthropicToolChoice 转换 tool_choice：required 对应 any，其他值视为工具名
+func anthropicToolChoice(choice string) map[string]any {
+	switch choice {
+	case ToolChoiceAuto:
+		return map[string]any{"type": "auto"}
+	case ToolChoiceNone:
+		return map[string]any{"type": "none"}
+	case ToolChoiceRequired:
+		return map[string]any{"type": "any"}
+	}
+	return map[string]any{"type": "tool", "name": choice}
+}
+
+// rememberThinking 保存带工具调用的回复中的思考块，发送该回合时原样发回
+func (p *anthropicProvider) rememberThinking(callID string, blocks []map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.thinkingCall = callID
+	p.thinking = blocks
+}
+
+// signedThinking 返回第一个工具调用为 callID 的回合保存的思考块（没有时为 nil）
+func (p *anthropicProvider) signedThinking(callID string) []map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if callID == "" || callID != p.thinkingCall {
+		return nil
+	}
+	return p.thinking
+}
+
+//
+// 响应解析
+//
+
+// anthropicBlock 回复中的内容块
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	Signature string          `json:"signature"`
+	Data      string          `json:"data"` // redacted_thinking 的加密内容
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// anthropicMessage Messages API 的回复
+type anthropicMessage struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// parseMessage 把回复转换为 LLMResponse（只有一个候选）；stop_reason 转换为 Chat Completions 的 finish_reason
+func (p *anthropicProvider) parseMessage(msg *anthropicMessage) *schema.LLMResponse {
+	var content, thinking strings.Builder
+	var calls []schema.ToolCall
+	var signed []map[string]any
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+			signed = append(signed, map[string]any{"type": "thinking", "thinking": block.Thinking, "signature": block.Signature})
+		case "redacted_thinking":
+			signed = append(signed, map[string]any{"type": "redacted_thinking", "data": block.Data})
+		case "tool_use":
+			calls = append(calls, toolUseCall(block))
+		}
+	}
+	if len(calls) > 0 {
+		p.rememberThinking(calls[0].ID, signed)
+	}
+
+	choice := schema.Choice{
+		Content:      content.String(),
+		Thinking:     thinking.String(),
+		ToolCalls:    calls,
+		FinishReason: stopReason(msg.StopReason),
+	}
+	prompt := msg.Usage.InputTokens + msg.Usage.CacheCreationInputTokens + msg.Usage.CacheReadInputTokens
+	return &schema.LLMResponse{
+		Content:      choice.Content,
+		Thinking:     choice.Thinking,
+		ToolCalls:    choice.ToolCalls,
+		FinishReason: choice.FinishReason,
+		Choices:      []schema.Choice{choice},
+		Usage: &schema.Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: msg.Usage.OutputTokens,
+			TotalTokens:      prompt + msg.Usage.OutputTokens,
+		},
+	}
+}
+
+// toolUseCall 转换 tool_use 块；参数无法解析时与 Chat Completions 一样降级为空参数
+func toolUseCall(block anthropicBlock) schema.ToolCall {
+	args, err := parseToolArguments(string(block.Input))
+	if err != nil {
+		slog.Warn("Invalid tool call arguments",
+			slog.String("tool", block.Name),
+			slog.String("arguments", string(block.Input)),
+			slog.String("err", err.Error()),
+		)
+		args = map[string]any{}
+	}
+	return schema.ToolCall{
+		ID:       block.ID,
+		Type:     "function",
+		Function: schema.FunctionCall{Name: block.Name, Arguments: args},
+	}
+}
+
+// stopReason 把 stop_reason 转换为 finish_reason，未知的值原样保留
+func stopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "refusal":
+		return "content_filter"
+	}
+	return reason
+}
+
+//
+// 流式响应（SSE 事件）
+//
+
+// anthropicEvent 流式响应中的一个事件（只解析用到的字段）
+type anthropicEvent struct {
+	Type         string           `json:"type"`
+	Index        int              `json:"index"`
+	Message      anthropicMessage `json:"message"`       // message_start
+	ContentBlock anthropicBlock   `json:"content_block"` // content_block_start
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"` // message_delta
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamErrorStatus 流中 error 事件的类型对应的 HTTP 状态码，用于重试分类
+var streamErrorStatus = map[string]int{
+	"rate_limit_error": http.StatusTooManyRequests,
+	"api_error":        http.StatusInternalServerError,
+	"overloaded_error": 529,
+}
+
+// streamBlock 流式接收中的内容块
+type streamBlock struct {
+	block                   anthropicBlock
+	text, thinking, partial strings.Builder
+}
+
+// readAnthropicStream 读取 SSE 事件并累积为完整的回复；文字与思考增量到达时调用 emit
+func readAnthropicStream(r io.Reader, emit func(StreamDelta)) (*anthropicMessage, error) {
+	if emit == nil {
+		emit = func(StreamDelta) {}
+	}
+	msg := &anthropicMessage{}
+	blocks := map[int]*streamBlock{}
+	stopped := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return nil, fmt.Errorf("decode stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			msg.Usage = ev.Message.Usage
+		case "content_block_start":
+			sb := &streamBlock{block: ev.ContentBlock}
+			// tool_use 的参数由之后的 input_json_delta 给出
+			sb.block.Input = nil
+			blocks[ev.Index] = sb
+		case "content_block_delta":
+			sb := blocks[ev.Index]
+			if sb == nil {
+				continue
+			}
+			switch ev.Delta.Type {
+			case "text_delta":
+				sb.text.WriteString(ev.Delta.Text)
+				if ev.Delta.Text != "" {
+					emit(StreamDelta{Content: ev.Delta.Text})
+				}
+			case "thinking_delta":
+				sb.thinking.WriteString(ev.Delta.Thinking)
+				if ev.Delta.Thinking != "" {
+					emit(StreamDelta{Thinking: ev.Delta.Thinking})
+				}
+			case "signature_delta":
+				sb.block.Signature += ev.Delta.Signature
+			case "input_json_delta":
+				sb.partial.WriteString(ev.Delta.PartialJSON)
+			}
+		case "message_delta":
+			if ev.Delta.StopReason != "" {
+				msg.StopReason = ev.Delta.StopReason
+			}
+			msg.Usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			stopped = true
+		case "error":
+			return nil, &AnthropicError{StatusCode: streamErrorStatus[ev.Error.Type], Type: ev.Error.Type, Message: ev.Error.Message}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !stopped {
+		return nil, fmt.Errorf("stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+	}
+
+	indexes := make([]int, 0, len(blocks))
+	for i := range blocks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		sb := blocks[i]
+		sb.block.Text += sb.text.String()
+		sb.block.Thinking += sb.thinking.String()
+		if sb.partial.Len() > 0 {
+			sb.block.Input = json.RawMessage(sb.partial.String())
+		}
+		msg.Content = append(msg.Content, sb.block)
+	}
+	return msg, nil
+}
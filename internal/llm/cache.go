@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+)
+
+//
+// 提示缓存：长会话中系统提示和早期上下文每次请求都原样重发，支持缓存的服务端可以复用已处理的前缀。
+// OpenAI、DeepSeek 等自动缓存前缀，不需要标记；Anthropic 系（Claude，包括经 OpenRouter、LiteLLM 等兼容网关访问）
+// 只缓存显式标记了 cache_control 的前缀。
+//
+
+// 提示缓存模式（llm.prompt_caching）
+const (
+	// PromptCachingOff 不发送缓存标记（默认）
+	PromptCachingOff = "off"
+	// PromptCachingAuto 只对需要显式标记的服务端（anthropic provider，或按模型名与 API 地址识别 Anthropic / Claude）发送标记
+	PromptCachingAuto = "auto"
+	// PromptCachingAnthropic 总是发送 Anthropic 风格的 cache_control 标记
+	PromptCachingAnthropic = "anthropic"
+)
+
+// cacheControl Anthropic 风格的缓存断点标记
+var cacheControl = map[string]any{"type": "ephemeral"}
+
+// ValidPromptCaching 是否是支持的提示缓存模式（空字符串等同 off）
+func ValidPromptCaching(mode string) bool {
+	switch mode {
+	case "", PromptCachingOff, PromptCachingAuto, PromptCachingAnthropic:
+		return true
+	}
+	return false
+}
+
+// WithPromptCaching 设置提示缓存模式（PromptCachingOff / Auto / Anthropic）；
+// 启用标记时，系统提示和最后一条 user 消息被标记为缓存断点，之前的内容作为稳定前缀被缓存
+func WithPromptCaching(mode string) ClientOption {
+	return func(c *Client) {
+		c.promptCaching = mode
+	}
+}
+
+// cacheMarkers 本次请求是否发送缓存标记；auto 模式按当前模型与 API 地址判断
+func (c *Client) cacheMarkers() bool {
+	switch c.promptCaching {
+	case PromptCachingAnthropic:
+		return true
+	case PromptCachingAuto:
+		return c.providerName == ProviderAnthropic ||
+			strings.Contains(strings.ToLower(c.model), "claude") ||
+			strings.Contains(strings.ToLower(c.baseURL), "anthropic")
+	}
+	return false
+}
+
+// markCacheBreakpoints 在系统提示（开头连续 system 消息的最后一条）和最后一条 user 消息上设置缓存断点。
+// 一个请求最多两个断点（Anthropic 上限为 4）：系统提示很少变化；最后一条 user 消息之前的历史
+// 在同一轮的多步工具调用之间保持不变
+func markCacheBreakpoints(messages []openai.ChatCompletionMessageParamUnion) {
+	system := -1
+	for i, m := range messages {
+		if m.OfSystem == nil {
+			break
+		}
+		system = i
+	}
+	if system >= 0 {
+		markSystem(messages[system].OfSystem)
+	}
+
+	for i := len(messages) - 1; i > system; i-- {
+		if messages[i].OfUser != nil {
+			markUser(messages[i].OfUser)
+			break
+		}
+	}
+}
+
+// markSystem 把系统提示转为内容块形式，在最后一块上设置 cache_control
+func markSystem(m *openai.ChatCompletionSystemMessageParam) {
+	if m.Content.OfString.Valid() {
+		m.Content.OfArrayOfContentParts = []openai.ChatCompletionContentPartTextParam{{Text: m.Content.OfString.Value}}
+		m.Content.OfString = param.Opt[string]{}
+	}
+	if parts := m.Content.OfArrayOfContentParts; len(parts) > 0 {
+		parts[len(parts)-1].SetExtraFields(map[string]any{"cache_control": cacheControl})
+	}
+}
+
+// markUser 把 user 消息转为内容块形式，在最后一块（文本或图片）上设置 cache_control
+func markUser(m *openai.ChatCompletionUserMessageParam) {
+	if m.Content.OfString.Valid() {
+		m.Content.OfArrayOfContentParts = []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(m.Content.OfString.Value)}
+		m.Content.OfString = param.Opt[string]{}
+	}
+	parts := m.Content.OfArrayOfContentParts
+	if len(parts) == 0 {
+		return
+	}
+	switch last := parts[len(parts)-1]; {
+	case last.OfText != nil:
+		last.OfText.SetExtraFields(map[string]any{"cache_control": cacheControl})
+	case last.OfImageURL != nil:
+		last.OfImageURL.SetExtraFields(map[string]any{"cache_control": cacheControl})
+	}
+}
@@ -2,14 +2,18 @@ package llm
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 
 	"log/slog"
+	"sort"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/packages/respjson"
+	"github.com/openai/openai-go/v3/shared"
 
 	"gopilot-cli/internal/retry"
 	"gopilot-cli/internal/schema"
@@ -20,8 +24,43 @@ import (
 type Client struct {
 	client      openai.Client
 	model       string
+	baseURL     string
 	retryConfig *retry.Config
 	onRetry     retry.OnRetryFunc
+	extraParams map[string]any
+	seed        *int64
+	strictTools bool
+	logprobs    bool
+	topLogprobs int
+	choices     int
+
+	// resendThinking 把历史中 assistant 消息的思考内容作为 reasoning_content 发回
+	resendThinking bool
+
+	// promptCaching 提示缓存模式，见 WithPromptCaching
+	promptCaching string
+
+	// reasoningEffort 推理强度，空字符串表示不发送，见 SetReasoningEffort
+	reasoningEffort string
+
+	// providerName 服务端 API（见 WithProvider），provider 为对应的实现
+	providerName string
+	provider     Provider
+
+	// maxTokens 单次回复的 token 上限，见 WithMaxTokens
+	maxTokens int
+
+	// apiKey 用于 Anthropic 请求认证，以及导出请求体时脱敏
+	apiKey      string
+	payloadDump func(payload []byte)
+}
+
+// reservedParams 由客户端自身构造的请求字段，不允许通过 extra params 覆盖
+var reservedParams = map[string]bool{
+	"model":    true,
+	"messages": true,
+	"tools":    true,
+	"stream":   true,
 }
 
 // ClientOption 客户端选项
@@ -41,6 +80,68 @@ func WithRetryCallback(fn retry.OnRetryFunc) ClientOption {
 	}
 }
 
+// WithExtraParams 设置额外的请求体字段（如 seed、frequency_penalty、logit_bias），
+// 原样合并到每次 chat completion 请求的顶层；model/messages/tools/stream 会被忽略，
+// 字符串形式的 reasoning_effort 等同 WithReasoningEffort
+func WithExtraParams(params map[string]any) ClientOption {
+	return func(c *Client) {
+		c.extraParams = make(map[string]any, len(params))
+		for k, v := range params {
+			if reservedParams[k] {
+				slog.Warn("Ignoring reserved key in extra params", slog.String("key", k))
+				continue
+			}
+			// reasoning_effort 由 SetReasoningEffort 管理，/think 才能在会话中修改它
+			if level, ok := v.(string); ok && k == "reasoning_effort" {
+				if err := c.SetReasoningEffort(level); err == nil {
+					continue
+				}
+			}
+			c.extraParams[k] = v
+		}
+	}
+}
+
+// WithSeed 设置采样随机种子，在服务端支持时获得可复现的输出
+func WithSeed(seed int64) ClientOption {
+	return func(c *Client) {
+		c.seed = &seed
+	}
+}
+
+// WithStrictTools 以严格模式发送工具定义（strict: true，且 schema 禁止额外属性、列全 required）。
+// 需要服务端支持 strict function calling，默认关闭。
+func WithStrictTools(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.strictTools = enabled
+	}
+}
+
+// WithResendThinking 把历史中 assistant 消息的思考内容（Thinking）以 reasoning_content 字段发回模型。
+// 默认关闭：多数服务端不需要也不接受回传的推理内容，且会显著增加上下文
+func WithResendThinking(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.resendThinking = enabled
+	}
+}
+
+// WithLogprobs 请求输出 token 的对数概率并解析到 LLMResponse.Logprobs；
+// topN > 0 时同时返回每个位置概率最高的 topN 个候选（最多 20）。服务端不返回时结果为空。
+func WithLogprobs(topN int) ClientOption {
+	return func(c *Client) {
+		c.logprobs = true
+		c.topLogprobs = min(max(topN, 0), 20)
+	}
+}
+
+// WithChoices 每次请求 n 个候选回复（n 参数），全部解析到 LLMResponse.Choices，
+// 其余字段仍取第一个候选；n <= 1 时不发送（默认单个候选）。多个候选按输出 token 计费
+func WithChoices(n int) ClientOption {
+	return func(c *Client) {
+		c.choices = n
+	}
+}
+
 // NewClient 创建 LLM 客户端
 func NewClient(apiKey, baseURL, model string, opts ...ClientOption) *Client {
 	clientOpts := []option.RequestOption{
@@ -54,14 +155,18 @@ func NewClient(apiKey, baseURL, model string, opts ...ClientOption) *Client {
 	c := &Client{
 		client:      openai.NewClient(clientOpts...),
 		model:       model,
+		baseURL:     baseURL,
 		retryConfig: retry.DefaultConfig(),
+		apiKey:      apiKey,
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.provider = newProvider(c)
 
 	slog.Info("Initialized LLM client",
+		slog.String("provider", c.ProviderName()),
 		slog.String("model", model),
 		slog.String("baseURL", baseURL),
 	)
@@ -69,31 +174,130 @@ func NewClient(apiKey, baseURL, model string, opts ...ClientOption) *Client {
 	return c
 }
 
-// Generate 生成 LLM 响应
-func (c *Client) Generate(ctx context.Context, messages []schema.Message, toolRegistry *tools.ToolRegistry) (*schema.LLMResponse, error) {
+// Model 返回当前使用的模型名称
+func (c *Client) Model() string {
+	return c.model
+}
+
+// SetModel 切换后续请求使用的模型
+func (c *Client) SetModel(model string) {
+	c.model = model
+	slog.Info("Switched LLM model", slog.String("model", model))
+}
+
+// RetryConfig 返回客户端使用的重试配置；客户端持有的是指针，修改会作用于之后的请求
+func (c *Client) RetryConfig() *retry.Config {
+	return c.retryConfig
+}
+
+// Generate 生成 LLM 响应；opts 只作用于本次调用（如 WithToolChoice）
+func (c *Client) Generate(ctx context.Context, messages []schema.Message, toolRegistry *tools.ToolRegistry, opts ...GenerateOption) (*schema.LLMResponse, error) {
+	var gen generateOptions
+	for _, opt := range opts {
+		opt(&gen)
+	}
+	req := &Request{Messages: messages, Tools: toolRegistry, ToolChoice: gen.toolChoice}
 	return retry.Do(ctx, c.retryConfig, func() (*schema.LLMResponse, error) {
-		return c.doGenerate(ctx, messages, toolRegistry)
+		return c.provider.Generate(ctx, req)
 	}, c.onRetry)
 }
 
-func (c *Client) doGenerate(ctx context.Context, messages []schema.Message, toolRegistry *tools.ToolRegistry) (*schema.LLMResponse, error) {
-	chatMessages := c.convertMessages(messages)
+// openAIProvider OpenAI 兼容的 Chat Completions API
+type openAIProvider struct {
+	c *Client
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, req *Request) (*schema.LLMResponse, error) {
+	c := p.c
+	params := c.buildParams(req)
+	c.dumpPayload(params)
 
+	completion, err := c.client.Chat.Completions.New(ctx, params, c.requestOptions()...)
+	if err != nil {
+		return nil, c.wrapError(err)
+	}
+
+	return c.parseResponse(completion), nil
+}
+
+// CountTokens Chat Completions 没有计数接口，使用本地 tokenizer 估算
+func (p *openAIProvider) CountTokens(_ context.Context, req *Request) (int, error) {
+	return estimateTokens(req)
+}
+
+// buildParams 构造 chat completion 请求参数（Generate 与 GenerateStream 共用）
+func (c *Client) buildParams(req *Request) openai.ChatCompletionNewParams {
 	params := openai.ChatCompletionNewParams{
 		Model:    c.model,
-		Messages: chatMessages,
+		Messages: c.convertMessages(req.Messages),
 	}
 
-	if toolRegistry != nil && len(toolRegistry.List()) > 0 {
-		params.Tools = c.convertTools(toolRegistry)
+	if c.seed != nil {
+		params.Seed = openai.Int(*c.seed)
 	}
 
-	completion, err := c.client.Chat.Completions.New(ctx, params)
-	if err != nil {
-		return nil, fmt.Errorf("chat completion failed: %w", err)
+	if c.logprobs {
+		params.Logprobs = openai.Bool(true)
+		if c.topLogprobs > 0 {
+			params.TopLogprobs = openai.Int(int64(c.topLogprobs))
+		}
 	}
 
-	return c.parseResponse(completion), nil
+	if c.choices > 1 {
+		params.N = openai.Int(int64(c.choices))
+	}
+
+	if c.reasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(c.reasoningEffort)
+	}
+
+	if req.Tools != nil && len(req.Tools.List()) > 0 {
+		params.Tools = c.convertTools(req.Tools)
+		if req.ToolChoice != "" {
+			params.ToolChoice = toolChoiceParam(req.ToolChoice)
+		}
+	}
+	return params
+}
+
+// wrapError 把请求错误转换为便于诊断的错误；配置类错误标记为不可重试
+func (c *Client) wrapError(err error) error {
+	if c.reasoningEffort != "" && isReasoningRejected(err) {
+		return retry.Permanent(&ReasoningUnsupportedError{Model: c.model, Level: c.reasoningEffort, Err: err})
+	}
+	if isModelNotFound(err) {
+		// 配置错误，重试没有意义
+		return retry.Permanent(&ModelNotFoundError{Model: c.model, BaseURL: c.baseURL, Err: err})
+	}
+	return fmt.Errorf("chat completion failed: %w", err)
+}
+
+// requestOptions 单次请求的选项：按类别配置了重试策略时关闭 SDK 自带的重试，
+// 由 retry.Do 统一按策略处理，避免两层重试叠加
+func (c *Client) requestOptions() []option.RequestOption {
+	opts := c.extraParamOptions()
+	if c.retryConfig.HasPolicies() {
+		opts = append(opts, option.WithMaxRetries(0))
+	}
+	return opts
+}
+
+// extraParamOptions 将 extra params 转为请求选项（按 key 排序，保证请求体稳定）
+func (c *Client) extraParamOptions() []option.RequestOption {
+	if len(c.extraParams) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(c.extraParams))
+	for k := range c.extraParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	opts := make([]option.RequestOption, 0, len(keys))
+	for _, k := range keys {
+		opts = append(opts, option.WithJSONSet(k, c.extraParams[k]))
+	}
+	return opts
 }
 
 // convertMessages 转换消息格式
@@ -107,6 +311,10 @@ func (c *Client) convertMessages(messages []schema.Message) []openai.ChatComplet
 			result = append(result, openai.SystemMessage(msg.Content))
 
 		case "user":
+			if len(msg.Images) > 0 {
+				result = append(result, openai.UserMessage(userContentParts(msg)))
+				continue
+			}
 			// 使用辅助函数 UserMessage
 			result = append(result, openai.UserMessage(msg.Content))
 
@@ -146,6 +354,9 @@ func (c *Client) convertMessages(messages []schema.Message) []openai.ChatComplet
 				// 没有工具调用，使用辅助函数
 				result = append(result, openai.AssistantMessage(msg.Content))
 			}
+			if c.resendThinking && msg.Thinking != "" {
+				result[len(result)-1].OfAssistant.SetExtraFields(map[string]any{"reasoning_content": msg.Thinking})
+			}
 
 		case "tool":
 			// 使用辅助函数 ToolMessage
@@ -153,20 +364,67 @@ func (c *Client) convertMessages(messages []schema.Message) []openai.ChatComplet
 		}
 	}
 
+	if c.cacheMarkers() {
+		markCacheBreakpoints(result)
+	}
+
 	return result
 }
 
+// thinkingText 从消息（或流式增量）的额外字段中提取思考内容；不同服务端使用的字段名不同
+func thinkingText(fields map[string]respjson.Field) string {
+	var text string
+	for k, v := range fields {
+		switch k {
+		case "reasoning_content",
+			"thoughts",
+			"internal_thoughts",
+			"reasoning":
+			text = rawText(v.Raw())
+		}
+	}
+	return text
+}
+
+// rawText 额外字段的原始 JSON 是字符串时返回解码后的文本，否则原样返回
+func rawText(raw string) string {
+	var text string
+	if err := json.Unmarshal([]byte(raw), &text); err == nil {
+		return text
+	}
+	return raw
+}
+
+// userContentParts 将带图片的 user 消息转换为多模态内容（图片以 data URL 内联）
+func userContentParts(msg schema.Message) []openai.ChatCompletionContentPartUnionParam {
+	parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(msg.Images)+1)
+	if msg.Content != "" {
+		parts = append(parts, openai.TextContentPart(msg.Content))
+	}
+	for _, img := range msg.Images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: "data:" + img.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(img.Data),
+		}))
+	}
+	return parts
+}
+
 // convertTools 转换工具格式
 func (c *Client) convertTools(registry *tools.ToolRegistry) []openai.ChatCompletionToolUnionParam {
 	toolList := registry.List()
 	result := make([]openai.ChatCompletionToolUnionParam, 0, len(toolList))
 
 	for _, tool := range toolList {
-		result = append(result, openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+		def := openai.FunctionDefinitionParam{
 			Name:        tool.Name(),
 			Description: openai.String(tool.Description()),
 			Parameters:  openai.FunctionParameters(tool.Parameters()),
-		}))
+		}
+		if c.strictTools {
+			def.Parameters = openai.FunctionParameters(tools.StrictSchema(tool.Parameters()))
+			def.Strict = openai.Bool(true)
+		}
+		result = append(result, openai.ChatCompletionFunctionTool(def))
 	}
 
 	return result
@@ -175,32 +433,63 @@ func (c *Client) convertTools(registry *tools.ToolRegistry) []openai.ChatComplet
 // parseResponse 解析 API 响应
 func (c *Client) parseResponse(completion *openai.ChatCompletion) *schema.LLMResponse {
 	if len(completion.Choices) == 0 {
-		return &schema.LLMResponse{FinishReason: "unknown"}
+		return &schema.LLMResponse{FinishReason: "unknown", SystemFingerprint: completion.SystemFingerprint}
+	}
+
+	choices := make([]schema.Choice, len(completion.Choices))
+	for i, choice := range completion.Choices {
+		choices[i] = parseChoice(choice)
 	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].Index < choices[j].Index })
 
-	message := completion.Choices[0].Message
+	first := choices[0]
 	response := &schema.LLMResponse{
-		Content:      message.Content,
-		FinishReason: string(completion.Choices[0].FinishReason),
+		Content:           first.Content,
+		Thinking:          first.Thinking,
+		ToolCalls:         first.ToolCalls,
+		FinishReason:      first.FinishReason,
+		SystemFingerprint: completion.SystemFingerprint,
+		Logprobs:          first.Logprobs,
+		Choices:           choices,
 	}
 
-	// 提取 thinking 内容
-	for k, v := range message.JSON.ExtraFields {
-		switch k {
-		case "reasoning_content",
-			"thoughts",
-			"internal_thoughts",
-			"reasoning":
-			response.Thinking = v.Raw()
+	if completion.JSON.Usage.Valid() {
+		response.Usage = &schema.Usage{
+			PromptTokens:     int(completion.Usage.PromptTokens),
+			CompletionTokens: int(completion.Usage.CompletionTokens),
+			TotalTokens:      int(completion.Usage.TotalTokens),
 		}
 	}
 
-	// 解析工具调用
+	return response
+}
+
+// parseChoice 解析一个候选的内容、思考、工具调用与对数概率
+func parseChoice(choice openai.ChatCompletionChoice) schema.Choice {
+	message := choice.Message
+	parsed := schema.Choice{
+		Index:        int(choice.Index),
+		Content:      message.Content,
+		FinishReason: string(choice.FinishReason),
+		Logprobs:     parseLogprobs(choice.Logprobs),
+	}
+
+	// 提取 thinking 内容
+	parsed.Thinking = thinkingText(message.JSON.ExtraFields)
+
+	// 解析工具调用（参数为空或非法时降级为空参数，避免 nil map 传给工具）
 	for _, tc := range message.ToolCalls {
-		var args map[string]any
-		json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		args, err := parseToolArguments(tc.Function.Arguments)
+		if err != nil {
+			slog.Warn("Invalid tool call arguments",
+				slog.String("tool", tc.Function.Name),
+				slog.String("arguments", tc.Function.Arguments),
+				slog.String("err", err.Error()),
+			)
+			args = map[string]any{}
+		}
 
-		response.ToolCalls = append(response.ToolCalls, schema.ToolCall{
+		parsed.ToolCalls = append(parsed.ToolCalls, schema.ToolCall{
 			ID:   tc.ID,
 			Type: "function",
 			Function: schema.FunctionCall{
@@ -210,5 +499,21 @@ func (c *Client) parseResponse(completion *openai.ChatCompletion) *schema.LLMRes
 		})
 	}
 
-	return response
+	return parsed
+}
+
+// parseLogprobs 转换输出内容的 token 对数概率（服务端未返回时为 nil）
+func parseLogprobs(lp openai.ChatCompletionChoiceLogprobs) []schema.TokenLogprob {
+	if len(lp.Content) == 0 {
+		return nil
+	}
+	out := make([]schema.TokenLogprob, 0, len(lp.Content))
+	for _, tok := range lp.Content {
+		entry := schema.TokenLogprob{Token: tok.Token, Logprob: tok.Logprob}
+		for _, top := range tok.TopLogprobs {
+			entry.TopLogprobs = append(entry.TopLogprobs, schema.TopLogprob{Token: top.Token, Logprob: top.Logprob})
+		}
+		out = append(out, entry)
+	}
+	return out
 }
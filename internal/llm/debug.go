@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+)
+
+//
+// ---------------------------------------------------------
+// 调试：导出实际发送的请求体
+// ---------------------------------------------------------
+//
+
+// redacted 替换敏感值的占位符
+const redacted = "[REDACTED]"
+
+// secretKeys 请求体中视为敏感信息的字段名（小写比较）
+var secretKeys = map[string]bool{
+	"api_key":       true,
+	"apikey":        true,
+	"api-key":       true,
+	"authorization": true,
+	"access_token":  true,
+	"secret":        true,
+	"password":      true,
+}
+
+// WithPayloadDump 每次调用前把序列化后的请求体（含 extra params，已脱敏）交给 fn，
+// 用于排查服务端不兼容、工具 schema 不被识别等问题。默认关闭。
+func WithPayloadDump(fn func(payload []byte)) ClientOption {
+	return func(c *Client) {
+		c.payloadDump = fn
+	}
+}
+
+// dumpPayload 序列化并脱敏请求体后交给 payloadDump；序列化失败时输出错误说明
+func (c *Client) dumpPayload(params openai.ChatCompletionNewParams) {
+	if c.payloadDump == nil {
+		return
+	}
+	c.payloadDump(c.payloadJSON(params))
+}
+
+// payloadJSON 生成与实际请求一致的 JSON：合并 extra params，敏感字段与 API key 均被替换
+func (c *Client) payloadJSON(params openai.ChatCompletionNewParams) []byte {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return []byte(`{"error": "cannot serialize request: ` + err.Error() + `"}`)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return raw
+	}
+	for k, v := range c.extraParams {
+		body[k] = v
+	}
+	return c.redactedJSON(body, raw)
+}
+
+// dumpBody 导出已经合并 extra params 的请求体（Anthropic 等直接以 JSON 对象构造请求的 Provider）
+func (c *Client) dumpBody(body map[string]any) {
+	if c.payloadDump == nil {
+		return
+	}
+	// 脱敏会修改 map，先复制一份，避免影响实际发送的请求
+	raw, err := json.Marshal(body)
+	if err != nil {
+		c.payloadDump([]byte(`{"error": "cannot serialize request: ` + err.Error() + `"}`))
+		return
+	}
+	var copied map[string]any
+	if err := json.Unmarshal(raw, &copied); err != nil {
+		c.payloadDump(raw)
+		return
+	}
+	c.payloadDump(c.redactedJSON(copied, raw))
+}
+
+// redactedJSON 替换 body 中的敏感字段与 API key 后格式化输出；失败时返回 raw
+func (c *Client) redactedJSON(body map[string]any, raw []byte) []byte {
+	out, err := json.MarshalIndent(redactSecrets(body), "", "  ")
+	if err != nil {
+		return raw
+	}
+	if c.apiKey != "" {
+		out = []byte(strings.ReplaceAll(string(out), c.apiKey, redacted))
+	}
+	return out
+}
+
+// redactSecrets 递归替换敏感字段的值
+func redactSecrets(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if secretKeys[strings.ToLower(k)] {
+				val[k] = redacted
+				continue
+			}
+			val[k] = redactSecrets(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = redactSecrets(item)
+		}
+	}
+	return v
+}
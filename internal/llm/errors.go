@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+
+	"gopilot-cli/internal/retry"
+)
+
+// ModelNotFoundError 服务端报告模型不存在或不可用（通常是 llm.model 配置错误）
+type ModelNotFoundError struct {
+	Model   string
+	BaseURL string
+	Err     error // 原始 SDK 错误
+}
+
+func (e *ModelNotFoundError) Error() string {
+	endpoint := e.BaseURL
+	if endpoint == "" {
+		endpoint = "the default OpenAI endpoint"
+	}
+	return fmt.Sprintf("model %q is not available at %s; check llm.model (or --model) and the models your provider offers", e.Model, endpoint)
+}
+
+func (e *ModelNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// AnthropicError Anthropic API 返回的错误响应
+type AnthropicError struct {
+	StatusCode int
+	Type       string // 错误类型，如 invalid_request_error、not_found_error、overloaded_error
+	Message    string
+	Response   *http.Response // 用于读取 Retry-After
+}
+
+func (e *AnthropicError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("anthropic API error %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("anthropic API error %d (%s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// modelNotFoundRe 各服务端"模型不存在"错误信息的常见写法
+var modelNotFoundRe = regexp.MustCompile(`(?i)model\b.*\b(not found|does not exist|not exist|not available|not supported|unknown|invalid|no such)|\b(invalid|unknown|unsupported)\s+model|no such model`)
+
+// isModelNotFound 判断错误是否为模型不存在 / 模型名非法（404 或 400，且错误码或信息指向模型）
+func isModelNotFound(err error) bool {
+	var anthropicErr *AnthropicError
+	if errors.As(err, &anthropicErr) {
+		if anthropicErr.StatusCode != http.StatusNotFound && anthropicErr.StatusCode != http.StatusBadRequest {
+			return false
+		}
+		// 模型不存在时为 not_found_error，信息形如 "model: claude-xxx"
+		return modelNotFoundRe.MatchString(anthropicErr.Message) ||
+			anthropicErr.Type == "not_found_error" && strings.Contains(strings.ToLower(anthropicErr.Message), "model")
+	}
+
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == "model_not_found" {
+		return true
+	}
+	if apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return modelNotFoundRe.MatchString(apiErr.Message)
+}
+
+// ClassifyError 将 chat completion 的错误归类，供 retry.Config.Classifier 使用：
+// 429 为限流（同时解析 Retry-After），5xx（含 Anthropic 的 529 过载）为服务端错误，连接失败 / 超时 / 连接中断为网络错误
+func ClassifyError(err error) (retry.Class, time.Duration) {
+	var anthropicErr *AnthropicError
+	if errors.As(err, &anthropicErr) {
+		switch {
+		case anthropicErr.StatusCode == http.StatusTooManyRequests:
+			return retry.ClassRateLimit, retryAfter(anthropicErr.Response)
+		case anthropicErr.StatusCode >= 500:
+			return retry.ClassServer, retryAfter(anthropicErr.Response)
+		}
+		return retry.ClassOther, 0
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return retry.ClassRateLimit, retryAfter(apiErr.Response)
+		case apiErr.StatusCode >= 500:
+			return retry.ClassServer, retryAfter(apiErr.Response)
+		}
+		return retry.ClassOther, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return retry.ClassNetwork, 0
+	}
+	return retry.ClassOther, 0
+}
+
+// retryAfter 解析响应中的 retry-after-ms / Retry-After（秒数或 HTTP 日期），没有时返回 0
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(strings.TrimSpace(resp.Header.Get("Retry-After-Ms")), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"github.com/openai/openai-go/v3"
+)
+
+// tool_choice 的取值；其他值视为要求调用的工具名
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// GenerateOption 单次 Generate 调用的可选参数
+type GenerateOption func(*generateOptions)
+
+type generateOptions struct {
+	toolChoice string
+}
+
+// WithToolChoice 设置本次请求的 tool_choice：auto / none（只回复文字，不调用工具）/ required，
+// 或指定必须调用的工具名。没有发送工具时忽略
+func WithToolChoice(choice string) GenerateOption {
+	return func(o *generateOptions) {
+		o.toolChoice = choice
+	}
+}
+
+func toolChoiceParam(choice string) openai.ChatCompletionToolChoiceOptionUnionParam {
+	switch choice {
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(choice)}
+	}
+	return openai.ChatCompletionToolChoiceOptionUnionParam{
+		OfFunctionToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+			Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice},
+		},
+	}
+}
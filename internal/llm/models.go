@@ -0,0 +1,77 @@
+package llm
+
+import "strings"
+
+// modelContextWindows 常见模型的上下文窗口（token），按前缀匹配，越具体的前缀越靠前
+var modelContextWindows = []struct {
+	prefix string
+	window int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"gpt-5", 400000},
+	{"gpt-oss", 131072},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"gemini", 1048576},
+	{"deepseek", 128000},
+	{"qwen", 131072},
+	{"glm", 128000},
+	{"kimi", 131072},
+	{"minimax", 1000000},
+}
+
+// ContextWindow 返回已知模型的上下文窗口大小；未知模型返回 false。
+// 会忽略 "provider/" 形式的前缀（如 openai/gpt-4o）。
+func ContextWindow(model string) (int, bool) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, m := range modelContextWindows {
+		if strings.HasPrefix(name, m.prefix) {
+			return m.window, true
+		}
+	}
+	return 0, false
+}
+
+// modelPrices 常见模型的公开价格（美元 / 百万 token，输入、输出），按前缀匹配，越具体越靠前。
+// 仅用于估算会话花费；价格变化或自建服务请在配置中覆盖。
+var modelPrices = []struct {
+	prefix        string
+	input, output float64
+}{
+	{"gpt-4.1-nano", 0.10, 0.40},
+	{"gpt-4.1-mini", 0.40, 1.60},
+	{"gpt-4.1", 2.00, 8.00},
+	{"gpt-4o-mini", 0.15, 0.60},
+	{"gpt-4o", 2.50, 10.00},
+	{"gpt-5-nano", 0.05, 0.40},
+	{"gpt-5-mini", 0.25, 2.00},
+	{"gpt-5", 1.25, 10.00},
+	{"gpt-3.5-turbo", 0.50, 1.50},
+	{"o4-mini", 1.10, 4.40},
+	{"o3-mini", 1.10, 4.40},
+	{"o3", 2.00, 8.00},
+	{"o1", 15.00, 60.00},
+}
+
+// ModelPricing 返回已知模型每百万 token 的输入、输出价格（美元）；未知模型返回 false
+func ModelPricing(model string) (input, output float64, ok bool) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, p := range modelPrices {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.input, p.output, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tokenizer"
+	"gopilot-cli/internal/tools"
+)
+
+//
+// ---------------------------------------------------------
+// Provider（不同服务端 API 的实现）
+// ---------------------------------------------------------
+//
+// Client 负责模型切换、重试、推理强度、extra params 等与服务端无关的设置；
+// 请求的构造、发送与响应的解析由 Provider 完成。Agent、Summarizer 只依赖 Client，
+// 切换 llm.provider 不需要任何改动。
+
+// 支持的 Provider（llm.provider）
+const (
+	// ProviderOpenAI OpenAI 兼容的 Chat Completions API（默认）
+	ProviderOpenAI = "openai"
+	// ProviderAnthropic Anthropic Messages API
+	ProviderAnthropic = "anthropic"
+)
+
+// Provider 一种服务端 API 的实现；每次调用读取 Client 当前的模型与设置
+type Provider interface {
+	// Generate 发送请求并返回完整响应
+	Generate(ctx context.Context, req *Request) (*schema.LLMResponse, error)
+	// GenerateStream 使用流式 API，回复文字与思考内容到达时调用 emit，返回的完整响应与 Generate 一致
+	GenerateStream(ctx context.Context, req *Request, emit func(StreamDelta)) (*schema.LLMResponse, error)
+	// CountTokens 返回请求（消息与工具定义）占用的输入 token 数
+	CountTokens(ctx context.Context, req *Request) (int, error)
+}
+
+// Request 一次模型调用的内容
+type Request struct {
+	Messages   []schema.Message
+	Tools      *tools.ToolRegistry
+	ToolChoice string // 见 WithToolChoice
+}
+
+// ValidProvider 是否是支持的 Provider（空字符串等同 openai）
+func ValidProvider(name string) bool {
+	switch name {
+	case "", ProviderOpenAI, ProviderAnthropic:
+		return true
+	}
+	return false
+}
+
+// WithProvider 选择服务端 API（ProviderOpenAI / ProviderAnthropic），默认 OpenAI 兼容
+func WithProvider(name string) ClientOption {
+	return func(c *Client) {
+		c.providerName = name
+	}
+}
+
+// newProvider 按名称创建 Provider；未知名称使用 OpenAI 兼容 API
+func newProvider(c *Client) Provider {
+	if c.providerName == ProviderAnthropic {
+		return newAnthropicProvider(c)
+	}
+	return &openAIProvider{c: c}
+}
+
+// ProviderName 返回使用的服务端 API（openai / anthropic）
+func (c *Client) ProviderName() string {
+	if c.providerName == ProviderAnthropic {
+		return ProviderAnthropic
+	}
+	return ProviderOpenAI
+}
+
+// CountTokens 返回 messages 与工具定义占用的输入 token 数：Anthropic 由服务端计算，
+// OpenAI 兼容 API 没有计数接口，使用本地 tokenizer 估算
+func (c *Client) CountTokens(ctx context.Context, messages []schema.Message, toolRegistry *tools.ToolRegistry) (int, error) {
+	req := &Request{Messages: messages, Tools: toolRegistry}
+	return retry.Do(ctx, c.retryConfig, func() (int, error) {
+		return c.provider.CountTokens(ctx, req)
+	}, c.onRetry)
+}
+
+// estimateTokens 本地估算请求的输入 token 数（工具定义按其 JSON schema 计入）
+func estimateTokens(req *Request) (int, error) {
+	msgs := req.Messages
+	if req.Tools != nil && len(req.Tools.List()) > 0 {
+		defs := make([]map[string]any, 0, len(req.Tools.List()))
+		for _, tool := range req.Tools.List() {
+			defs = append(defs, map[string]any{
+				"name":        tool.Name(),
+				"description": tool.Description(),
+				"parameters":  tool.Parameters(),
+			})
+		}
+		raw, err := json.Marshal(defs)
+		if err != nil {
+			return 0, fmt.Errorf("serialize tool definitions: %w", err)
+		}
+		msgs = append(append([]schema.Message(nil), msgs...), schema.Message{Role: "system", Content: string(raw)})
+	}
+	return tokenizer.EstimateTokens(msgs), nil
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+)
+
+//
+// 推理强度（reasoning_effort）：支持的模型按强度决定思考的深度，可在会话中用 /think 随时调整
+//
+
+// ReasoningLevels 支持的推理强度，从低到高
+var ReasoningLevels = []string{"minimal", "low", "medium", "high"}
+
+// DefaultReasoningLevel /think on 使用的推理强度
+const DefaultReasoningLevel = "medium"
+
+// reasoningModels 已知接受 reasoning_effort 的模型前缀（OpenAI 推理模型，以及 OpenAI 兼容接口下的 Gemini 2.5、Grok mini）
+var reasoningModels = []string{"o1", "o3", "o4", "gpt-5", "gpt-oss", "gemini-2.5", "grok-3-mini"}
+
+// SupportsReasoningEffort 模型是否已知接受 reasoning_effort；未知模型返回 false，但服务端仍可能接受
+func SupportsReasoningEffort(model string) bool {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prefix := range reasoningModels {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseReasoningLevel 解析 /think 与配置中的推理强度："on" 为 DefaultReasoningLevel，"off" 或空字符串为不发送
+func ParseReasoningLevel(s string) (string, error) {
+	level := strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case level == "" || level == "off":
+		return "", nil
+	case level == "on":
+		return DefaultReasoningLevel, nil
+	case slices.Contains(ReasoningLevels, level):
+		return level, nil
+	}
+	return "", fmt.Errorf("invalid reasoning level %q (want on, off or one of %s)", s, strings.Join(ReasoningLevels, ", "))
+}
+
+// WithReasoningEffort 设置初始推理强度（见 ParseReasoningLevel）；无效的值被忽略
+func WithReasoningEffort(level string) ClientOption {
+	return func(c *Client) {
+		if err := c.SetReasoningEffort(level); err != nil {
+			slog.Warn("Ignoring reasoning effort", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// ReasoningEffort 返回当前的推理强度；空字符串表示不发送 reasoning_effort（使用模型默认）
+func (c *Client) ReasoningEffort() string {
+	return c.reasoningEffort
+}
+
+// SetReasoningEffort 修改之后请求的推理强度（on / off / minimal / low / medium / high）
+func (c *Client) SetReasoningEffort(level string) error {
+	parsed, err := ParseReasoningLevel(level)
+	if err != nil {
+		return err
+	}
+	c.reasoningEffort = parsed
+	slog.Info("Set reasoning effort", slog.String("level", parsed))
+	return nil
+}
+
+// ReasoningUnsupportedError 服务端拒绝了 reasoning_effort（当前模型不支持推理强度或该档位）
+type ReasoningUnsupportedError struct {
+	Model string
+	Level string
+	Err   error // 原始 SDK 错误
+}
+
+func (e *ReasoningUnsupportedError) Error() string {
+	return fmt.Sprintf("model %q does not accept reasoning effort %q; use /think off (or another level) or unset llm.reasoning_effort", e.Model, e.Level)
+}
+
+func (e *ReasoningUnsupportedError) Unwrap() error {
+	return e.Err
+}
+
+// isReasoningRejected 判断 400 错误是否由 reasoning_effort 引起
+func isReasoningRejected(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return apiErr.Param == "reasoning_effort" || reasoningRejectedRe.MatchString(apiErr.Message)
+}
+
+var reasoningRejectedRe = regexp.MustCompile(`(?i)reasoning[_ .]effort`)
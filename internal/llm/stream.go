@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+//
+// ---------------------------------------------------------
+// 流式响应（stream: true）
+// ---------------------------------------------------------
+//
+// 回复文字与思考内容一到达就交给回调，调用方可以边接收边显示，不必等待整个回复生成完毕；
+// 工具调用的参数片段在内部合并，最终结果与 Generate 相同。
+
+// StreamDelta 流式响应中到达的一段增量（只包含第一个候选）
+type StreamDelta struct {
+	Content  string
+	Thinking string
+}
+
+// GenerateStream 与 Generate 相同，但使用流式 API：每段回复文字或思考内容到达时调用 onDelta，
+// 返回的完整响应与 Generate 一致。已经输出增量之后出错时不再重试（重试会重复输出），直接返回错误
+func (c *Client) GenerateStream(ctx context.Context, messages []schema.Message, toolRegistry *tools.ToolRegistry, onDelta func(StreamDelta), opts ...GenerateOption) (*schema.LLMResponse, error) {
+	var gen generateOptions
+	for _, opt := range opts {
+		opt(&gen)
+	}
+	emitted := false
+	emit := func(d StreamDelta) {
+		emitted = true
+		if onDelta != nil {
+			onDelta(d)
+		}
+	}
+	req := &Request{Messages: messages, Tools: toolRegistry, ToolChoice: gen.toolChoice}
+	return retry.Do(ctx, c.retryConfig, func() (*schema.LLMResponse, error) {
+		resp, err := c.provider.GenerateStream(ctx, req, emit)
+		if err != nil && emitted {
+			return nil, retry.Permanent(err)
+		}
+		return resp, err
+	}, c.onRetry)
+}
+
+// streamChoice 一个候选在流式接收中累积的内容
+type streamChoice struct {
+	content  strings.Builder
+	thinking strings.Builder
+	calls    *ToolCallAccumulator
+	finish   string
+	logprobs []openai.ChatCompletionTokenLogprob
+}
+
+func (p *openAIProvider) GenerateStream(ctx context.Context, req *Request, emit func(StreamDelta)) (*schema.LLMResponse, error) {
+	c := p.c
+	params := c.buildParams(req)
+	// 流式响应默认不带用量，要求服务端在最后一个 chunk 中返回，花费统计才不会缺失
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	c.dumpPayload(params)
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params, c.requestOptions()...)
+	defer stream.Close()
+
+	choices := map[int]*streamChoice{}
+	response := &schema.LLMResponse{}
+	for stream.Next() {
+		chunk := stream.Current()
+		if chunk.SystemFingerprint != "" {
+			response.SystemFingerprint = chunk.SystemFingerprint
+		}
+		if chunk.JSON.Usage.Valid() {
+			response.Usage = &schema.Usage{
+				PromptTokens:     int(chunk.Usage.PromptTokens),
+				CompletionTokens: int(chunk.Usage.CompletionTokens),
+				TotalTokens:      int(chunk.Usage.TotalTokens),
+			}
+		}
+
+		for _, choice := range chunk.Choices {
+			index := int(choice.Index)
+			sc, ok := choices[index]
+			if !ok {
+				sc = &streamChoice{calls: NewToolCallAccumulator()}
+				choices[index] = sc
+			}
+
+			delta := choice.Delta
+			thinking := thinkingText(delta.JSON.ExtraFields)
+			sc.content.WriteString(delta.Content)
+			sc.thinking.WriteString(thinking)
+			for _, tc := range delta.ToolCalls {
+				fragIndex := int(tc.Index)
+				if !tc.JSON.Index.Valid() {
+					fragIndex = -1
+				}
+				sc.calls.Add(ToolCallFragment{
+					Index:     fragIndex,
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
+			}
+			if choice.FinishReason != "" {
+				sc.finish = choice.FinishReason
+			}
+			sc.logprobs = append(sc.logprobs, choice.Logprobs.Content...)
+
+			if index == 0 && (delta.Content != "" || thinking != "") {
+				emit(StreamDelta{Content: delta.Content, Thinking: thinking})
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, c.wrapError(err)
+	}
+
+	if len(choices) == 0 {
+		response.FinishReason = "unknown"
+		return response, nil
+	}
+	indexes := make([]int, 0, len(choices))
+	for i := range choices {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		response.Choices = append(response.Choices, choices[i].result(i))
+	}
+
+	first := response.Choices[0]
+	response.Content = first.Content
+	response.Thinking = first.Thinking
+	response.ToolCalls = first.ToolCalls
+	response.FinishReason = first.FinishReason
+	response.Logprobs = first.Logprobs
+	return response, nil
+}
+
+// result 把累积的内容转换为候选；参数无法解析的工具调用与非流式一样降级为空参数
+func (sc *streamChoice) result(index int) schema.Choice {
+	return schema.Choice{
+		Index:        index,
+		Content:      sc.content.String(),
+		Thinking:     sc.thinking.String(),
+		ToolCalls:    sc.calls.lenientToolCalls(),
+		FinishReason: sc.finish,
+		Logprobs:     parseLogprobs(openai.ChatCompletionChoiceLogprobs{Content: sc.logprobs}),
+	}
+}
+
+// lenientToolCalls 与 ToolCalls 相同，但参数无法解析的调用记录警告并使用空参数，而不是整体失败
+func (a *ToolCallAccumulator) lenientToolCalls() []schema.ToolCall {
+	if len(a.calls) == 0 {
+		return nil
+	}
+	calls, _ := a.build(false)
+	return calls
+}
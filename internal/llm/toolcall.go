@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"gopilot-cli/internal/schema"
+)
+
+//
+// ---------------------------------------------------------
+// Tool Call Fragments（流式工具调用片段合并）
+// ---------------------------------------------------------
+//
+
+// ToolCallFragment 流式响应中的一个工具调用片段（delta）。
+// 同一个工具调用的参数会被拆成多段到达，只有首段通常携带 ID 和函数名。
+// Index < 0 表示片段没有携带 index，此时按 ID 归并。
+type ToolCallFragment struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// pendingToolCall 合并中的工具调用
+type pendingToolCall struct {
+	index int // 服务端给出的 index；< 0 表示片段没有携带 index
+	id    string
+	name  string
+	args  strings.Builder
+}
+
+// ToolCallAccumulator 按 index / id 累积工具调用片段。
+// 参数按到达顺序拼接，全部片段到齐后再统一解析 JSON，
+// 避免在半个 token（甚至半个 UTF-8 字符）处解析导致参数损坏。
+type ToolCallAccumulator struct {
+	calls   []*pendingToolCall
+	byIndex map[int]*pendingToolCall
+	byID    map[string]*pendingToolCall
+	last    *pendingToolCall
+}
+
+// NewToolCallAccumulator 创建片段累积器
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{
+		byIndex: make(map[int]*pendingToolCall),
+		byID:    make(map[string]*pendingToolCall),
+	}
+}
+
+// Add 加入一个片段
+func (a *ToolCallAccumulator) Add(f ToolCallFragment) {
+	call := a.lookup(f)
+	if call == nil {
+		call = &pendingToolCall{index: f.Index}
+		a.calls = append(a.calls, call)
+		if f.Index >= 0 {
+			a.byIndex[f.Index] = call
+		}
+	}
+
+	if f.ID != "" && call.id == "" {
+		call.id = f.ID
+		a.byID[f.ID] = call
+	}
+	if f.Name != "" && call.name == "" {
+		call.name = f.Name
+	}
+	call.args.WriteString(f.Arguments)
+	a.last = call
+}
+
+// lookup 查找片段所属的工具调用：优先 index，其次 id，
+// 两者都缺失时视为上一个调用的后续片段。
+func (a *ToolCallAccumulator) lookup(f ToolCallFragment) *pendingToolCall {
+	if f.Index >= 0 {
+		if call, ok := a.byIndex[f.Index]; ok {
+			return call
+		}
+		return nil
+	}
+	if f.ID != "" {
+		return a.byID[f.ID]
+	}
+	return a.last
+}
+
+// ordered 返回排序后的调用：带 index 的按 index 排序，没有 index 的按到达顺序排在所有带 index 的调用之后
+func (a *ToolCallAccumulator) ordered() []*pendingToolCall {
+	ordered := make([]*pendingToolCall, len(a.calls))
+	copy(ordered, a.calls)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		x, y := ordered[i], ordered[j]
+		if (x.index < 0) != (y.index < 0) {
+			return y.index < 0
+		}
+		return x.index >= 0 && x.index < y.index
+	})
+	return ordered
+}
+
+// ToolCalls 返回按 index 排序的完整工具调用（没有 index 的调用排在最后）。
+// 任一调用的参数无法解析时返回错误（错误信息包含工具名和原始参数）。
+func (a *ToolCallAccumulator) ToolCalls() ([]schema.ToolCall, error) {
+	return a.build(true)
+}
+
+// build 按 ordered 的顺序解析参数并生成工具调用。strict 时任一调用的参数无法解析即返回错误；
+// 否则记录警告并使用空参数
+func (a *ToolCallAccumulator) build(strict bool) ([]schema.ToolCall, error) {
+	ordered := a.ordered()
+
+	result := make([]schema.ToolCall, 0, len(ordered))
+	for _, call := range ordered {
+		raw := call.args.String()
+		args, err := parseToolArguments(raw)
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("tool call %q (id=%s) has invalid arguments %q: %w", call.name, call.id, raw, err)
+			}
+			slog.Warn("Invalid tool call arguments",
+				slog.String("tool", call.name),
+				slog.String("arguments", raw),
+				slog.String("err", err.Error()),
+			)
+			args = map[string]any{}
+		}
+		result = append(result, schema.ToolCall{
+			ID:   call.id,
+			Type: "function",
+			Function: schema.FunctionCall{
+				Name:      call.name,
+				Arguments: args,
+			},
+		})
+	}
+	return result, nil
+}
+
+// MergeToolCallFragments 合并一组工具调用片段（片段之间可以交错乱序到达）
+func MergeToolCallFragments(frags []ToolCallFragment) ([]schema.ToolCall, error) {
+	acc := NewToolCallAccumulator()
+	for _, f := range frags {
+		acc.Add(f)
+	}
+	return acc.ToolCalls()
+}
+
+// parseToolArguments 解析工具调用参数。
+// 空字符串与 null 视为空参数；非 JSON 对象返回错误。
+// 值为 null 的顶层参数视为未提供（严格模式下可选参数以 null 传递），直接删除。
+func parseToolArguments(raw string) (map[string]any, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return map[string]any{}, nil
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return nil, err
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	for k, v := range args {
+		if v == nil {
+			delete(args, k)
+		}
+	}
+	return args, nil
+}
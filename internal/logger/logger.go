@@ -2,12 +2,15 @@ package logger
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"gopilot-cli/internal/schema"
 	"gopilot-cli/internal/tools"
@@ -23,30 +26,65 @@ import (
 // 包括：LLM 请求内容、LLM 响应内容、工具调用结果等。
 // 内部使用互斥锁（mutex）确保多协程访问时的并发安全。
 type AgentLogger struct {
-	logDir   string     // 日志目录 (~/.gopilot/log)
-	logFile  *os.File   // 当前运行的日志文件句柄
-	logIndex int        // 日志条目计数器
-	mu       sync.Mutex // 互斥锁，保证所有操作并发安全
+	logDir        string     // 日志目录 (~/.gopilot/log)
+	logFile       *os.File   // 当前运行的日志文件句柄
+	logIndex      int        // 日志条目计数器
+	maxEntryBytes int        // REQUEST / RESPONSE 条目序列化后的大小上限（0 表示不限制）
+	tail          io.Writer  // 额外同步输出日志条目的目标（nil 表示不输出）
+	mu            sync.Mutex // 互斥锁，保证所有操作并发安全
 }
 
-// NewAgentLogger 创建日志管理器实例，并初始化日志目录。
-// 若目录或用户 Home 路径不存在，会自动尝试创建。
-func NewAgentLogger() (*AgentLogger, error) {
+// ErrNoLogFile 当前没有可读取的日志文件（尚未开始运行或日志未启用）
+var ErrNoLogFile = errors.New("no active log file")
+
+// Option 日志管理器选项
+type Option func(*AgentLogger)
+
+// WithMaxEntryBytes 限制 REQUEST / RESPONSE 条目 JSON 的大小。
+// 超限时截断其中过长的字符串字段（保持 JSON 结构合法），0 表示不限制。
+func WithMaxEntryBytes(n int) Option {
+	return func(l *AgentLogger) {
+		l.maxEntryBytes = n
+	}
+}
+
+// WithTail 将写入日志文件的内容同步输出到 w（如 stderr 或另一个终端），便于实时查看。
+// 写入 w 失败不影响日志文件本身。
+func WithTail(w io.Writer) Option {
+	return func(l *AgentLogger) {
+		l.tail = w
+	}
+}
+
+// Dir 返回运行日志目录 (~/.gopilot/log)，每次运行一个 agent_run_<时间>.log 文件
+func Dir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("cannot determine user home directory: %w", err)
+		return "", fmt.Errorf("cannot determine user home directory: %w", err)
 	}
+	return filepath.Join(home, ".gopilot", "log"), nil
+}
 
-	logDir := filepath.Join(home, ".gopilot", "log")
+// NewAgentLogger 创建日志管理器实例，并初始化日志目录。
+// 若目录或用户 Home 路径不存在，会自动尝试创建。
+func NewAgentLogger(opts ...Option) (*AgentLogger, error) {
+	logDir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
 
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return nil, fmt.Errorf("cannot create log directory: %w", err)
 	}
 
-	return &AgentLogger{
+	l := &AgentLogger{
 		logDir:   logDir,
 		logIndex: 0,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
 }
 
 //
@@ -89,6 +127,7 @@ func (l *AgentLogger) StartNewRun() error {
 	if _, err := file.WriteString(header); err != nil {
 		return fmt.Errorf("failed writing header: %w", err)
 	}
+	l.writeTail(fmt.Sprintf("%s(%s)\n", header, logPath))
 
 	return nil
 }
@@ -109,6 +148,80 @@ func safeJSON(v any) []byte {
 	return j
 }
 
+// minTruncatedField 截断字符串字段时保留的最小长度
+const minTruncatedField = 64
+
+// cappedJSON 序列化条目，并保证结果不超过 maxEntryBytes：
+// 逐步减半单个字符串字段的保留长度，直到整体大小满足上限；
+// 字段截到最短仍超出（如消息条数很多）时，直接截断序列化结果（此时不再是合法 JSON）。
+func (l *AgentLogger) cappedJSON(v any) []byte {
+	j := safeJSON(v)
+	if l.maxEntryBytes <= 0 || len(j) <= l.maxEntryBytes {
+		return j
+	}
+
+	for limit := l.maxEntryBytes / 2; limit >= minTruncatedField; limit /= 2 {
+		j = safeJSON(truncateStrings(v, limit))
+		if len(j) <= l.maxEntryBytes {
+			return j
+		}
+	}
+	return []byte(truncateString(string(j), l.maxEntryBytes))
+}
+
+// truncateString 把 s 截断到不超过 limit 字节（含截断标记），不切断多字节字符
+func truncateString(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	// 标记中的字节数取决于截掉多少，先按截到 limit 估算，标记变长时再缩短
+	cut := limit
+	marker := ""
+	for {
+		marker = fmt.Sprintf("...[truncated %d bytes]", len(s)-cut)
+		if cut+len(marker) <= limit || cut == 0 {
+			break
+		}
+		cut = max(limit-len(marker), 0)
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+	}
+	if len(marker) > limit {
+		// 上限比标记还短时只保留能放下的部分
+		return marker[:limit]
+	}
+	return s[:cut] + marker
+}
+
+// truncateStrings 递归复制 v，并截断其中超过 limit 字节的字符串
+func truncateStrings(v any, limit int) any {
+	switch vv := v.(type) {
+	case string:
+		return truncateString(vv, limit)
+	case map[string]any:
+		out := make(map[string]any, len(vv))
+		for k, item := range vv {
+			out[k] = truncateStrings(item, limit)
+		}
+		return out
+	case []map[string]any:
+		out := make([]map[string]any, len(vv))
+		for i, item := range vv {
+			out[i] = truncateStrings(item, limit).(map[string]any)
+		}
+		return out
+	case []any:
+		out := make([]any, len(vv))
+		for i, item := range vv {
+			out[i] = truncateStrings(item, limit)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
 //
 // ---------------------------------------------------------
 // Write to Log File
@@ -140,10 +253,22 @@ func (l *AgentLogger) writeLog(logType, content string) error {
 	if _, err := l.logFile.WriteString(entry); err != nil {
 		return fmt.Errorf("write log failed: %w", err)
 	}
+	l.writeTail(entry)
 
 	return l.logFile.Sync() // 确保写入磁盘
 }
 
+//
+// ---------------------------------------------------------
+// Log Request Payload (debug)
+// ---------------------------------------------------------
+//
+
+// LogPayload 记录实际发送给服务端的原始请求体（调试用，调用方负责脱敏）
+func (l *AgentLogger) LogPayload(payload []byte) error {
+	return l.writeLog("REQUEST PAYLOAD", string(payload))
+}
+
 //
 // ---------------------------------------------------------
 // Log LLM Request
@@ -199,7 +324,7 @@ func (l *AgentLogger) LogRequest(messages []schema.Message, toolList []tools.Too
 		req["tools"] = names
 	}
 
-	return l.writeLog("REQUEST", "LLM Request:\n\n"+string(safeJSON(req)))
+	return l.writeLog("REQUEST", "LLM Request:\n\n"+string(l.cappedJSON(req)))
 }
 
 //
@@ -210,6 +335,7 @@ func (l *AgentLogger) LogRequest(messages []schema.Message, toolList []tools.Too
 
 // LogResponse 记录 LLM 模型返回的数据。
 // 包括 content、thinking（推理内容）、tool_calls、finish_reason 等。
+// 同一响应的 content 与 tool_calls 写在同一条 RESPONSE 记录中，与回传给模型的 assistant 消息一致。
 func (l *AgentLogger) LogResponse(
 	content string,
 	thinking string,
@@ -241,7 +367,7 @@ func (l *AgentLogger) LogResponse(
 		resp["tool_calls"] = dumps
 	}
 
-	return l.writeLog("RESPONSE", "LLM Response:\n\n"+string(safeJSON(resp)))
+	return l.writeLog("RESPONSE", "LLM Response:\n\n"+string(l.cappedJSON(resp)))
 }
 
 //
@@ -291,6 +417,35 @@ func (l *AgentLogger) GetLogFilePath() string {
 	return l.logFile.Name()
 }
 
+// Tail 返回当前日志文件的最后 n 行（n <= 0 返回空）。
+// 尚无日志文件时返回 ErrNoLogFile。
+func (l *AgentLogger) Tail(n int) ([]string, error) {
+	path := l.GetLogFilePath()
+	if path == "" {
+		return nil, ErrNoLogFile
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read log file: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// writeTail 同步输出到 tail 目标（调用方需持有锁），失败时忽略
+func (l *AgentLogger) writeTail(s string) {
+	if l.tail != nil {
+		_, _ = io.WriteString(l.tail, s)
+	}
+}
+
 // Close 关闭日志文件。
 func (l *AgentLogger) Close() error {
 	l.mu.Lock()
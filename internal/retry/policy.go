@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"math"
+	"time"
+)
+
+// Class 错误类别，用于按类别选择重试策略
+type Class string
+
+const (
+	// ClassNetwork 连接失败、超时、连接被重置等网络错误
+	ClassNetwork Class = "network"
+	// ClassServer 服务端 5xx 错误
+	ClassServer Class = "server"
+	// ClassRateLimit 429 限流
+	ClassRateLimit Class = "rate_limit"
+	// ClassOther 其他错误
+	ClassOther Class = "other"
+)
+
+// Classifier 判定错误类别；retryAfter > 0 表示服务端要求的等待时间（如 Retry-After 头）
+type Classifier func(err error) (class Class, retryAfter time.Duration)
+
+// Policy 某一类错误的重试策略
+type Policy struct {
+	MaxRetries      int
+	InitialDelay    time.Duration
+	MaxDelay        time.Duration
+	ExponentialBase float64
+	// RespectRetryAfter 服务端给出 Retry-After 时按其等待（不超过 MaxDelay），而不是按指数退避计算
+	RespectRetryAfter bool
+}
+
+// ValidBase 报告 base 能否作为指数退避基数：必须是大于 1 的有限值，
+// 否则退避时间不增长（1）或越来越短（< 1）
+func ValidBase(base float64) bool {
+	return base > 1 && !math.IsInf(base, 0)
+}
+
+// delay 计算第 attempt 次重试（从 0 开始）前的等待时间；
+// 基数无效时按 DefaultExponentialBase 计算，结果始终落在 [0, MaxDelay]
+func (p Policy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if p.RespectRetryAfter && retryAfter > 0 {
+		return min(retryAfter, p.MaxDelay)
+	}
+	base := p.ExponentialBase
+	if !ValidBase(base) {
+		base = DefaultExponentialBase
+	}
+	delay := float64(max(p.InitialDelay, 0)) * math.Pow(base, float64(max(attempt, 0)))
+	if math.IsNaN(delay) || delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	return time.Duration(max(delay, 0))
+}
+
+// basePolicy Config 顶层字段构成的默认策略，未单独配置的类别使用它
+func (c *Config) basePolicy() Policy {
+	return Policy{
+		MaxRetries:      c.MaxRetries,
+		InitialDelay:    c.InitialDelay,
+		MaxDelay:        c.MaxDelay,
+		ExponentialBase: c.ExponentialBase,
+	}
+}
+
+// classify 返回错误的类别、适用的策略与 Retry-After；没有分类器时所有错误使用默认策略
+func (c *Config) classify(err error) (Class, Policy, time.Duration) {
+	if c.Classifier == nil {
+		return ClassOther, c.basePolicy(), 0
+	}
+	class, retryAfter := c.Classifier(err)
+	if p, ok := c.Policies[class]; ok {
+		return class, p, retryAfter
+	}
+	return class, c.basePolicy(), retryAfter
+}
+
+// HasPolicies 是否配置了按类别的重试策略
+func (c *Config) HasPolicies() bool {
+	return c != nil && len(c.Policies) > 0
+}
+
+// Delay 返回 err 所属类别第 attempt 次重试（从 0 开始）前的等待时间，与 Do 实际等待的时间一致
+func (c *Config) Delay(err error, attempt int) time.Duration {
+	_, p, retryAfter := c.classify(err)
+	return p.delay(attempt, retryAfter)
+}
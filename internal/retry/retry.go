@@ -2,20 +2,31 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"sync"
 	"time"
 )
 
-// Config 重试配置
+// Config 重试配置。
+// 顶层字段是默认策略；设置 Classifier 与 Policies 后，可为网络错误、5xx、429 等类别分别指定策略，
+// 每个类别的重试次数单独计数，未配置的类别使用默认策略。
 type Config struct {
 	Enabled         bool
 	MaxRetries      int
 	InitialDelay    time.Duration
 	MaxDelay        time.Duration
 	ExponentialBase float64
+
+	// Classifier 错误分类器（如 llm.ClassifyError），为 nil 时所有错误使用默认策略
+	Classifier Classifier
+	// Policies 按类别覆盖的重试策略
+	Policies map[Class]Policy
 }
 
+// DefaultExponentialBase 默认的指数退避基数，配置的基数无效时也使用它
+const DefaultExponentialBase = 2.0
+
 // DefaultConfig 默认重试配置
 func DefaultConfig() *Config {
 	return &Config{
@@ -23,33 +34,153 @@ func DefaultConfig() *Config {
 		MaxRetries:      3,
 		InitialDelay:    time.Second,
 		MaxDelay:        60 * time.Second,
-		ExponentialBase: 2.0,
+		ExponentialBase: DefaultExponentialBase,
+	}
+}
+
+// SetLimits 更新默认策略的重试次数与退避时间（如会话内 /retry 命令）；按类别的策略保持不变，
+// 用 SetClassLimits 单独修改。maxRetries 为 0 时关闭全部重试。Do 每次调用都读取配置，修改对之后的请求立即生效；
+// 不要在 Do 执行期间并发调用
+func (c *Config) SetLimits(maxRetries int, initialDelay, maxDelay time.Duration) error {
+	if err := validateLimits(maxRetries, initialDelay, maxDelay); err != nil {
+		return err
+	}
+	c.Enabled = maxRetries > 0
+	c.MaxRetries = maxRetries
+	c.InitialDelay = initialDelay
+	c.MaxDelay = maxDelay
+	return nil
+}
+
+// SetClassLimits 更新 class 这一类错误的重试次数与退避时间，合并进已有的按类别策略：
+// 其他类别不受影响，该类别原有的指数基数与 RespectRetryAfter 保留；该类别尚无策略时以默认策略为基础创建。
+// 需要先设置 Classifier，否则按类别的策略不会生效
+func (c *Config) SetClassLimits(class Class, maxRetries int, initialDelay, maxDelay time.Duration) error {
+	if c.Classifier == nil {
+		return fmt.Errorf("per-class retry policies need an error classifier")
+	}
+	if err := validateLimits(maxRetries, initialDelay, maxDelay); err != nil {
+		return err
 	}
+	p, ok := c.Policies[class]
+	if !ok {
+		p = c.basePolicy()
+	}
+	p.MaxRetries = maxRetries
+	p.InitialDelay = initialDelay
+	p.MaxDelay = maxDelay
+	if c.Policies == nil {
+		c.Policies = map[Class]Policy{}
+	}
+	c.Policies[class] = p
+	return nil
+}
+
+// validateLimits 校验 SetLimits / SetClassLimits 的参数
+func validateLimits(maxRetries int, initialDelay, maxDelay time.Duration) error {
+	if maxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative (got %d)", maxRetries)
+	}
+	if initialDelay <= 0 {
+		return fmt.Errorf("initial delay must be positive (got %s)", initialDelay)
+	}
+	if maxDelay < initialDelay {
+		return fmt.Errorf("max delay %s must not be less than initial delay %s", maxDelay, initialDelay)
+	}
+	return nil
 }
 
 // ExhaustedError 重试耗尽错误
 type ExhaustedError struct {
 	LastError error
 	Attempts  int
+	// BudgetExhausted 因整次运行的重试预算用尽而提前放弃（而非达到单次 MaxRetries）
+	BudgetExhausted bool
 }
 
 func (e *ExhaustedError) Error() string {
+	if e.BudgetExhausted {
+		return fmt.Sprintf("retry budget for this run exhausted after %d attempts: %v", e.Attempts, e.LastError)
+	}
 	return fmt.Sprintf("retry failed after %d attempts: %v", e.Attempts, e.LastError)
 }
 
-// OnRetryFunc 重试回调函数类型
+func (e *ExhaustedError) Unwrap() error {
+	return e.LastError
+}
+
+// Budget 多次调用共享的重试预算（如一次 Agent 运行内的全部 LLM 调用），并发安全。
+// 每次重试消耗 1，用尽后 Do 不再重试、直接返回失败。
+type Budget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// NewBudget 创建共 n 次重试的预算
+func NewBudget(n int) *Budget {
+	return &Budget{remaining: n}
+}
+
+// Take 消耗一次重试，预算已用尽时返回 false
+func (b *Budget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// Remaining 剩余可用的重试次数
+func (b *Budget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+type budgetKey struct{}
+
+// WithBudget 返回携带重试预算的 context，Do 会在每次重试前从中扣减
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFromContext 取出 context 中的重试预算（未设置时返回 nil）
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// permanentError 标记不应重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 包装不应重试的错误（如模型不存在）：Do 遇到时立即返回其中的原始错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// OnRetryFunc 重试回调函数类型；attempt 是 err 所属类别的第几次重试（从 1 开始），
+// 与 Config.Delay(err, attempt-1) 一起可得到本次实际等待的时间
 type OnRetryFunc func(err error, attempt int)
 
-// CalculateDelay 计算延迟时间（指数退避）
+// CalculateDelay 计算默认策略下的延迟时间（指数退避）
 func (c *Config) CalculateDelay(attempt int) time.Duration {
-	delay := float64(c.InitialDelay) * math.Pow(c.ExponentialBase, float64(attempt))
-	if delay > float64(c.MaxDelay) {
-		delay = float64(c.MaxDelay)
-	}
-	return time.Duration(delay)
+	return c.basePolicy().delay(attempt, 0)
 }
 
-// Do 执行带重试的函数
+// Do 执行带重试的函数。
+// 每次失败按错误类别选择策略：该类别的重试次数达到其 MaxRetries 后返回 ExhaustedError，
+// 退避时间也按该类别已重试的次数计算，与其他类别的重试无关。
+// ctx 中带有重试预算（WithBudget）时，每次重试都会消耗预算，预算用尽则立即返回 ExhaustedError。
 func Do[T any](ctx context.Context, cfg *Config, fn func() (T, error), onRetry OnRetryFunc) (T, error) {
 	var zero T
 	var lastErr error
@@ -59,10 +190,16 @@ func Do[T any](ctx context.Context, cfg *Config, fn func() (T, error), onRetry O
 	}
 
 	if !cfg.Enabled {
-		return fn()
+		result, err := fn()
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return result, perm.err
+		}
+		return result, err
 	}
 
-	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+	retries := map[Class]int{}
+	for attempt := 0; ; attempt++ {
 		result, err := fn()
 		if err == nil {
 			return result, nil
@@ -70,14 +207,24 @@ func Do[T any](ctx context.Context, cfg *Config, fn func() (T, error), onRetry O
 
 		lastErr = err
 
-		if attempt >= cfg.MaxRetries {
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return zero, perm.err
+		}
+
+		class, policy, retryAfter := cfg.classify(err)
+		if retries[class] >= policy.MaxRetries {
 			return zero, &ExhaustedError{LastError: lastErr, Attempts: attempt + 1}
 		}
+		if b := BudgetFromContext(ctx); b != nil && !b.Take() {
+			return zero, &ExhaustedError{LastError: lastErr, Attempts: attempt + 1, BudgetExhausted: true}
+		}
+		retries[class]++
 
-		delay := cfg.CalculateDelay(attempt)
+		delay := policy.delay(retries[class]-1, retryAfter)
 
 		if onRetry != nil {
-			onRetry(err, attempt+1)
+			onRetry(err, retries[class])
 		}
 
 		select {
@@ -86,6 +233,4 @@ func Do[T any](ctx context.Context, cfg *Config, fn func() (T, error), onRetry O
 		case <-time.After(delay):
 		}
 	}
-
-	return zero, lastErr
 }
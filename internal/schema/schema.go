@@ -20,7 +20,16 @@ type Message struct {
 	Thinking   string     `json:"thinking,omitempty"` // 扩展思考内容
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
-	Name       string     `json:"name,omitempty"` // 用于 tool 角色
+	Name       string     `json:"name,omitempty"`     // 用于 tool 角色
+	IsError    bool       `json:"is_error,omitempty"` // tool 角色：工具执行失败
+	Images     []Image    `json:"images,omitempty"`   // 多模态图片（仅 user 角色）
+}
+
+// Image 随消息发送给模型的图片
+type Image struct {
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
 }
 
 // LLMResponse LLM 响应
@@ -29,4 +38,48 @@ type LLMResponse struct {
 	Thinking     string     `json:"thinking,omitempty"`
 	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
 	FinishReason string     `json:"finish_reason"`
+
+	// SystemFingerprint 服务端后端配置指纹，变化时即使 seed 相同输出也可能不同
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+
+	// Usage 本次调用的 token 用量（服务端未返回时为 nil）
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Logprobs 输出 token 的对数概率（需开启 llm.WithLogprobs 且服务端支持，否则为空）
+	Logprobs []TokenLogprob `json:"logprobs,omitempty"`
+
+	// Choices 服务端返回的全部候选（按 index 排序），上面的字段取自第一个；
+	// 只有请求了多个候选（llm.WithChoices）时才会多于一个
+	Choices []Choice `json:"choices,omitempty"`
+}
+
+// Choice 一个候选回复
+type Choice struct {
+	Index        int            `json:"index"`
+	Content      string         `json:"content"`
+	Thinking     string         `json:"thinking,omitempty"`
+	ToolCalls    []ToolCall     `json:"tool_calls,omitempty"`
+	FinishReason string         `json:"finish_reason"`
+	Logprobs     []TokenLogprob `json:"logprobs,omitempty"`
+}
+
+// TokenLogprob 单个输出 token 的对数概率
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// TopLogprobs 该位置概率最高的候选 token（请求了 top_logprobs 时）
+	TopLogprobs []TopLogprob `json:"top_logprobs,omitempty"`
+}
+
+// TopLogprob 候选 token 及其对数概率
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+}
+
+// Usage token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
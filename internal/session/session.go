@@ -0,0 +1,237 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+// Session 保存的会话
+type Session struct {
+	Name      string           `json:"name"`
+	Workspace string           `json:"workspace"` // 保存时的工作空间（绝对路径）
+	Model     string           `json:"model"`
+	SavedAt   time.Time        `json:"saved_at"`
+	Messages  []schema.Message `json:"messages"` // 完整历史，第一条为保存时的系统提示
+	// Shells 保存时登记的后台进程（bash run_in_background），恢复会话时重新登记
+	Shells []tools.ShellSnapshot `json:"shells,omitempty"`
+}
+
+// Info 会话列表中的一项（不含消息内容）
+type Info struct {
+	Name      string
+	Workspace string
+	Model     string
+	SavedAt   time.Time
+	Messages  int
+}
+
+// Store 会话存储：每个会话一个 JSON 文件 <dir>/<name>.json
+type Store struct {
+	dir string
+}
+
+// DefaultDir 返回默认的会话目录 ~/.gopilot/sessions
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".gopilot", "sessions"), nil
+}
+
+// NewStore 创建目录 dir 下的会话存储（目录在首次保存时创建）
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir 返回会话目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// validName 会话名：字母、数字、"."、"_"、"-"，不以 "." 开头
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateName 检查会话名能否安全地用作文件名
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid session name %q (use up to 64 letters, digits, '.', '_' or '-')", name)
+	}
+	return nil
+}
+
+// AutosavePrefix 自动保存的会话名前缀，/save 使用它需要确认
+const AutosavePrefix = "autosave-"
+
+// AutosaveName 返回工作空间自动保存的会话名 "autosave-<目录名>-<hash>"，每个工作空间一个
+func AutosaveName(workspace string) string {
+	return AutosavePrefix + wspath.WorkspaceSlug(workspace)
+}
+
+// OverwriteConflict 说明在 workspace 中以 name 保存会被拒绝覆盖的原因：名称使用自动保存的前缀，
+// 或已有同名会话保存于其他工作空间（所有工作空间共用一个会话目录）；可以安全保存时返回空字符串
+func (s *Store) OverwriteConflict(name, workspace string) string {
+	if strings.HasPrefix(name, AutosavePrefix) {
+		return fmt.Sprintf("names starting with %q are reserved for autosaves", AutosavePrefix)
+	}
+	existing, err := s.Load(name)
+	if err != nil || existing.Workspace == "" {
+		return ""
+	}
+	if wspath.NormalizeWorkspace(existing.Workspace) != wspath.NormalizeWorkspace(workspace) {
+		return fmt.Sprintf("session %q already exists for workspace %s", name, existing.Workspace)
+	}
+	return ""
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// Path 返回会话 name 的文件路径
+func (s *Store) Path(name string) string {
+	return s.path(name)
+}
+
+// Save 保存会话（同名会话被覆盖），返回文件路径；SavedAt 为零值时使用当前时间。
+// 先写临时文件再 rename，中途失败不会损坏已有的同名会话
+func (s *Store) Save(sess *Session) (string, error) {
+	if err := ValidateName(sess.Name); err != nil {
+		return "", err
+	}
+	if sess.SavedAt.IsZero() {
+		sess.SavedAt = time.Now()
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("serialize session: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", fmt.Errorf("create session directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".session-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	path := s.path(sess.Name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("write session: %w", err)
+	}
+	return path, nil
+}
+
+// Load 读取会话；不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+func (s *Store) Load(name string) (*Session, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("session %q not found in %s: %w", name, s.dir, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("session file %s is corrupted: %w", s.path(name), err)
+	}
+	if sess.Name == "" {
+		sess.Name = name
+	}
+	return &sess, nil
+}
+
+// List 返回所有保存的会话，最近保存的在前；无法解析的文件被跳过
+func (s *Store) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	var infos []Info
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || ValidateName(name) != nil {
+			continue
+		}
+		sess, err := s.Load(name)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, Info{
+			Name:      sess.Name,
+			Workspace: sess.Workspace,
+			Model:     sess.Model,
+			SavedAt:   sess.SavedAt,
+			Messages:  len(sess.Messages),
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].SavedAt.After(infos[j].SavedAt) })
+	return infos, nil
+}
+
+// Latest 返回在 workspace 中最近保存的会话（包括自动保存的）；没有时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+func (s *Store) Latest(workspace string) (*Session, error) {
+	infos, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	want := wspath.NormalizeWorkspace(workspace)
+	for _, info := range infos {
+		if info.Workspace != "" && wspath.NormalizeWorkspace(info.Workspace) == want {
+			return s.Load(info.Name)
+		}
+	}
+	return nil, fmt.Errorf("no session saved for %s in %s: %w", workspace, s.dir, fs.ErrNotExist)
+}
+
+// PruneAutosaves 删除自动保存的会话中超过 maxAge 未更新的，以及同一工作空间较旧的多余副本
+// （每个工作空间只保留最新的一份）；maxAge <= 0 时不按时间删除。/save 保存的会话不受影响。返回被删除的会话名
+func (s *Store) PruneAutosaves(maxAge time.Duration) ([]string, error) {
+	infos, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	kept := map[string]bool{}
+	var removed []string
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name, AutosavePrefix) {
+			continue
+		}
+		ws := wspath.NormalizeWorkspace(info.Workspace)
+		if !kept[ws] && (maxAge <= 0 || time.Since(info.SavedAt) <= maxAge) {
+			kept[ws] = true
+			continue
+		}
+		if err := os.Remove(s.path(info.Name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("remove session %s: %w", info.Name, err)
+		}
+		removed = append(removed, info.Name)
+	}
+	return removed, nil
+}
@@ -2,17 +2,23 @@ package tokenizer
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/pkoukk/tiktoken-go"
 
 	"gopilot-cli/internal/schema"
 )
 
+// encoding 缓存的 cl100k_base 编码器：GetEncoding 每次都会重新构建编码表，每个 step 都要估算时开销明显
+var encoding = sync.OnceValues(func() (*tiktoken.Tiktoken, error) {
+	return tiktoken.GetEncoding("cl100k_base")
+})
+
 // EstimateTokens 估算消息历史的 token 数量。
 // 优先使用 tiktoken-go 进行编码统计，若不可用则回退到字符长度估算。
 // 对每条消息，统计 Content、Thinking、ToolCalls 的 token 数，并加上元数据开销。
 func EstimateTokens(messages []schema.Message) int {
-	enc, err := tiktoken.GetEncoding("cl100k_base")
+	enc, err := encoding()
 	if err != nil {
 		return EstimateTokensFallback(messages)
 	}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// AskUserTool（需要用户补充信息时结束本轮并提问）
+// ---------------------------------------------------------
+
+// askUserNote 写入会话历史的工具结果：回答会作为下一条用户消息到达
+const askUserNote = "The question was shown to the user. Stop here; their answer will arrive as the next user message."
+
+type AskUserTool struct{}
+
+// NewAskUserTool 创建 ask_user 工具
+func NewAskUserTool() *AskUserTool {
+	return &AskUserTool{}
+}
+
+func (t *AskUserTool) Name() string {
+	return "ask_user"
+}
+
+// Mutates 见 Mutator：只向用户提问
+func (t *AskUserTool) Mutates() bool {
+	return false
+}
+
+func (t *AskUserTool) Description() string {
+	return "Ask the user a question when you cannot continue without their input " +
+		"(missing requirements, an ambiguous choice, confirmation of a risky step). " +
+		"The current run ends and the user's answer arrives as the next message. " +
+		"Do not use it to report that the task is finished."
+}
+
+func (t *AskUserTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"question": map[string]any{
+				"type":        "string",
+				"description": "The question for the user, self-contained and specific",
+			},
+		},
+		"required": []string{"question"},
+	}
+}
+
+func (t *AskUserTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	question, _ := args["question"].(string)
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return &ToolResult{Success: false, Error: "question is required"}, nil
+	}
+	return &ToolResult{Success: true, Content: askUserNote, Question: question}, nil
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxAttachmentBytes 单个附件允许发送给模型的最大字节数，超出时只发送文字描述
+const MaxAttachmentBytes = 5 << 20
+
+// Attachment 工具返回的非文本结果（图片、文件）。
+//
+// 工具作者约定：
+//   - 设置 Path（本地文件，推荐绝对路径）或 Data（内存内容）之一，两者都有时优先使用 Data；
+//   - MIMEType 可省略，缺省时按扩展名或内容推断；
+//   - Description 是给不支持视觉的模型看的文字替代，应说明附件内容（如“柱状图：各月销量”）；
+//   - ToolResult.Content 仍应包含对结果的简要文字说明，附件只是补充。
+//
+// 当模型支持视觉（llm.vision: true）且附件是 image/* 时，Agent 会在本轮工具结果之后
+// 追加一条带图片的 user 消息；其他情况（非图片、超过 MaxAttachmentBytes、读取失败）
+// 降级为追加到工具结果中的文字描述。
+type Attachment struct {
+	Name        string `json:"name,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Data        []byte `json:"-"`
+	Description string `json:"description,omitempty"`
+}
+
+// DisplayName 返回附件名，缺省时取 Path 的文件名
+func (a Attachment) DisplayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.Path != "" {
+		return filepath.Base(a.Path)
+	}
+	return "attachment"
+}
+
+// Load 读取附件内容并确定 MIME 类型
+func (a Attachment) Load() ([]byte, string, error) {
+	data := a.Data
+	if data == nil {
+		if a.Path == "" {
+			return nil, "", fmt.Errorf("attachment %s has neither data nor path", a.DisplayName())
+		}
+		info, err := os.Stat(a.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		if info.Size() > MaxAttachmentBytes {
+			return nil, "", fmt.Errorf("attachment %s is too large (%d bytes, max %d)", a.DisplayName(), info.Size(), MaxAttachmentBytes)
+		}
+		data, err = os.ReadFile(a.Path)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if len(data) > MaxAttachmentBytes {
+		return nil, "", fmt.Errorf("attachment %s is too large (%d bytes, max %d)", a.DisplayName(), len(data), MaxAttachmentBytes)
+	}
+
+	mimeType := a.MIMEType
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(a.DisplayName()))
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return data, mimeType, nil
+}
+
+// IsImage 判断 MIME 类型是否为图片
+func IsImage(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// Describe 返回附件的文字描述，用于不支持视觉的模型或非图片附件
+func (a Attachment) Describe() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Attachment: %s", a.DisplayName())
+
+	var meta []string
+	if a.MIMEType != "" {
+		meta = append(meta, a.MIMEType)
+	}
+	if a.Data != nil {
+		meta = append(meta, fmt.Sprintf("%d bytes", len(a.Data)))
+	} else if info, err := os.Stat(a.Path); err == nil {
+		meta = append(meta, fmt.Sprintf("%d bytes", info.Size()))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&sb, " (%s)", strings.Join(meta, ", "))
+	}
+	if a.Path != "" {
+		fmt.Fprintf(&sb, " at %s", a.Path)
+	}
+	sb.WriteString("]")
+	if a.Description != "" {
+		sb.WriteString(" ")
+		sb.WriteString(a.Description)
+	}
+	return sb.String()
+}
@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+//
+// ---------------------------------------------------------
+// SessionBackup（会话级备份，/rollback 一次撤销本会话的所有文件修改）
+// ---------------------------------------------------------
+//
+// write_file、edit_file、copy_file 第一次修改某个文件之前，把它的原始内容复制到
+// ~/.gopilot/backups/<session>/（保留相对 workspace 的路径）；原本不存在的文件只记录下来。
+// Rollback 把备份的文件恢复为原始内容、删除本会话新建的文件。
+// 被 .gitignore / .gopilotignore 忽略的文件（构建产物、依赖目录等）不备份；
+// 备份总大小超过上限后，之后的文件不再备份，Rollback 时报告为未覆盖。
+// bash 命令对文件的修改不在备份范围内。
+
+// DefaultBackupMaxBytes 默认的单个会话备份总大小上限
+const DefaultBackupMaxBytes = 50 << 20
+
+// backupEntry 一个被修改文件的原始状态
+type backupEntry struct {
+	existed bool // 修改前文件已存在（备份了内容）
+	mode    fs.FileMode
+	dirs    []string // 文件原本不存在时，为它新建的父目录（绝对路径，由浅到深）
+}
+
+// SessionBackup 会话备份，并发安全；nil 表示未开启（所有方法都不做任何事）
+type SessionBackup struct {
+	mu        sync.Mutex
+	workspace string
+	dir       string
+	maxBytes  int64
+	size      int64
+	ignore    *IgnoreMatcher
+	entries   map[string]backupEntry // 相对 workspace，斜杠分隔
+	skipped   map[string]string      // 未备份的文件 → 原因
+}
+
+// RollbackReport Rollback 的结果（路径相对 workspace，已排序）
+type RollbackReport struct {
+	Restored []string // 恢复为原始内容的文件
+	Removed  []string // 删除的本会话新建文件
+	Skipped  []string // 修改过但没有备份的文件（超过大小上限或备份失败），无法恢复
+}
+
+// NewSessionBackup 为 workspace 创建本会话的备份目录 ~/.gopilot/backups/<session>/；
+// maxBytes 为备份总大小上限（<= 0 时使用 DefaultBackupMaxBytes）
+func NewSessionBackup(workspace string, maxBytes int64) (*SessionBackup, error) {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine user home directory: %w", err)
+	}
+	session := fmt.Sprintf("%s-%s-%d", wspath.WorkspaceSlug(root), time.Now().Format("20060102_150405"), os.Getpid())
+	dir := filepath.Join(home, ".gopilot", "backups", session)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create backup directory: %w", err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultBackupMaxBytes
+	}
+	return &SessionBackup{
+		workspace: root,
+		dir:       dir,
+		maxBytes:  maxBytes,
+		ignore:    LoadIgnoreMatcher(root),
+		entries:   map[string]backupEntry{},
+		skipped:   map[string]string{},
+	}, nil
+}
+
+// Dir 返回本会话的备份目录
+func (b *SessionBackup) Dir() string {
+	if b == nil {
+		return ""
+	}
+	return b.dir
+}
+
+// Len 返回可以回滚的文件数
+func (b *SessionBackup) Len() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// ignored rel 本身或它所在的某个目录被忽略文件匹配
+func (b *SessionBackup) ignored(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if b.ignore.Match(path.Join(parts[:i]...), true) {
+			return true
+		}
+	}
+	return b.ignore.Match(rel, false)
+}
+
+// Save 在修改 file（workspace 内的绝对路径）之前调用：本会话第一次修改时备份原始内容，
+// 文件不存在时记录为新建。之后的修改不再备份，Rollback 恢复的是会话开始前的版本
+func (b *SessionBackup) Save(file string) {
+	if b == nil || !isWithin(b.workspace, file) {
+		return
+	}
+	rel, err := filepath.Rel(b.workspace, file)
+	if err != nil || rel == "." {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[rel]; ok {
+		return
+	}
+	if _, ok := b.skipped[rel]; ok {
+		return
+	}
+	if b.ignored(rel) {
+		return
+	}
+
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		b.entries[rel] = backupEntry{dirs: missingDirs(b.workspace, filepath.Dir(file))}
+		return
+	}
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if b.size+info.Size() > b.maxBytes {
+		b.skipped[rel] = fmt.Sprintf("backups would exceed %d bytes", b.maxBytes)
+		slog.Warn("File not backed up: session backup size limit reached", slog.String("file", rel), slog.Int64("max_bytes", b.maxBytes))
+		return
+	}
+	if _, err := copyFile(file, filepath.Join(b.dir, filepath.FromSlash(rel)), info.Mode()); err != nil {
+		b.skipped[rel] = err.Error()
+		slog.Warn("File not backed up", slog.String("file", rel), slog.String("error", err.Error()))
+		return
+	}
+	b.size += info.Size()
+	b.entries[rel] = backupEntry{existed: true, mode: info.Mode()}
+}
+
+// missingDirs 返回 dir 及其上级目录中尚不存在的部分（不超出 root，由浅到深）
+func missingDirs(root, dir string) []string {
+	var dirs []string
+	for isWithin(root, dir) && dir != root {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dirs = append([]string{dir}, dirs...)
+		dir = filepath.Dir(dir)
+	}
+	return dirs
+}
+
+// Rollback 把本会话修改过的文件恢复为原始内容并删除新建的文件，然后清空备份。
+// 出错时继续处理其余文件，未能恢复的文件保留在备份中，可以再次 Rollback
+func (b *SessionBackup) Rollback() (RollbackReport, error) {
+	var report RollbackReport
+	if b == nil {
+		return report, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rels := make([]string, 0, len(b.entries))
+	for rel := range b.entries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var errs []error
+	var createdDirs []string
+	for _, rel := range rels {
+		e := b.entries[rel]
+		target := filepath.Join(b.workspace, filepath.FromSlash(rel))
+		if e.existed {
+			saved := filepath.Join(b.dir, filepath.FromSlash(rel))
+			if _, err := copyFile(saved, target, e.mode); err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", rel, err))
+				continue
+			}
+			_ = os.Remove(saved)
+			report.Restored = append(report.Restored, rel)
+		} else {
+			if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("remove %s: %w", rel, err))
+				continue
+			}
+			createdDirs = append(createdDirs, e.dirs...)
+			report.Removed = append(report.Removed, rel)
+		}
+		delete(b.entries, rel)
+	}
+
+	// 删除为新建文件创建的目录（由深到浅；目录中还有其他文件时保留）
+	sort.Slice(createdDirs, func(i, j int) bool { return len(createdDirs[i]) > len(createdDirs[j]) })
+	for _, dir := range createdDirs {
+		_ = os.Remove(dir)
+	}
+
+	for rel := range b.skipped {
+		report.Skipped = append(report.Skipped, rel)
+	}
+	sort.Strings(report.Skipped)
+	b.skipped = map[string]string{}
+	if len(b.entries) == 0 {
+		b.size = 0
+	}
+	return report, errors.Join(errs...)
+}
+
+// Close 删除本会话的备份目录
+func (b *SessionBackup) Close() error {
+	if b == nil {
+		return nil
+	}
+	return os.RemoveAll(b.dir)
+}
+
+// WithWriteBackup write_file 写入前备份原始文件
+func WithWriteBackup(b *SessionBackup) WriteOption {
+	return func(w *WriteTool) {
+		w.backup = b
+	}
+}
+
+// WithEditBackup edit_file 修改前备份原始文件
+func WithEditBackup(b *SessionBackup) EditOption {
+	return func(e *EditTool) {
+		e.backup = b
+	}
+}
+
+// WithCopyBackup copy_file 写入目标前备份目标位置的原始文件
+func WithCopyBackup(b *SessionBackup) CopyOption {
+	return func(c *CopyFileTool) {
+		c.backup = b
+	}
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"path/filepath"
+	"time"
 )
 
 // ToolResult 工具执行结果
@@ -9,12 +11,56 @@ type ToolResult struct {
 	Success bool   `json:"success"`
 	Content string `json:"content"`
 	Error   string `json:"error,omitempty"`
+	// ErrorKind 机器可读的错误类别（如 ErrorKindReadOnly），为空表示普通的执行失败
+	ErrorKind string `json:"error_kind,omitempty"`
 
 	// 下面这几个是给 Bash 这种需要结构化输出的工具用的
 	Stdout   string `json:"stdout,omitempty"`
 	Stderr   string `json:"stderr,omitempty"`
 	ExitCode int    `json:"exit_code,omitempty"`
 	BashID   string `json:"bash_id,omitempty"`
+	// WorkingDir、DurationMs bash 命令开始时的工作目录（已解析）与耗时（后台命令结束后才有）
+	WorkingDir string `json:"working_dir,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+
+	// Diagnostics 从输出中解析出的编译 / 测试错误（需开启 WithDiagnostics）
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+
+	// Attachments 非文本结果（图片/文件），约定见 Attachment
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Question 非空表示工具需要用户回答（见 ask_user）：Agent 结束本轮并把问题作为结果返回
+	Question string `json:"question,omitempty"`
+
+	// Changes 本次调用创建 / 修改 / 删除的文件，Agent 汇总为每次 Run 的变更清单
+	Changes []ChangedFile `json:"changes,omitempty"`
+}
+
+const (
+	// ErrorKindReadOnly 只读上下文（计划阶段、只读模式）中请求了修改类工具
+	ErrorKindReadOnly = "read_only"
+	// ErrorKindInterrupted 工具运行中被用户中断（运行本身继续）
+	ErrorKindInterrupted = "interrupted"
+)
+
+// ChangeOp 文件变更类型
+type ChangeOp string
+
+const (
+	ChangeCreated  ChangeOp = "created"
+	ChangeModified ChangeOp = "modified"
+	ChangeDeleted  ChangeOp = "deleted"
+)
+
+// ChangedFile 工具对一个文件的变更（Path 为工具参数中的路径，相对 workspace）
+type ChangedFile struct {
+	Path string   `json:"path"`
+	Op   ChangeOp `json:"op"`
+}
+
+// changeOf 构造单个文件变更的列表
+func changeOf(path string, op ChangeOp) []ChangedFile {
+	return []ChangedFile{{Path: filepath.ToSlash(filepath.Clean(path)), Op: op}}
 }
 
 // Tool 工具接口
@@ -25,6 +71,22 @@ type Tool interface {
 	Execute(ctx context.Context, args map[string]any) (*ToolResult, error)
 }
 
+// TimeoutHinter 可选接口：工具声明自己的默认执行超时（如构建需要几分钟，读文件应立即完成）。
+// 未实现该接口或返回值 <= 0 的工具使用 Agent 的全局默认超时
+type TimeoutHinter interface {
+	TimeoutHint() time.Duration
+}
+
+// ToolTimeout 返回工具的执行超时：优先使用工具声明的值，否则为 fallback（<= 0 表示不限制）
+func ToolTimeout(tool Tool, fallback time.Duration) time.Duration {
+	if h, ok := tool.(TimeoutHinter); ok {
+		if d := h.TimeoutHint(); d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
 // ToOpenAISchema 将 Tool 转换为 OpenAI 工具格式
 func ToOpenAISchema(tool Tool) map[string]any {
 	return map[string]any{
@@ -37,9 +99,10 @@ func ToOpenAISchema(tool Tool) map[string]any {
 	}
 }
 
-// ToolRegistry 工具注册表
+// ToolRegistry 工具注册表（按注册顺序保存，保证发送给模型的工具顺序稳定）
 type ToolRegistry struct {
 	tools map[string]Tool
+	order []string
 }
 
 // NewToolRegistry 创建工具注册表
@@ -49,9 +112,13 @@ func NewToolRegistry() *ToolRegistry {
 	}
 }
 
-// Register 注册工具
+// Register 注册工具；同名工具会被替换，但保持其原有位置
 func (r *ToolRegistry) Register(tool Tool) {
-	r.tools[tool.Name()] = tool
+	name := tool.Name()
+	if _, exists := r.tools[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = tool
 }
 
 // Get 获取工具
@@ -60,19 +127,19 @@ func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	return tool, ok
 }
 
-// List 列出所有工具
+// List 按注册顺序列出所有工具
 func (r *ToolRegistry) List() []Tool {
-	tools := make([]Tool, 0, len(r.tools))
-	for _, tool := range r.tools {
-		tools = append(tools, tool)
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
 	}
 	return tools
 }
 
 // ToOpenAISchemas 转换所有工具为 OpenAI 格式
 func (r *ToolRegistry) ToOpenAISchemas() []map[string]any {
-	schemas := make([]map[string]any, 0, len(r.tools))
-	for _, tool := range r.tools {
+	schemas := make([]map[string]any, 0, len(r.order))
+	for _, tool := range r.List() {
 		schemas = append(schemas, ToOpenAISchema(tool))
 	}
 	return schemas
@@ -4,11 +4,15 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -30,9 +34,19 @@ import (
 // ...
 // [exit_code]:
 // ...
-func formatBashContent(stdout, stderr string, exitCode int, bashID string) string {
+//
+// maxLines > 0 时 stdout / stderr 各自只保留首尾若干行（见 limitLines）。
+// 前台命令没有任何输出时返回一句明确的说明（见 silentResultNote），避免模型把安静的成功误认为出错。
+func formatBashContent(stdout, stderr string, exitCode int, bashID string, maxLines int, info *execInfo) string {
+	if bashID == "" && strings.TrimSpace(stdout) == "" && strings.TrimSpace(stderr) == "" {
+		return silentResultNote(exitCode) + info.section()
+	}
+
 	var b strings.Builder
 
+	stdout = limitLines(stdout, maxLines)
+	stderr = limitLines(stderr, maxLines)
+
 	if stdout != "" {
 		b.WriteString(stdout)
 	}
@@ -58,13 +72,266 @@ func formatBashContent(stdout, stderr string, exitCode int, bashID string) strin
 	}
 	b.WriteString("[exit_code]:\n")
 	b.WriteString(fmt.Sprintf("%d", exitCode))
+	b.WriteString(info.section())
+	return b.String()
+}
+
+// execInfo 命令的执行信息：开始时的工作目录与耗时（duration < 0 表示仍在运行）
+type execInfo struct {
+	dir      string
+	duration time.Duration
+}
+
+// section formatBashContent 末尾的 [working_dir] / [duration_ms] 段；nil 时为空
+func (i *execInfo) section() string {
+	if i == nil {
+		return ""
+	}
+	out := "\n[working_dir]:\n" + i.dir
+	if i.duration >= 0 {
+		out += fmt.Sprintf("\n[duration_ms]:\n%d", i.duration.Milliseconds())
+	}
+	return out
+}
+
+// durationMs 以毫秒表示的耗时；仍在运行（d < 0）时为 0
+func durationMs(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return d.Milliseconds()
+}
+
+// resolveWorkingDir 命令实际的工作目录：dir 为空时是当前进程的工作目录，并解析符号链接
+func resolveWorkingDir(dir string) string {
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	return dir
+}
 
-	if b.Len() == 0 {
-		return "(no output)"
+// silentResultNote 没有输出的前台命令的结果说明
+func silentResultNote(exitCode int) string {
+	if exitCode == 0 {
+		return "Command succeeded with no output, exit code 0"
+	}
+	return fmt.Sprintf("Command failed with no output, exit code %d", exitCode)
+}
+
+// limitLines 输出超过 maxLines 行时保留前后各一半，中间替换为省略标记；maxLines <= 0 表示不限制
+func limitLines(s string, maxLines int) string {
+	if maxLines <= 0 || s == "" {
+		return s
+	}
+
+	trailingNewline := strings.HasSuffix(s, "\n")
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return s
+	}
+
+	head := (maxLines + 1) / 2
+	tail := maxLines - head
+	omitted := len(lines) - head - tail
+
+	var b strings.Builder
+	b.WriteString(strings.Join(lines[:head], "\n"))
+	fmt.Fprintf(&b, "\n... [%d lines omitted] ...\n", omitted)
+	if tail > 0 {
+		b.WriteString(strings.Join(lines[len(lines)-tail:], "\n"))
+	}
+	if trailingNewline {
+		b.WriteString("\n")
 	}
 	return b.String()
 }
 
+//
+// ============================================================
+// BashOption —— bash 系列工具的共享配置
+// ============================================================
+//
+
+// bashSettings bash / bash_output / bash_kill 共享的设置
+type bashSettings struct {
+	maxOutputLines int
+	stderrPolicy   StderrPolicy
+	diagnostics    bool
+	confineDir     string // 非空时命令固定在该目录执行，且拒绝 cd 到其外部
+	newID          func() string
+	maxConcurrent  int // 同时运行的前台命令上限，<= 0 表示不限制
+	dryRun         bool
+	liveOutput     io.Writer // 非 nil 时前台命令的输出同时实时写到这里
+	nonInteractive bool      // 为命令设置 DEBIAN_FRONTEND=noninteractive 等环境变量，避免等待输入
+	execInfo       bool      // 在返回给模型的内容中附加工作目录与耗时
+}
+
+// DefaultMaxConcurrentBash 默认同时运行的前台命令上限
+const DefaultMaxConcurrentBash = 4
+
+// BashOption bash 系列工具的可选配置
+type BashOption func(*bashSettings)
+
+// WithMaxOutputLines 限制返回给模型的输出行数（保留首尾各一半），n <= 0 表示不限制
+func WithMaxOutputLines(n int) BashOption {
+	return func(s *bashSettings) {
+		s.maxOutputLines = n
+	}
+}
+
+// WithStderrPolicy 设置前台命令的成功判定策略（见 StderrPolicy）
+func WithStderrPolicy(p StderrPolicy) BashOption {
+	return func(s *bashSettings) {
+		s.stderrPolicy = p
+	}
+}
+
+// WithDiagnostics 解析 Go 编译 / vet / test 的错误行，在结果末尾追加紧凑的诊断列表（原始输出保留）
+func WithDiagnostics(enabled bool) BashOption {
+	return func(s *bashSettings) {
+		s.diagnostics = enabled
+	}
+}
+
+// WithWorkspaceConfinement 将前台 / 后台命令的工作目录固定为 workspace，
+// 并拒绝 cd 到 workspace 之外的命令（best-effort 的静态检查，不是真正的沙箱）
+func WithWorkspaceConfinement(workspace string) BashOption {
+	return func(s *bashSettings) {
+		s.confineDir = workspace
+	}
+}
+
+// WithIDGenerator 替换后台进程 bash_id 的生成函数（默认随机 UUID 前 8 位），
+// 便于测试与调试时得到可预测的 ID
+func WithIDGenerator(fn func() string) BashOption {
+	return func(s *bashSettings) {
+		if fn != nil {
+			s.newID = fn
+		}
+	}
+}
+
+// WithMaxConcurrent 限制同时运行的前台命令数（默认 DefaultMaxConcurrentBash），
+// 超出的调用排队等待空闲名额；n <= 0 表示不限制。后台命令不受影响。
+func WithMaxConcurrent(n int) BashOption {
+	return func(s *bashSettings) {
+		s.maxConcurrent = n
+	}
+}
+
+// WithNonInteractive 为前台 / 后台命令设置 DEBIAN_FRONTEND=noninteractive、GIT_TERMINAL_PROMPT=0、GIT_EDITOR=true，
+// 让 apt、git 等不再等待用户输入（默认开启）
+func WithNonInteractive(enabled bool) BashOption {
+	return func(s *bashSettings) {
+		s.nonInteractive = enabled
+	}
+}
+
+// WithExecInfo 在返回给模型的内容末尾附加 [working_dir] 与 [duration_ms]（后台命令在结束后才有耗时）；
+// ToolResult.WorkingDir / DurationMs 无论是否开启都会填写
+func WithExecInfo(enabled bool) BashOption {
+	return func(s *bashSettings) {
+		s.execInfo = enabled
+	}
+}
+
+// info 开启 WithExecInfo 时返回 formatBashContent 使用的执行信息，否则为 nil
+func (s bashSettings) info(dir string, duration time.Duration) *execInfo {
+	if !s.execInfo {
+		return nil
+	}
+	return &execInfo{dir: dir, duration: duration}
+}
+
+// WithDryRun 只回显命令而不执行：返回 "would execute: <command>" 的模拟成功结果，
+// 后台模式也不会启动进程或登记 bash_id。用于在开启真实执行前审查 Agent 打算运行的命令
+func WithDryRun(enabled bool) BashOption {
+	return func(s *bashSettings) {
+		s.dryRun = enabled
+	}
+}
+
+// WithLiveOutput 前台命令运行时把 stdout / stderr 同步回显到 w（通常是交互终端），
+// 结果中的输出照常完整捕获；w 为 nil 表示不回显
+func WithLiveOutput(w io.Writer) BashOption {
+	return func(s *bashSettings) {
+		if w != nil {
+			s.liveOutput = &lockedWriter{w: w}
+		}
+	}
+}
+
+// lockedWriter 串行化 stdout / stderr 两路复制对同一个 writer 的写入；
+// 回显失败（如终端已关闭）时忽略错误，不影响输出的捕获
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(p)
+	return len(p), nil
+}
+
+func newBashSettings(opts []BashOption) bashSettings {
+	s := bashSettings{
+		stderrPolicy:   StderrNonzeroExit,
+		newID:          generateBashID,
+		maxConcurrent:  DefaultMaxConcurrentBash,
+		nonInteractive: true,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// StderrPolicy 决定前台命令何时视为失败，使 Success 在各平台上含义一致
+type StderrPolicy string
+
+const (
+	// StderrNever 只要命令正常运行结束（未超时、未取消、能启动）即视为成功，退出码仅作参考
+	StderrNever StderrPolicy = "never"
+	// StderrNonzeroExit 退出码非 0 视为失败，stderr 输出仅作参考（默认）
+	StderrNonzeroExit StderrPolicy = "nonzero-exit"
+	// StderrAlways 退出码非 0 或 stderr 有任何输出都视为失败
+	StderrAlways StderrPolicy = "always"
+)
+
+// ParseStderrPolicy 解析配置值，空字符串返回默认策略
+func ParseStderrPolicy(s string) (StderrPolicy, error) {
+	switch p := StderrPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return StderrNonzeroExit, nil
+	case StderrNever, StderrNonzeroExit, StderrAlways:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid stderr_is_error policy %q (want never, nonzero-exit or always)", s)
+	}
+}
+
+// failure 按策略判断已结束的命令是否失败，返回 nil 表示成功
+func (p StderrPolicy) failure(exitCode int, stderr string) error {
+	if p == StderrNever {
+		return nil
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("exit status %d", exitCode)
+	}
+	if p == StderrAlways && strings.TrimSpace(stderr) != "" {
+		return fmt.Errorf("command wrote to stderr (stderr_is_error: always)")
+	}
+	return nil
+}
+
 //
 // ============================================================
 // BackgroundShell —— 后台进程状态容器
@@ -83,6 +350,8 @@ type BackgroundShell struct {
 	Status   string // running / completed / failed / terminated / error
 	ExitCode *int
 	Start    time.Time
+	End      time.Time // 结束时间（仍在运行时为零值）
+	Dir      string    // 命令开始时的工作目录（已解析）
 
 	mu sync.Mutex
 }
@@ -114,6 +383,7 @@ func (s *BackgroundShell) UpdateStatus(alive bool, code int) {
 		s.Status = "failed"
 	}
 	s.ExitCode = &code
+	s.End = time.Now()
 }
 
 func (s *BackgroundShell) SetErrorStatus(msg string) {
@@ -132,6 +402,19 @@ func (s *BackgroundShell) Terminate() {
 	s.Status = "terminated"
 	code := -1
 	s.ExitCode = &code
+	if s.End.IsZero() {
+		s.End = time.Now()
+	}
+}
+
+// Duration 命令运行的时长；仍在运行时返回 -1
+func (s *BackgroundShell) Duration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.End.IsZero() {
+		return -1
+	}
+	return s.End.Sub(s.Start)
 }
 
 //
@@ -167,6 +450,113 @@ func (m *BackgroundShellManager) Remove(id string) {
 	delete(m.shells, id)
 }
 
+// TerminateAll 终止仍在运行的后台进程并清空管理器，返回被终止的进程数
+func (m *BackgroundShellManager) TerminateAll() int {
+	m.mu.Lock()
+	shells := m.shells
+	m.shells = make(map[string]*BackgroundShell)
+	m.mu.Unlock()
+
+	n := 0
+	for _, shell := range shells {
+		shell.mu.Lock()
+		running := shell.Status == "running"
+		shell.mu.Unlock()
+		if running {
+			shell.Terminate()
+			n++
+		}
+	}
+	return n
+}
+
+// BackgroundShellIDs 返回当前登记的全部后台进程 ID（已排序）
+func BackgroundShellIDs() []string {
+	ids := globalShellManager.ListIDs()
+	sort.Strings(ids)
+	return ids
+}
+
+// maxSnapshotLines 每个后台进程快照最多保存的未读输出行数（保留最后的部分）
+const maxSnapshotLines = 200
+
+// ShellSnapshot 后台进程的元数据，随会话保存，下次启动时由 RestoreBackgroundShells 恢复登记
+type ShellSnapshot struct {
+	ID       string    `json:"id"`
+	Command  string    `json:"command"`
+	Dir      string    `json:"dir,omitempty"`
+	Status   string    `json:"status"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`              // 仍在运行的进程记为快照时间
+	Unread   []string  `json:"unread,omitempty"` // 尚未被 bash_output 读取的输出（最多 maxSnapshotLines 行）
+}
+
+// SnapshotBackgroundShells 返回当前登记的全部后台进程的快照（按 ID 排序）
+func SnapshotBackgroundShells() []ShellSnapshot {
+	now := time.Now()
+	snaps := make([]ShellSnapshot, 0)
+	for _, id := range BackgroundShellIDs() {
+		shell := globalShellManager.Get(id)
+		if shell == nil {
+			continue
+		}
+		shell.mu.Lock()
+		unread := shell.OutputLines[shell.LastReadIndex:]
+		if len(unread) > maxSnapshotLines {
+			unread = unread[len(unread)-maxSnapshotLines:]
+		}
+		snap := ShellSnapshot{
+			ID:       shell.BashID,
+			Command:  shell.Command,
+			Dir:      shell.Dir,
+			Status:   shell.Status,
+			ExitCode: shell.ExitCode,
+			Start:    shell.Start,
+			End:      shell.End,
+			Unread:   append([]string(nil), unread...),
+		}
+		shell.mu.Unlock()
+		if snap.End.IsZero() {
+			snap.End = now
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps
+}
+
+// RestoreBackgroundShells 把上一个会话的后台进程登记为已结束的进程，返回恢复的数量。
+// 进程本身不会随 gopilot 退出而保留：快照时仍在运行的记为 terminated，
+// bash_output 返回其未读输出与说明，bash_kill 将其移除；ID 已被占用的快照被跳过
+func RestoreBackgroundShells(snaps []ShellSnapshot) int {
+	n := 0
+	for _, snap := range snaps {
+		if snap.ID == "" || globalShellManager.Get(snap.ID) != nil {
+			continue
+		}
+		shell := &BackgroundShell{
+			BashID:      snap.ID,
+			Command:     snap.Command,
+			Dir:         snap.Dir,
+			Status:      snap.Status,
+			ExitCode:    snap.ExitCode,
+			Start:       snap.Start,
+			End:         snap.End,
+			OutputLines: append([]string(nil), snap.Unread...),
+		}
+		if shell.Status == "running" {
+			shell.Status = "terminated"
+			code := -1
+			shell.ExitCode = &code
+			shell.OutputLines = append(shell.OutputLines,
+				"[gopilot] restored from a previous session: the process was stopped when gopilot exited")
+		}
+		globalShellManager.Add(shell)
+		n++
+	}
+	return n
+}
+
 func (m *BackgroundShellManager) ListIDs() []string {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -249,6 +639,36 @@ func getBoolArg(args map[string]any, key string, def bool) bool {
 	return def
 }
 
+// requiredStringArg 读取必需的字符串参数：缺失（或为 null）与类型不对时返回说明错误，
+// 模型有时会漏掉必需参数，不能直接做类型断言
+func requiredStringArg(args map[string]any, key string) (string, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return "", fmt.Errorf("missing required argument '%s'", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid required argument '%s': expected a string, got %s", key, jsonTypeName(v))
+	}
+	return s, nil
+}
+
+// jsonTypeName 返回 JSON 解码后的值对应的 JSON 类型名
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case float64, int, int32, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
 // generateBashID 生成一个 8 字符的随机 ID（对应 Python 的 str(uuid.uuid4())[:8]）
 func generateBashID() string {
 	return uuid.New().String()[:8]
@@ -262,19 +682,76 @@ func generateBashID() string {
 
 type BashTool struct {
 	isWindows bool
+	settings  bashSettings
+	slots     chan struct{} // 前台命令的并发名额，nil 表示不限制
 }
 
-func NewBashTool() *BashTool {
-	return &BashTool{
+func NewBashTool(opts ...BashOption) *BashTool {
+	t := &BashTool{
 		isWindows: runtime.GOOS == "windows",
+		settings:  newBashSettings(opts),
+	}
+	if n := t.settings.maxConcurrent; n > 0 {
+		t.slots = make(chan struct{}, n)
 	}
+	return t
+}
+
+// dryRunResult dry-run 模式下代替执行的模拟成功结果
+func dryRunResult(command string, background bool) *ToolResult {
+	mode := "foreground"
+	if background {
+		mode = "background"
+	}
+	return &ToolResult{
+		Success: true,
+		Content: fmt.Sprintf("[dry-run] would execute (%s): %s\n\nThe command was not run; no output is available.", mode, command),
+		Stdout:  fmt.Sprintf("would execute: %s", command),
+	}
+}
+
+// acquire 占用一个前台执行名额，名额用尽时排队等待；ctx 结束则放弃
+func (t *BashTool) acquire(ctx context.Context) (release func(), err error) {
+	if t.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case t.slots <- struct{}{}:
+		return func() { <-t.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("command cancelled while waiting for a free slot: %w", ctx.Err())
+	}
+}
+
+// Close 会话结束时终止所有后台进程，避免退出后仍在运行（由 Agent.Close 调用）
+func (t *BashTool) Close() error {
+	globalShellManager.TerminateAll()
+	return nil
 }
 
 func (t *BashTool) Name() string {
 	return "bash"
 }
 
+// Mutates 见 Mutator：任意命令都可能修改文件或系统
+func (t *BashTool) Mutates() bool {
+	return true
+}
+
+// TimeoutHint 前台命令自带超时（最长 600 秒），这里再留出排队等待空闲槽位的余量
+func (t *BashTool) TimeoutHint() time.Duration {
+	return 15 * time.Minute
+}
+
 func (t *BashTool) Description() string {
+	desc := t.baseDescription()
+	if t.settings.confineDir != "" {
+		desc += "\n\nCommands run in the workspace directory; changing directory outside the workspace is rejected."
+	}
+	return desc
+}
+
+func (t *BashTool) baseDescription() string {
 	if t.isWindows {
 		return `Execute PowerShell commands in foreground or background.
 
@@ -336,6 +813,11 @@ func (t *BashTool) Parameters() map[string]any {
 
 // Execute 对应 Python BashTool.execute
 func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	return t.ExecuteWithProgress(ctx, args, nil)
+}
+
+// ExecuteWithProgress 与 Execute 相同；前台命令运行期间把 stdout / stderr 的每一行作为进度发给 progress
+func (t *BashTool) ExecuteWithProgress(ctx context.Context, args map[string]any, progress ProgressFunc) (*ToolResult, error) {
 	command, _ := args["command"].(string)
 	if strings.TrimSpace(command) == "" {
 		return &ToolResult{
@@ -352,18 +834,40 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 	}
 	runBG := getBoolArg(args, "run_in_background", false)
 
+	if dir := t.settings.confineDir; dir != "" {
+		if err := checkConfinedCommand(command, dir); err != nil {
+			return &ToolResult{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+	}
+
+	if t.settings.dryRun {
+		return dryRunResult(command, runBG), nil
+	}
+
 	var cmd *exec.Cmd
 	if t.isWindows {
 		cmd = exec.Command("powershell.exe", "-NoProfile", "-Command", command)
 	} else {
 		cmd = exec.Command("bash", "-c", command)
 	}
+	cmd.Dir = t.settings.confineDir
+	cmd.Env = t.settings.commandEnv()
+	workDir := resolveWorkingDir(cmd.Dir)
 
 	// -----------------------------
 	// 后台执行
 	// -----------------------------
 	if runBG {
-		id := generateBashID()
+		id := t.settings.newID()
+		if globalShellManager.Get(id) != nil {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("bash_id %q is already in use", id),
+			}, nil
+		}
 
 		stdoutPipe, err := cmd.StdoutPipe()
 		if err != nil {
@@ -388,6 +892,7 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 			StdoutReader: bufio.NewReader(stdoutPipe),
 			Start:        time.Now(),
 			Status:       "running",
+			Dir:          workDir,
 		}
 		globalShellManager.Add(shell)
 
@@ -397,43 +902,81 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 		formattedContent := fmt.Sprintf("%s\n\nCommand: %s\nBash ID: %s", message, command, id)
 
 		return &ToolResult{
-			Success:  true,
-			Content:  formattedContent,
-			Stdout:   fmt.Sprintf("Background command started with ID: %s", id),
-			Stderr:   "",
-			ExitCode: 0,
-			BashID:   id,
+			Success:    true,
+			Content:    formattedContent,
+			Stdout:     fmt.Sprintf("Background command started with ID: %s", id),
+			Stderr:     "",
+			ExitCode:   0,
+			BashID:     id,
+			WorkingDir: workDir,
 		}, nil
 	}
 
 	// -----------------------------
 	// 前台执行
 	// -----------------------------
+	release, err := t.acquire(ctx)
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	defer release()
+
 	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
+	stdoutW := []io.Writer{&stdoutBuf}
+	stderrW := []io.Writer{&stderrBuf}
+	if live := t.settings.liveOutput; live != nil {
+		stdoutW = append(stdoutW, live)
+		stderrW = append(stderrW, live)
+	}
+	if progress != nil {
+		lines := newProgressLines(progress)
+		defer lines.Flush()
+		stdoutW = append(stdoutW, lines)
+		stderrW = append(stderrW, lines)
+	}
+	cmd.Stdout = io.MultiWriter(stdoutW...)
+	cmd.Stderr = io.MultiWriter(stderrW...)
 
+	// 杀掉命令后，仍持有输出管道的子进程不应让等待无限延长
+	cmd.WaitDelay = time.Second
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return &ToolResult{
+			Success:    false,
+			Error:      err.Error(),
+			ExitCode:   -1,
+			WorkingDir: workDir,
+		}, nil
+	}
 	done := make(chan error, 1)
 	go func() {
-		done <- cmd.Run()
+		done <- cmd.Wait()
 	}()
+	timedOut := false
 
-	var err error
+	// 只由上面的 goroutine 调用 Wait：杀掉进程后等它返回，输出缓冲区此后不再被写入
 	select {
 	case <-ctx.Done():
 		_ = cmd.Process.Kill()
-		_ = cmd.Wait()
+		<-done
 		err = fmt.Errorf("command cancelled: %w", ctx.Err())
 	case e := <-done:
 		err = e
 	case <-time.After(time.Duration(timeout) * time.Second):
 		_ = cmd.Process.Kill()
-		_ = cmd.Wait()
-		err = fmt.Errorf("command timed out after %d seconds", timeout)
+		<-done
+		timedOut = true
 	}
+	duration := time.Since(start)
 
 	stdout := stdoutBuf.String()
 	stderr := stderrBuf.String()
+	if timedOut {
+		err = fmt.Errorf("command timed out after %d seconds%s", timeout, interactiveHint(stdout, stderr))
+	}
 
 	exitCode := 0
 	if cmd.ProcessState != nil {
@@ -442,26 +985,28 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 		exitCode = -1
 	}
 
-	content := formatBashContent(stdout, stderr, exitCode, "")
+	// 命令正常结束（含非 0 退出）时由策略统一判定成败；超时、取消、启动失败始终是错误
+	var exitErr *exec.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		err = t.settings.stderrPolicy.failure(exitCode, stderr)
+	}
 
+	result := &ToolResult{
+		Success:    err == nil,
+		Content:    formatBashContent(stdout, stderr, exitCode, "", t.settings.maxOutputLines, t.settings.info(workDir, duration)),
+		Stdout:     stdout,
+		Stderr:     stderr,
+		ExitCode:   exitCode,
+		WorkingDir: workDir,
+		DurationMs: duration.Milliseconds(),
+	}
 	if err != nil {
-		return &ToolResult{
-			Success:  false,
-			Content:  content,
-			Error:    err.Error(),
-			Stdout:   stdout,
-			Stderr:   stderr,
-			ExitCode: exitCode,
-		}, nil
+		result.Error = err.Error()
 	}
-
-	return &ToolResult{
-		Success:  true,
-		Content:  content,
-		Stdout:   stdout,
-		Stderr:   stderr,
-		ExitCode: exitCode,
-	}, nil
+	if t.settings.diagnostics {
+		attachDiagnostics(result)
+	}
+	return result, nil
 }
 
 //
@@ -470,16 +1015,23 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 // ============================================================
 //
 
-type BashOutputTool struct{}
+type BashOutputTool struct {
+	settings bashSettings
+}
 
-func NewBashOutputTool() *BashOutputTool {
-	return &BashOutputTool{}
+func NewBashOutputTool(opts ...BashOption) *BashOutputTool {
+	return &BashOutputTool{settings: newBashSettings(opts)}
 }
 
 func (t *BashOutputTool) Name() string {
 	return "bash_output"
 }
 
+// Mutates 见 Mutator：只读取后台命令的输出
+func (t *BashOutputTool) Mutates() bool {
+	return false
+}
+
 func (t *BashOutputTool) Description() string {
 	return `Retrieves output from a running or completed background bash shell.
 
@@ -542,16 +1094,23 @@ func (t *BashOutputTool) Execute(ctx context.Context, args map[string]any) (*Too
 		exitCode = *shell.ExitCode
 	}
 
-	content := formatBashContent(stdout, "", exitCode, id)
-
-	return &ToolResult{
-		Success:  true,
-		Content:  content,
-		Stdout:   stdout,
-		Stderr:   "",
-		ExitCode: exitCode,
-		BashID:   id,
-	}, nil
+	duration := shell.Duration()
+	content := formatBashContent(stdout, "", exitCode, id, t.settings.maxOutputLines, t.settings.info(shell.Dir, duration))
+
+	result := &ToolResult{
+		Success:    true,
+		Content:    content,
+		Stdout:     stdout,
+		Stderr:     "",
+		ExitCode:   exitCode,
+		BashID:     id,
+		WorkingDir: shell.Dir,
+		DurationMs: durationMs(duration),
+	}
+	if t.settings.diagnostics {
+		attachDiagnostics(result)
+	}
+	return result, nil
 }
 
 //
@@ -560,16 +1119,23 @@ func (t *BashOutputTool) Execute(ctx context.Context, args map[string]any) (*Too
 // ============================================================
 //
 
-type BashKillTool struct{}
+type BashKillTool struct {
+	settings bashSettings
+}
 
-func NewBashKillTool() *BashKillTool {
-	return &BashKillTool{}
+func NewBashKillTool(opts ...BashOption) *BashKillTool {
+	return &BashKillTool{settings: newBashSettings(opts)}
 }
 
 func (t *BashKillTool) Name() string {
 	return "bash_kill"
 }
 
+// Mutates 见 Mutator：终止进程
+func (t *BashKillTool) Mutates() bool {
+	return true
+}
+
 func (t *BashKillTool) Description() string {
 	return `Kills a running background bash shell by its ID.
 
@@ -619,14 +1185,17 @@ func (t *BashKillTool) Execute(ctx context.Context, args map[string]any) (*ToolR
 		exitCode = -1
 	}
 
-	content := formatBashContent(stdout, "", exitCode, id)
+	duration := shell.Duration()
+	content := formatBashContent(stdout, "", exitCode, id, t.settings.maxOutputLines, t.settings.info(shell.Dir, duration))
 
 	return &ToolResult{
-		Success:  true,
-		Content:  content,
-		Stdout:   stdout,
-		Stderr:   "",
-		ExitCode: exitCode,
-		BashID:   id,
+		Success:    true,
+		Content:    content,
+		Stdout:     stdout,
+		Stderr:     "",
+		ExitCode:   exitCode,
+		BashID:     id,
+		WorkingDir: shell.Dir,
+		DurationMs: durationMs(duration),
 	}, nil
 }
@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// 紧凑的工具结果（发给模型时节省 token，日志与终端仍使用完整格式）
+// ---------------------------------------------------------
+
+var (
+	// read_file 右对齐的行号：去掉左侧填充
+	paddedLineNo = regexp.MustCompile(`(?m)^ +(\d+(?:\.\d+)?)\|`)
+	// formatBashContent 的段标题（连同前面的空行），exit_code / bash_id / working_dir / duration_ms 的值在下一行
+	bashValueSection  = regexp.MustCompile(`\n*\[(exit_code|bash_id|working_dir|duration_ms)\]:\n(.*)`)
+	bashStderrSection = regexp.MustCompile(`\n*\[stderr\]:\n`)
+	// 连续的空行
+	blankRun = regexp.MustCompile(`\n{3,}`)
+)
+
+// CompactContent 返回工具结果的紧凑形式：去掉 read_file 行号的对齐填充，
+// 把 bash 的 "[exit_code]:\n0" 等段标题折叠为 "exit_code: 0"，"[stderr]:" 简写为 "stderr:"，
+// 并去掉段标题前的空行，去掉行尾空白并把连续空行合并为一行。行首缩进保持不变
+func CompactContent(s string) string {
+	if s == "" {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	s = strings.Join(lines, "\n")
+
+	s = paddedLineNo.ReplaceAllString(s, "$1|")
+	s = bashValueSection.ReplaceAllString(s, "\n$1: $2")
+	s = bashStderrSection.ReplaceAllString(s, "\nstderr:\n")
+	s = blankRun.ReplaceAllString(s, "\n\n")
+	return strings.Trim(s, "\n")
+}
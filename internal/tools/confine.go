@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+//
+// ============================================================
+// Bash 工作空间限制（best-effort）
+// ============================================================
+//
+// 开启后命令固定在 workspace 下执行，并拒绝把目录切换到 workspace 之外的 cd。
+// 这只是静态检查：脚本内部的 cd、子进程、直接访问绝对路径（cat /etc/passwd）
+// 都无法拦截，不能替代容器等真正的沙箱。
+
+// cdCommands 会切换当前目录的命令（bash 与 PowerShell）
+var cdCommands = map[string]bool{
+	"cd":           true,
+	"pushd":        true,
+	"chdir":        true,
+	"set-location": true,
+	"sl":           true,
+}
+
+// segmentPrefixes 出现在命令开头、其后才是真正命令名的关键字
+var segmentPrefixes = map[string]bool{
+	"then":  true,
+	"do":    true,
+	"else":  true,
+	"if":    true,
+	"while": true,
+	"until": true,
+	"time":  true,
+	"!":     true,
+}
+
+// checkConfinedCommand 检查命令中的 cd 目标是否都在 workspace 内。
+// 相对路径按此前 cd 之后的目录解析；无法静态确定的目标（含变量、命令替换、~ 或不带参数回到 home）一律拒绝。
+func checkConfinedCommand(command, workspace string) error {
+	cwd, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	for _, segment := range splitCommandSegments(command) {
+		words := shellWords(segment)
+		for len(words) > 0 && (segmentPrefixes[words[0]] || isEnvAssignment(words[0])) {
+			words = words[1:]
+		}
+		if len(words) == 0 || !cdCommands[strings.ToLower(words[0])] {
+			continue
+		}
+
+		target := cdTarget(words[1:])
+		switch {
+		case target == "" || strings.HasPrefix(target, "~"):
+			return fmt.Errorf("command %q changes to the home directory, which is outside the workspace (bash confinement is enabled)", strings.TrimSpace(segment))
+		case target == "-":
+			continue
+		case strings.ContainsAny(target, "$`*?"):
+			return fmt.Errorf("command %q changes to a directory that cannot be checked statically (bash confinement is enabled)", strings.TrimSpace(segment))
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(cwd, target)
+		}
+		resolved, err := resolveInWorkspace(workspace, target)
+		if err != nil {
+			return fmt.Errorf("command changes directory to %s, which is outside the workspace (bash confinement is enabled)", target)
+		}
+		cwd = resolved
+	}
+	return nil
+}
+
+// cdTarget 返回 cd 的目标参数（跳过 -P / -L 等选项）
+func cdTarget(args []string) string {
+	for i, a := range args {
+		if a == "--" {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		}
+		if a != "-" && strings.HasPrefix(a, "-") {
+			continue
+		}
+		return a
+	}
+	return ""
+}
+
+// isEnvAssignment 判断是否为命令前的 NAME=value 环境变量赋值
+func isEnvAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (i == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCommandSegments 按命令分隔符（; & | ( ) { } 换行 反引号）切分，忽略引号内的分隔符
+func splitCommandSegments(command string) []string {
+	var segments []string
+	var cur strings.Builder
+	var quote rune
+
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			segments = append(segments, s)
+		}
+		cur.Reset()
+	}
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			cur.WriteRune(r)
+		case strings.ContainsRune(";&|(){}\n`", r):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return segments
+}
+
+// shellWords 按空白切分单词并去掉引号（不做变量展开）
+func shellWords(segment string) []string {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, r := range segment {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//
+// ---------------------------------------------------------
+// CopyFileTool（复制文件或目录树，默认不覆盖）
+// ---------------------------------------------------------
+
+// maxCopyFiles 单次递归复制最多的文件数
+const maxCopyFiles = 10000
+
+type CopyFileTool struct {
+	workspace string
+	overlay   *Overlay
+	backup    *SessionBackup
+	tracker   *ReadTracker
+}
+
+// CopyOption CopyFileTool 的可选配置
+type CopyOption func(*CopyFileTool)
+
+// WithCopyOverlay 从 overlay 读取已修改的源文件，并把副本写入 overlay
+func WithCopyOverlay(o *Overlay) CopyOption {
+	return func(t *CopyFileTool) {
+		t.overlay = o
+	}
+}
+
+// NewCopyFileTool 创建 copy_file 工具（源与目标都限制在 workspace 内）
+func NewCopyFileTool(workspace string, opts ...CopyOption) *CopyFileTool {
+	t := &CopyFileTool{workspace: workspace}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *CopyFileTool) Name() string {
+	return "copy_file"
+}
+
+// Mutates 见 Mutator
+func (t *CopyFileTool) Mutates() bool {
+	return true
+}
+
+func (t *CopyFileTool) Description() string {
+	return "Copy a file, or a directory tree with recursive: true, within the workspace (e.g. to scaffold from a template). " +
+		"destination is the full target path; missing parent directories are created and file modes are preserved. " +
+		"Existing files are never overwritten unless overwrite: true. Symbolic links inside a copied tree are skipped."
+}
+
+func (t *CopyFileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"source": map[string]any{
+				"type":        "string",
+				"description": "File or directory to copy (relative to workspace)",
+			},
+			"destination": map[string]any{
+				"type":        "string",
+				"description": "Target path of the copy (relative to workspace)",
+			},
+			"recursive": map[string]any{
+				"type":        "boolean",
+				"description": "Required to copy a directory tree (default: false)",
+			},
+			"overwrite": map[string]any{
+				"type":        "boolean",
+				"description": "Replace files that already exist at the destination (default: false)",
+			},
+		},
+		"required": []string{"source", "destination"},
+	}
+}
+
+// copyEntry 一个待复制的文件或目录
+type copyEntry struct {
+	src, dst string
+	rel      string // 相对 destination 的路径，用于报告变更
+	mode     fs.FileMode
+	dir      bool
+	exists   bool
+}
+
+func (t *CopyFileTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	source, _ := args["source"].(string)
+	destination, _ := args["destination"].(string)
+	recursive, _ := args["recursive"].(bool)
+	overwrite, _ := args["overwrite"].(bool)
+	if source == "" || destination == "" {
+		return &ToolResult{Success: false, Error: "source and destination are required"}, nil
+	}
+
+	src, err := resolveInWorkspace(t.workspace, source)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	dst, err := resolveInWorkspace(t.workspace, destination)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	info, err := os.Stat(t.overlay.ReadPath(src))
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("source not found: %s", source)}, nil
+	}
+	if info.IsDir() && !recursive {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("%s is a directory; set recursive: true to copy it", source)}, nil
+	}
+	if src == dst || (info.IsDir() && isWithin(src, dst)) {
+		return &ToolResult{Success: false, Error: "destination must not be the source or inside it"}, nil
+	}
+
+	entries, err := planCopy(src, dst, info, t.overlay)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	// 先检查冲突再写入，拒绝时目标保持原样
+	for _, e := range entries {
+		switch {
+		case !e.exists:
+		case e.dir != isDirPath(e.dst):
+			return &ToolResult{Success: false, Error: fmt.Sprintf("cannot replace %s: one is a file and the other a directory", filepath.Join(destination, e.rel))}, nil
+		case !e.dir && !overwrite:
+			return &ToolResult{Success: false, Error: fmt.Sprintf("%s already exists; set overwrite: true to replace it", filepath.Join(destination, e.rel))}, nil
+		}
+	}
+
+	// 写入前备份目标位置的原始文件（新建的目录此时还不存在，回滚时一并删除）
+	if t.overlay == nil {
+		for _, e := range entries {
+			if !e.dir {
+				t.backup.Save(e.dst)
+			}
+		}
+	}
+
+	var files int
+	var bytes int64
+	var changes []ChangedFile
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return &ToolResult{Success: false, Error: ctx.Err().Error(), Changes: changes}, nil
+		}
+		if e.dir {
+			// overlay 中的目录随文件一起创建
+			if t.overlay == nil {
+				if err := os.MkdirAll(e.dst, e.mode.Perm()|0o700); err != nil {
+					return &ToolResult{Success: false, Error: err.Error(), Changes: changes}, nil
+				}
+			}
+			continue
+		}
+		target, err := t.overlay.WritePath(e.dst)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error(), Changes: changes}, nil
+		}
+		n, err := copyFile(t.overlay.ReadPath(e.src), target, e.mode)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error(), Changes: changes}, nil
+		}
+		t.overlay.MarkWritten(e.dst)
+		t.tracker.Record(e.dst, target)
+		files++
+		bytes += n
+		op := ChangeCreated
+		if e.exists {
+			op = ChangeModified
+		}
+		changes = append(changes, ChangedFile{Path: filepath.ToSlash(filepath.Join(destination, e.rel)), Op: op})
+	}
+
+	return &ToolResult{
+		Success: true,
+		Content: fmt.Sprintf("Copied %d file(s), %d bytes from %s to %s", files, bytes, source, destination),
+		Changes: changes,
+	}, nil
+}
+
+// planCopy 列出需要复制的目录与文件（目录在其内容之前）；目录树中的符号链接跳过。
+// 目标是否已存在同时考虑 overlay 中的文件
+func planCopy(src, dst string, info fs.FileInfo, overlay *Overlay) ([]copyEntry, error) {
+	if !info.IsDir() {
+		return []copyEntry{{src: src, dst: dst, mode: info.Mode(), exists: pathExists(overlay.ReadPath(dst))}}, nil
+	}
+
+	var entries []copyEntry
+	files := 0
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			if files++; files > maxCopyFiles {
+				return fmt.Errorf("too many files to copy (more than %d)", maxCopyFiles)
+			}
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		entries = append(entries, copyEntry{src: p, dst: target, rel: rel, mode: fi.Mode(), dir: d.IsDir(), exists: pathExists(overlay.ReadPath(target))})
+		return nil
+	})
+	return entries, err
+}
+
+// copyFile 复制单个文件并保留权限位，返回写入的字节数
+func copyFile(src, dst string, mode fs.FileMode) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	// 覆盖已存在的文件时 OpenFile 不会修改权限，显式设置
+	return n, os.Chmod(dst, mode.Perm())
+}
+
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func isDirPath(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//
+// ============================================================
+// Diagnostics —— 从构建 / 测试输出中提取结构化错误
+// ============================================================
+//
+
+// maxDiagnostics 附加到结果中的诊断条数上限，避免大量重复报错撑爆上下文
+const maxDiagnostics = 50
+
+// Diagnostic 一条可定位到源码位置的错误
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	Test    string `json:"test,omitempty"` // go test 失败时所属的测试名
+}
+
+// String 以 file:line[:col]: message 形式输出
+func (d Diagnostic) String() string {
+	loc := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		loc += fmt.Sprintf(":%d", d.Column)
+	}
+	if d.Test != "" {
+		return fmt.Sprintf("%s: [%s] %s", loc, d.Test, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, d.Message)
+}
+
+var (
+	// go build / go vet / gopls 风格：path/x.go:12:5: message（vet 可能带 "vet: " 前缀）
+	// go test 的 t.Errorf 输出：缩进后的 x_test.go:12: message
+	goDiagnosticRe = regexp.MustCompile(`^\s*(?:vet: )?([^\s:][^:]*\.go):(\d+)(?::(\d+))?: (.+)$`)
+	// go test 的测试名：=== RUN / --- FAIL
+	goTestNameRe = regexp.MustCompile(`^\s*(?:=== RUN|--- FAIL:)\s+(\S+)`)
+)
+
+// ParseDiagnostics 从命令输出中识别 Go 编译、vet 与 go test 的错误行。
+// 未识别的行直接忽略；相同位置的相同消息只保留一条。
+func ParseDiagnostics(output string) []Diagnostic {
+	var diags []Diagnostic
+	seen := map[string]bool{}
+	test := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if m := goTestNameRe.FindStringSubmatch(line); m != nil {
+			test = m[1]
+			continue
+		}
+
+		m := goDiagnosticRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		d := Diagnostic{
+			File:    m[1],
+			Line:    lineNo,
+			Column:  col,
+			Message: strings.TrimSpace(m[4]),
+		}
+		// 只有缩进的测试日志行才属于当前测试，编译错误顶格输出
+		if test != "" && strings.HasPrefix(line, " ") {
+			d.Test = test
+		}
+
+		key := d.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// formatDiagnostics 生成附加在 Content 之后的紧凑诊断列表
+func formatDiagnostics(diags []Diagnostic) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[diagnostics]: %d issue(s)\n", len(diags))
+	for i, d := range diags {
+		if i == maxDiagnostics {
+			fmt.Fprintf(&b, "... and %d more\n", len(diags)-maxDiagnostics)
+			break
+		}
+		b.WriteString("- ")
+		b.WriteString(d.String())
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// attachDiagnostics 解析原始输出，把诊断写入结果并在 Content 末尾追加摘要（原始输出保持不变）
+func attachDiagnostics(result *ToolResult) {
+	diags := ParseDiagnostics(result.Stdout + "\n" + result.Stderr)
+	if len(diags) == 0 {
+		return
+	}
+	result.Diagnostics = diags
+	result.Content += "\n" + formatDiagnostics(diags)
+}
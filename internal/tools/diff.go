@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// Unified Diff（简易行级 diff，用于结果预览）
+// ---------------------------------------------------------
+
+// maxDiffLines diff 两侧行数之和的上限，超过则不生成 diff（LCS 为 O(n*m)）
+const maxDiffLines = 400
+
+// diffContext 每个 hunk 前后保留的上下文行数
+const diffContext = 3
+
+type diffOp struct {
+	kind    byte // ' ' / '-' / '+'
+	text    string
+	oldLine int // 该操作之前旧文件已消耗的行数
+	newLine int // 该操作之前新文件已消耗的行数
+}
+
+// splitLines 按行切分，忽略末尾换行产生的空行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// unifiedDiff 生成 oldText → newText 的 unified diff。
+// 行数过多时返回 ok=false；内容相同返回空字符串。
+func unifiedDiff(oldText, newText, name string) (string, bool) {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+	if len(a)+len(b) > maxDiffLines {
+		return "", false
+	}
+
+	ops := diffLines(a, b)
+
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return "", true
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+
+	i := 0
+	for i < len(ops) {
+		// 找到下一个变更
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i >= len(ops) {
+			break
+		}
+
+		start := max(0, i-diffContext)
+		end := i
+		// 向后扩展，直到连续的未变更行超过 2*context
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run >= len(ops) || run-end > 2*diffContext {
+				end = min(len(ops), end+diffContext)
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&sb, ops[start:end])
+		i = end
+	}
+
+	return strings.TrimRight(sb.String(), "\n"), true
+}
+
+// writeHunk 输出一个 hunk（含 @@ 头）
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	oldStart, newStart := ops[0].oldLine+1, ops[0].newLine+1
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, op := range ops {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines 基于 LCS 计算行级编辑序列
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] = a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], oldLine: i, newLine: j})
+			i++
+			j++
+		case i < n && (j >= m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], oldLine: i, newLine: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], oldLine: i, newLine: j})
+			j++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+//
+// ---------------------------------------------------------
+// 写文件时的换行与编码策略
+// ---------------------------------------------------------
+//
+
+// NewlinePolicy 写入文件时对末尾换行的处理
+type NewlinePolicy string
+
+const (
+	// NewlineLeave 原样写入（默认）
+	NewlineLeave NewlinePolicy = "leave"
+	// NewlineEnsure 非空内容保证以一个换行结尾（沿用内容中的 \r\n 风格）
+	NewlineEnsure NewlinePolicy = "ensure"
+	// NewlineStrip 去掉末尾所有换行
+	NewlineStrip NewlinePolicy = "strip"
+)
+
+// ParseNewlinePolicy 解析配置值，空字符串视为默认的 leave
+func ParseNewlinePolicy(s string) (NewlinePolicy, error) {
+	switch p := NewlinePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return NewlineLeave, nil
+	case NewlineLeave, NewlineEnsure, NewlineStrip:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid trailing newline policy %q (want leave, ensure or strip)", s)
+}
+
+// apply 按策略处理内容末尾的换行
+func (p NewlinePolicy) apply(content string) string {
+	switch p {
+	case NewlineEnsure:
+		if content == "" || strings.HasSuffix(content, "\n") {
+			return content
+		}
+		if strings.Contains(content, "\r\n") {
+			return content + "\r\n"
+		}
+		return content + "\n"
+	case NewlineStrip:
+		return strings.TrimRight(content, "\r\n")
+	}
+	return content
+}
+
+// Encoding 写入文件使用的编码
+type Encoding string
+
+const (
+	// EncodingUTF8 UTF-8，不加 BOM（默认）
+	EncodingUTF8 Encoding = "utf-8"
+	// EncodingUTF8BOM UTF-8，带 BOM
+	EncodingUTF8BOM Encoding = "utf-8-bom"
+	// EncodingUTF16LE UTF-16 小端，带 BOM
+	EncodingUTF16LE Encoding = "utf-16le"
+	// EncodingUTF16BE UTF-16 大端，带 BOM
+	EncodingUTF16BE Encoding = "utf-16be"
+)
+
+// ParseEncoding 解析配置值，空字符串视为默认的 utf-8
+func ParseEncoding(s string) (Encoding, error) {
+	switch e := Encoding(strings.ToLower(strings.TrimSpace(s))); e {
+	case "", "utf8":
+		return EncodingUTF8, nil
+	case EncodingUTF8, EncodingUTF8BOM, EncodingUTF16LE, EncodingUTF16BE:
+		return e, nil
+	}
+	return "", fmt.Errorf("invalid output encoding %q (want utf-8, utf-8-bom, utf-16le or utf-16be)", s)
+}
+
+const bom = "\ufeff"
+
+// encode 把文本编码为写入文件的字节。
+// 带 BOM 的编码会先去掉内容自带的 BOM，避免重复；默认 utf-8 原样写入。
+func (e Encoding) encode(content string) []byte {
+	switch e {
+	case EncodingUTF8BOM:
+		return []byte(bom + strings.TrimPrefix(content, bom))
+	case EncodingUTF16LE, EncodingUTF16BE:
+		units := utf16.Encode([]rune(bom + strings.TrimPrefix(content, bom)))
+		out := make([]byte, 0, len(units)*2)
+		for _, u := range units {
+			if e == EncodingUTF16LE {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out
+	}
+	return []byte(content)
+}
+
+// text 把文件字节转换为用于比较 / diff 的文本：UTF-16 先解码，UTF-8 原样返回
+func (e Encoding) text(data []byte) string {
+	if e == EncodingUTF16LE || e == EncodingUTF16BE {
+		return decodeText(data)
+	}
+	return string(data)
+}
+
+// decodeText 按 BOM 识别 UTF-8 / UTF-16 文件内容并返回文本（无 BOM 时按 UTF-8 处理）
+func decodeText(data []byte) string {
+	switch {
+	case len(data) >= 3 && string(data[:3]) == bom:
+		return string(data[3:])
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE:
+		return decodeUTF16(data[2:], false)
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		return decodeUTF16(data[2:], true)
+	}
+	return string(data)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
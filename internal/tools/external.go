@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//
+// ---------------------------------------------------------
+// ExternalTool（配置中声明的外部工具：运行命令，参数以 JSON 从 stdin 传入，stdout 作为结果）
+// ---------------------------------------------------------
+
+const (
+	// DefaultExternalToolTimeout 外部工具的默认超时
+	DefaultExternalToolTimeout = time.Minute
+	// externalToolMaxTokens 返回给模型的输出 token 上限
+	externalToolMaxTokens = 8000
+)
+
+// ExternalToolSpec 一个外部工具的定义
+type ExternalToolSpec struct {
+	Name        string
+	Description string
+	// Command 命令与参数（不经过 shell），在 workspace 下运行
+	Command []string
+	// Parameters 参数的 JSON Schema（顶层为 object）；为空时表示不接受参数
+	Parameters map[string]any
+	// Timeout <= 0 时使用 DefaultExternalToolTimeout
+	Timeout time.Duration
+	// ReadOnly 声明命令不修改工作空间，计划阶段与只读模式下也可使用
+	ReadOnly bool
+}
+
+type ExternalTool struct {
+	spec      ExternalToolSpec
+	workspace string
+}
+
+// NewExternalTool 根据定义创建外部工具；名称、命令与参数 schema 无效时返回错误
+func NewExternalTool(workspace string, spec ExternalToolSpec) (*ExternalTool, error) {
+	if len(spec.Command) == 0 || strings.TrimSpace(spec.Command[0]) == "" {
+		return nil, fmt.Errorf("external tool %q: command is required", spec.Name)
+	}
+	if spec.Parameters == nil {
+		spec.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if spec.Timeout <= 0 {
+		spec.Timeout = DefaultExternalToolTimeout
+	}
+	t := &ExternalTool{spec: spec, workspace: workspace}
+	if err := ValidateTool(t); err != nil {
+		return nil, fmt.Errorf("external tool: %w", err)
+	}
+	return t, nil
+}
+
+// LoadExternalTools 创建配置中的全部外部工具；与 reserved 中的名称（内置工具）或彼此重名时返回错误
+func LoadExternalTools(workspace string, specs []ExternalToolSpec, reserved []string) ([]Tool, error) {
+	taken := make(map[string]bool, len(reserved)+len(specs))
+	for _, name := range reserved {
+		taken[name] = true
+	}
+	out := make([]Tool, 0, len(specs))
+	for _, spec := range specs {
+		if taken[spec.Name] {
+			return nil, fmt.Errorf("external tool %q: a tool with this name already exists", spec.Name)
+		}
+		t, err := NewExternalTool(workspace, spec)
+		if err != nil {
+			return nil, err
+		}
+		taken[spec.Name] = true
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (t *ExternalTool) Name() string {
+	return t.spec.Name
+}
+
+func (t *ExternalTool) Description() string {
+	if t.spec.Description != "" {
+		return t.spec.Description
+	}
+	return fmt.Sprintf("User-defined tool that runs %s.", t.spec.Command[0])
+}
+
+func (t *ExternalTool) Parameters() map[string]any {
+	return t.spec.Parameters
+}
+
+// Mutates 见 Mutator：外部命令默认视为会修改，除非配置声明为只读
+func (t *ExternalTool) Mutates() bool {
+	return !t.spec.ReadOnly
+}
+
+// TimeoutHint 由工具自己的超时控制，Agent 的工具超时稍长于它
+func (t *ExternalTool) TimeoutHint() time.Duration {
+	return t.spec.Timeout + 10*time.Second
+}
+
+func (t *ExternalTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("cannot encode arguments: %v", err)}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.spec.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.spec.Command[0], t.spec.Command[1:]...)
+	cmd.Dir = t.workspace
+	cmd.Env = append(os.Environ(), "GOPILOT_TOOL="+t.spec.Name, "GOPILOT_WORKSPACE="+t.workspace)
+	cmd.Stdin = bytes.NewReader(input)
+	// 超时杀掉命令后，仍持有输出管道的子进程不应让等待无限延长
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	result := &ToolResult{
+		Content: TruncateTextByTokens(stdout.String(), externalToolMaxTokens),
+		Stdout:  stdout.String(),
+		Stderr:  stderr.String(),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Error = fmt.Sprintf("%s timed out after %s", t.spec.Name, t.spec.Timeout)
+	case errors.As(err, &exitErr):
+		result.Error = fmt.Sprintf("%s exited with code %d", t.spec.Name, result.ExitCode)
+	case err != nil:
+		result.Error = fmt.Sprintf("cannot run %s: %v", t.spec.Command[0], err)
+	default:
+		result.Success = true
+		return result, nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		result.Error += ":\n" + TruncateTextByTokens(msg, externalToolMaxTokens)
+	}
+	return result, nil
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pkoukk/tiktoken-go"
 )
@@ -98,19 +102,32 @@ func max(a, b int) int {
 
 type ReadTool struct {
 	workspace string
+	access    readAccess
 }
 
-// NewReadTool 创建文件读取工具
-func NewReadTool(workspace string) *ReadTool {
-	return &ReadTool{workspace: workspace}
+// NewReadTool 创建文件读取工具（可用 WithExtraReadRoots 允许读取 workspace 之外的目录）
+func NewReadTool(workspace string, opts ...ReadOption) *ReadTool {
+	return &ReadTool{workspace: workspace, access: newReadAccess(workspace, opts)}
+}
+
+// TimeoutHint 读文件应当很快完成，卡住（如网络文件系统）时尽早放弃
+func (t *ReadTool) TimeoutHint() time.Duration {
+	return 30 * time.Second
 }
 
 func (t *ReadTool) Name() string {
 	return "read_file"
 }
 
+// Mutates 见 Mutator
+func (t *ReadTool) Mutates() bool {
+	return false
+}
+
 func (t *ReadTool) Description() string {
-	return "Read file content with line numbers. Supports offset/limit and token truncation."
+	return "Read file content with line numbers. Supports offset/limit paging; large files are returned in chunks with the next offset to continue from. " +
+		"Very long lines (minified code, one-line JSON) are wrapped into numbered segments such as 12.2 and shown in parts; use column to continue inside such a line. " +
+		"To look at a symbol in a large file, pass context_pattern: only matching lines and context_lines of surrounding context are returned, with gaps marked."
 }
 
 func (t *ReadTool) Parameters() map[string]any {
@@ -123,12 +140,24 @@ func (t *ReadTool) Parameters() map[string]any {
 			},
 			"offset": map[string]any{
 				"type":        "integer",
-				"description": "Starting line number (1-indexed)",
+				"description": "Starting line number (1-indexed). When output is cut off, the result says which offset to continue from.",
 			},
 			"limit": map[string]any{
 				"type":        "integer",
 				"description": "Number of lines to read",
 			},
+			"column": map[string]any{
+				"type":        "integer",
+				"description": "Optional: 1-indexed character position to start from within the first line read. Only needed to continue a very long line, as the result instructs.",
+			},
+			"context_pattern": map[string]any{
+				"type":        "string",
+				"description": "Optional regular expression (RE2 syntax, e.g. \"func Load\"). When set, only matching lines and their surrounding context are returned; offset, limit and column are ignored.",
+			},
+			"context_lines": map[string]any{
+				"type":        "integer",
+				"description": "Lines of context before and after each match when context_pattern is set (default: 3, max: 50)",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -136,60 +165,184 @@ func (t *ReadTool) Parameters() map[string]any {
 
 func (t *ReadTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
 	// 解析参数
-	path := args["path"].(string)
-
-	var offset, limit *int
-	if v, ok := args["offset"].(int); ok {
-		offset = &v
-	}
-	if v, ok := args["limit"].(int); ok {
-		limit = &v
+	path, err := requiredStringArg(args, "path")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
 	}
 
-	// 解析文件路径（相对路径基于 workspace）
-	file := filepath.Join(t.workspace, path)
+	// JSON 数字解析为 float64，统一用 getIntArg 读取
+	offset := getIntArg(args, "offset", 1)
+	limit := getIntArg(args, "limit", 0)
+	column := max(getIntArg(args, "column", 1), 1)
+
+	// 解析文件路径（相对路径基于 workspace），只允许 workspace 与额外的只读根目录
+	file, err := t.access.resolve(path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
 
-	data, err := os.ReadFile(file)
+	data, err := os.ReadFile(t.access.overlay.ReadPath(file))
 	if err != nil {
 		return &ToolResult{Success: false, Error: fmt.Sprintf("File not found: %s", path)}, nil
 	}
+	t.access.tracker.Record(file, t.access.overlay.ReadPath(file))
 
 	lines := strings.Split(string(data), "\n")
+	// 以换行结尾的文件不额外计一个空行
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+
+	// 只返回匹配 context_pattern 的行及其上下文
+	if pattern, _ := args["context_pattern"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("invalid context_pattern: %v", err)}, nil
+		}
+		n := min(max(getIntArg(args, "context_lines", defaultContextLines), 0), maxContextLines)
+		ranges, matched, count := contextRanges(lines, re, n)
+		return &ToolResult{Success: true, Content: formatContext(path, pattern, lines, ranges, matched, count)}, nil
+	}
 
 	// -------------------------
 	// 处理 offset / limit
 	// -------------------------
-	start := 0
-	if offset != nil {
-		start = *offset - 1
-		if start < 0 {
-			start = 0
-		}
-	}
-	end := len(lines)
-	if limit != nil {
-		end = min(start+*limit, len(lines))
+	start := max(offset-1, 0)
+	if start >= total && total > 0 {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("offset %d is beyond end of file (%d lines)", offset, total),
+		}, nil
 	}
-	if start > len(lines) {
-		start = len(lines)
+	end := total
+	if limit > 0 {
+		end = min(start+limit, total)
 	}
 
-	selected := lines[start:end]
+	if column > 1 && total > 0 {
+		if n := utf8.RuneCountInString(lines[start]); column > n {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("column %d is beyond end of line %d (%d characters)", column, start+1, n),
+			}, nil
+		}
+	}
 
 	// -------------------------
-	// 添加行号（右对齐 6 格）
+	// 添加行号（右对齐 6 格），按 token 预算在行边界处分页；超长行软换行并分段显示
 	// -------------------------
-	formatted := make([]string, len(selected))
-	for i, line := range selected {
-		formatted[i] = fmt.Sprintf("%6d|%s", start+i+1, line)
+	page := formatLinesWithinTokens(lines[start:end], start+1, column, readMaxTokens)
+	shownEnd := start + page.shown
+
+	content := strings.Join(page.lines, "\n")
+	switch {
+	case page.truncated:
+		content += fmt.Sprintf("\n\n[Line %d is too long and was truncated.]", shownEnd)
+	case page.cut != nil:
+		c := page.cut
+		content += fmt.Sprintf("\n\n[Line %d has %d characters; showing characters %d-%d. Use offset=%d and column=%d to continue this line.]",
+			c.lineNo, c.total, c.from, c.to, c.lineNo, c.to+1)
 	}
+	if page.cut == nil && shownEnd < total {
+		content += fmt.Sprintf("\n\n[Showing lines %d-%d of %d. Use offset=%d to continue reading.]",
+			start+1, shownEnd, total, shownEnd+1)
+	}
+
+	return &ToolResult{Success: true, Content: content}, nil
+}
 
-	content := strings.Join(formatted, "\n")
+// readMaxTokens read_file 单次返回的 token 上限（与 Python 版 32000 一致）
+const readMaxTokens = 32000
 
-	// Token 截断（保持与 Python 32000 限制一致）
-	content = TruncateTextByTokens(content, 32000)
+const (
+	// longLineChars 超过该字符数的行按此宽度软换行，续行编号为 "行号.段号"
+	longLineChars = 2000
+	// maxLineChars 单次最多显示一行中的字符数，其余部分用 column 继续读取
+	maxLineChars = 20000
+)
 
-	return &ToolResult{Success: true, Content: content}, nil
+// readPage 一次 read_file 的显示结果
+type readPage struct {
+	lines     []string // 带行号的显示行
+	shown     int      // 已（全部或部分）显示的源文件行数
+	cut       *lineCut // 最后一行只显示了一部分
+	truncated bool     // 单行超出 token 预算被截断
+}
+
+// lineCut 超长行分次显示时的位置（字符位置从 1 开始）
+type lineCut struct {
+	lineNo, from, to, total int
+}
+
+// wrapLine 从第 column 个字符开始格式化一行：超长行切成 longLineChars 宽的段，
+// 最多显示 maxLineChars 个字符；未显示完时返回 cut
+func wrapLine(lineNo int, line string, column int) (string, *lineCut) {
+	if column <= 1 && len(line) <= longLineChars {
+		return fmt.Sprintf("%6d|%s", lineNo, line), nil
+	}
+
+	runes := []rune(line)
+	from := min(column, len(runes)+1) - 1
+	to := min(from+maxLineChars, len(runes))
+
+	var b strings.Builder
+	seg := 1
+	for i := from; i < to || seg == 1; i += longLineChars {
+		if seg > 1 {
+			b.WriteString("\n")
+		}
+		label := strconv.Itoa(lineNo)
+		if from > 0 || len(runes) > longLineChars {
+			label = fmt.Sprintf("%d.%d", lineNo, i/longLineChars+1)
+		}
+		fmt.Fprintf(&b, "%6s|%s", label, string(runes[i:min(i+longLineChars, to)]))
+		seg++
+	}
+
+	if to < len(runes) {
+		return b.String(), &lineCut{lineNo: lineNo, from: from + 1, to: to, total: len(runes)}
+	}
+	return b.String(), nil
+}
+
+// formatLinesWithinTokens 为行添加行号，累计 token 不超过 maxTokens 时停止（至少返回一行）。
+// firstColumn 为第一行的起始字符位置；超长行只显示一部分时在该行停止。
+// 单行本身超出预算时截断该行并标记 truncated。
+func formatLinesWithinTokens(lines []string, firstLineNo, firstColumn, maxTokens int) readPage {
+	enc, err := tiktoken.GetEncoding("cl100k_base")
+
+	page := readPage{lines: make([]string, 0, len(lines))}
+	used := 0
+	for i, line := range lines {
+		column := 1
+		if i == 0 {
+			column = firstColumn
+		}
+		formatted, cut := wrapLine(firstLineNo+i, line, column)
+
+		var cost int
+		if err != nil {
+			cost = len(formatted)/4 + 1 // 编码器不可用时按字符粗略估算
+		} else {
+			cost = len(enc.Encode(formatted, nil, nil)) + 1
+		}
+
+		if used+cost > maxTokens {
+			if len(page.lines) == 0 {
+				return readPage{lines: []string{TruncateTextByTokens(formatted, maxTokens)}, shown: 1, truncated: true}
+			}
+			break
+		}
+		used += cost
+		page.lines = append(page.lines, formatted)
+		page.shown++
+		if cut != nil {
+			page.cut = cut
+			break
+		}
+	}
+	return page
 }
 
 //
@@ -199,18 +352,56 @@ func (t *ReadTool) Execute(ctx context.Context, args map[string]any) (*ToolResul
 
 type WriteTool struct {
 	workspace string
+	newline   NewlinePolicy
+	encoding  Encoding
+	overlay   *Overlay
+	tracker   *ReadTracker
+	backup    *SessionBackup
+}
+
+// WriteOption WriteTool 的可选配置
+type WriteOption func(*WriteTool)
+
+// WithNewlinePolicy 设置写入时末尾换行的处理方式（默认 NewlineLeave，原样写入）
+func WithNewlinePolicy(p NewlinePolicy) WriteOption {
+	return func(t *WriteTool) {
+		t.newline = p
+	}
+}
+
+// WithEncoding 设置写入文件的编码（默认 EncodingUTF8，原样写入）
+func WithEncoding(e Encoding) WriteOption {
+	return func(t *WriteTool) {
+		t.encoding = e
+	}
+}
+
+// WithWriteOverlay 写入 overlay 而不是真实 workspace
+func WithWriteOverlay(o *Overlay) WriteOption {
+	return func(t *WriteTool) {
+		t.overlay = o
+	}
 }
 
-func NewWriteTool(workspace string) *WriteTool {
-	return &WriteTool{workspace: workspace}
+func NewWriteTool(workspace string, opts ...WriteOption) *WriteTool {
+	t := &WriteTool{workspace: workspace, newline: NewlineLeave, encoding: EncodingUTF8}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
 func (t *WriteTool) Name() string {
 	return "write_file"
 }
 
+// Mutates 见 Mutator
+func (t *WriteTool) Mutates() bool {
+	return true
+}
+
 func (t *WriteTool) Description() string {
-	return "Write full content to a file. Overwrites existing content."
+	return "Write full content to a file. Overwrites existing content; the result summarizes what was replaced."
 }
 
 func (t *WriteTool) Parameters() map[string]any {
@@ -229,23 +420,81 @@ func (t *WriteTool) Parameters() map[string]any {
 }
 
 func (t *WriteTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
-	path := args["path"].(string)
-	content := args["content"].(string)
+	path, err := requiredStringArg(args, "path")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	content, err := requiredStringArg(args, "content")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	content = t.newline.apply(content)
+	data := t.encoding.encode(content)
+
+	// 写入只限 workspace（额外的只读根目录不可写）
+	file, err := resolveInWorkspace(t.workspace, path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	// 读取后被外部修改的文件不覆盖，以免丢失用户或其他进程的修改
+	if changed, how := t.tracker.Changed(file, t.overlay.ReadPath(file)); changed {
+		return &ToolResult{Success: false, Error: fmt.Sprintf(
+			"%s changed on disk since you last read or wrote it (%s), e.g. by the user, another process or a command; read it again and merge your changes before writing", path, how)}, nil
+	}
 
-	file := filepath.Join(t.workspace, path)
+	// 覆盖前读取旧内容，用于生成变更摘要
+	old, readErr := os.ReadFile(t.overlay.ReadPath(file))
+	existed := readErr == nil
+
+	// 开启 overlay 时写入影子目录
+	target, err := t.overlay.WritePath(file)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	// 会话备份（overlay 模式下不修改 workspace，无需备份）
+	if t.overlay == nil {
+		t.backup.Save(file)
+	}
 
 	// 创建目录
-	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 		return &ToolResult{Success: false, Error: err.Error()}, nil
 	}
 
 	// 写入内容
-	err := os.WriteFile(file, []byte(content), 0644)
+	err = os.WriteFile(target, data, 0644)
 	if err != nil {
 		return &ToolResult{Success: false, Error: err.Error()}, nil
 	}
+	t.overlay.MarkWritten(file)
+	t.tracker.Record(file, target)
+
+	summary := fmt.Sprintf("Successfully wrote to %s\n", file)
+	op := ChangeCreated
+	if existed {
+		summary += describeOverwrite(path, t.encoding.text(old), t.encoding.text(data))
+		op = ChangeModified
+	} else {
+		summary += fmt.Sprintf("Created new file (%d bytes)", len(data))
+	}
+
+	return &ToolResult{Success: true, Content: summary, Changes: changeOf(path, op)}, nil
+}
+
+// describeOverwrite 描述覆盖写入替换掉了什么：新旧大小，以及（足够小时）diff
+func describeOverwrite(path, oldContent, newContent string) string {
+	head := fmt.Sprintf("Replaced existing file (%d bytes -> %d bytes)", len(oldContent), len(newContent))
+	if oldContent == newContent {
+		return head + "\nContent unchanged"
+	}
 
-	return &ToolResult{Success: true, Content: fmt.Sprintf("Successfully wrote to %s", file)}, nil
+	diff, ok := unifiedDiff(oldContent, newContent, path)
+	if !ok {
+		return head + "\n(diff omitted: file too large)"
+	}
+	return head + "\n\n" + diff
 }
 
 //
@@ -255,18 +504,73 @@ func (t *WriteTool) Execute(ctx context.Context, args map[string]any) (*ToolResu
 
 type EditTool struct {
 	workspace string
+	overlay   *Overlay
+	tracker   *ReadTracker
+	backup    *SessionBackup
+}
+
+// EditOption EditTool 的可选配置
+type EditOption func(*EditTool)
+
+// WithEditOverlay 修改写入 overlay 而不是真实 workspace
+func WithEditOverlay(o *Overlay) EditOption {
+	return func(t *EditTool) {
+		t.overlay = o
+	}
 }
 
-func NewEditTool(workspace string) *EditTool {
-	return &EditTool{workspace: workspace}
+func NewEditTool(workspace string, opts ...EditOption) *EditTool {
+	t := &EditTool{workspace: workspace}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// lineNumberPrefix read_file 输出中每行开头的行号前缀（"%6d|"）
+var lineNumberPrefix = regexp.MustCompile(`^ *\d+\|`)
+
+// lineNumberHint old_str 的每个非空行都以行号前缀开头时，说明模型多半是从 read_file 的输出中连同行号一起复制的：
+// 返回提示去掉前缀（去掉后能匹配时明确说明）；否则返回空字符串。
+// 不自动去掉前缀再替换，以免误改确实以 "12|" 开头的文本
+func lineNumberHint(oldStr, content string) string {
+	lines := strings.Split(oldStr, "\n")
+	prefixed := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		loc := lineNumberPrefix.FindStringIndex(line)
+		if loc == nil {
+			return ""
+		}
+		lines[i] = line[loc[1]:]
+		prefixed++
+	}
+	if prefixed == 0 {
+		return ""
+	}
+
+	hint := "\n\nHint: old_str seems to include the line-number prefixes (\"    12|\") shown by read_file. " +
+		"They are not part of the file; copy only the text after the \"|\""
+	if strings.Contains(content, strings.Join(lines, "\n")) {
+		return hint + " (without them, old_str matches the file)."
+	}
+	return hint + "."
 }
 
 func (t *EditTool) Name() string {
 	return "edit_file"
 }
 
+// Mutates 见 Mutator
+func (t *EditTool) Mutates() bool {
+	return true
+}
+
 func (t *EditTool) Description() string {
-	return "Perform exact string replacement in a file. old_str must appear exactly once."
+	return "Perform exact string replacement in a file. old_str must appear exactly once. " +
+		"Copy old_str from the file content only: do not include the line-number prefixes (\"    12|\") that read_file adds."
 }
 
 func (t *EditTool) Parameters() map[string]any {
@@ -288,30 +592,66 @@ func (t *EditTool) Parameters() map[string]any {
 }
 
 func (t *EditTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
-	path := args["path"].(string)
-	oldStr := args["old_str"].(string)
-	newStr := args["new_str"].(string)
+	path, err := requiredStringArg(args, "path")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	oldStr, err := requiredStringArg(args, "old_str")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	newStr, err := requiredStringArg(args, "new_str")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
 
-	file := filepath.Join(t.workspace, path)
+	// 相同的替换不会改变文件，避免返回误导性的 "Successfully edited"
+	if oldStr == newStr {
+		return &ToolResult{Success: false, Error: "old_str and new_str are identical; no change made"}, nil
+	}
+
+	file, err := resolveInWorkspace(t.workspace, path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
 
-	data, err := os.ReadFile(file)
+	data, err := os.ReadFile(t.overlay.ReadPath(file))
 	if err != nil {
 		return &ToolResult{Success: false, Error: fmt.Sprintf("File not found: %s", path)}, nil
 	}
 
 	content := string(data)
+	// 替换基于最新内容，外部修改不会丢失；但模型对文件的印象已过时，提示它重新读取
+	changed, how := t.tracker.Changed(file, t.overlay.ReadPath(file))
 
 	if !strings.Contains(content, oldStr) {
-		return &ToolResult{Success: false, Error: fmt.Sprintf("Text not found: %s", oldStr)}, nil
+		return &ToolResult{Success: false, Error: fmt.Sprintf("Text not found: %s%s", oldStr, lineNumberHint(oldStr, content))}, nil
 	}
 
 	// 精确替换一个
 	updated := strings.Replace(content, oldStr, newStr, 1)
 
-	err = os.WriteFile(file, []byte(updated), 0644)
+	target, err := t.overlay.WritePath(file)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	if t.overlay == nil {
+		t.backup.Save(file)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	err = os.WriteFile(target, []byte(updated), 0644)
 	if err != nil {
 		return &ToolResult{Success: false, Error: err.Error()}, nil
 	}
+	t.overlay.MarkWritten(file)
+	t.tracker.Record(file, target)
 
-	return &ToolResult{Success: true, Content: fmt.Sprintf("Successfully edited %s", file)}, nil
+	summary := fmt.Sprintf("Successfully edited %s", file)
+	if changed {
+		summary += fmt.Sprintf("\n\nWarning: %s changed on disk since you last read or wrote it (%s), e.g. by the user, another process or a command. "+
+			"The edit was applied to the current content; read the file again before further changes.", path, how)
+	}
+	return &ToolResult{Success: true, Content: summary, Changes: changeOf(path, ChangeModified)}, nil
 }
@@ -0,0 +1,339 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// ---------------------------------------------------------
+// FormatTool（按扩展名运行格式化命令，报告被改动的文件）
+// ---------------------------------------------------------
+
+const (
+	// DefaultFormatTimeout 单次格式化的默认超时
+	DefaultFormatTimeout = time.Minute
+	// maxFormatFiles 单次最多格式化的文件数
+	maxFormatFiles = 5000
+	// formatBatchSize 每次命令调用传入的文件数，避免命令行过长
+	formatBatchSize = 200
+	// formatMaxTokens 输出的 token 上限
+	formatMaxTokens = 4000
+)
+
+// DefaultFormatters 默认的格式化命令：扩展名 → 命令与参数（文件路径追加在末尾）
+func DefaultFormatters() map[string][]string {
+	return map[string][]string{
+		".go": {"gofmt", "-w"},
+	}
+}
+
+type FormatTool struct {
+	workspace  string
+	formatters map[string][]string
+	timeout    time.Duration
+	tracker    *ReadTracker
+}
+
+// FormatOption format_code 工具的可选配置
+type FormatOption func(*FormatTool)
+
+// WithFormatters 按扩展名覆盖格式化命令（如 ".py": ["black", "-q"]），未列出的扩展名沿用默认；
+// 命令为空列表表示不格式化该类文件
+func WithFormatters(formatters map[string][]string) FormatOption {
+	return func(t *FormatTool) {
+		for ext, argv := range formatters {
+			ext = strings.ToLower(ext)
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			if len(argv) == 0 {
+				delete(t.formatters, ext)
+				continue
+			}
+			t.formatters[ext] = argv
+		}
+	}
+}
+
+// WithFormatTimeout 设置单次格式化的超时，d <= 0 时使用默认值
+func WithFormatTimeout(d time.Duration) FormatOption {
+	return func(t *FormatTool) {
+		if d > 0 {
+			t.timeout = d
+		}
+	}
+}
+
+// NewFormatTool 创建 format_code 工具；调用方可用 Available 确认至少有一个格式化命令可用
+func NewFormatTool(workspace string, opts ...FormatOption) *FormatTool {
+	t := &FormatTool{
+		workspace:  workspace,
+		formatters: DefaultFormatters(),
+		timeout:    DefaultFormatTimeout,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Available 是否至少有一个配置的格式化命令在 PATH 中
+func (t *FormatTool) Available() bool {
+	for _, argv := range t.formatters {
+		if _, err := exec.LookPath(argv[0]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *FormatTool) Name() string {
+	return "format_code"
+}
+
+// Mutates 见 Mutator：就地改写文件
+func (t *FormatTool) Mutates() bool {
+	return true
+}
+
+func (t *FormatTool) Description() string {
+	return "Format source files in the workspace with the project's formatter and report which files changed. " +
+		"Formatters by extension: " + t.describeFormatters() + ". " +
+		"Pass a file or a directory (default: the whole workspace; .gitignore/.gopilotignore are respected). " +
+		"Run it after editing code instead of invoking formatters with bash."
+}
+
+func (t *FormatTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory relative to the workspace (default: workspace root)",
+			},
+		},
+	}
+}
+
+// TimeoutHint 格式化整个工作空间可能较慢，由工具自己的超时控制
+func (t *FormatTool) TimeoutHint() time.Duration {
+	return t.timeout + 10*time.Second
+}
+
+func (t *FormatTool) describeFormatters() string {
+	exts := t.Extensions()
+	parts := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		parts = append(parts, fmt.Sprintf("%s (%s)", ext, strings.Join(t.formatters[ext], " ")))
+	}
+	if len(parts) == 0 {
+		return "none configured"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Extensions 返回配置了格式化命令的扩展名（已排序）
+func (t *FormatTool) Extensions() []string {
+	exts := make([]string, 0, len(t.formatters))
+	for ext := range t.formatters {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func (t *FormatTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, _ := args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+
+	root, err := resolveInWorkspace(t.workspace, ".")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	target, err := resolveInWorkspace(t.workspace, path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("path not found: %s", path)}, nil
+	}
+
+	byExt, capped, err := t.collect(ctx, root, target, info.IsDir())
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	if len(byExt) == 0 {
+		if !info.IsDir() {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("no formatter configured for %s (configured: %s)", path, t.describeFormatters())}, nil
+		}
+		return &ToolResult{Success: true, Content: fmt.Sprintf("No files to format in %s (formatters: %s).", path, t.describeFormatters())}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var report strings.Builder
+	var changes []ChangedFile
+	var failures []string
+	formatted := 0
+	for _, ext := range sortedExts(byExt) {
+		files := byExt[ext]
+		argv := t.formatters[ext]
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			failures = append(failures, fmt.Sprintf("skipped %d %s file(s): %s is not installed or not in PATH", len(files), ext, argv[0]))
+			continue
+		}
+
+		before := hashFiles(root, files)
+		ok := true
+		for start := 0; start < len(files); start += formatBatchSize {
+			batch := files[start:min(start+formatBatchSize, len(files))]
+			if out, err := runFormatter(ctx, root, argv, batch); err != nil {
+				msg := fmt.Sprintf("%s failed: %v", strings.Join(argv, " "), err)
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					msg = fmt.Sprintf("%s timed out after %s", strings.Join(argv, " "), t.timeout)
+				}
+				if out != "" {
+					msg += "\n" + out
+				}
+				failures = append(failures, msg)
+				ok = false
+				break
+			}
+		}
+		if ok {
+			formatted += len(files)
+		}
+		for _, f := range files {
+			if sum, ok := hashFile(filepath.Join(root, f)); ok && sum != before[f] {
+				changes = append(changes, changeOf(f, ChangeModified)...)
+				t.tracker.Record(filepath.Join(root, f), filepath.Join(root, f))
+			}
+		}
+	}
+
+	fmt.Fprintf(&report, "Formatted %d file(s) in %s", formatted, path)
+	if capped {
+		fmt.Fprintf(&report, " (stopped after %d files; narrow the path to format the rest)", maxFormatFiles)
+	}
+	if len(changes) == 0 {
+		report.WriteString("\nNo files changed.")
+	} else {
+		fmt.Fprintf(&report, "\nChanged (%d):", len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(&report, "\n  %s", c.Path)
+		}
+	}
+	if len(failures) > 0 {
+		report.WriteString("\n\n" + strings.Join(failures, "\n"))
+		return &ToolResult{Success: false, Error: TruncateTextByTokens(report.String(), formatMaxTokens), Changes: changes}, nil
+	}
+	return &ToolResult{Success: true, Content: TruncateTextByTokens(report.String(), formatMaxTokens), Changes: changes}, nil
+}
+
+// collect 按扩展名收集需要格式化的文件（路径相对 workspace 根目录，使用 "/"）
+func (t *FormatTool) collect(ctx context.Context, root, target string, isDir bool) (map[string][]string, bool, error) {
+	byExt := map[string][]string{}
+	add := func(abs string) {
+		ext := strings.ToLower(filepath.Ext(abs))
+		if _, ok := t.formatters[ext]; !ok {
+			return
+		}
+		rel, _ := filepath.Rel(root, abs)
+		byExt[ext] = append(byExt[ext], filepath.ToSlash(rel))
+	}
+	if !isDir {
+		add(target)
+		return byExt, false, nil
+	}
+
+	ignore := LoadIgnoreMatcher(root)
+	count := 0
+	capped := false
+	err := filepath.WalkDir(target, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // 无法读取的条目跳过
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(root, p)
+		if p != target && ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if count >= maxFormatFiles {
+			capped = true
+			return filepath.SkipAll
+		}
+		count++
+		add(p)
+		return nil
+	})
+	return byExt, capped, err
+}
+
+// runFormatter 在 workspace 根目录下运行格式化命令，files 追加在参数末尾
+func runFormatter(ctx context.Context, root string, argv, files []string) (string, error) {
+	cmdArgs := append([]string{}, argv[1:]...)
+	for _, f := range files {
+		// 以 "./" 开头，避免以 "-" 开头的文件名被当成选项
+		cmdArgs = append(cmdArgs, "./"+f)
+	}
+	cmd := exec.CommandContext(ctx, argv[0], cmdArgs...)
+	cmd.Dir = root
+	// 超时杀掉命令后，仍持有输出管道的子进程不应让等待无限延长
+	cmd.WaitDelay = time.Second
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return strings.TrimSpace(output.String()), err
+}
+
+func sortedExts(byExt map[string][]string) []string {
+	exts := make([]string, 0, len(byExt))
+	for ext := range byExt {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// hashFiles 记录格式化前每个文件的内容摘要
+func hashFiles(root string, files []string) map[string][sha256.Size]byte {
+	sums := make(map[string][sha256.Size]byte, len(files))
+	for _, f := range files {
+		if sum, ok := hashFile(filepath.Join(root, f)); ok {
+			sums[f] = sum
+		}
+	}
+	return sums
+}
+
+func hashFile(path string) ([sha256.Size]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(data), true
+}
@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// GitDiffTool（查看工作区未提交的改动）
+// ---------------------------------------------------------
+
+// gitDiffMaxTokens diff 输出的 token 上限，超出时保留首尾
+const gitDiffMaxTokens = 16000
+
+type GitDiffTool struct {
+	workspace string
+}
+
+// NewGitDiffTool 创建 git_diff 工具
+func NewGitDiffTool(workspace string) *GitDiffTool {
+	return &GitDiffTool{workspace: workspace}
+}
+
+func (t *GitDiffTool) Name() string {
+	return "git_diff"
+}
+
+// Mutates 见 Mutator
+func (t *GitDiffTool) Mutates() bool {
+	return false
+}
+
+func (t *GitDiffTool) Description() string {
+	return "Show uncommitted changes in the workspace git repository as a unified diff: " +
+		"staged changes (git diff --cached) and unstaged changes (git diff). " +
+		"Use it to review your own edits before finishing. Optionally limit to a path."
+}
+
+func (t *GitDiffTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Optional: file or directory (relative to the workspace) to limit the diff to",
+			},
+		},
+	}
+}
+
+func (t *GitDiffTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	var pathspec []string
+	if p, _ := args["path"].(string); strings.TrimSpace(p) != "" {
+		abs, err := resolveInWorkspace(t.workspace, p)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		pathspec = []string{"--", abs}
+	}
+
+	if _, err := runGit(ctx, t.workspace, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	base := []string{"diff", "--no-color", "--no-ext-diff"}
+	staged, err := runGit(ctx, t.workspace, append(append(base, "--cached"), pathspec...)...)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	unstaged, err := runGit(ctx, t.workspace, append(base, pathspec...)...)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	if staged == "" && unstaged == "" {
+		return &ToolResult{Success: true, Content: "No uncommitted changes."}, nil
+	}
+
+	var b strings.Builder
+	if staged != "" {
+		b.WriteString("Staged changes (git diff --cached):\n\n")
+		b.WriteString(staged)
+	}
+	if unstaged != "" {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("Unstaged changes (git diff):\n\n")
+		b.WriteString(unstaged)
+	}
+
+	return &ToolResult{
+		Success: true,
+		Content: TruncateTextByTokens(strings.TrimRight(b.String(), "\n"), gitDiffMaxTokens),
+	}, nil
+}
+
+// runGit 在 workspace 中执行 git 子命令，返回 stdout；失败时把 stderr 整理为错误信息
+func runGit(ctx context.Context, workspace string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = workspace
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("git is not installed or not in PATH")
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "not a git repository") {
+			return "", fmt.Errorf("workspace %s is not a git repository", workspace)
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+//
+// ---------------------------------------------------------
+// GitShowTool（读取文件在某个 git 版本中的内容）
+// ---------------------------------------------------------
+
+// gitShowMaxTokens 文件内容的 token 上限，超出时保留首尾
+const gitShowMaxTokens = 16000
+
+type GitShowTool struct {
+	workspace string
+}
+
+// NewGitShowTool 创建 git_show 工具
+func NewGitShowTool(workspace string) *GitShowTool {
+	return &GitShowTool{workspace: workspace}
+}
+
+func (t *GitShowTool) Name() string {
+	return "git_show"
+}
+
+// Mutates 见 Mutator
+func (t *GitShowTool) Mutates() bool {
+	return false
+}
+
+func (t *GitShowTool) Description() string {
+	return "Read a file as it exists at a git revision of the workspace repository (git show <revision>:<path>), " +
+		"e.g. to compare the current file with the committed version. revision defaults to HEAD; " +
+		"any commit, branch, tag or expression such as HEAD~2 works."
+}
+
+func (t *GitShowTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File path relative to the workspace",
+			},
+			"revision": map[string]any{
+				"type":        "string",
+				"description": "Commit, branch, tag or revision expression (default: HEAD)",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *GitShowTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, err := requiredStringArg(args, "path")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	rev, _ := args["revision"].(string)
+	rev = strings.TrimSpace(rev)
+	if rev == "" {
+		rev = "HEAD"
+	}
+	// 以 "-" 开头的版本会被 git 当作选项
+	if strings.HasPrefix(rev, "-") || strings.ContainsAny(rev, ": \t\n") {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("invalid revision %q", rev)}, nil
+	}
+
+	abs, err := resolveInWorkspace(t.workspace, path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	root, err := resolveInWorkspace(t.workspace, ".")
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("%s is not a file path", path)}, nil
+	}
+
+	if _, err := runGit(ctx, t.workspace, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	commit, err := runGit(ctx, t.workspace, "rev-parse", "--verify", "--quiet", "--short", rev+"^{commit}")
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("unknown revision %q in the workspace repository", rev)}, nil
+	}
+	commit = strings.TrimSpace(commit)
+
+	// "./" 使路径相对 workspace（workspace 可以是仓库的子目录）
+	object := rev + ":./" + filepath.ToSlash(rel)
+	kind, err := runGit(ctx, t.workspace, "cat-file", "-t", object)
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("%s does not exist at %s (%s)", path, rev, commit)}, nil
+	}
+	if kind = strings.TrimSpace(kind); kind != "blob" {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("%s is a directory at %s (%s), not a file", path, rev, commit)}, nil
+	}
+
+	content, err := runGit(ctx, t.workspace, "cat-file", "blob", object)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	header := fmt.Sprintf("%s at %s (%s):", path, rev, commit)
+	if strings.IndexByte(content, 0) >= 0 {
+		return &ToolResult{Success: true, Content: fmt.Sprintf("%s binary file, %d bytes (content not shown)", header, len(content))}, nil
+	}
+	if content == "" {
+		return &ToolResult{Success: true, Content: header + " (empty file)"}, nil
+	}
+	return &ToolResult{
+		Success: true,
+		Content: header + "\n\n" + TruncateTextByTokens(content, gitShowMaxTokens),
+	}, nil
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//
+// ---------------------------------------------------------
+// GoModTool（go mod tidy / go get / go mod download，返回 go.mod、go.sum 的变化）
+// ---------------------------------------------------------
+
+const (
+	// defaultGoModTimeout 单次模块操作的默认超时
+	defaultGoModTimeout = 3 * time.Minute
+	// maxGoModTimeout 模型可以指定的最长超时
+	maxGoModTimeout = 10 * time.Minute
+	// goModMaxTokens 输出的 token 上限
+	goModMaxTokens = 4000
+)
+
+type GoModTool struct {
+	workspace string
+}
+
+// NewGoModTool 创建 go_mod 工具；调用方应先用 GoAvailable 确认 go 命令可用
+func NewGoModTool(workspace string) *GoModTool {
+	return &GoModTool{workspace: workspace}
+}
+
+// GoAvailable go 命令是否在 PATH 中
+func GoAvailable() bool {
+	_, err := exec.LookPath("go")
+	return err == nil
+}
+
+func (t *GoModTool) Name() string {
+	return "go_mod"
+}
+
+// Mutates 见 Mutator：修改 go.mod / go.sum 并下载模块
+func (t *GoModTool) Mutates() bool {
+	return true
+}
+
+func (t *GoModTool) Description() string {
+	return "Run a Go module operation in the workspace and report the result: " +
+		"tidy (go mod tidy), get (go get <package>, e.g. \"github.com/pkg/errors@v0.9.1\" or \"golang.org/x/text@latest\"), " +
+		"or download (go mod download). Reports whether go.mod and go.sum changed, the modules added, upgraded or removed, " +
+		"and any errors. Prefer this over running these commands with bash."
+}
+
+func (t *GoModTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"tidy", "get", "download"},
+				"description": "Module operation to run",
+			},
+			"package": map[string]any{
+				"type":        "string",
+				"description": "Module or package path with an optional @version (required for get)",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory of the module (containing go.mod), relative to the workspace (default: workspace root)",
+			},
+			"timeout": map[string]any{
+				"type":        "integer",
+				"description": "Timeout in seconds (default: 180, max: 600)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// TimeoutHint 模块操作可能需要下载依赖，按最长超时声明，由工具自己的 timeout 参数控制
+func (t *GoModTool) TimeoutHint() time.Duration {
+	return maxGoModTimeout + 10*time.Second
+}
+
+func (t *GoModTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	action, _ := args["action"].(string)
+	pkg, _ := args["package"].(string)
+	pkg = strings.TrimSpace(pkg)
+
+	var goArgs []string
+	switch action {
+	case "tidy":
+		goArgs = []string{"mod", "tidy"}
+	case "download":
+		goArgs = []string{"mod", "download"}
+	case "get":
+		if pkg == "" {
+			return &ToolResult{Success: false, Error: "package is required for get"}, nil
+		}
+		if strings.HasPrefix(pkg, "-") {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("invalid package %q", pkg)}, nil
+		}
+		goArgs = []string{"get", pkg}
+	default:
+		return &ToolResult{Success: false, Error: fmt.Sprintf("unknown action %q (want tidy, get or download)", action)}, nil
+	}
+
+	dir := t.workspace
+	rel := "."
+	if p, _ := args["path"].(string); strings.TrimSpace(p) != "" {
+		abs, err := resolveInWorkspace(t.workspace, p)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		dir, rel = abs, filepath.ToSlash(filepath.Clean(p))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("no go.mod in %s", rel)}, nil
+	}
+
+	timeout := defaultGoModTimeout
+	if s := getIntArg(args, "timeout", 0); s > 0 {
+		timeout = time.Duration(min(s, int(maxGoModTimeout/time.Second))) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	before := snapshotModFiles(dir)
+
+	cmd := exec.CommandContext(ctx, "go", goArgs...)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	runErr := cmd.Run()
+
+	changes := diffModFiles(before, snapshotModFiles(dir), rel)
+	report := formatGoModResult("go "+strings.Join(goArgs, " "), output.String(), changes)
+
+	if runErr != nil {
+		var msg string
+		switch {
+		case errors.Is(runErr, exec.ErrNotFound):
+			msg = "go is not installed or not in PATH"
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			msg = fmt.Sprintf("go %s timed out after %s", strings.Join(goArgs, " "), timeout)
+		default:
+			msg = fmt.Sprintf("go %s failed: %v", strings.Join(goArgs, " "), runErr)
+		}
+		return &ToolResult{
+			Success: false,
+			Error:   TruncateTextByTokens(msg+"\n"+report, goModMaxTokens),
+			Changes: changes,
+		}, nil
+	}
+	return &ToolResult{Success: true, Content: TruncateTextByTokens(report, goModMaxTokens), Changes: changes}, nil
+}
+
+// modFiles go.mod / go.sum 的内容（不存在时为 nil）
+type modFiles map[string][]byte
+
+func snapshotModFiles(dir string) modFiles {
+	files := modFiles{}
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			files[name] = data
+		}
+	}
+	return files
+}
+
+// diffModFiles 比较前后的 go.mod / go.sum，返回变更（路径相对 workspace）
+func diffModFiles(before, after modFiles, rel string) []ChangedFile {
+	var changes []ChangedFile
+	for _, name := range []string{"go.mod", "go.sum"} {
+		old, hadOld := before[name]
+		cur, hasCur := after[name]
+		var op ChangeOp
+		switch {
+		case !hadOld && hasCur:
+			op = ChangeCreated
+		case hadOld && !hasCur:
+			op = ChangeDeleted
+		case hadOld && !bytes.Equal(old, cur):
+			op = ChangeModified
+		default:
+			continue
+		}
+		changes = append(changes, changeOf(filepath.Join(rel, name), op)...)
+	}
+	return changes
+}
+
+// formatGoModResult 汇总命令、文件变化、模块变化（go: added / upgraded / downgraded / removed）与其余输出
+func formatGoModResult(command, output string, changes []ChangedFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Ran: %s\n", command)
+
+	status := map[string]string{"go.mod": "unchanged", "go.sum": "unchanged"}
+	for _, c := range changes {
+		status[filepath.Base(c.Path)] = string(c.Op)
+	}
+	fmt.Fprintf(&b, "go.mod: %s\ngo.sum: %s\n", status["go.mod"], status["go.sum"])
+
+	var modules, other []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "go: added "), strings.HasPrefix(line, "go: upgraded "),
+			strings.HasPrefix(line, "go: downgraded "), strings.HasPrefix(line, "go: removed "):
+			modules = append(modules, strings.TrimPrefix(line, "go: "))
+		case strings.HasPrefix(line, "go: downloading "), strings.HasPrefix(line, "go: finding module "):
+			// 下载进度对模型没有用处
+		default:
+			other = append(other, line)
+		}
+	}
+	if len(modules) > 0 {
+		b.WriteString("\nModule changes:\n")
+		for _, m := range modules {
+			fmt.Fprintf(&b, "  %s\n", m)
+		}
+	}
+	if len(other) > 0 {
+		b.WriteString("\nOutput:\n")
+		b.WriteString(strings.Join(other, "\n"))
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// IgnoreMatcher（.gitignore / .gopilotignore 简易匹配）
+// ---------------------------------------------------------
+
+// ignoreFiles 会被读取的忽略文件（仅读取根目录下的文件）
+var ignoreFiles = []string{".gitignore", ".gopilotignore"}
+
+// alwaysIgnored 始终跳过的目录
+var alwaysIgnored = []string{".git"}
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // 含 "/" 的模式相对根目录匹配
+}
+
+// IgnoreMatcher 支持 gitignore 的常用子集：
+// 注释、空行、"!" 取反、结尾 "/" 仅匹配目录、含 "/" 的模式按相对路径匹配、
+// 前缀 "**/" 匹配任意层级。不支持子目录中的忽略文件。
+type IgnoreMatcher struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreMatcher 读取 root 下的忽略文件；文件不存在时返回仅含默认规则的匹配器
+func LoadIgnoreMatcher(root string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	for _, name := range alwaysIgnored {
+		m.rules = append(m.rules, ignoreRule{pattern: name, dirOnly: true})
+	}
+
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+		f.Close()
+	}
+	return m
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	line = strings.TrimPrefix(line, "**/")
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
+
+// Match 判断相对根目录的路径（使用 "/" 或系统分隔符均可）是否被忽略。
+// 后出现的规则优先，与 gitignore 语义一致。
+func (m *IgnoreMatcher) Match(rel string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		target := base
+		if r.anchored {
+			target = rel
+		}
+		if ok, _ := path.Match(r.pattern, target); ok {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// 交互式提示：bash 命令的 stdin 不连接任何输入，等待输入的命令只能等到超时
+// ---------------------------------------------------------
+
+// nonInteractiveEnv 让常见工具不再等待输入：apt / dpkg 使用默认答案，git 不弹出凭据提示，
+// 需要编辑器的 git 操作（merge、rebase --continue 等）直接采用默认提交信息
+var nonInteractiveEnv = []string{
+	"DEBIAN_FRONTEND=noninteractive",
+	"GIT_TERMINAL_PROMPT=0",
+	"GIT_EDITOR=true",
+}
+
+// commandEnv 前台 / 后台命令的环境变量；nil 表示继承当前进程环境
+func (s bashSettings) commandEnv() []string {
+	if !s.nonInteractive {
+		return nil
+	}
+	return append(os.Environ(), nonInteractiveEnv...)
+}
+
+// promptPattern 看起来在等待回答的输出结尾（如 "[Y/n]"、"Password:"、"Continue?"）
+var promptPattern = regexp.MustCompile(`(?i)(\[y/n\]|\(y/n\)|\[yes/no\]|\(yes/no\)|password|passphrase|username|` +
+	`continue\?|proceed\?|overwrite\?|[?:>]\s*$)`)
+
+// lastLine 返回输出中最后一个非空行
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimRight(output, " \t\r\n"), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// interactiveHint 超时的命令像是在等待输入时返回说明（追加在超时错误之后），否则返回空字符串：
+// 没有任何输出，或最后一行输出像是提示
+func interactiveHint(stdout, stderr string) string {
+	const advice = "stdin is not connected, so the command cannot receive an answer. " +
+		"Use a non-interactive flag (e.g. -y, --yes, --no-edit, --batch) or pipe the answer in (e.g. `yes | cmd`)."
+
+	if strings.TrimSpace(stdout) == "" && strings.TrimSpace(stderr) == "" {
+		return "; it produced no output and may be waiting for input or a terminal: " + advice
+	}
+	for _, out := range []string{stderr, stdout} {
+		if strings.TrimSpace(out) == "" {
+			continue
+		}
+		if last := lastLine(out); promptPattern.MatchString(last) {
+			return fmt.Sprintf("; it appears to be waiting for input (last output: %q): %s", last, advice)
+		}
+	}
+	return ""
+}
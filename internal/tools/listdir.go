@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// Directory Tree（目录树渲染，供 list_dir 与启动注入复用）
+// ---------------------------------------------------------
+
+// maxTreeEntries 单次渲染最多输出的条目数
+const maxTreeEntries = 500
+
+// RenderTree 以 tree 风格渲染 root 下 maxDepth 层以内的目录结构，
+// 遵循 root 下的 .gitignore / .gopilotignore。目录排在文件之前。
+func RenderTree(root string, maxDepth int) (string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", root)
+	}
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+
+	w := &treeWalker{
+		root:    root,
+		ignore:  LoadIgnoreMatcher(root),
+		limit:   maxTreeEntries,
+		builder: &strings.Builder{},
+	}
+	w.builder.WriteString(filepath.Base(root) + "/\n")
+	w.walk(root, "", 1, maxDepth)
+
+	if w.skipped > 0 {
+		fmt.Fprintf(w.builder, "... (%d more entries not shown)\n", w.skipped)
+	}
+	return strings.TrimRight(w.builder.String(), "\n"), nil
+}
+
+type treeWalker struct {
+	root    string
+	ignore  *IgnoreMatcher
+	limit   int
+	count   int
+	skipped int
+	builder *strings.Builder
+}
+
+func (w *treeWalker) walk(dir, prefix string, depth, maxDepth int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(w.builder, "%s└── [error: %v]\n", prefix, err)
+		return
+	}
+
+	visible := make([]os.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		rel, _ := filepath.Rel(w.root, filepath.Join(dir, e.Name()))
+		if w.ignore.Match(rel, e.IsDir()) {
+			continue
+		}
+		visible = append(visible, e)
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		if visible[i].IsDir() != visible[j].IsDir() {
+			return visible[i].IsDir()
+		}
+		return visible[i].Name() < visible[j].Name()
+	})
+
+	for i, e := range visible {
+		if w.count >= w.limit {
+			w.skipped += len(visible) - i
+			return
+		}
+		w.count++
+
+		last := i == len(visible)-1
+		branch, childPrefix := "├── ", prefix+"│   "
+		if last {
+			branch, childPrefix = "└── ", prefix+"    "
+		}
+
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		w.builder.WriteString(prefix + branch + name + "\n")
+
+		if e.IsDir() && depth < maxDepth {
+			w.walk(filepath.Join(dir, e.Name()), childPrefix, depth+1, maxDepth)
+		}
+	}
+}
+
+//
+// ---------------------------------------------------------
+// ListDirTool（列出目录结构）
+// ---------------------------------------------------------
+
+type ListDirTool struct {
+	workspace string
+	access    readAccess
+}
+
+// NewListDirTool 创建目录列表工具（可用 WithExtraReadRoots 允许列出 workspace 之外的目录）
+func NewListDirTool(workspace string, opts ...ReadOption) *ListDirTool {
+	return &ListDirTool{workspace: workspace, access: newReadAccess(workspace, opts)}
+}
+
+func (t *ListDirTool) Name() string {
+	return "list_dir"
+}
+
+// Mutates 见 Mutator
+func (t *ListDirTool) Mutates() bool {
+	return false
+}
+
+func (t *ListDirTool) Description() string {
+	return "List a directory as a tree (directories first), respecting .gitignore/.gopilotignore. Use depth to control recursion."
+}
+
+func (t *ListDirTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory path relative to workspace (default: workspace root)",
+			},
+			"depth": map[string]any{
+				"type":        "integer",
+				"description": "Maximum depth to descend (default: 2, max: 5)",
+			},
+		},
+	}
+}
+
+func (t *ListDirTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		path = "."
+	}
+
+	depth := getIntArg(args, "depth", 2)
+	if depth > 5 {
+		depth = 5
+	}
+
+	dir, err := t.access.resolve(path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	tree, err := RenderTree(dir, depth)
+	if err != nil {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("Cannot list directory %s: %v", path, err)}, nil
+	}
+
+	return &ToolResult{Success: true, Content: TruncateTextByTokens(tree, 32000)}, nil
+}
@@ -0,0 +1,384 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+//
+// ---------------------------------------------------------
+// MemoryStore（跨会话持久化的键值笔记）
+// ---------------------------------------------------------
+//
+// 每个工作空间一个 JSON 文件：~/.gopilot/memory/<workspace-hash>.json。
+// 同一进程内用互斥锁串行化；多个 gopilot 进程之间用 <file>.lock 锁文件互斥，
+// 写入先写临时文件再 rename，读到的始终是完整文件。
+
+const (
+	// DefaultMemoryMaxBytes 默认的记忆总大小上限（所有 key + value 的字节数）
+	DefaultMemoryMaxBytes = 8 * 1024
+	// maxMemoryKeyLen key 的最大长度
+	maxMemoryKeyLen = 100
+	// memorySummaryValueLen 注入上下文的摘要中每个 value 的最大字符数
+	memorySummaryValueLen = 200
+
+	memoryLockTimeout = 5 * time.Second
+	memoryLockStale   = 30 * time.Second
+)
+
+// MemoryEntry 一条记忆
+type MemoryEntry struct {
+	Value   string    `json:"value"`
+	Updated time.Time `json:"updated"`
+}
+
+// memoryFile 记忆文件的结构
+type memoryFile struct {
+	Workspace string                 `json:"workspace"`
+	Entries   map[string]MemoryEntry `json:"entries"`
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	path      string
+	workspace string
+	maxBytes  int
+}
+
+// MemoryOption 记忆存储的可选配置
+type MemoryOption func(*MemoryStore)
+
+// WithMemoryMaxBytes 设置记忆总大小上限，n <= 0 时使用默认值
+func WithMemoryMaxBytes(n int) MemoryOption {
+	return func(s *MemoryStore) {
+		if n > 0 {
+			s.maxBytes = n
+		}
+	}
+}
+
+// NewMemoryStore 创建工作空间的记忆存储（文件在首次写入时创建）
+func NewMemoryStore(workspace string, opts ...MemoryOption) (*MemoryStore, error) {
+	abs, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	path, err := wspath.StateFile("memory", abs, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MemoryStore{
+		path:      path,
+		workspace: abs,
+		maxBytes:  DefaultMemoryMaxBytes,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Path 返回记忆文件路径
+func (s *MemoryStore) Path() string {
+	return s.path
+}
+
+// Get 读取一条记忆
+func (s *MemoryStore) Get(key string) (MemoryEntry, bool, error) {
+	var entry MemoryEntry
+	var ok bool
+	err := s.view(func(f *memoryFile) {
+		entry, ok = f.Entries[key]
+	})
+	return entry, ok, err
+}
+
+// All 返回全部记忆
+func (s *MemoryStore) All() (map[string]MemoryEntry, error) {
+	var out map[string]MemoryEntry
+	err := s.view(func(f *memoryFile) {
+		out = f.Entries
+	})
+	return out, err
+}
+
+// Set 写入或覆盖一条记忆；超过总大小上限时拒绝
+func (s *MemoryStore) Set(key, value string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return errors.New("key is required")
+	}
+	if len(key) > maxMemoryKeyLen {
+		return fmt.Errorf("key is too long (%d bytes, max %d)", len(key), maxMemoryKeyLen)
+	}
+
+	return s.update(func(f *memoryFile) error {
+		size := len(key) + len(value)
+		for k, e := range f.Entries {
+			if k != key {
+				size += len(k) + len(e.Value)
+			}
+		}
+		if size > s.maxBytes {
+			return fmt.Errorf("memory is full (%d of %d bytes would be used); delete or shorten existing entries first", size, s.maxBytes)
+		}
+		f.Entries[key] = MemoryEntry{Value: value, Updated: time.Now().UTC()}
+		return nil
+	})
+}
+
+// Delete 删除一条记忆，返回它是否存在
+func (s *MemoryStore) Delete(key string) (bool, error) {
+	var existed bool
+	err := s.update(func(f *memoryFile) error {
+		_, existed = f.Entries[key]
+		delete(f.Entries, key)
+		return nil
+	})
+	return existed, err
+}
+
+// Summary 生成注入上下文的紧凑摘要（按 key 排序，过长的 value 截断）；没有记忆时返回空字符串
+func (s *MemoryStore) Summary() (string, error) {
+	entries, err := s.All()
+	if err != nil || len(entries) == 0 {
+		return "", err
+	}
+	var b strings.Builder
+	for _, key := range sortedKeys(entries) {
+		value := strings.Join(strings.Fields(entries[key].Value), " ")
+		if r := []rune(value); len(r) > memorySummaryValueLen {
+			value = string(r[:memorySummaryValueLen]) + "..."
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", key, value)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// view 在锁内读取记忆文件
+func (s *MemoryStore) view(fn func(*memoryFile)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	fn(f)
+	return nil
+}
+
+// update 在进程内锁与文件锁内完成读 - 改 - 写
+func (s *MemoryStore) update(fn func(*memoryFile) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("cannot create memory directory: %w", err)
+	}
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		return err
+	}
+	return s.save(f)
+}
+
+func (s *MemoryStore) load() (*memoryFile, error) {
+	f := &memoryFile{Workspace: s.workspace, Entries: map[string]MemoryEntry{}}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read memory file: %w", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("memory file %s is corrupted: %w", s.path, err)
+	}
+	if f.Entries == nil {
+		f.Entries = map[string]MemoryEntry{}
+	}
+	return f, nil
+}
+
+// save 先写临时文件再 rename，避免其他进程读到写了一半的文件
+func (s *MemoryStore) save(f *memoryFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".memory-*.tmp")
+	if err != nil {
+		return fmt.Errorf("write memory file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write memory file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write memory file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// lockFile 以独占创建锁文件的方式实现跨进程互斥；超过 memoryLockStale 的锁视为残留并清除
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(memoryLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("lock memory file: %w", err)
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > memoryLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("memory file is locked by another process (%s)", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func sortedKeys(entries map[string]MemoryEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//
+// ---------------------------------------------------------
+// MemoryTool
+// ---------------------------------------------------------
+
+type MemoryTool struct {
+	store *MemoryStore
+}
+
+// NewMemoryTool 创建 memory 工具
+func NewMemoryTool(store *MemoryStore) *MemoryTool {
+	return &MemoryTool{store: store}
+}
+
+func (t *MemoryTool) Name() string {
+	return "memory"
+}
+
+// Mutates 见 Mutator：set / delete 写入持久化的记忆
+func (t *MemoryTool) Mutates() bool {
+	return true
+}
+
+func (t *MemoryTool) Description() string {
+	return "Persistent key-value notes for this workspace that survive across sessions. " +
+		"Store durable facts worth remembering next time (decisions, conventions, build commands, user preferences), " +
+		"not temporary task state. Actions: set (key, value), get (key), delete (key), list. " +
+		"Stored notes are summarized into the context at session start."
+}
+
+func (t *MemoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"set", "get", "delete", "list"},
+				"description": "Operation to perform",
+			},
+			"key": map[string]any{
+				"type":        "string",
+				"description": "Short descriptive key, e.g. \"test-command\" (required for set, get, delete)",
+			},
+			"value": map[string]any{
+				"type":        "string",
+				"description": "Fact to store (required for set)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *MemoryTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	action, _ := args["action"].(string)
+	key, _ := args["key"].(string)
+	key = strings.TrimSpace(key)
+
+	if key == "" && (action == "set" || action == "get" || action == "delete") {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("key is required for %s", action)}, nil
+	}
+
+	switch action {
+	case "set":
+		value, ok := args["value"].(string)
+		if !ok || strings.TrimSpace(value) == "" {
+			return &ToolResult{Success: false, Error: "value is required for set"}, nil
+		}
+		if err := t.store.Set(key, value); err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		return &ToolResult{Success: true, Content: fmt.Sprintf("Remembered %q.", key)}, nil
+
+	case "get":
+		entry, ok, err := t.store.Get(key)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		if !ok {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("no memory stored under %q", key)}, nil
+		}
+		return &ToolResult{Success: true, Content: entry.Value}, nil
+
+	case "delete":
+		existed, err := t.store.Delete(key)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		if !existed {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("no memory stored under %q", key)}, nil
+		}
+		return &ToolResult{Success: true, Content: fmt.Sprintf("Forgot %q.", key)}, nil
+
+	case "list":
+		entries, err := t.store.All()
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		if len(entries) == 0 {
+			return &ToolResult{Success: true, Content: "No memories stored for this workspace."}, nil
+		}
+		var b strings.Builder
+		for _, k := range sortedKeys(entries) {
+			fmt.Fprintf(&b, "%s: %s\n", k, entries[k].Value)
+		}
+		return &ToolResult{Success: true, Content: strings.TrimSuffix(b.String(), "\n")}, nil
+	}
+
+	return &ToolResult{Success: false, Error: fmt.Sprintf("unknown action %q (want set, get, delete or list)", action)}, nil
+}
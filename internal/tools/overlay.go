@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+//
+// ---------------------------------------------------------
+// Overlay（写时复制的影子目录）
+// ---------------------------------------------------------
+//
+// 开启后 write_file、edit_file、copy_file 把修改写入影子目录而不是真实 workspace，
+// read_file 优先读取影子目录中的版本。用户检查后用 Apply 写回 workspace，或 Discard 丢弃。
+// bash、list_dir、workspace_stats 仍只看到真实 workspace。
+
+// Overlay workspace 的影子目录，并发安全；nil 表示未开启（所有方法都直接使用真实路径）
+type Overlay struct {
+	mu        sync.Mutex
+	workspace string
+	dir       string
+	changed   map[string]bool // 已写入影子目录的文件（相对 workspace，斜杠分隔）
+}
+
+// NewOverlay 在临时目录中为 workspace 创建影子目录
+func NewOverlay(workspace string) (*Overlay, error) {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	dir, err := os.MkdirTemp("", "gopilot-overlay-*")
+	if err != nil {
+		return nil, fmt.Errorf("create overlay directory: %w", err)
+	}
+	return &Overlay{workspace: root, dir: dir, changed: map[string]bool{}}, nil
+}
+
+// Dir 返回影子目录路径
+func (o *Overlay) Dir() string {
+	return o.dir
+}
+
+// rel workspace 内的绝对路径 → 相对路径；不在 workspace 内时返回 false
+func (o *Overlay) rel(real string) (string, bool) {
+	if !isWithin(o.workspace, real) {
+		return "", false
+	}
+	rel, err := filepath.Rel(o.workspace, real)
+	return filepath.ToSlash(rel), err == nil && rel != "."
+}
+
+// ReadPath 返回读取 real（workspace 内的绝对路径）时应使用的路径：影子目录中有修改版本时返回它
+func (o *Overlay) ReadPath(real string) string {
+	if o == nil {
+		return real
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if rel, ok := o.rel(real); ok && o.changed[rel] {
+		return filepath.Join(o.dir, filepath.FromSlash(rel))
+	}
+	return real
+}
+
+// WritePath 返回写入 real 时应使用的路径（影子目录中的对应位置）；
+// 写入成功后调用 MarkWritten，之后 ReadPath 才会指向影子文件
+func (o *Overlay) WritePath(real string) (string, error) {
+	if o == nil {
+		return real, nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	rel, ok := o.rel(real)
+	if !ok {
+		return "", fmt.Errorf("path %s is outside the workspace", real)
+	}
+	return filepath.Join(o.dir, filepath.FromSlash(rel)), nil
+}
+
+// MarkWritten 记录 real 的修改版本已写入影子目录（WritePath 返回的路径）
+func (o *Overlay) MarkWritten(real string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if rel, ok := o.rel(real); ok {
+		o.changed[rel] = true
+	}
+}
+
+// Changes 返回尚未写回的文件（相对 workspace，已排序）
+func (o *Overlay) Changes() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sortedChanges()
+}
+
+func (o *Overlay) sortedChanges() []string {
+	out := make([]string, 0, len(o.changed))
+	for rel := range o.changed {
+		out = append(out, rel)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Apply 把影子目录中的修改复制回 workspace（保留权限、创建父目录），成功后清空影子目录；
+// 返回写回的文件。中途失败时已写回的文件保留，其余修改仍留在影子目录中
+func (o *Overlay) Apply() ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var applied []string
+	for _, rel := range o.sortedChanges() {
+		shadow := filepath.Join(o.dir, filepath.FromSlash(rel))
+		info, err := os.Stat(shadow)
+		if errors.Is(err, fs.ErrNotExist) {
+			delete(o.changed, rel) // 影子文件已被删除（如手动清理）
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+		// 已存在的文件保留原权限（影子副本由 write/edit 新建，权限不代表原文件）
+		target := filepath.Join(o.workspace, filepath.FromSlash(rel))
+		mode := info.Mode()
+		if cur, err := os.Stat(target); err == nil {
+			mode = cur.Mode()
+		}
+		if _, err := copyFile(shadow, target, mode); err != nil {
+			return applied, fmt.Errorf("apply %s: %w", rel, err)
+		}
+		if err := os.Remove(shadow); err != nil {
+			return applied, err
+		}
+		delete(o.changed, rel)
+		applied = append(applied, rel)
+	}
+	return applied, o.reset()
+}
+
+// Discard 丢弃影子目录中的全部修改，返回被丢弃的文件
+func (o *Overlay) Discard() ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	discarded := o.sortedChanges()
+	o.changed = map[string]bool{}
+	return discarded, o.reset()
+}
+
+// Close 没有未写回的修改时删除影子目录；否则保留，以便手动取回
+func (o *Overlay) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.changed) > 0 {
+		return nil
+	}
+	return os.RemoveAll(o.dir)
+}
+
+// reset 清空影子目录内容（目录本身保留）
+func (o *Overlay) reset() error {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(o.dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveInWorkspace 将 path（绝对路径或相对 workspace 的路径）解析为绝对路径，
+// 并确认其位于 workspace 之内；已存在的路径会先解析符号链接，防止借链接逃逸。
+func resolveInWorkspace(workspace, path string) (string, error) {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target, err = evalExistingPrefix(filepath.Clean(target))
+	if err != nil {
+		return "", err
+	}
+
+	if !isWithin(root, target) {
+		return "", fmt.Errorf("path %s is outside the workspace", path)
+	}
+	return target, nil
+}
+
+// evalExistingPrefix 解析路径中已存在部分的符号链接，尚不存在的尾部原样拼回
+func evalExistingPrefix(path string) (string, error) {
+	var rest []string
+	cur := path
+	for {
+		real, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return path, nil
+		}
+		rest = append([]string{filepath.Base(cur)}, rest...)
+		cur = parent
+	}
+}
+
+// isWithin 判断 target 是否等于 root 或位于其下
+func isWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+)
+
+// ProgressFunc 接收工具执行期间的一条进度消息（如 bash 输出的一行）
+type ProgressFunc func(message string)
+
+// ProgressReporter 可选接口：长时间运行的工具在执行期间报告进度。
+// Agent 对实现了该接口的工具调用 ExecuteWithProgress，其余工具照常调用 Execute；
+// progress 可能为 nil，且可能在多个 goroutine 中被调用
+type ProgressReporter interface {
+	ExecuteWithProgress(ctx context.Context, args map[string]any, progress ProgressFunc) (*ToolResult, error)
+}
+
+// progressLines 把写入的字节按行拆分，每个完整的行作为一条进度消息发出；
+// Flush 发出最后一段没有换行符的输出。并发写入安全
+type progressLines struct {
+	mu      sync.Mutex
+	fn      ProgressFunc
+	pending []byte
+}
+
+func newProgressLines(fn ProgressFunc) *progressLines {
+	return &progressLines{fn: fn}
+}
+
+func (p *progressLines) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, b...)
+	for {
+		i := bytes.IndexByte(p.pending, '\n')
+		if i < 0 {
+			break
+		}
+		p.emit(p.pending[:i])
+		p.pending = p.pending[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush 发出尚未以换行结束的剩余输出
+func (p *progressLines) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) > 0 {
+		p.emit(p.pending)
+		p.pending = nil
+	}
+}
+
+func (p *progressLines) emit(line []byte) {
+	p.fn(strings.TrimRight(string(line), "\r"))
+}
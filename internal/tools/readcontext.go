@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// read_file 的 context_pattern 模式：只返回匹配行及其上下文
+// ---------------------------------------------------------
+
+const (
+	// defaultContextLines context_lines 的默认值
+	defaultContextLines = 3
+	// maxContextLines context_lines 的上限
+	maxContextLines = 50
+	// maxContextMatches 最多显示的匹配行数，超出部分只给出总数
+	maxContextMatches = 200
+)
+
+// lineRange 闭区间 [from, to]，下标从 0 开始
+type lineRange struct {
+	from, to int
+}
+
+// contextRanges 找出匹配 re 的行，并把每个匹配前后 n 行合并成互不重叠的区间（相邻或重叠的窗口合并）。
+// 返回区间、匹配行集合与匹配总数；只为前 maxContextMatches 个匹配生成区间
+func contextRanges(lines []string, re *regexp.Regexp, n int) ([]lineRange, map[int]bool, int) {
+	var ranges []lineRange
+	matched := map[int]bool{}
+	total := 0
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		total++
+		if total > maxContextMatches {
+			continue
+		}
+		matched[i] = true
+		r := lineRange{from: max(i-n, 0), to: min(i+n, len(lines)-1)}
+		if k := len(ranges) - 1; k >= 0 && r.from <= ranges[k].to+1 {
+			ranges[k].to = max(ranges[k].to, r.to)
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, matched, total
+}
+
+// formatContext 显示匹配区间：匹配行的行号后用 ":"，上下文行用 "|"，区间之间用省略标记分隔
+func formatContext(path, pattern string, lines []string, ranges []lineRange, matched map[int]bool, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("No lines in %s match %q (%d lines).", path, pattern, len(lines))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d matching lines for %q in %s (%d lines); matches are marked with \":\".\n", total, pattern, path, len(lines))
+	for k, r := range ranges {
+		if gap := r.from - prevEnd(ranges, k); gap > 0 {
+			fmt.Fprintf(&b, "   ...  (%d lines omitted)\n", gap)
+		}
+		for i := r.from; i <= r.to; i++ {
+			formatted, cut := wrapLine(i+1, lines[i], 1)
+			if matched[i] {
+				formatted = strings.Replace(formatted, "|", ":", 1)
+			}
+			b.WriteString(formatted)
+			if cut != nil {
+				b.WriteString(" [...]")
+			}
+			b.WriteString("\n")
+		}
+	}
+	if last := ranges[len(ranges)-1].to; last < len(lines)-1 {
+		fmt.Fprintf(&b, "   ...  (%d lines omitted)\n", len(lines)-1-last)
+	}
+	if total > maxContextMatches {
+		fmt.Fprintf(&b, "\n[Only the first %d of %d matches are shown; use a more specific pattern.]\n", maxContextMatches, total)
+	}
+	return TruncateTextByTokens(strings.TrimSuffix(b.String(), "\n"), readMaxTokens)
+}
+
+// prevEnd 第 k 个区间之前已显示到的下一行下标
+func prevEnd(ranges []lineRange, k int) int {
+	if k == 0 {
+		return 0
+	}
+	return ranges[k-1].to + 1
+}
@@ -0,0 +1,25 @@
+package tools
+
+// Mutator 可选接口：工具声明自己是否修改工作空间或系统状态（文件、进程、持久化的记忆等）。
+// 只读模式（计划阶段、--read-only）由 Agent 按该声明统一拒绝修改类工具；
+// 未实现该接口的工具视为会修改，新工具不声明也不会在只读模式下被误用
+type Mutator interface {
+	Mutates() bool
+}
+
+// IsReadOnly 判断工具是否声明为只读
+func IsReadOnly(tool Tool) bool {
+	m, ok := tool.(Mutator)
+	return ok && !m.Mutates()
+}
+
+// ReadOnly 返回只包含只读工具的新注册表（保持注册顺序）
+func (r *ToolRegistry) ReadOnly() *ToolRegistry {
+	out := NewToolRegistry()
+	for _, tool := range r.List() {
+		if IsReadOnly(tool) {
+			out.Register(tool)
+		}
+	}
+	return out
+}
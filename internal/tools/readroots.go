@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+//
+// ---------------------------------------------------------
+// 只读工具的访问范围（workspace + 额外的只读根目录）
+// ---------------------------------------------------------
+//
+// monorepo 中 workspace 可能只是一个子目录，而模型需要参考同级目录。
+// read_file、list_dir、workspace_stats 可以访问配置的额外根目录；写文件仍只限 workspace。
+
+// ReadOption 只读工具（read_file、list_dir、workspace_stats）的可选配置
+type ReadOption func(*readAccess)
+
+// WithExtraReadRoots 允许只读工具访问 workspace 之外的这些目录（相对路径基于 workspace）
+func WithExtraReadRoots(roots ...string) ReadOption {
+	return func(r *readAccess) {
+		r.extraRoots = append(r.extraRoots, roots...)
+	}
+}
+
+// WithReadOverlay read_file 优先读取 overlay 中已修改的版本
+func WithReadOverlay(o *Overlay) ReadOption {
+	return func(r *readAccess) {
+		r.overlay = o
+	}
+}
+
+// readAccess 只读工具允许访问的范围
+type readAccess struct {
+	workspace  string
+	extraRoots []string
+	overlay    *Overlay
+	tracker    *ReadTracker
+}
+
+func newReadAccess(workspace string, opts []ReadOption) readAccess {
+	r := readAccess{workspace: workspace}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	for i, root := range r.extraRoots {
+		if !filepath.IsAbs(root) {
+			r.extraRoots[i] = filepath.Join(workspace, root)
+		}
+	}
+	return r
+}
+
+// resolve 将 path（绝对路径或相对 workspace 的路径）解析为绝对路径，
+// 要求其位于 workspace 或某个额外根目录之内（同样先解析符号链接）
+func (r readAccess) resolve(path string) (string, error) {
+	target, err := resolveInWorkspace(r.workspace, path)
+	if err == nil || len(r.extraRoots) == 0 {
+		return target, err
+	}
+
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(r.workspace, abs)
+	}
+	for _, root := range r.extraRoots {
+		if target, err := resolveInWorkspace(root, abs); err == nil {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("path %s is outside the workspace and the allowed read roots", path)
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//
+// ---------------------------------------------------------
+// RunLogsTool（列出 / 读取之前运行的日志 agent_run_*.log，只能访问日志目录）
+// ---------------------------------------------------------
+
+const (
+	// defaultRunLogsLimit list 默认列出的日志数
+	defaultRunLogsLimit = 10
+	// maxRunLogsLimit list 最多列出的日志数
+	maxRunLogsLimit = 50
+	// runLogMaxTokens read 返回内容的 token 上限
+	runLogMaxTokens = 8000
+)
+
+// runLogName 运行日志的文件名（由 logger 以 agent_run_20060102_150405.log 格式创建）
+var runLogName = regexp.MustCompile(`^agent_run_\d{8}_\d{6}\.log$`)
+
+// RunLog 一个运行日志文件
+type RunLog struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ListRunLogs 按修改时间从新到旧返回 dir 中的运行日志，limit > 0 时最多返回 limit 个；目录不存在时返回空列表
+func ListRunLogs(dir string, limit int) ([]RunLog, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []RunLog
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !runLogName.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		logs = append(logs, RunLog{Name: e.Name(), Path: filepath.Join(dir, e.Name()), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if !logs[i].ModTime.Equal(logs[j].ModTime) {
+			return logs[i].ModTime.After(logs[j].ModTime)
+		}
+		return logs[i].Name > logs[j].Name
+	})
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+type RunLogsTool struct {
+	dir string
+}
+
+// NewRunLogsTool 创建 run_logs 工具，只能列出和读取 dir（运行日志目录，见 logger.Dir）中的日志
+func NewRunLogsTool(dir string) *RunLogsTool {
+	return &RunLogsTool{dir: dir}
+}
+
+func (t *RunLogsTool) Name() string {
+	return "run_logs"
+}
+
+// Mutates 见 Mutator：只读取日志
+func (t *RunLogsTool) Mutates() bool {
+	return false
+}
+
+func (t *RunLogsTool) Description() string {
+	return "Inspect the logs of earlier gopilot runs (requests, responses and tool results) to diagnose what went wrong. " +
+		"Actions: list (most recent first, with time and size), read (one log by name, long logs are truncated in the middle). " +
+		"Only files in the log directory can be read."
+}
+
+func (t *RunLogsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read"},
+				"description": "Operation to perform",
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Log file name from list, e.g. \"agent_run_20250101_120000.log\" (required for read; \"latest\" reads the newest log)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Number of logs to list (default: 10, max: 50)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *RunLogsTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		limit := min(max(getIntArg(args, "limit", defaultRunLogsLimit), 1), maxRunLogsLimit)
+		logs, err := ListRunLogs(t.dir, limit)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		if len(logs) == 0 {
+			return &ToolResult{Success: true, Content: fmt.Sprintf("No run logs in %s.", t.dir)}, nil
+		}
+		return &ToolResult{Success: true, Content: FormatRunLogs(logs)}, nil
+
+	case "read":
+		name, _ := args["name"].(string)
+		path, err := t.resolve(strings.TrimSpace(name))
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return &ToolResult{Success: false, Error: fmt.Sprintf("cannot read log: %v", err)}, nil
+		}
+		content := fmt.Sprintf("%s:\n%s", filepath.Base(path), TruncateTextByTokens(string(data), runLogMaxTokens))
+		return &ToolResult{Success: true, Content: content}, nil
+	}
+	return &ToolResult{Success: false, Error: fmt.Sprintf("unknown action %q (want list or read)", action)}, nil
+}
+
+// resolve 把日志名解析为日志目录中的文件；只接受 list 返回的文件名，拒绝任何路径
+func (t *RunLogsTool) resolve(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("name is required for read")
+	}
+	if name == "latest" {
+		logs, err := ListRunLogs(t.dir, 1)
+		if err != nil {
+			return "", err
+		}
+		if len(logs) == 0 {
+			return "", fmt.Errorf("no run logs in %s", t.dir)
+		}
+		return logs[0].Path, nil
+	}
+	if !runLogName.MatchString(name) {
+		return "", fmt.Errorf("invalid log name %q: use a name from list, e.g. agent_run_20250101_120000.log", name)
+	}
+	path := filepath.Join(t.dir, name)
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("no run log named %s", name)
+	}
+	return path, nil
+}
+
+// FormatRunLogs 每行一个日志：修改时间、大小、文件名
+func FormatRunLogs(logs []RunLog) string {
+	var b strings.Builder
+	for _, l := range logs {
+		fmt.Fprintf(&b, "%s  %10s  %s\n", l.ModTime.Format("2006-01-02 15:04:05"), humanSize(l.Size), l.Name)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//
+// ---------------------------------------------------------
+// ScratchDir（每个会话一个临时目录，会话结束时删除）
+// ---------------------------------------------------------
+//
+// 目录位于 workspace/.gopilot/tmp/<pid>-<random>，文件工具与 bash 都能直接使用；
+// 进程崩溃留下的目录在下一次创建时按 pid 判断并清理。
+
+// ScratchRoot 临时目录的父目录（相对 workspace）
+const ScratchRoot = ".gopilot/tmp"
+
+type ScratchDir struct {
+	path string
+	rel  string
+}
+
+// NewScratchDir 在 workspace 下创建本会话的临时目录，并先尽力清理已退出进程留下的目录
+func NewScratchDir(workspace string) (*ScratchDir, error) {
+	root := filepath.Join(workspace, filepath.FromSlash(ScratchRoot))
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create scratch directory: %w", err)
+	}
+	// 目录内放一个 .gitignore，避免临时文件被提交
+	ignore := filepath.Join(root, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		_ = os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+
+	if removed := CleanStaleScratch(workspace); removed > 0 {
+		slog.Info("Removed stale scratch directories", slog.Int("count", removed))
+	}
+
+	path, err := os.MkdirTemp(root, strconv.Itoa(os.Getpid())+"-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create scratch directory: %w", err)
+	}
+	return &ScratchDir{path: path, rel: ScratchRoot + "/" + filepath.Base(path)}, nil
+}
+
+// Path 临时目录的绝对路径
+func (s *ScratchDir) Path() string {
+	return s.path
+}
+
+// Rel 临时目录相对 workspace 的路径（使用 "/"）
+func (s *ScratchDir) Rel() string {
+	return s.rel
+}
+
+// Close 删除临时目录及其中的所有文件；没有其他会话的目录时一并删除 .gopilot/tmp（以及空的 .gopilot）
+func (s *ScratchDir) Close() error {
+	if err := os.RemoveAll(s.path); err != nil {
+		return fmt.Errorf("remove scratch directory: %w", err)
+	}
+	root := filepath.Dir(s.path)
+	if entries, err := os.ReadDir(root); err == nil && len(entries) <= 1 {
+		if len(entries) == 0 || entries[0].Name() == ".gitignore" {
+			// os.Remove 只删除空目录，不会误删其他会话刚创建的目录
+			_ = os.Remove(filepath.Join(root, ".gitignore"))
+			_ = os.Remove(root)
+			_ = os.Remove(filepath.Dir(root))
+		}
+	}
+	return nil
+}
+
+// CleanStaleScratch 删除 workspace 中创建进程已退出的临时目录（异常退出时残留），返回删除的数量；
+// 出错的条目跳过
+func CleanStaleScratch(workspace string) int {
+	root := filepath.Join(workspace, filepath.FromSlash(ScratchRoot))
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.SplitN(e.Name(), "-", 2)[0])
+		if err == nil && processAlive(pid) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, e.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// processAlive 进程是否仍在运行；无法确定时视为在运行，避免删掉其他会话正在使用的目录
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// Windows 上 FindProcess 只对存在的进程成功
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
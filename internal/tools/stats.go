@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// ---------------------------------------------------------
+// StatsTool（工作空间代码统计：按扩展名的文件数 / 行数 / 大小，最大的文件）
+// ---------------------------------------------------------
+
+const (
+	// maxStatsFiles 单次统计最多遍历的文件数
+	maxStatsFiles = 20000
+	// maxStatsLineBytes 超过该大小的文件只计大小，不计行数
+	maxStatsLineBytes = 5 << 20
+	// statsMaxTokens 输出的 token 上限
+	statsMaxTokens = 8000
+)
+
+type StatsTool struct {
+	workspace string
+	access    readAccess
+}
+
+// NewStatsTool 创建 workspace_stats 工具（可用 WithExtraReadRoots 允许统计 workspace 之外的目录）
+func NewStatsTool(workspace string, opts ...ReadOption) *StatsTool {
+	return &StatsTool{workspace: workspace, access: newReadAccess(workspace, opts)}
+}
+
+func (t *StatsTool) Name() string {
+	return "workspace_stats"
+}
+
+// Mutates 见 Mutator
+func (t *StatsTool) Mutates() bool {
+	return false
+}
+
+func (t *StatsTool) Description() string {
+	return "Summarize a directory of the workspace: file count, lines and size per file extension, totals, and the largest files. " +
+		"Respects .gitignore/.gopilotignore and skips binary files when counting lines. Useful to scope work in an unfamiliar repository."
+}
+
+func (t *StatsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory relative to the workspace (default: workspace root)",
+			},
+			"top": map[string]any{
+				"type":        "integer",
+				"description": "Number of largest files to list (default: 10, max: 50)",
+			},
+		},
+	}
+}
+
+// extStats 某一扩展名的汇总
+type extStats struct {
+	ext   string
+	files int
+	lines int
+	size  int64
+}
+
+// fileStats 单个文件的统计
+type fileStats struct {
+	path  string
+	lines int
+	size  int64
+}
+
+func (t *StatsTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, _ := args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+	top := min(max(getIntArg(args, "top", 10), 1), 50)
+
+	root, err := t.access.resolve(path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return &ToolResult{Success: false, Error: fmt.Sprintf("not a directory: %s", path)}, nil
+	}
+
+	ignore := LoadIgnoreMatcher(t.workspace)
+	byExt := map[string]*extStats{}
+	var files []fileStats
+	var totalLines, binary int
+	var totalSize int64
+	capped := false
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // 无法读取的条目跳过
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, _ := filepath.Rel(t.workspace, p)
+		if p != root && ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if len(files) >= maxStatsFiles {
+			capped = true
+			return filepath.SkipAll
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		lines, isText := countLines(p, info.Size())
+		if !isText {
+			binary++
+		}
+
+		ext := strings.ToLower(filepath.Ext(d.Name()))
+		if ext == "" {
+			ext = "(none)"
+		}
+		s := byExt[ext]
+		if s == nil {
+			s = &extStats{ext: ext}
+			byExt[ext] = s
+		}
+		s.files++
+		s.lines += lines
+		s.size += info.Size()
+
+		relRoot, _ := filepath.Rel(root, p)
+		files = append(files, fileStats{path: filepath.ToSlash(relRoot), lines: lines, size: info.Size()})
+		totalLines += lines
+		totalSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+
+	return &ToolResult{
+		Success: true,
+		Content: TruncateTextByTokens(formatStats(path, byExt, files, totalLines, totalSize, binary, top, capped), statsMaxTokens),
+	}, nil
+}
+
+// countLines 统计文本文件的行数；二进制文件（前 8KB 含 NUL）或过大的文件返回 0，
+// 第二个返回值表示是否按文本处理
+func countLines(path string, size int64) (int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	head := make([]byte, 8192)
+	n, _ := io.ReadFull(f, head)
+	if bytes.IndexByte(head[:n], 0) >= 0 {
+		return 0, false
+	}
+	if size > maxStatsLineBytes || n == 0 {
+		return 0, true
+	}
+
+	lines := bytes.Count(head[:n], []byte{'\n'})
+	last := head[n-1]
+	buf := make([]byte, 32*1024)
+	for {
+		m, err := f.Read(buf)
+		if m > 0 {
+			lines += bytes.Count(buf[:m], []byte{'\n'})
+			last = buf[m-1]
+		}
+		if err != nil {
+			break
+		}
+	}
+	if last != '\n' {
+		lines++ // 最后一行没有换行符
+	}
+	return lines, true
+}
+
+func formatStats(path string, byExt map[string]*extStats, files []fileStats, totalLines int, totalSize int64, binary, top int, capped bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Stats for %s\n", path)
+	fmt.Fprintf(&b, "Files: %d (binary: %d)  Lines: %d  Size: %s\n", len(files), binary, totalLines, humanSize(totalSize))
+	if capped {
+		fmt.Fprintf(&b, "[Stopped after %d files; narrow the path for complete numbers.]\n", maxStatsFiles)
+	}
+	if len(files) == 0 {
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+
+	exts := make([]*extStats, 0, len(byExt))
+	for _, s := range byExt {
+		exts = append(exts, s)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if exts[i].lines != exts[j].lines {
+			return exts[i].lines > exts[j].lines
+		}
+		if exts[i].files != exts[j].files {
+			return exts[i].files > exts[j].files
+		}
+		return exts[i].ext < exts[j].ext
+	})
+	b.WriteString("\nBy extension (files / lines / size):\n")
+	for _, s := range exts {
+		fmt.Fprintf(&b, "  %-10s %6d %9d %10s\n", s.ext, s.files, s.lines, humanSize(s.size))
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].size != files[j].size {
+			return files[i].size > files[j].size
+		}
+		return files[i].path < files[j].path
+	})
+	fmt.Fprintf(&b, "\nLargest files (size / lines):\n")
+	for _, f := range files[:min(top, len(files))] {
+		fmt.Fprintf(&b, "  %10s %9d  %s\n", humanSize(f.size), f.lines, f.path)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// humanSize 以 B / KB / MB / GB 显示字节数
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
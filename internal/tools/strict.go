@@ -0,0 +1,122 @@
+package tools
+
+import "sort"
+
+// StrictSchema 返回 JSON Schema 的严格模式副本（OpenAI strict function calling 要求）：
+//   - 每个 object 都设置 additionalProperties: false；
+//   - required 包含全部属性，原本可选的属性类型改为可为 null（模型传 null 表示未提供）；
+//   - 递归处理嵌套的 properties 与 items。
+//
+// 不修改传入的 schema。
+func StrictSchema(schema map[string]any) map[string]any {
+	return strictNode(schema)
+}
+
+func strictNode(node map[string]any) map[string]any {
+	out := make(map[string]any, len(node)+2)
+	for k, v := range node {
+		out[k] = v
+	}
+
+	if items, ok := node["items"].(map[string]any); ok {
+		out["items"] = strictNode(items)
+	}
+
+	props, hasProps := node["properties"].(map[string]any)
+	if node["type"] != "object" && !hasProps {
+		return out
+	}
+
+	required := map[string]bool{}
+	for _, name := range requiredNames(node["required"]) {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(props))
+	newProps := make(map[string]any, len(props))
+	for name, p := range props {
+		names = append(names, name)
+		prop, ok := p.(map[string]any)
+		if !ok {
+			newProps[name] = p
+			continue
+		}
+		prop = strictNode(prop)
+		if !required[name] {
+			prop = nullable(prop)
+		}
+		newProps[name] = prop
+	}
+	sort.Strings(names)
+
+	// 原有 required 顺序在前，其余按名称排序追加
+	all := requiredNames(node["required"])
+	for _, name := range names {
+		if !required[name] {
+			all = append(all, name)
+		}
+	}
+
+	out["type"] = "object"
+	out["properties"] = newProps
+	out["required"] = all
+	out["additionalProperties"] = false
+	return out
+}
+
+// requiredNames 兼容 []string 与 []any 两种 required 写法
+func requiredNames(v any) []string {
+	switch r := v.(type) {
+	case []string:
+		return append([]string(nil), r...)
+	case []any:
+		names := make([]string, 0, len(r))
+		for _, x := range r {
+			if s, ok := x.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// nullable 让属性接受 null；enum 中同时补上 nil
+func nullable(prop map[string]any) map[string]any {
+	switch t := prop["type"].(type) {
+	case string:
+		if t != "null" {
+			prop["type"] = []any{t, "null"}
+		}
+	case []any:
+		for _, x := range t {
+			if x == "null" {
+				return prop
+			}
+		}
+		prop["type"] = append(append([]any(nil), t...), "null")
+	case []string:
+		types := make([]any, 0, len(t)+1)
+		hasNull := false
+		for _, x := range t {
+			types = append(types, x)
+			hasNull = hasNull || x == "null"
+		}
+		if !hasNull {
+			types = append(types, "null")
+		}
+		prop["type"] = types
+	}
+
+	switch e := prop["enum"].(type) {
+	case []string:
+		vals := make([]any, 0, len(e)+1)
+		for _, x := range e {
+			vals = append(vals, x)
+		}
+		prop["enum"] = append(vals, nil)
+	case []any:
+		prop["enum"] = append(append([]any(nil), e...), nil)
+	}
+	return prop
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+//
+// ---------------------------------------------------------
+// ReadTracker（记录模型读取文件时的大小与修改时间，发现之后被外部修改）
+// ---------------------------------------------------------
+//
+// 会话期间用户或其他进程可能修改模型已经读过的文件，模型对文件内容的印象随之过时。
+// read_file 读取、write_file / edit_file / copy_file / format_code 写入后记录文件状态；之后写入前再比较：
+// write_file 拒绝覆盖被外部修改的文件（否则外部修改会丢失），edit_file 照常在最新内容上替换，
+// 但在结果中提示文件已变化、应重新读取确认。
+
+// fileStamp 记录时的文件状态
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// ReadTracker 按绝对路径记录文件状态，并发安全；nil 表示未开启（所有方法都不做任何事）
+type ReadTracker struct {
+	mu    sync.Mutex
+	files map[string]fileStamp
+}
+
+// NewReadTracker 创建文件状态记录器，供 read_file、write_file、edit_file 共享
+func NewReadTracker() *ReadTracker {
+	return &ReadTracker{files: make(map[string]fileStamp)}
+}
+
+// Record 以 path 在磁盘上的当前状态作为比较基准（file 为工具看到的逻辑路径，path 为实际读写的路径，
+// overlay 模式下二者不同）；文件不存在时不再跟踪
+func (t *ReadTracker) Record(file, path string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(t.files, file)
+		return
+	}
+	t.files[file] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// Changed 返回 file 自上次记录以来是否在外部被修改（大小或修改时间不同）及说明；
+// 未记录过或已被删除的文件返回 false
+func (t *ReadTracker) Changed(file, path string) (bool, string) {
+	if t == nil {
+		return false, ""
+	}
+	t.mu.Lock()
+	stamp, ok := t.files[file]
+	t.mu.Unlock()
+	if !ok {
+		return false, ""
+	}
+	info, err := os.Stat(path)
+	if err != nil || (info.Size() == stamp.size && info.ModTime().Equal(stamp.modTime)) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("modified at %s, %d -> %d bytes",
+		info.ModTime().Format("15:04:05"), stamp.size, info.Size())
+}
+
+// WithReadTracker read_file 读取后记录文件状态
+func WithReadTracker(t *ReadTracker) ReadOption {
+	return func(r *readAccess) {
+		r.tracker = t
+	}
+}
+
+// WithWriteTracker write_file 拒绝覆盖读取后被外部修改的文件，并在写入后更新记录
+func WithWriteTracker(t *ReadTracker) WriteOption {
+	return func(w *WriteTool) {
+		w.tracker = t
+	}
+}
+
+// WithEditTracker edit_file 修改读取后被外部修改的文件时在结果中提示，并在写入后更新记录
+func WithEditTracker(t *ReadTracker) EditOption {
+	return func(e *EditTool) {
+		e.tracker = t
+	}
+}
+
+// WithCopyTracker copy_file 写入副本后更新记录，之后的 write_file 不会把它当作外部修改
+func WithCopyTracker(t *ReadTracker) CopyOption {
+	return func(c *CopyFileTool) {
+		c.tracker = t
+	}
+}
+
+// WithFormatTracker format_code 改写文件后更新记录，之后的 write_file 不会把它当作外部修改
+func WithFormatTracker(t *ReadTracker) FormatOption {
+	return func(f *FormatTool) {
+		f.tracker = t
+	}
+}
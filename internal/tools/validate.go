@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// toolNameRe 服务端接受的函数名：字母、数字、下划线与连字符，最长 64 个字符
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// jsonSchemaTypes JSON Schema 的基本类型
+var jsonSchemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+// ValidateTool 检查工具名与 Parameters() 的结构：顶层必须是 type 为 object 的 JSON Schema，
+// properties 中每个属性都是对象且类型合法，required 只引用已声明的属性。
+// 启动时调用，避免格式错误的 schema 在请求时被服务端以难以理解的方式拒绝
+func ValidateTool(tool Tool) error {
+	name := tool.Name()
+	if !toolNameRe.MatchString(name) {
+		return fmt.Errorf("invalid tool name %q (want 1-64 letters, digits, '_' or '-')", name)
+	}
+	params := tool.Parameters()
+	if params == nil {
+		return fmt.Errorf("tool %q: parameters schema is nil", name)
+	}
+	if _, err := json.Marshal(params); err != nil {
+		return fmt.Errorf("tool %q: parameters schema is not valid JSON: %w", name, err)
+	}
+	if params["type"] != "object" {
+		return fmt.Errorf("tool %q: parameters schema must have \"type\": \"object\" at the top level (got %v)", name, params["type"])
+	}
+	if err := validateSchemaNode(params, "parameters"); err != nil {
+		return fmt.Errorf("tool %q: %w", name, err)
+	}
+	return nil
+}
+
+// validateSchemaNode 递归检查 schema 节点；path 用于在错误中指出位置
+func validateSchemaNode(node map[string]any, path string) error {
+	if t, ok := node["type"]; ok {
+		if err := validateSchemaType(t, path); err != nil {
+			return err
+		}
+	}
+
+	var props map[string]any
+	if p, ok := node["properties"]; ok {
+		if props, ok = p.(map[string]any); !ok {
+			return fmt.Errorf("%s.properties must be an object, got %T", path, p)
+		}
+		for name, v := range props {
+			prop, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s.properties.%s must be an object, got %T", path, name, v)
+			}
+			if err := validateSchemaNode(prop, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r, ok := node["required"]; ok {
+		switch r.(type) {
+		case []string, []any:
+		default:
+			return fmt.Errorf("%s.required must be an array of strings, got %T", path, r)
+		}
+		if list, ok := r.([]any); ok {
+			for _, x := range list {
+				if _, ok := x.(string); !ok {
+					return fmt.Errorf("%s.required must be an array of strings, found %v", path, x)
+				}
+			}
+		}
+		for _, name := range requiredNames(r) {
+			if _, ok := props[name]; !ok {
+				return fmt.Errorf("%s.required lists %q, which is not in properties", path, name)
+			}
+		}
+	}
+
+	if items, ok := node["items"]; ok {
+		m, ok := items.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s.items must be an object, got %T", path, items)
+		}
+		return validateSchemaNode(m, path+".items")
+	}
+	return nil
+}
+
+// validateSchemaType type 可以是单个类型名或类型名数组
+func validateSchemaType(t any, path string) error {
+	var names []any
+	switch v := t.(type) {
+	case string:
+		names = []any{v}
+	case []string:
+		for _, s := range v {
+			names = append(names, s)
+		}
+	case []any:
+		names = v
+	default:
+		return fmt.Errorf("%s.type must be a string or an array of strings, got %T", path, t)
+	}
+	for _, n := range names {
+		s, ok := n.(string)
+		if !ok || !jsonSchemaTypes[s] {
+			return fmt.Errorf("%s.type has unknown type %v", path, n)
+		}
+	}
+	return nil
+}
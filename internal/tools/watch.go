@@ -0,0 +1,433 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+//
+// ---------------------------------------------------------
+// FileWatcher（基于 fsnotify 的文件变化监听，供 watch_file / poll_file_changes 共享）
+// ---------------------------------------------------------
+
+// maxWatchedFiles 同时监听的文件数上限
+const maxWatchedFiles = 20
+
+// maxWatchSnapshotBytes 超过该大小的文件不保存内容快照，只报告是否变化
+const maxWatchSnapshotBytes = 1 << 20
+
+// watchedFile 单个被监听文件的状态
+type watchedFile struct {
+	rel      string // 相对 workspace 的显示路径
+	exists   bool
+	size     int64
+	modTime  time.Time
+	snapshot string // 上次检查时的内容（文件过大时为空）
+	tooLarge bool
+	dirty    bool // 自上次检查以来收到过 fsnotify 事件
+}
+
+// FileChange 一次检查得到的文件变化
+type FileChange struct {
+	Path   string
+	Status string // unchanged / modified / created / deleted
+	Diff   string // modified 时的 unified diff，或 created 时的新内容
+}
+
+// FileWatcher 监听 workspace 内的文件。为兼容“写临时文件再重命名”的编辑器，
+// 实际监听的是文件所在目录，再按文件路径过滤事件。
+type FileWatcher struct {
+	workspace string
+	maxFiles  int
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	files   map[string]*watchedFile // 绝对路径 -> 状态
+	dirs    map[string]int          // 已监听目录 -> 引用计数
+	closed  bool
+}
+
+// NewFileWatcher 创建文件监听器（fsnotify 在首次 Watch 时才初始化）
+func NewFileWatcher(workspace string) *FileWatcher {
+	return &FileWatcher{
+		workspace: workspace,
+		maxFiles:  maxWatchedFiles,
+		files:     make(map[string]*watchedFile),
+		dirs:      make(map[string]int),
+	}
+}
+
+// Watch 开始监听 path，并记录当前内容作为比较基准；返回相对 workspace 的路径
+func (w *FileWatcher) Watch(path string) (string, error) {
+	abs, err := resolveInWorkspace(w.workspace, path)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return "", fmt.Errorf("file watcher is closed")
+	}
+	if f, ok := w.files[abs]; ok {
+		return f.rel, nil
+	}
+	if len(w.files) >= w.maxFiles {
+		return "", fmt.Errorf("too many watched files (max %d); unwatch some first", w.maxFiles)
+	}
+	if err := w.ensureStarted(); err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(abs)
+	if w.dirs[dir] == 0 {
+		if err := w.watcher.Add(dir); err != nil {
+			return "", fmt.Errorf("cannot watch %s: %w", path, err)
+		}
+	}
+	w.dirs[dir]++
+
+	f := &watchedFile{rel: w.relPath(abs)}
+	f.refresh(abs)
+	w.files[abs] = f
+	return f.rel, nil
+}
+
+// Unwatch 停止监听 path
+func (w *FileWatcher) Unwatch(path string) error {
+	abs, err := resolveInWorkspace(w.workspace, path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.files[abs]; !ok {
+		return fmt.Errorf("not watching %s", path)
+	}
+	delete(w.files, abs)
+
+	dir := filepath.Dir(abs)
+	w.dirs[dir]--
+	if w.dirs[dir] <= 0 {
+		delete(w.dirs, dir)
+		if w.watcher != nil {
+			_ = w.watcher.Remove(dir)
+		}
+	}
+	return nil
+}
+
+// Poll 返回自上次检查以来的变化（path 为空时检查全部），并把当前内容设为新的比较基准
+func (w *FileWatcher) Poll(path string) ([]FileChange, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var targets []string
+	if path != "" {
+		abs, err := resolveInWorkspace(w.workspace, path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := w.files[abs]; !ok {
+			return nil, fmt.Errorf("not watching %s (call watch_file first)", path)
+		}
+		targets = []string{abs}
+	} else {
+		for abs := range w.files {
+			targets = append(targets, abs)
+		}
+		sort.Strings(targets)
+	}
+
+	changes := make([]FileChange, 0, len(targets))
+	for _, abs := range targets {
+		changes = append(changes, w.files[abs].check(abs))
+	}
+	return changes, nil
+}
+
+// Close 停止所有监听，可重复调用
+func (w *FileWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.files = map[string]*watchedFile{}
+	w.dirs = map[string]int{}
+	if w.watcher != nil {
+		return w.watcher.Close()
+	}
+	return nil
+}
+
+// ensureStarted 初始化 fsnotify 并启动事件循环（调用方持有锁）
+func (w *FileWatcher) ensureStarted() error {
+	if w.watcher != nil {
+		return nil
+	}
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot start file watcher: %w", err)
+	}
+	w.watcher = fw
+	go w.loop(fw)
+	return nil
+}
+
+func (w *FileWatcher) loop(fw *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			w.mu.Lock()
+			if f, ok := w.files[filepath.Clean(ev.Name)]; ok {
+				f.dirty = true
+			}
+			w.mu.Unlock()
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("File watcher error", slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (w *FileWatcher) relPath(abs string) string {
+	root, _ := filepath.Abs(w.workspace)
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
+// refresh 以磁盘上的当前状态作为新的比较基准
+func (f *watchedFile) refresh(abs string) {
+	f.dirty = false
+	f.snapshot = ""
+	f.tooLarge = false
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		f.exists = false
+		return
+	}
+	f.exists = true
+	f.size = info.Size()
+	f.modTime = info.ModTime()
+	if info.Size() > maxWatchSnapshotBytes {
+		f.tooLarge = true
+		return
+	}
+	if data, err := os.ReadFile(abs); err == nil {
+		f.snapshot = string(data)
+	}
+}
+
+// check 对比当前状态与基准，得到变化并刷新基准。
+// 除 fsnotify 事件外也比较大小和修改时间，避免网络文件系统等漏报事件。
+func (f *watchedFile) check(abs string) FileChange {
+	info, statErr := os.Stat(abs)
+	exists := statErr == nil
+	statChanged := exists != f.exists ||
+		(exists && (info.Size() != f.size || !info.ModTime().Equal(f.modTime)))
+
+	if !f.dirty && !statChanged {
+		return FileChange{Path: f.rel, Status: "unchanged"}
+	}
+
+	oldExists, oldSnapshot, oldTooLarge := f.exists, f.snapshot, f.tooLarge
+	f.refresh(abs)
+
+	switch {
+	case !oldExists && !f.exists:
+		return FileChange{Path: f.rel, Status: "unchanged"}
+	case oldExists && !f.exists:
+		return FileChange{Path: f.rel, Status: "deleted"}
+	case !oldExists:
+		return FileChange{Path: f.rel, Status: "created", Diff: f.snapshot}
+	}
+
+	if oldTooLarge || f.tooLarge {
+		return FileChange{Path: f.rel, Status: "modified", Diff: "(diff omitted: file too large)"}
+	}
+	if oldSnapshot == f.snapshot {
+		return FileChange{Path: f.rel, Status: "unchanged"}
+	}
+	diff, ok := unifiedDiff(oldSnapshot, f.snapshot, f.rel)
+	if !ok {
+		diff = "(diff too large; current content)\n" + f.snapshot
+	}
+	return FileChange{Path: f.rel, Status: "modified", Diff: diff}
+}
+
+//
+// ---------------------------------------------------------
+// WatchFileTool（注册 / 取消监听）
+// ---------------------------------------------------------
+
+type WatchFileTool struct {
+	watcher *FileWatcher
+}
+
+// NewWatchFileTool 创建文件监听工具，与 PollFileChangesTool 共享同一个 FileWatcher
+func NewWatchFileTool(watcher *FileWatcher) *WatchFileTool {
+	return &WatchFileTool{watcher: watcher}
+}
+
+func (t *WatchFileTool) Name() string {
+	return "watch_file"
+}
+
+// Mutates 见 Mutator：只记录监听状态，不修改文件
+func (t *WatchFileTool) Mutates() bool {
+	return false
+}
+
+func (t *WatchFileTool) Description() string {
+	return fmt.Sprintf(`Start (or stop) watching a workspace file for changes, e.g. a test log or build output edited outside the agent.
+
+Use poll_file_changes afterwards to see whether it changed since the last check, with a diff.
+The file itself may not exist yet, but its directory must. At most %d files can be watched at once.`, maxWatchedFiles)
+}
+
+func (t *WatchFileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File path (absolute or relative to workspace; must be inside the workspace)",
+			},
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"watch", "unwatch"},
+				"description": "watch (default) or unwatch",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *WatchFileTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, _ := args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		return &ToolResult{Success: false, Error: "path is required"}, nil
+	}
+	action, _ := args["action"].(string)
+
+	switch action {
+	case "", "watch":
+		rel, err := t.watcher.Watch(path)
+		if err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		return &ToolResult{Success: true, Content: fmt.Sprintf("Watching %s. Use poll_file_changes to check for changes.", rel)}, nil
+	case "unwatch":
+		if err := t.watcher.Unwatch(path); err != nil {
+			return &ToolResult{Success: false, Error: err.Error()}, nil
+		}
+		return &ToolResult{Success: true, Content: fmt.Sprintf("Stopped watching %s", path)}, nil
+	default:
+		return &ToolResult{Success: false, Error: fmt.Sprintf("unknown action %q (want watch or unwatch)", action)}, nil
+	}
+}
+
+// Close 释放底层 fsnotify 资源（Agent 关闭时调用）
+func (t *WatchFileTool) Close() error {
+	return t.watcher.Close()
+}
+
+//
+// ---------------------------------------------------------
+// PollFileChangesTool（检查被监听文件的变化）
+// ---------------------------------------------------------
+
+type PollFileChangesTool struct {
+	watcher *FileWatcher
+}
+
+// NewPollFileChangesTool 创建文件变化检查工具
+func NewPollFileChangesTool(watcher *FileWatcher) *PollFileChangesTool {
+	return &PollFileChangesTool{watcher: watcher}
+}
+
+func (t *PollFileChangesTool) Name() string {
+	return "poll_file_changes"
+}
+
+// Mutates 见 Mutator
+func (t *PollFileChangesTool) Mutates() bool {
+	return false
+}
+
+func (t *PollFileChangesTool) Description() string {
+	return "Check files registered with watch_file for changes since the last check. Returns a diff for modified files and the content of newly created ones."
+}
+
+func (t *PollFileChangesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Optional: only check this watched file (default: all watched files)",
+			},
+		},
+	}
+}
+
+func (t *PollFileChangesTool) Execute(ctx context.Context, args map[string]any) (*ToolResult, error) {
+	path, _ := args["path"].(string)
+
+	changes, err := t.watcher.Poll(path)
+	if err != nil {
+		return &ToolResult{Success: false, Error: err.Error()}, nil
+	}
+	if len(changes) == 0 {
+		return &ToolResult{Success: true, Content: "No files are being watched. Use watch_file first."}, nil
+	}
+
+	var sb strings.Builder
+	changed := 0
+	for _, c := range changes {
+		if c.Status == "unchanged" {
+			continue
+		}
+		changed++
+		fmt.Fprintf(&sb, "%s: %s\n", c.Path, c.Status)
+		if c.Diff != "" {
+			sb.WriteString(c.Diff)
+			if !strings.HasSuffix(c.Diff, "\n") {
+				sb.WriteString("\n")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if changed == 0 {
+		return &ToolResult{Success: true, Content: fmt.Sprintf("No changes in %d watched file(s)", len(changes))}, nil
+	}
+
+	return &ToolResult{Success: true, Content: TruncateTextByTokens(strings.TrimRight(sb.String(), "\n"), 32000)}, nil
+}
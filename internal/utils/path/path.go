@@ -1,11 +1,146 @@
 package path
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"unicode"
 )
 
 func ProjectRoot() string {
 	wd, _ := os.Getwd()
 	return filepath.Join(wd, "..")
 }
+
+// Expand 展开路径中的环境变量（$VAR / ${VAR}）和开头的 ~（当前用户主目录）
+func Expand(p string) (string, error) {
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~: %w", err)
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	return p, nil
+}
+
+// ResolveWorkspace 按 命令行参数 > 配置 > 当前目录 的优先级确定工作空间，返回绝对路径。
+// 配置中的路径会展开 ~ 与环境变量，相对路径以当前目录为基准
+func ResolveWorkspace(flagValue, configured string) (string, error) {
+	dir := flagValue
+	if dir == "" && strings.TrimSpace(configured) != "" {
+		expanded, err := Expand(strings.TrimSpace(configured))
+		if err != nil {
+			return "", err
+		}
+		dir = expanded
+	}
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current directory: %w", err)
+		}
+		return wd, nil
+	}
+	return filepath.Abs(dir)
+}
+
+// CheckWritable 通过创建并删除一个临时文件检查目录是否可写（只读挂载、权限不足等情况返回错误）
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".gopilot-write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	return nil
+}
+
+// NormalizeWorkspace 返回工作空间路径的规范形式，等价的写法（相对路径、末尾斜杠、"."/".."、符号链接）
+// 得到相同结果；所在文件系统不区分大小写时（macOS / Windows 的默认设置）统一转为小写
+func NormalizeWorkspace(workspace string) string {
+	abs, err := filepath.Abs(workspace)
+	if err != nil {
+		abs = workspace
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	abs = filepath.Clean(abs)
+	if caseInsensitive(abs) {
+		abs = strings.ToLower(abs)
+	}
+	return abs
+}
+
+// caseInsensitive 判断 p 所在文件系统是否不区分大小写：把路径中最后一个含字母的部分换成大小写翻转的名字，
+// 若仍指向同一个文件即不区分大小写。路径不存在时按平台默认值判断
+func caseInsensitive(p string) bool {
+	for dir := p; ; {
+		base := filepath.Base(dir)
+		if flipped := flipCase(base); flipped != base {
+			orig, err1 := os.Stat(dir)
+			alt, err2 := os.Stat(filepath.Join(filepath.Dir(dir), flipped))
+			if err1 == nil {
+				return err2 == nil && os.SameFile(orig, alt)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+		}
+		dir = parent
+	}
+}
+
+func flipCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return unicode.ToLower(r)
+		case unicode.IsLower(r):
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
+
+// WorkspaceHash 返回工作空间的稳定标识：规范化路径（见 NormalizeWorkspace）的 SHA-256 前 16 位十六进制，
+// 用于 ~/.gopilot 下按工作空间区分的数据文件名
+func WorkspaceHash(workspace string) string {
+	sum := sha256.Sum256([]byte(NormalizeWorkspace(workspace)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+var slugUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// WorkspaceSlug 返回便于辨认的工作空间标识 "<目录名>-<hash>"（如 "gopilot-cli-3f2a9c1d0b7e4a58"），
+// 目录名只保留小写字母与数字；唯一性由 hash 保证
+func WorkspaceSlug(workspace string) string {
+	name := strings.Trim(slugUnsafe.ReplaceAllString(strings.ToLower(filepath.Base(NormalizeWorkspace(workspace))), "-"), "-")
+	if r := []rune(name); len(r) > 32 {
+		name = strings.TrimRight(string(r[:32]), "-")
+	}
+	if name == "" {
+		name = "workspace"
+	}
+	return name + "-" + WorkspaceHash(workspace)
+}
+
+// StateFile 返回工作空间在 ~/.gopilot/<kind>/ 下的状态文件路径 "<hash><ext>"（不创建文件）
+func StateFile(kind, workspace, ext string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".gopilot", kind, WorkspaceHash(workspace)+ext), nil
+}
@@ -0,0 +1,66 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Spinner 在一行内循环显示动画与已耗时（如 "⠋ Thinking... (12s)"），用于等待阻塞调用期间提示仍在运行
+type Spinner struct {
+	w        io.Writer
+	label    string
+	interval time.Duration
+	start    time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartSpinner 启动 spinner 并立即绘制第一帧；interval <= 0 时使用 100ms。
+// 调用方必须调用 Stop，Stop 返回后 spinner 不会再写入 w
+func StartSpinner(w io.Writer, label string, interval time.Duration) *Spinner {
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	s := &Spinner{
+		w:        w,
+		label:    label,
+		interval: interval,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Spinner) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		elapsed := time.Since(s.start).Truncate(time.Second)
+		fmt.Fprintf(s.w, "\r%s %s (%s)\033[K", spinnerFrames[frame%len(spinnerFrames)], s.label, elapsed)
+		select {
+		case <-s.stop:
+			fmt.Fprint(s.w, "\r\033[K") // 清除 spinner 所在行
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止 spinner、清除其所在行，并等待后台 goroutine 退出；可重复调用，nil 安全
+func (s *Spinner) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// panicTool 执行时直接 panic（模拟 nil map 写入之类的缺陷）
+type panicTool struct{}
+
+func (panicTool) Name() string               { return "boom" }
+func (panicTool) Description() string        { return "Always panics" }
+func (panicTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (panicTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	var m map[string]int
+	m["x"] = 1
+	return nil, nil
+}
+
+func TestAgentSurvivesToolPanic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "boom", Args: `{}`}),
+		textReply("recovered"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{panicTool{}}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "recovered", out)
+
+	var toolMsg string
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			toolMsg = msg.Content
+		}
+	}
+	require.True(t, strings.HasPrefix(toolMsg, "Error: tool boom panicked:"), toolMsg)
+	require.Contains(t, toolMsg, "nil map")
+}
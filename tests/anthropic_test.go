@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+// anthropicClient 创建使用 Anthropic Messages API、指向 mock 服务的客户端
+func (m *mockLLM) anthropicClient(opts ...llm.ClientOption) *llm.Client {
+	return m.client(append([]llm.ClientOption{llm.WithProvider(llm.ProviderAnthropic)}, opts...)...)
+}
+
+// anthropicReply Messages API 的回复，content 为内容块列表
+func anthropicReply(stopReason string, content ...map[string]any) mockReply {
+	b, _ := json.Marshal(map[string]any{
+		"id":          "msg_mock",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       "mock-model",
+		"content":     content,
+		"stop_reason": stopReason,
+		"usage":       map[string]any{"input_tokens": 30, "output_tokens": 5, "cache_read_input_tokens": 10},
+	})
+	return mockReply{Status: http.StatusOK, Body: string(b)}
+}
+
+// anthropicEvents 把事件编码为 Messages API 的 SSE 流
+func anthropicEvents(events ...map[string]any) mockReply {
+	var b strings.Builder
+	for _, ev := range events {
+		data, _ := json.Marshal(ev)
+		b.WriteString("event: " + ev["type"].(string) + "\ndata: " + string(data) + "\n\n")
+	}
+	return mockReply{
+		Status: http.StatusOK,
+		Body:   b.String(),
+		Header: map[string]string{"Content-Type": "text/event-stream"},
+	}
+}
+
+// requireJSON 断言 v 序列化后与 want 等价
+func requireJSON(t *testing.T, want string, v any) {
+	t.Helper()
+	got, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.JSONEq(t, want, string(got))
+}
+
+func TestAnthropicGenerateConvertsRequestAndResponse(t *testing.T) {
+	m := newMockLLM(t, anthropicReply("tool_use",
+		map[string]any{"type": "text", "text": "Reading it."},
+		map[string]any{"type": "tool_use", "id": "toolu_2", "name": "read_file", "input": map[string]any{"path": "b.go"}},
+	))
+	headersCh := make(chan http.Header, 1)
+	m.hook = func(_ int, r *http.Request) { headersCh <- r.Header.Clone() }
+
+	history := []schema.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "open a.go"},
+		{Role: "assistant", ToolCalls: []schema.ToolCall{{
+			ID: "toolu_1", Type: "function",
+			Function: schema.FunctionCall{Name: "read_file", Arguments: map[string]any{"path": "a.go"}},
+		}}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "package a"},
+		{Role: "system", Content: "focus on tests"},
+		{Role: "user", Content: "now b.go"},
+	}
+	reg := tools.NewToolRegistry()
+	reg.Register(namedTool{name: "read_file", desc: "Read a file"})
+
+	resp, err := m.anthropicClient().Generate(context.Background(), history, reg)
+	require.NoError(t, err)
+
+	require.Equal(t, "Reading it.", resp.Content)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Len(t, resp.ToolCalls, 1)
+	require.Equal(t, "toolu_2", resp.ToolCalls[0].ID)
+	require.Equal(t, map[string]any{"path": "b.go"}, resp.ToolCalls[0].Function.Arguments)
+	require.Equal(t, &schema.Usage{PromptTokens: 40, CompletionTokens: 5, TotalTokens: 45}, resp.Usage)
+
+	headers := <-headersCh
+	require.Equal(t, "test-key", headers.Get("X-Api-Key"))
+	require.NotEmpty(t, headers.Get("Anthropic-Version"))
+
+	req := m.Requests()[0]
+	require.Equal(t, "mock-model", req["model"])
+	require.EqualValues(t, llm.DefaultMaxTokens, req["max_tokens"])
+	requireJSON(t, `[{"type": "text", "text": "be brief"}]`, req["system"])
+	requireJSON(t, `[{"name": "read_file", "description": "Read a file", "input_schema": {"type": "object"}}]`, req["tools"])
+	// 工具结果、之后的 system 指令与 user 消息合并为一条 user 消息
+	requireJSON(t, `[
+		{"role": "user", "content": [{"type": "text", "text": "open a.go"}]},
+		{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"path": "a.go"}}]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "toolu_1", "content": "package a"},
+			{"type": "text", "text": "focus on tests"},
+			{"type": "text", "text": "now b.go"}
+		]}
+	]`, req["messages"])
+}
+
+func TestAnthropicGenerateStream(t *testing.T) {
+	m := newMockLLM(t, anthropicEvents(
+		map[string]any{"type": "message_start", "message": map[string]any{"usage": map[string]any{"input_tokens": 12, "output_tokens": 1}}},
+		map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "thinking", "thinking": ""}},
+		map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "thinking_delta", "thinking": "hmm"}},
+		map[string]any{"type": "content_block_stop", "index": 0},
+		map[string]any{"type": "content_block_start", "index": 1, "content_block": map[string]any{"type": "text", "text": ""}},
+		map[string]any{"type": "content_block_delta", "index": 1, "delta": map[string]any{"type": "text_delta", "text": "Let me "}},
+		map[string]any{"type": "ping"},
+		map[string]any{"type": "content_block_delta", "index": 1, "delta": map[string]any{"type": "text_delta", "text": "check."}},
+		map[string]any{"type": "content_block_stop", "index": 1},
+		map[string]any{"type": "content_block_start", "index": 2, "content_block": map[string]any{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": map[string]any{}}},
+		map[string]any{"type": "content_block_delta", "index": 2, "delta": map[string]any{"type": "input_json_delta", "partial_json": `{"path":`}},
+		map[string]any{"type": "content_block_delta", "index": 2, "delta": map[string]any{"type": "input_json_delta", "partial_json": ` "main.go"}`}},
+		map[string]any{"type": "content_block_stop", "index": 2},
+		map[string]any{"type": "message_delta", "delta": map[string]any{"stop_reason": "tool_use"}, "usage": map[string]any{"output_tokens": 9}},
+		map[string]any{"type": "message_stop"},
+	))
+
+	var deltas []llm.StreamDelta
+	resp, err := m.anthropicClient().GenerateStream(context.Background(), pingMessages, nil, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []llm.StreamDelta{{Thinking: "hmm"}, {Content: "Let me "}, {Content: "check."}}, deltas)
+	require.Equal(t, "Let me check.", resp.Content)
+	require.Equal(t, "hmm", resp.Thinking)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Len(t, resp.ToolCalls, 1)
+	require.Equal(t, "read_file", resp.ToolCalls[0].Function.Name)
+	require.Equal(t, map[string]any{"path": "main.go"}, resp.ToolCalls[0].Function.Arguments)
+	require.Equal(t, &schema.Usage{PromptTokens: 12, CompletionTokens: 9, TotalTokens: 21}, resp.Usage)
+	require.Equal(t, true, m.Requests()[0]["stream"])
+
+	// 流在 message_stop 之前中断时报错
+	m = newMockLLM(t, anthropicEvents(
+		map[string]any{"type": "message_start", "message": map[string]any{}},
+		map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}},
+	))
+	_, err = m.anthropicClient().GenerateStream(context.Background(), pingMessages, nil, nil)
+	require.ErrorContains(t, err, "message_stop")
+}
+
+func TestAnthropicThinkingBlocksResentWithToolTurn(t *testing.T) {
+	m := newMockLLM(t,
+		anthropicReply("tool_use",
+			map[string]any{"type": "thinking", "thinking": "need the file", "signature": "sig-1"},
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "noop", "input": map[string]any{}},
+		),
+		anthropicReply("end_turn", map[string]any{"type": "text", "text": "done"}),
+	)
+	c := m.anthropicClient(llm.WithReasoningEffort("low"))
+
+	history := []schema.Message{{Role: "user", Content: "go"}}
+	resp, err := c.Generate(context.Background(), history, nil)
+	require.NoError(t, err)
+	require.Equal(t, "need the file", resp.Thinking)
+
+	history = append(history,
+		schema.Message{Role: "assistant", Thinking: resp.Thinking, ToolCalls: resp.ToolCalls},
+		schema.Message{Role: "tool", ToolCallID: "toolu_1", Content: "ok"},
+	)
+	_, err = c.Generate(context.Background(), history, nil)
+	require.NoError(t, err)
+
+	reqs := m.Requests()
+	requireJSON(t, `{"type": "enabled", "budget_tokens": 4096}`, reqs[0]["thinking"])
+	assistant := reqs[1]["messages"].([]any)[1].(map[string]any)
+	requireJSON(t, `[
+		{"type": "thinking", "thinking": "need the file", "signature": "sig-1"},
+		{"type": "tool_use", "id": "toolu_1", "name": "noop", "input": {}}
+	]`, assistant["content"])
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	m := newMockLLM(t, mockReply{
+		Status: http.StatusNotFound,
+		Body:   `{"type": "error", "error": {"type": "not_found_error", "message": "model: claude-nope"}}`,
+	})
+	_, err := m.anthropicClient().Generate(context.Background(), pingMessages, nil)
+	var notFound *llm.ModelNotFoundError
+	require.True(t, errors.As(err, &notFound), "got %v", err)
+	require.Equal(t, "mock-model", notFound.Model)
+
+	m = newMockLLM(t, mockReply{
+		Status: 529,
+		Body:   `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`,
+		Header: map[string]string{"Retry-After": "3"},
+	})
+	_, err = m.anthropicClient().Generate(context.Background(), pingMessages, nil)
+	var apiErr *llm.AnthropicError
+	require.True(t, errors.As(err, &apiErr), "got %v", err)
+	require.Equal(t, "overloaded_error", apiErr.Type)
+	class, _ := llm.ClassifyError(err)
+	require.Equal(t, retry.ClassServer, class)
+}
+
+func TestAnthropicCountTokens(t *testing.T) {
+	m := newMockLLM(t, mockReply{Status: http.StatusOK, Body: `{"input_tokens": 321}`})
+	paths := make(chan string, 1)
+	m.hook = func(_ int, r *http.Request) { paths <- r.URL.Path }
+
+	n, err := m.anthropicClient().CountTokens(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, 321, n)
+	require.Equal(t, "/messages/count_tokens", <-paths)
+	require.NotContains(t, m.Requests()[0], "max_tokens")
+
+	// OpenAI 兼容 API 没有计数接口，本地估算，不发请求
+	m = newMockLLM(t, textReply("unused"))
+	n, err = m.client().CountTokens(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Positive(t, n)
+	require.Empty(t, m.Requests())
+}
+
+func TestAgentRunsOnAnthropicProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t,
+		anthropicReply("tool_use", map[string]any{"type": "tool_use", "id": "toolu_1", "name": "noop", "input": map[string]any{}}),
+		anthropicReply("end_turn", map[string]any{"type": "text", "text": "all done"}),
+	)
+	ag, err := agent.NewAgent(m.anthropicClient(), "sys", []tools.Tool{namedTool{name: "noop"}}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+
+	result, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "all done", result)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	msgs := reqs[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	require.Equal(t, "user", last["role"])
+	require.Equal(t, "tool_result", last["content"].([]any)[0].(map[string]any)["type"])
+}
+
+func TestAnthropicMarksFailedToolResults(t *testing.T) {
+	m := newMockLLM(t, anthropicReply("end_turn", map[string]any{"type": "text", "text": "ok"}))
+
+	history := []schema.Message{
+		{Role: "user", Content: "open a.go and b.go"},
+		{Role: "assistant", ToolCalls: []schema.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: schema.FunctionCall{Name: "read_file", Arguments: map[string]any{"path": "a.go"}}},
+			{ID: "toolu_2", Type: "function", Function: schema.FunctionCall{Name: "read_file", Arguments: map[string]any{"path": "b.go"}}},
+		}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "package a"},
+		{Role: "tool", ToolCallID: "toolu_2", Content: "Error: File not found: b.go", IsError: true},
+	}
+	_, err := m.anthropicClient().Generate(context.Background(), history, nil)
+	require.NoError(t, err)
+
+	msgs := m.Requests()[0]["messages"].([]any)
+	requireJSON(t, `{"role": "user", "content": [
+		{"type": "tool_result", "tool_use_id": "toolu_1", "content": "package a"},
+		{"type": "tool_result", "tool_use_id": "toolu_2", "content": "Error: File not found: b.go", "is_error": true}
+	]}`, msgs[len(msgs)-1])
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestAskUserEndsRunWithQuestion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_q", Name: "ask_user", Args: `{"question": "Which port should the server use?"}`}),
+		textReply("Configured port 8080."),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewAskUserTool()}, 10, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	// 提问：本轮结束，返回问题而不是继续调用模型
+	ag.AddUserMessage("start the server")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, agent.OutcomeNeedsInput, ag.LastOutcome())
+	require.Equal(t, "Which port should the server use?", out)
+	require.Len(t, m.Requests(), 1)
+
+	// 工具调用有对应的 tool 结果，历史保持合法
+	history := ag.History()
+	last := history[len(history)-1]
+	require.Equal(t, "tool", last.Role)
+	require.Equal(t, "call_q", last.ToolCallID)
+
+	// 回答后继续，模型正常结束即为完成
+	ag.AddUserMessage("8080")
+	out, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, agent.OutcomeDone, ag.LastOutcome())
+	require.Equal(t, "Configured port 8080.", out)
+}
+
+func TestAskUserRequiresQuestion(t *testing.T) {
+	res, err := tools.NewAskUserTool().Execute(context.Background(), map[string]any{"question": "  "})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Empty(t, res.Question)
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// pngHeader 最小的 PNG 文件头，足以被识别为 image/png
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// chartTool 返回一张图片附件和一个 CSV 附件
+type chartTool struct{}
+
+func (chartTool) Name() string               { return "chart" }
+func (chartTool) Description() string        { return "Render a chart" }
+func (chartTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (chartTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	return &tools.ToolResult{
+		Success: true,
+		Content: "Rendered chart",
+		Attachments: []tools.Attachment{
+			{Name: "chart.png", Data: pngHeader, Description: "bar chart of sales"},
+			{Name: "data.csv", Data: []byte("a,b\n1,2\n")},
+		},
+	}, nil
+}
+
+func runChartAgent(t *testing.T, vision bool) []any {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "chart", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{chartTool{}}, 5, t.TempDir(), 100000,
+		agent.WithVision(vision))
+	require.NoError(t, err)
+
+	ag.AddUserMessage("draw")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	return reqs[1]["messages"].([]any)
+}
+
+func TestToolAttachmentsSentAsImageWithVision(t *testing.T) {
+	msgs := runChartAgent(t, true)
+
+	last := msgs[len(msgs)-1].(map[string]any)
+	require.Equal(t, "user", last["role"])
+	parts := last["content"].([]any)
+
+	var imageURL string
+	for _, p := range parts {
+		part := p.(map[string]any)
+		if part["type"] == "image_url" {
+			imageURL = part["image_url"].(map[string]any)["url"].(string)
+		}
+	}
+	require.True(t, strings.HasPrefix(imageURL, "data:image/png;base64,"), imageURL)
+
+	// 工具结果中保留文字说明；CSV 不是图片，只以描述形式出现
+	tool := msgs[len(msgs)-2].(map[string]any)
+	require.Equal(t, "tool", tool["role"])
+	content := tool["content"].(string)
+	require.Contains(t, content, "sent as image")
+	require.Contains(t, content, "[Attachment: data.csv")
+}
+
+func TestToolAttachmentsDegradeWithoutVision(t *testing.T) {
+	msgs := runChartAgent(t, false)
+
+	last := msgs[len(msgs)-1].(map[string]any)
+	require.Equal(t, "tool", last["role"])
+	content := last["content"].(string)
+	require.Contains(t, content, "Rendered chart")
+	require.Contains(t, content, "[Attachment: chart.png (image/png")
+	require.Contains(t, content, "bar chart of sales")
+
+	raw, _ := json.Marshal(msgs)
+	require.NotContains(t, string(raw), "image_url")
+}
+
+func TestAttachmentLoadMissingFile(t *testing.T) {
+	att := tools.Attachment{Path: "/nonexistent/shot.png"}
+	_, _, err := att.Load()
+	require.Error(t, err)
+	require.Equal(t, "shot.png", att.DisplayName())
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// backupTools 共享同一个会话备份的文件工具
+func backupTools(t *testing.T, ws string, maxBytes int64) (*tools.SessionBackup, *tools.WriteTool, *tools.EditTool, *tools.CopyFileTool) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	b, err := tools.NewSessionBackup(ws, maxBytes)
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() })
+	return b,
+		tools.NewWriteTool(ws, tools.WithWriteBackup(b)),
+		tools.NewEditTool(ws, tools.WithEditBackup(b)),
+		tools.NewCopyFileTool(ws, tools.WithCopyBackup(b))
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestSessionBackupRollback(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main\n// TODO\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "cmd"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "cmd", "run.sh"), []byte("echo v1\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, ".gitignore"), []byte("build/\n"), 0o644))
+	b, write, edit, cp := backupTools(t, ws, 0)
+
+	require.True(t, filepath.IsAbs(b.Dir()))
+	require.DirExists(t, b.Dir())
+
+	// 同一文件修改两次：回滚到会话开始前的版本
+	run(t, edit, map[string]any{"path": "main.go", "old_str": "// TODO", "new_str": "// done"})
+	run(t, edit, map[string]any{"path": "main.go", "old_str": "// done", "new_str": "// done twice"})
+	run(t, write, map[string]any{"path": "cmd/run.sh", "content": "echo v2\n"})
+	run(t, write, map[string]any{"path": "pkg/util/new.go", "content": "package util\n"})
+	run(t, cp, map[string]any{"source": "main.go", "destination": "copy.go"})
+	run(t, write, map[string]any{"path": "build/out.txt", "content": "artifact\n"})
+
+	// 备份保留相对路径；被 .gitignore 忽略的文件不备份
+	require.Equal(t, "package main\n// TODO\n", readString(t, filepath.Join(b.Dir(), "main.go")))
+	require.Equal(t, "echo v1\n", readString(t, filepath.Join(b.Dir(), "cmd", "run.sh")))
+	require.Equal(t, 4, b.Len())
+
+	report, err := b.Rollback()
+	require.NoError(t, err)
+	require.Equal(t, []string{"cmd/run.sh", "main.go"}, report.Restored)
+	require.Equal(t, []string{"copy.go", "pkg/util/new.go"}, report.Removed)
+	require.Empty(t, report.Skipped)
+
+	require.Equal(t, "package main\n// TODO\n", readString(t, filepath.Join(ws, "main.go")))
+	require.Equal(t, "echo v1\n", readString(t, filepath.Join(ws, "cmd", "run.sh")))
+	require.NoFileExists(t, filepath.Join(ws, "copy.go"))
+	require.NoDirExists(t, filepath.Join(ws, "pkg"), "directories created for new files are removed")
+	require.Equal(t, "artifact\n", readString(t, filepath.Join(ws, "build", "out.txt")), "ignored files are left alone")
+	if !isWindows() {
+		info, err := os.Stat(filepath.Join(ws, "cmd", "run.sh"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	}
+
+	// 回滚后备份清空，再次回滚没有可做的
+	require.Equal(t, 0, b.Len())
+	report, err = b.Rollback()
+	require.NoError(t, err)
+	require.Empty(t, report.Restored)
+	require.Empty(t, report.Removed)
+}
+
+func TestSessionBackupSizeLimit(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "small.txt"), []byte("small\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "large.txt"), make([]byte, 100), 0o644))
+	b, write, _, _ := backupTools(t, ws, 64)
+
+	run(t, write, map[string]any{"path": "small.txt", "content": "changed\n"})
+	run(t, write, map[string]any{"path": "large.txt", "content": "changed\n"})
+
+	report, err := b.Rollback()
+	require.NoError(t, err)
+	require.Equal(t, []string{"small.txt"}, report.Restored)
+	require.Equal(t, []string{"large.txt"}, report.Skipped)
+	require.Equal(t, "small\n", readString(t, filepath.Join(ws, "small.txt")))
+	require.Equal(t, "changed\n", readString(t, filepath.Join(ws, "large.txt")))
+}
+
+func TestSessionBackupClose(t *testing.T) {
+	ws := t.TempDir()
+	b, _, _, _ := backupTools(t, ws, 0)
+	require.NoError(t, b.Close())
+	require.NoDirExists(t, b.Dir())
+
+	// nil 表示未开启
+	var off *tools.SessionBackup
+	off.Save(filepath.Join(ws, "x"))
+	report, err := off.Rollback()
+	require.NoError(t, err)
+	require.Empty(t, report.Restored)
+	require.NoError(t, off.Close())
+}
@@ -2,7 +2,13 @@ package tests
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -370,3 +376,449 @@ func TestTimeoutValidation(t *testing.T) {
 		t.Fatalf("Unexpected failure for timeout<1")
 	}
 }
+
+// =======================================
+// Max output lines (head + tail)
+// =======================================
+
+func TestBashMaxOutputLines(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses seq")
+	}
+	bash := tools.NewBashTool(tools.WithMaxOutputLines(10))
+
+	res, err := bash.Execute(context.Background(), map[string]any{
+		"command": "seq 1 1000",
+	})
+	if err != nil || !res.Success {
+		t.Fatalf("Exec failed: %v %s", err, res.Error)
+	}
+
+	for _, want := range []string{"1\n2\n3\n4\n5\n", "... [990 lines omitted] ...", "996\n997\n998\n999\n1000\n"} {
+		if !strings.Contains(res.Content, want) {
+			t.Fatalf("Expected %q in content:\n%s", want, res.Content)
+		}
+	}
+	if strings.Contains(res.Content, "\n500\n") {
+		t.Fatalf("Middle lines should be elided:\n%s", res.Content)
+	}
+	// 原始 stdout 不截断
+	if n := strings.Count(res.Stdout, "\n"); n != 1000 {
+		t.Fatalf("Expected raw stdout to keep 1000 lines, got %d", n)
+	}
+
+	// 默认不限制
+	res, _ = tools.NewBashTool().Execute(context.Background(), map[string]any{
+		"command": "seq 1 1000",
+	})
+	if strings.Contains(res.Content, "omitted") || !strings.Contains(res.Content, "\n500\n") {
+		t.Fatalf("Default should be unlimited")
+	}
+}
+
+// =======================================
+// stderr_is_error policy
+// =======================================
+
+func TestBashStderrPolicy(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash syntax")
+	}
+
+	const stderrOnly = "echo out && echo warn >&2"
+	const nonzeroExit = "exit 3"
+
+	cases := []struct {
+		policy    string
+		stderrOK  bool
+		nonzeroOK bool
+	}{
+		{policy: "never", stderrOK: true, nonzeroOK: true},
+		{policy: "nonzero-exit", stderrOK: true, nonzeroOK: false},
+		{policy: "", stderrOK: true, nonzeroOK: false},
+		{policy: "always", stderrOK: false, nonzeroOK: false},
+	}
+
+	for _, tc := range cases {
+		policy, err := tools.ParseStderrPolicy(tc.policy)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.policy, err)
+		}
+		bash := tools.NewBashTool(tools.WithStderrPolicy(policy))
+
+		res, _ := bash.Execute(context.Background(), map[string]any{"command": stderrOnly})
+		if res.Success != tc.stderrOK {
+			t.Fatalf("policy %q, stderr with exit 0: expected Success=%v, got %v (%s)", tc.policy, tc.stderrOK, res.Success, res.Error)
+		}
+
+		res, _ = bash.Execute(context.Background(), map[string]any{"command": nonzeroExit})
+		if res.Success != tc.nonzeroOK {
+			t.Fatalf("policy %q, exit 3: expected Success=%v, got %v (%s)", tc.policy, tc.nonzeroOK, res.Success, res.Error)
+		}
+		if res.ExitCode != 3 {
+			t.Fatalf("policy %q: expected exit code 3, got %d", tc.policy, res.ExitCode)
+		}
+	}
+
+	// 默认构造与 nonzero-exit 一致
+	res, _ := tools.NewBashTool().Execute(context.Background(), map[string]any{"command": nonzeroExit})
+	if res.Success {
+		t.Fatalf("default policy should fail on non-zero exit")
+	}
+
+	// never 策略下超时仍然是失败
+	bash := tools.NewBashTool(tools.WithStderrPolicy(tools.StderrNever))
+	res, _ = bash.Execute(context.Background(), map[string]any{"command": "sleep 3", "timeout": 1})
+	if res.Success {
+		t.Fatalf("timeout must fail regardless of policy")
+	}
+
+	if _, err := tools.ParseStderrPolicy("sometimes"); err == nil {
+		t.Fatalf("expected error for invalid policy")
+	}
+}
+
+// =======================================
+// Close terminates background shells
+// =======================================
+
+func TestBashCloseTerminatesBackground(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep")
+	}
+	bash := tools.NewBashTool()
+
+	res, _ := bash.Execute(context.Background(), map[string]any{
+		"command":           "sleep 30",
+		"run_in_background": true,
+	})
+	if !res.Success || res.BashID == "" {
+		t.Fatalf("failed to start background command: %v", res.Error)
+	}
+
+	if err := bash.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// Close 之后后台进程已终止并从管理器移除
+	out, _ := tools.NewBashOutputTool().Execute(context.Background(), map[string]any{
+		"bash_id": res.BashID,
+	})
+	if out.Success {
+		t.Fatalf("expected shell %s to be gone after Close", res.BashID)
+	}
+}
+
+// =======================================
+// Workspace confinement
+// =======================================
+
+func TestBashWorkspaceConfinement(t *testing.T) {
+	if isWindows() {
+		t.Skip("bash syntax")
+	}
+	ws := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(ws, "sub", "deep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	bash := tools.NewBashTool(tools.WithWorkspaceConfinement(ws))
+	ctx := context.Background()
+
+	// 命令默认在 workspace 下执行
+	res, _ := bash.Execute(ctx, map[string]any{"command": "pwd"})
+	if !res.Success {
+		t.Fatalf("pwd failed: %v", res.Error)
+	}
+	want, _ := filepath.EvalSymlinks(ws)
+	got, _ := filepath.EvalSymlinks(strings.TrimSpace(res.Stdout))
+	if got != want {
+		t.Fatalf("pwd = %q, want workspace %q", got, want)
+	}
+
+	allowed := []string{
+		"cd sub && pwd",
+		"cd sub/deep && cd .. && cd .. && pwd",
+		"cd " + filepath.Join(ws, "sub") + " && ls",
+		`echo "cd /etc"`,
+		"git status; cd -- sub",
+		"(cd sub; ls)",
+	}
+	for _, cmd := range allowed {
+		res, _ := bash.Execute(ctx, map[string]any{"command": cmd})
+		if strings.Contains(res.Error, "confinement") {
+			t.Fatalf("command %q should be allowed: %v", cmd, res.Error)
+		}
+	}
+
+	blocked := []string{
+		"cd /etc && cat passwd",
+		"ls; cd ..",
+		"cd sub && cd ../..",
+		"cd",
+		"cd ~/projects",
+		"cd $HOME",
+		"FOO=1 pushd /tmp",
+		"if true; then cd /; fi",
+		"echo $(cd /etc && ls)",
+	}
+	for _, cmd := range blocked {
+		res, _ := bash.Execute(ctx, map[string]any{"command": cmd, "run_in_background": true})
+		if res.Success || !strings.Contains(res.Error, "confinement is enabled") {
+			t.Fatalf("command %q should be rejected, got success=%v err=%q", cmd, res.Success, res.Error)
+		}
+	}
+
+	// 未开启时不做检查
+	res, _ = tools.NewBashTool().Execute(ctx, map[string]any{"command": "cd / && pwd"})
+	if !res.Success {
+		t.Fatalf("unconfined cd failed: %v", res.Error)
+	}
+}
+
+// =======================================
+// Injected ID generator
+// =======================================
+
+// sequentialIDs 返回按 prefix-1、prefix-2 ... 递增的 ID 生成器
+func sequentialIDs(prefix string) func() string {
+	n := 0
+	return func() string {
+		n++
+		return fmt.Sprintf("%s-%d", prefix, n)
+	}
+}
+
+func TestBashIDGenerator(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep")
+	}
+	bash := tools.NewBashTool(tools.WithIDGenerator(sequentialIDs("seq")))
+	ctx := context.Background()
+	kill := tools.NewBashKillTool()
+
+	for i, want := range []string{"seq-1", "seq-2"} {
+		res, _ := bash.Execute(ctx, map[string]any{"command": "sleep 30", "run_in_background": true})
+		if !res.Success || res.BashID != want {
+			t.Fatalf("start %d: got id %q (err %q), want %q", i, res.BashID, res.Error, want)
+		}
+		defer kill.Execute(ctx, map[string]any{"bash_id": want})
+	}
+
+	ids := tools.BackgroundShellIDs()
+	if !slices.Contains(ids, "seq-1") || !slices.Contains(ids, "seq-2") {
+		t.Fatalf("manager ids = %v, want seq-1 and seq-2", ids)
+	}
+
+	// 生成器返回已在使用的 ID 时拒绝启动，而不是覆盖已有进程
+	dup := tools.NewBashTool(tools.WithIDGenerator(func() string { return "seq-1" }))
+	res, _ := dup.Execute(ctx, map[string]any{"command": "sleep 30", "run_in_background": true})
+	if res.Success || !strings.Contains(res.Error, "already in use") {
+		t.Fatalf("expected duplicate id rejection, got success=%v err=%q", res.Success, res.Error)
+	}
+
+	kill.Execute(ctx, map[string]any{"bash_id": "seq-1"})
+	ids = tools.BackgroundShellIDs()
+	if slices.Contains(ids, "seq-1") || !slices.Contains(ids, "seq-2") {
+		t.Fatalf("after kill: manager ids = %v", ids)
+	}
+}
+
+func TestBashMaxConcurrent(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep")
+	}
+	trace := filepath.Join(t.TempDir(), "trace")
+	bash := tools.NewBashTool(tools.WithMaxConcurrent(2))
+	ctx := context.Background()
+
+	// 5 条命令同时发起，每条记录开始 / 结束，名额为 2 时最多 2 条同时运行
+	cmd := fmt.Sprintf("echo s >> %q; sleep 0.3; echo e >> %q", trace, trace)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := bash.Execute(ctx, map[string]any{"command": cmd})
+			if !res.Success {
+				t.Errorf("command failed: %s", res.Error)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, peak := 0, 0
+	for _, ev := range strings.Fields(string(data)) {
+		if ev == "s" {
+			running++
+		} else {
+			running--
+		}
+		peak = max(peak, running)
+	}
+	if peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2 (trace %q)", peak, data)
+	}
+
+	// 等待名额时 ctx 取消会直接放弃，而不是无限排队
+	busy := tools.NewBashTool(tools.WithMaxConcurrent(1))
+	go busy.Execute(ctx, map[string]any{"command": "sleep 1"})
+	time.Sleep(100 * time.Millisecond)
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	res, _ := busy.Execute(cctx, map[string]any{"command": "echo hi"})
+	if res.Success || !strings.Contains(res.Error, "waiting for a free slot") {
+		t.Fatalf("expected queued call to be cancelled, got success=%v err=%q", res.Success, res.Error)
+	}
+}
+
+func TestBashDryRun(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	bash := tools.NewBashTool(tools.WithDryRun(true), tools.WithIDGenerator(sequentialIDs("dry")))
+	ctx := context.Background()
+	command := fmt.Sprintf("echo hi > %q", marker)
+
+	res, _ := bash.Execute(ctx, map[string]any{"command": command})
+	if !res.Success || res.Stdout != "would execute: "+command || !strings.Contains(res.Content, "[dry-run] would execute (foreground)") {
+		t.Fatalf("unexpected dry-run result: %+v", res)
+	}
+
+	// 后台模式既不启动进程，也不登记 bash_id
+	res, _ = bash.Execute(ctx, map[string]any{"command": command, "run_in_background": true})
+	if !res.Success || res.BashID != "" || !strings.Contains(res.Content, "(background)") {
+		t.Fatalf("unexpected background dry-run result: %+v", res)
+	}
+	if slices.Contains(tools.BackgroundShellIDs(), "dry-1") {
+		t.Fatalf("dry run registered a background shell: %v", tools.BackgroundShellIDs())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("dry run spawned a subprocess (marker exists, err=%v)", err)
+	}
+}
+
+func TestBashSilentCommandContent(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash builtins")
+	}
+	bash := tools.NewBashTool()
+	ctx := context.Background()
+
+	for _, cmd := range []string{"true", "exit 0"} {
+		res, _ := bash.Execute(ctx, map[string]any{"command": cmd})
+		if !res.Success || res.Content != "Command succeeded with no output, exit code 0" {
+			t.Fatalf("%s: got success=%v content=%q", cmd, res.Success, res.Content)
+		}
+	}
+
+	res, _ := bash.Execute(ctx, map[string]any{"command": "exit 3"})
+	if res.Success || res.Content != "Command failed with no output, exit code 3" {
+		t.Fatalf("exit 3: got success=%v content=%q", res.Success, res.Content)
+	}
+
+	// 有输出时仍使用原有格式
+	res, _ = bash.Execute(ctx, map[string]any{"command": "echo hi"})
+	if !strings.Contains(res.Content, "hi") || !strings.Contains(res.Content, "[exit_code]:\n0") {
+		t.Fatalf("echo: unexpected content %q", res.Content)
+	}
+}
+
+func TestBashLiveOutputStillCaptures(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash redirection")
+	}
+	var live strings.Builder
+	bash := tools.NewBashTool(tools.WithLiveOutput(&live))
+
+	res, _ := bash.Execute(context.Background(), map[string]any{
+		"command": "echo line1; echo oops >&2; echo line2",
+	})
+	if !res.Success {
+		t.Fatalf("command failed: %s", res.Error)
+	}
+	if res.Stdout != "line1\nline2\n" || res.Stderr != "oops\n" {
+		t.Fatalf("captured stdout=%q stderr=%q", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Content, "line1\nline2") || !strings.Contains(res.Content, "[stderr]:\noops") {
+		t.Fatalf("unexpected content %q", res.Content)
+	}
+	for _, want := range []string{"line1\n", "oops\n", "line2\n"} {
+		if !strings.Contains(live.String(), want) {
+			t.Fatalf("live output %q is missing %q", live.String(), want)
+		}
+	}
+}
+
+// =======================================
+// 工作目录与耗时
+// =======================================
+
+func TestBashReportsWorkingDirAndDuration(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep and pwd")
+	}
+	ws := t.TempDir()
+	want, err := filepath.EvalSymlinks(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 未开启 exec_info 时只填写字段，内容保持原有格式
+	res, _ := tools.NewBashTool(tools.WithWorkspaceConfinement(ws)).Execute(ctx, map[string]any{"command": "sleep 0.2; pwd"})
+	if !res.Success || res.WorkingDir != want || res.DurationMs < 200 {
+		t.Fatalf("got success=%v dir=%q duration=%d, want dir %q and >= 200ms", res.Success, res.WorkingDir, res.DurationMs, want)
+	}
+	if strings.Contains(res.Content, "[working_dir]") {
+		t.Fatalf("exec info should be off by default: %q", res.Content)
+	}
+
+	bash := tools.NewBashTool(tools.WithWorkspaceConfinement(ws), tools.WithExecInfo(true))
+	res, _ = bash.Execute(ctx, map[string]any{"command": "sleep 0.2; pwd"})
+	if !strings.Contains(res.Content, "[working_dir]:\n"+want) || !strings.Contains(res.Content, "[duration_ms]:\n") {
+		t.Fatalf("missing exec info: %q", res.Content)
+	}
+	res, _ = bash.Execute(ctx, map[string]any{"command": "true"})
+	if !strings.HasPrefix(res.Content, "Command succeeded with no output, exit code 0\n[working_dir]:\n"+want) {
+		t.Fatalf("silent command: unexpected content %q", res.Content)
+	}
+}
+
+func TestBashBackgroundDurationOnCompletion(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep")
+	}
+	ws := t.TempDir()
+	ctx := context.Background()
+	opts := []tools.BashOption{tools.WithWorkspaceConfinement(ws), tools.WithExecInfo(true)}
+
+	res, _ := tools.NewBashTool(opts...).Execute(ctx, map[string]any{"command": "sleep 0.2; echo done", "run_in_background": true})
+	if !res.Success || res.WorkingDir == "" {
+		t.Fatalf("start: success=%v dir=%q", res.Success, res.WorkingDir)
+	}
+	id := res.BashID
+	defer tools.NewBashKillTool().Execute(ctx, map[string]any{"bash_id": id})
+
+	out := tools.NewBashOutputTool(opts...)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, _ := out.Execute(ctx, map[string]any{"bash_id": id})
+		if strings.Contains(r.Content, "[duration_ms]:") {
+			if r.DurationMs < 200 || !strings.Contains(r.Content, "[working_dir]:\n"+r.WorkingDir) {
+				t.Fatalf("finished: duration=%d content=%q", r.DurationMs, r.Content)
+			}
+			return
+		}
+		if r.DurationMs != 0 {
+			t.Fatalf("running command should have no duration yet, got %d", r.DurationMs)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background command did not report a duration: %q", r.Content)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
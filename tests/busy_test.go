@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+)
+
+// 并发调用 Run：第二次调用立即得到 ErrBusy，且不修改会话历史（用 go test -race 运行可检查数据竞争）
+func TestConcurrentRunIsRejected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	inCall := make(chan struct{})
+	release := make(chan struct{})
+	m := newMockLLM(t, textReply("first"), textReply("second"))
+	m.hook = func(n int, r *http.Request) {
+		if n == 1 {
+			close(inCall)
+			<-release
+		}
+	}
+
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("hi")
+
+	type result struct {
+		out string
+		err error
+	}
+	first := make(chan result, 1)
+	go func() {
+		out, err := ag.Run(context.Background())
+		first <- result{out, err}
+	}()
+
+	select {
+	case <-inCall:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first run did not reach the LLM")
+	}
+	require.True(t, ag.Busy())
+
+	_, err = ag.Run(context.Background())
+	require.ErrorIs(t, err, agent.ErrBusy)
+	_, err = ag.DryPlan(context.Background(), "anything")
+	require.ErrorIs(t, err, agent.ErrBusy)
+	_, err = ag.RunPlan(context.Background())
+	require.ErrorIs(t, err, agent.ErrBusy)
+
+	close(release)
+	res := <-first
+	require.NoError(t, res.err)
+	require.Equal(t, "first", res.out)
+	require.False(t, ag.Busy())
+	require.Len(t, ag.History(), 3, "rejected calls must not touch the history")
+	require.Len(t, m.Requests(), 1)
+
+	// 上一次运行结束后可以再次运行
+	ag.AddUserMessage("again")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "second", out)
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+)
+
+// multiChoiceReply 每个 content 一个候选的回复
+func multiChoiceReply(contents ...string) mockReply {
+	choices := make([]map[string]any, len(contents))
+	// 服务端不保证按 index 顺序返回
+	for i, c := range contents {
+		choices[len(contents)-1-i] = map[string]any{
+			"index":         i,
+			"message":       map[string]any{"role": "assistant", "content": c},
+			"finish_reason": "stop",
+		}
+	}
+	return mockReply{Status: http.StatusOK, Body: completionBody("", nil, map[string]any{"choices": choices})}
+}
+
+func TestMultipleChoicesAreParsed(t *testing.T) {
+	m := newMockLLM(t, multiChoiceReply("first idea", "second idea", "third idea"))
+	resp, err := m.client(llm.WithChoices(3)).Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 3, m.Requests()[0]["n"])
+	require.Len(t, resp.Choices, 3)
+	for i, want := range []string{"first idea", "second idea", "third idea"} {
+		require.Equal(t, i, resp.Choices[i].Index)
+		require.Equal(t, want, resp.Choices[i].Content)
+		require.Equal(t, "stop", resp.Choices[i].FinishReason)
+	}
+	// 顶层字段取自第一个候选
+	require.Equal(t, "first idea", resp.Content)
+}
+
+func TestSingleChoiceByDefault(t *testing.T) {
+	m := newMockLLM(t, textReply("pong"))
+	resp, err := m.client().Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m.Requests()[0], "n")
+	require.Len(t, resp.Choices, 1)
+	require.Equal(t, "pong", resp.Content)
+}
+
+// Agent 采用第一个候选，其余通过 Alternatives 暴露
+func TestAgentUsesFirstChoice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t, multiChoiceReply("A", "B"))
+	ag, err := agent.NewAgent(m.client(llm.WithChoices(2)), "sys", nil, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("brainstorm")
+
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "A", out)
+	h := ag.History()
+	require.Equal(t, "A", h[len(h)-1].Content)
+
+	require.Len(t, ag.LastChoices(), 2)
+	alts := ag.Alternatives()
+	require.Len(t, alts, 1)
+	require.Equal(t, "B", alts[0].Content)
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestCompactContentIsSmallerThanVerbose(t *testing.T) {
+	ws := t.TempDir()
+	src := "package main\n\nfunc main() {   \n\tprintln(\"hi\")  // keep  spacing\n}\n\n\n\n// end\n"
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte(src), 0o644))
+
+	res, err := tools.NewReadTool(ws).Execute(context.Background(), map[string]any{"path": "main.go"})
+	require.NoError(t, err)
+	require.True(t, res.Success)
+
+	compact := tools.CompactContent(res.Content)
+	require.Less(t, len(compact), len(res.Content))
+	require.Contains(t, res.Content, "     1|package main")
+	require.Contains(t, compact, "1|package main")
+	require.NotContains(t, compact, " 1|")
+	// 行首缩进与行内空白保持不变，行尾空白被删除
+	require.Contains(t, compact, "4|\tprintln(\"hi\")  // keep  spacing\n")
+	require.Contains(t, compact, "3|func main() {\n")
+
+	res, err = tools.NewBashTool().Execute(context.Background(), map[string]any{"command": "echo out; echo err >&2; exit 3"})
+	require.NoError(t, err)
+	compact = tools.CompactContent(res.Content)
+	require.Less(t, len(compact), len(res.Content))
+	require.Equal(t, "out\nstderr:\nerr\nexit_code: 3", compact)
+}
+
+func TestCompactToolResultsOnlyAffectModelMessages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("alpha\nbeta\n"), 0o644))
+
+	readVia := func(compact bool) string {
+		m := newMockLLM(t,
+			toolReply("", mockCall{ID: "call_1", Name: "read_file", Args: `{"path":"a.txt"}`}),
+			textReply("done"),
+		)
+		ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewReadTool(ws)}, 5, ws, 100000,
+			agent.WithCompactToolResults(compact))
+		require.NoError(t, err)
+		ag.AddUserMessage("go")
+		_, err = ag.Run(context.Background())
+		require.NoError(t, err)
+		for _, msg := range ag.History() {
+			if msg.Role == "tool" {
+				return msg.Content
+			}
+		}
+		return ""
+	}
+
+	verbose, compact := readVia(false), readVia(true)
+	require.Equal(t, "     1|alpha\n     2|beta", verbose)
+	require.Equal(t, "1|alpha\n2|beta", compact)
+}
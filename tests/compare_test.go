@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestCompareUsesAlternateClientWithoutTouchingHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	main := newMockLLM(t, textReply("answer from main"))
+	ag, err := agent.NewAgent(main.client(), "sys", []tools.Tool{tools.NewReadTool(ws)}, 10, ws, 100000)
+	require.NoError(t, err)
+
+	_, err = ag.Compare(context.Background(), main.client())
+	require.ErrorIs(t, err, agent.ErrNothingToCompare)
+
+	ag.AddUserMessage("what is 2+2?")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	before := ag.History()
+
+	alt := newMockLLM(t, textReply("answer from alt"))
+	altClient := alt.client()
+	altClient.SetModel("other-model")
+
+	cmp, err := ag.Compare(context.Background(), altClient)
+	require.NoError(t, err)
+	require.Equal(t, "what is 2+2?", cmp.Prompt)
+	require.Equal(t, "answer from main", cmp.Current)
+	require.Equal(t, "answer from alt", cmp.Alternate)
+
+	reqs := alt.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, "other-model", reqs[0]["model"])
+	require.Equal(t, "none", reqs[0]["tool_choice"])
+	msgs, _ := reqs[0]["messages"].([]any)
+	require.Len(t, msgs, 2) // system + 最近的用户消息，不含主模型的回复
+
+	require.Equal(t, before, ag.History())
+	require.Len(t, main.Requests(), 1)
+}
+
+func TestCompareReportsAlternateModelErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	ag, err := agent.NewAgent(newMockLLM(t).client(), "sys", nil, 10, ws, 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("hello")
+	before := ag.History()
+
+	alt := newMockLLM(t, badRequest)
+	_, err = ag.Compare(context.Background(), alt.client())
+	require.Error(t, err)
+	require.Equal(t, before, ag.History())
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/config"
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+// 没有项目配置时回退到 ~/.gopilot/config.yaml
+func TestFindConfigFileFallsBackToUserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	_, found := config.FindConfigFile("")
+	require.False(t, found)
+
+	userPath, err := config.UserConfigPath()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, ".gopilot", "config.yaml"), userPath)
+
+	cfg := config.DefaultConfig()
+	cfg.LLM.APIBase = "https://example.test/v1"
+	cfg.LLM.Model = "test-model"
+	cfg.LLM.APIKey = "sk-test"
+	require.NoError(t, config.SaveToFile(cfg, userPath))
+
+	info, err := os.Stat(userPath)
+	require.NoError(t, err)
+	if !isWindows() {
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	path, found := config.FindConfigFile("")
+	require.True(t, found)
+	require.Equal(t, userPath, path)
+
+	loaded, err := config.LoadFromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.test/v1", loaded.LLM.APIBase)
+	require.Equal(t, "test-model", loaded.LLM.Model)
+	require.Equal(t, "sk-test", loaded.LLM.APIKey)
+	require.Equal(t, cfg.Agent.MaxSteps, loaded.Agent.MaxSteps)
+}
+
+// 项目内 configs/config.yaml 优先于用户配置
+func TestFindConfigFilePrefersProjectConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	project := t.TempDir()
+	t.Chdir(project)
+
+	userPath, _ := config.UserConfigPath()
+	require.NoError(t, config.SaveToFile(config.DefaultConfig(), userPath))
+	require.NoError(t, config.SaveToFile(config.DefaultConfig(), filepath.Join(project, config.DefaultConfigPath)))
+
+	path, found := config.FindConfigFile("")
+	require.True(t, found)
+	require.Equal(t, config.DefaultConfigPath, path)
+}
+
+func TestFindConfigFileExplicit(t *testing.T) {
+	path, found := config.FindConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.False(t, found)
+	require.NotEmpty(t, path)
+}
+
+func TestIdleTimeoutConfig(t *testing.T) {
+	require.Zero(t, config.DefaultConfig().UI.IdleTimeout)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("ui:\n  idle_timeout: 30m\n"), 0o644))
+
+	cfg, err := config.LoadFromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, cfg.UI.IdleTimeout)
+}
+
+// 随仓库发布的示例配置必须能直接加载
+func TestShippedConfigLoads(t *testing.T) {
+	cfg, err := config.LoadFromFile(filepath.Join("..", "configs", "config.yaml"), config.WithStrict(true))
+	require.NoError(t, err)
+	require.Zero(t, cfg.UI.IdleTimeout)
+}
+
+// 拼写错误的键被报告（带行号与建议），其余配置照常加载
+func TestUnknownConfigKeyIsReported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("agent:\n  maxsteps: 3\n  token_limit: 1000\n"), 0o644))
+
+	var keys []config.UnknownKey
+	cfg, err := config.LoadFromFile(path, config.WithUnknownKeyHandler(func(k config.UnknownKey) {
+		keys = append(keys, k)
+	}))
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, "agent.maxsteps", keys[0].Key)
+	require.Equal(t, 2, keys[0].Line)
+	require.Equal(t, "max_steps", keys[0].Suggestion)
+	require.Contains(t, keys[0].String(), `did you mean "max_steps"`)
+
+	require.Equal(t, config.DefaultConfig().Agent.MaxSteps, cfg.Agent.MaxSteps)
+	require.Equal(t, 1000, cfg.Agent.TokenLimit)
+}
+
+// 严格模式下未知的键是错误
+func TestUnknownConfigKeyStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("llm:\n  modle: x\nlogging: true\n"), 0o644))
+
+	_, err := config.LoadFromFile(path, config.WithStrict(true))
+	var unknown *config.UnknownKeysError
+	require.ErrorAs(t, err, &unknown)
+	require.Len(t, unknown.Keys, 2)
+	require.Equal(t, "llm.modle", unknown.Keys[0].Key)
+	require.Equal(t, "model", unknown.Keys[0].Suggestion)
+	require.Equal(t, "logging", unknown.Keys[1].Key)
+}
+
+// 类型错误不受未知键处理影响，仍然报错
+func TestConfigTypeErrorStillFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("agent:\n  max_steps: many\n"), 0o644))
+
+	_, err := config.LoadFromFile(path, config.WithUnknownKeyHandler(func(config.UnknownKey) {}))
+	require.Error(t, err)
+}
+
+// 工作空间优先级：--workspace > agent.workspace_dir > 当前目录
+func TestResolveWorkspacePrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PROJECTS", filepath.Join(home, "src"))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	flagDir := t.TempDir()
+
+	ws, err := wspath.ResolveWorkspace(flagDir, "~/ignored")
+	require.NoError(t, err)
+	require.Equal(t, flagDir, ws)
+
+	ws, err = wspath.ResolveWorkspace("", "~/work")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, "work"), ws)
+
+	ws, err = wspath.ResolveWorkspace("", "$PROJECTS/app")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, "src", "app"), ws)
+
+	ws, err = wspath.ResolveWorkspace("", "rel")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(wd, "rel"), ws)
+
+	ws, err = wspath.ResolveWorkspace("", "  ")
+	require.NoError(t, err)
+	require.Equal(t, wd, ws)
+
+	// 示例配置不设置默认工作空间，保持"当前目录"的行为
+	require.Empty(t, config.DefaultConfig().Agent.WorkspaceDir)
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+func TestCopyFileSingle(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0o755))
+	tool := tools.NewCopyFileTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"source": "run.sh", "destination": "scripts/new/run.sh"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, "Copied 1 file(s), 18 bytes from run.sh to scripts/new/run.sh", res.Content)
+	require.Equal(t, []tools.ChangedFile{{Path: "scripts/new/run.sh", Op: tools.ChangeCreated}}, res.Changes)
+
+	data, err := os.ReadFile(filepath.Join(ws, "scripts/new/run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho hi\n", string(data))
+	info, err := os.Stat(filepath.Join(ws, "scripts/new/run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	// 目录需要 recursive，workspace 之外的目标被拒绝
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "scripts", "destination": "copy"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "set recursive: true")
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "run.sh", "destination": "../escape.sh"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace")
+}
+
+func TestCopyFileRecursive(t *testing.T) {
+	ws := t.TempDir()
+	tpl := filepath.Join(ws, "template")
+	require.NoError(t, os.MkdirAll(filepath.Join(tpl, "cmd", "app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tpl, "go.mod"), []byte("module app\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tpl, "cmd", "app", "main.go"), []byte("package main\n"), 0o600))
+	tool := tools.NewCopyFileTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"source": "template", "destination": "svc", "recursive": true})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, "Copied 2 file(s), 24 bytes from template to svc", res.Content)
+	require.ElementsMatch(t, []tools.ChangedFile{
+		{Path: "svc/go.mod", Op: tools.ChangeCreated},
+		{Path: "svc/cmd/app/main.go", Op: tools.ChangeCreated},
+	}, res.Changes)
+
+	data, err := os.ReadFile(filepath.Join(ws, "svc", "cmd", "app", "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package main\n", string(data))
+	info, err := os.Stat(filepath.Join(ws, "svc", "cmd", "app", "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// 不能复制到自身内部
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "template", "destination": "template/nested", "recursive": true})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "inside it")
+}
+
+func TestCopyFileOverwriteProtection(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("new"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "b.txt"), []byte("old"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "src"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "src", "x.txt"), []byte("x"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "src", "y.txt"), []byte("y"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "dst"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "dst", "y.txt"), []byte("keep"), 0o644))
+	tool := tools.NewCopyFileTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"source": "a.txt", "destination": "b.txt"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "b.txt already exists; set overwrite: true")
+	data, _ := os.ReadFile(filepath.Join(ws, "b.txt"))
+	require.Equal(t, "old", string(data))
+
+	// 目录树中有冲突时什么都不复制
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "src", "destination": "dst", "recursive": true})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "dst/y.txt already exists")
+	require.NoFileExists(t, filepath.Join(ws, "dst", "x.txt"))
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "a.txt", "destination": "b.txt", "overwrite": true})
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, []tools.ChangedFile{{Path: "b.txt", Op: tools.ChangeModified}}, res.Changes)
+	data, _ = os.ReadFile(filepath.Join(ws, "b.txt"))
+	require.Equal(t, "new", string(data))
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"source": "src", "destination": "dst", "recursive": true, "overwrite": true})
+	require.True(t, res.Success, res.Error)
+	data, _ = os.ReadFile(filepath.Join(ws, "dst", "y.txt"))
+	require.Equal(t, "y", string(data))
+}
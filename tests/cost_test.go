@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/cost"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/tools"
+)
+
+// usageToolReply 调用 noop 工具并报告固定用量：1000 prompt + 500 completion tokens
+func usageToolReply() mockReply {
+	return mockReply{Body: completionBody("", []mockCall{{ID: "call_1", Name: "noop", Args: `{}`}}, map[string]any{
+		"usage": map[string]any{"prompt_tokens": 1000, "completion_tokens": 500, "total_tokens": 1500},
+	})}
+}
+
+// 价格 1 美元 / 百万 token：每次调用 $0.0015
+var unitPricing = cost.Pricing{InputPerMTok: 1, OutputPerMTok: 1}
+
+func newBudgetAgent(t *testing.T, m *mockLLM, opts ...agent.Option) *agent.Agent {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	opts = append([]agent.Option{
+		agent.WithPricing(unitPricing),
+		agent.WithCostBudget(cost.Budget{WarnUSD: 0.002, HardCapUSD: 0.003}),
+	}, opts...)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "noop"}}, 10, t.TempDir(), 100000, opts...)
+	require.NoError(t, err)
+	return ag
+}
+
+func TestCostBudgetStopsAtHardCap(t *testing.T) {
+	m := newMockLLM(t, usageToolReply())
+	ag := newBudgetAgent(t, m)
+
+	ag.AddUserMessage("loop forever")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, "Stopped: estimated session cost"), out)
+
+	// 第 2 次调用后累计 $0.003 达到上限，不再发起第 3 次
+	require.Len(t, m.Requests(), 2)
+	require.InDelta(t, 0.003, ag.CostTracker().Spent(), 1e-9)
+	prompt, completion := ag.CostTracker().Tokens()
+	require.Equal(t, 2000, prompt)
+	require.Equal(t, 1000, completion)
+
+	// 历史保持一致：每个工具调用都有结果
+	last := ag.History()[len(ag.History())-1]
+	require.Equal(t, "tool", last.Role)
+}
+
+func TestCostBudgetConfirmContinues(t *testing.T) {
+	m := newMockLLM(t, usageToolReply(), usageToolReply(), usageToolReply(), textReply("done"))
+
+	var asked []float64
+	ag := newBudgetAgent(t, m, agent.WithBudgetConfirm(func(spent, limit float64) bool {
+		asked = append(asked, limit)
+		return true
+	}))
+
+	ag.AddUserMessage("work")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+	require.Len(t, m.Requests(), 4)
+	require.Len(t, asked, 1)
+	require.InDelta(t, 0.003, asked[0], 1e-9)
+	require.InDelta(t, 0.006, ag.CostTracker().NextCap(), 1e-9)
+}
+
+func TestCostTrackerStatus(t *testing.T) {
+	tr := cost.NewTracker(cost.Budget{WarnUSD: 0.002, HardCapUSD: 0.004})
+
+	require.Equal(t, cost.StatusOK, tr.Add(1000, 500, unitPricing))
+	require.Equal(t, cost.StatusWarn, tr.Add(1000, 500, unitPricing))
+	require.Equal(t, cost.StatusOK, tr.Add(0, 0, unitPricing), "warn only once")
+	require.Equal(t, cost.StatusHardCap, tr.Add(1000, 0, unitPricing))
+	require.True(t, tr.Exceeded())
+
+	tr.Approve()
+	require.False(t, tr.Exceeded())
+
+	// 价格未知时只计 token
+	require.Equal(t, cost.StatusOK, tr.Add(100, 100, cost.Pricing{}))
+	require.Equal(t, 1, tr.Unpriced())
+}
+
+func TestModelPricing(t *testing.T) {
+	in, out, ok := llm.ModelPricing("gpt-4o-mini-2024-07-18")
+	require.True(t, ok)
+	require.Equal(t, 0.15, in)
+	require.Equal(t, 0.60, out)
+
+	_, _, ok = llm.ModelPricing("local-llama")
+	require.False(t, ok)
+}
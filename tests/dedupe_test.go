@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+// runRepeatedReads 让模型连续三次读取同一个文件（第三次带 offset），返回最终历史中的 tool 消息
+func runRepeatedReads(t *testing.T, dedupe bool) []schema.Message {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	var content strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&content, "line %d of a fairly large file\n", i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "big.txt"), []byte(content.String()), 0o644))
+
+	read := `{"path": "big.txt"}`
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "read_file", Args: read}),
+		toolReply("", mockCall{ID: "call_2", Name: "read_file", Args: read}),
+		toolReply("", mockCall{ID: "call_3", Name: "read_file", Args: `{"path": "big.txt", "offset": 2}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewReadTool(ws)}, 10, ws, 100000,
+		agent.WithDedupeToolResults(dedupe))
+	require.NoError(t, err)
+	ag.AddUserMessage("read big.txt")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	var results []schema.Message
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			results = append(results, msg)
+		}
+	}
+	require.Len(t, results, 3)
+	return results
+}
+
+func historyChars(msgs []schema.Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += len(m.Content)
+	}
+	return n
+}
+
+func TestRepeatedToolResultsAreDeduplicated(t *testing.T) {
+	results := runRepeatedReads(t, true)
+
+	// 较早的相同结果被替换为引用，最新的一份保持完整
+	require.Contains(t, results[0].Content, "Duplicate result elided")
+	require.Contains(t, results[0].Content, "big.txt")
+	require.NotContains(t, results[0].Content, "line 1 of")
+	require.Contains(t, results[1].Content, "line 1 of a fairly large file")
+	// 参数不同的调用不受影响
+	require.Contains(t, results[2].Content, "line 2 of a fairly large file")
+	require.NotContains(t, results[2].Content, "Duplicate")
+
+	full := runRepeatedReads(t, false)
+	require.Equal(t, full[0].Content, full[1].Content)
+	require.Less(t, historyChars(results), historyChars(full))
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+const goBuildOutput = `# gopilot-cli/internal/tools
+internal/tools/file.go:42:9: undefined: foo
+internal/tools/file.go:57:2: declared and not used: x
+internal/tools/file.go:42:9: undefined: foo
+`
+
+const goVetOutput = `# gopilot-cli/cmd/gopilot
+vet: cmd/gopilot/main.go:120:3: fmt.Printf format %d has arg name of wrong type string
+`
+
+const goTestOutput = `=== RUN   TestAdd
+    math_test.go:14: Add(1, 2) = 4, want 3
+--- FAIL: TestAdd (0.00s)
+=== RUN   TestSub
+--- PASS: TestSub (0.00s)
+--- FAIL: TestDiv (0.00s)
+    div_test.go:9: unexpected error: division by zero
+FAIL
+FAIL	example.com/calc	0.003s
+`
+
+func TestParseDiagnosticsGoBuild(t *testing.T) {
+	diags := tools.ParseDiagnostics(goBuildOutput)
+	require.Equal(t, []tools.Diagnostic{
+		{File: "internal/tools/file.go", Line: 42, Column: 9, Message: "undefined: foo"},
+		{File: "internal/tools/file.go", Line: 57, Column: 2, Message: "declared and not used: x"},
+	}, diags)
+	require.Equal(t, "internal/tools/file.go:42:9: undefined: foo", diags[0].String())
+}
+
+func TestParseDiagnosticsGoVet(t *testing.T) {
+	diags := tools.ParseDiagnostics(goVetOutput)
+	require.Len(t, diags, 1)
+	require.Equal(t, "cmd/gopilot/main.go", diags[0].File)
+	require.Equal(t, 120, diags[0].Line)
+	require.Equal(t, 3, diags[0].Column)
+	require.True(t, strings.HasPrefix(diags[0].Message, "fmt.Printf format"))
+}
+
+func TestParseDiagnosticsGoTest(t *testing.T) {
+	diags := tools.ParseDiagnostics(goTestOutput)
+	require.Equal(t, []tools.Diagnostic{
+		{File: "math_test.go", Line: 14, Message: "Add(1, 2) = 4, want 3", Test: "TestAdd"},
+		{File: "div_test.go", Line: 9, Message: "unexpected error: division by zero", Test: "TestDiv"},
+	}, diags)
+	require.Equal(t, "math_test.go:14: [TestAdd] Add(1, 2) = 4, want 3", diags[0].String())
+
+	require.Empty(t, tools.ParseDiagnostics("ok  \texample.com/calc\t0.002s\nPASS\n"))
+}
+
+func TestBashDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	cmd := "printf '%s' '" + goBuildOutput + "' >&2; exit 1"
+
+	// 默认关闭：不附加诊断
+	res, err := tools.NewBashTool().Execute(ctx, map[string]any{"command": cmd})
+	require.NoError(t, err)
+	require.Empty(t, res.Diagnostics)
+	require.NotContains(t, res.Content, "[diagnostics]")
+
+	res, err = tools.NewBashTool(tools.WithDiagnostics(true)).Execute(ctx, map[string]any{"command": cmd})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Len(t, res.Diagnostics, 2)
+	require.Contains(t, res.Content, "[diagnostics]: 2 issue(s)\n- internal/tools/file.go:42:9: undefined: foo")
+	// 原始输出保留
+	require.Equal(t, goBuildOutput, res.Stderr)
+	require.Contains(t, res.Content, "# gopilot-cli/internal/tools")
+}
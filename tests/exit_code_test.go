@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// runForExitCode 运行一次任务，返回按 maxFailureRatio 得到的退出码
+func runForExitCode(t *testing.T, maxSteps int, maxFailureRatio float64, replies ...mockReply) (agent.RunResult, int) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	m := newMockLLM(t, replies...)
+	ag, err := agent.NewAgent(m.client(), "sys",
+		[]tools.Tool{tools.NewReadTool(ws), tools.NewAskUserTool()}, maxSteps, ws, 100000)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("do it")
+	_, err = ag.Run(context.Background())
+	result := ag.LastResult()
+	return result, result.ExitCode(err, maxFailureRatio)
+}
+
+func TestStepLimitExitsNonZero(t *testing.T) {
+	missing := mockCall{ID: "call_1", Name: "read_file", Args: `{"path": "missing.txt"}`}
+	result, code := runForExitCode(t, 2, 0, toolReply("", missing), toolReply("", missing))
+
+	require.Equal(t, agent.OutcomeStepLimit, result.Outcome)
+	require.NotZero(t, code)
+	require.Equal(t, agent.ExitStepLimit, code)
+}
+
+func TestExitCodeForCompletedRun(t *testing.T) {
+	_, code := runForExitCode(t, 5, 0, textReply("done"))
+	require.Equal(t, agent.ExitOK, code)
+}
+
+func TestExitCodeForNeedsInput(t *testing.T) {
+	ask := mockCall{ID: "call_1", Name: "ask_user", Args: `{"question": "Which file?"}`}
+	result, code := runForExitCode(t, 5, 0, toolReply("", ask))
+	require.Equal(t, agent.OutcomeNeedsInput, result.Outcome)
+	require.Equal(t, agent.ExitNeedsInput, code)
+}
+
+func TestExitCodeForToolFailureDensity(t *testing.T) {
+	missing := mockCall{ID: "call_1", Name: "read_file", Args: `{"path": "missing.txt"}`}
+	replies := []mockReply{toolReply("", missing), toolReply("", missing), textReply("gave up")}
+
+	result, code := runForExitCode(t, 5, 0.5, replies...)
+	require.Equal(t, agent.OutcomeDone, result.Outcome)
+	require.Equal(t, 2, result.ToolCalls)
+	require.Equal(t, 2, result.ToolFailures)
+	require.Equal(t, agent.ExitToolFailures, code)
+
+	// 未设置阈值时只看结束原因
+	_, code = runForExitCode(t, 5, 0, replies...)
+	require.Equal(t, agent.ExitOK, code)
+}
+
+// TestOneShotExitCodeForStepLimit 以 --prompt 运行编译出的 gopilot：
+// 达到 max_steps 时 runOneShot 返回的退出码经 exitCodeError 成为进程的退出码
+func TestOneShotExitCodeForStepLimit(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "gopilot")
+	out, err := exec.Command("go", "build", "-o", bin, "../cmd/gopilot").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	missing := mockCall{ID: "call_1", Name: "read_file", Args: `{"path": "missing.txt"}`}
+	m := newMockLLM(t, toolReply("", missing))
+
+	ws := t.TempDir()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := fmt.Sprintf("llm:\n  api_key: test-key\n  api_base: %q\n  model: mock-model\n"+
+		"  retry:\n    enabled: false\nagent:\n  max_steps: 2\n", m.URL)
+	require.NoError(t, os.WriteFile(cfgPath, []byte(cfg), 0o644))
+
+	cmd := exec.Command(bin, "--config", cfgPath, "-w", ws, "-q", "-p", "read missing.txt")
+	cmd.Env = append(os.Environ(), "HOME="+t.TempDir())
+	out, err = cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr, string(out))
+	require.Equal(t, 2, exitErr.ExitCode(), string(out))
+	require.Equal(t, agent.ExitStepLimit, exitErr.ExitCode())
+	require.Len(t, m.Requests(), 2)
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// trackedFileTools read_file、write_file、edit_file 共享同一个 ReadTracker
+func trackedFileTools(ws string) (*tools.ReadTool, *tools.WriteTool, *tools.EditTool) {
+	tracker := tools.NewReadTracker()
+	return tools.NewReadTool(ws, tools.WithReadTracker(tracker)),
+		tools.NewWriteTool(ws, tools.WithWriteTracker(tracker)),
+		tools.NewEditTool(ws, tools.WithEditTracker(tracker))
+}
+
+// modifyExternally 模拟用户在编辑器中修改文件（修改时间明确向后移动，不依赖文件系统的时间精度）
+func modifyExternally(t *testing.T, file, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+}
+
+func TestWriteRefusesExternallyModifiedFile(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("one\n"), 0o644))
+	read, write, _ := trackedFileTools(ws)
+	ctx := context.Background()
+
+	res, err := read.Execute(ctx, map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	modifyExternally(t, file, "one\nuser line\n")
+
+	res, err = write.Execute(ctx, map[string]any{"path": "a.txt", "content": "agent\n"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "changed on disk since you last read")
+	data, _ := os.ReadFile(file)
+	require.Equal(t, "one\nuser line\n", string(data), "the external change must not be overwritten")
+
+	// 重新读取后可以写入
+	res, err = read.Execute(ctx, map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	require.Contains(t, res.Content, "user line")
+	res, err = write.Execute(ctx, map[string]any{"path": "a.txt", "content": "merged\n"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// 自己的写入更新记录，连续写入不受影响
+	res, err = write.Execute(ctx, map[string]any{"path": "a.txt", "content": "merged again\n"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+}
+
+func TestEditWarnsAboutExternallyModifiedFile(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "a.go")
+	require.NoError(t, os.WriteFile(file, []byte("package a\n\nconst X = 1\n"), 0o644))
+	read, _, edit := trackedFileTools(ws)
+	ctx := context.Background()
+
+	_, err := read.Execute(ctx, map[string]any{"path": "a.go"})
+	require.NoError(t, err)
+
+	modifyExternally(t, file, "package a\n\n// user comment\nconst X = 1\n")
+
+	res, err := edit.Execute(ctx, map[string]any{"path": "a.go", "old_str": "X = 1", "new_str": "X = 2"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Warning: a.go changed on disk")
+	data, _ := os.ReadFile(file)
+	require.Equal(t, "package a\n\n// user comment\nconst X = 2\n", string(data), "the edit keeps the external change")
+
+	// 编辑后记录已更新，下一次编辑不再提示
+	res, err = edit.Execute(ctx, map[string]any{"path": "a.go", "old_str": "X = 2", "new_str": "X = 3"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.NotContains(t, res.Content, "Warning")
+}
+
+func TestUntrackedFilesAreNotChecked(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("one\n"), 0o644))
+	ctx := context.Background()
+
+	// 未读过的文件照常覆盖
+	_, write, _ := trackedFileTools(ws)
+	res, err := write.Execute(ctx, map[string]any{"path": "a.txt", "content": "two\n"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// 未开启跟踪时不检查
+	read := tools.NewReadTool(ws)
+	_, err = read.Execute(ctx, map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	modifyExternally(t, file, "three\n")
+	res, err = tools.NewWriteTool(ws).Execute(ctx, map[string]any{"path": "a.txt", "content": "four\n"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+}
+
+// format_code 与 copy_file 自己的写入不算外部修改
+func TestFormatAndCopyUpdateTracker(t *testing.T) {
+	requireGofmt(t)
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte(misformattedGo), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "template.txt"), []byte("from template\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "b.txt"), []byte("old\n"), 0o644))
+	tracker := tools.NewReadTracker()
+	read := tools.NewReadTool(ws, tools.WithReadTracker(tracker))
+	write := tools.NewWriteTool(ws, tools.WithWriteTracker(tracker))
+	format := tools.NewFormatTool(ws, tools.WithFormatTracker(tracker))
+	cp := tools.NewCopyFileTool(ws, tools.WithCopyTracker(tracker))
+	ctx := context.Background()
+
+	run(t, read, map[string]any{"path": "main.go"})
+	res := run(t, format, map[string]any{"path": "main.go"})
+	require.Contains(t, res.Content, "Changed (1)")
+	run(t, write, map[string]any{"path": "main.go", "content": formattedGo + "// more\n"})
+
+	run(t, read, map[string]any{"path": "b.txt"})
+	run(t, cp, map[string]any{"source": "template.txt", "destination": "b.txt", "overwrite": true})
+	run(t, write, map[string]any{"path": "b.txt", "content": "agent\n"})
+
+	// 真正的外部修改仍被发现
+	modifyExternally(t, filepath.Join(ws, "b.txt"), "user\n")
+	res, err := write.Execute(ctx, map[string]any{"path": "b.txt", "content": "agent again\n"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func echoToolSpec() tools.ExternalToolSpec {
+	return tools.ExternalToolSpec{
+		Name:        "echo_args",
+		Description: "Echo the arguments back.",
+		Command:     []string{"cat"},
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"text": map[string]any{"type": "string"},
+			},
+			"required": []any{"text"},
+		},
+	}
+}
+
+func TestExternalToolEchoesArguments(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses cat")
+	}
+	tool, err := tools.NewExternalTool(t.TempDir(), echoToolSpec())
+	require.NoError(t, err)
+	require.Equal(t, "echo_args", tool.Name())
+	require.Equal(t, tools.DefaultExternalToolTimeout+10*time.Second, tool.TimeoutHint())
+
+	res, err := tool.Execute(context.Background(), map[string]any{"text": "hello"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(res.Content), &got))
+	require.Equal(t, map[string]any{"text": "hello"}, got)
+}
+
+func TestExternalToolFailure(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sh")
+	}
+	tool, err := tools.NewExternalTool(t.TempDir(), tools.ExternalToolSpec{
+		Name:    "fails",
+		Command: []string{"sh", "-c", "echo bad input >&2; exit 3"},
+	})
+	require.NoError(t, err)
+
+	res, err := tool.Execute(context.Background(), nil)
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Equal(t, 3, res.ExitCode)
+	require.Contains(t, res.Error, "exited with code 3")
+	require.Contains(t, res.Error, "bad input")
+}
+
+func TestExternalToolTimeout(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses sleep")
+	}
+	tool, err := tools.NewExternalTool(t.TempDir(), tools.ExternalToolSpec{
+		Name:    "slow",
+		Command: []string{"sleep", "5"},
+		Timeout: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := tool.Execute(context.Background(), nil)
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "timed out")
+	require.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestLoadExternalToolsRejectsInvalidSpecs(t *testing.T) {
+	ws := t.TempDir()
+
+	_, err := tools.LoadExternalTools(ws, []tools.ExternalToolSpec{echoToolSpec()}, []string{"echo_args"})
+	require.ErrorContains(t, err, "already exists")
+
+	_, err = tools.LoadExternalTools(ws, []tools.ExternalToolSpec{echoToolSpec(), echoToolSpec()}, nil)
+	require.ErrorContains(t, err, "already exists")
+
+	_, err = tools.LoadExternalTools(ws, []tools.ExternalToolSpec{{Name: "no_command"}}, nil)
+	require.ErrorContains(t, err, "command is required")
+
+	bad := echoToolSpec()
+	bad.Parameters = map[string]any{"type": "string"}
+	_, err = tools.LoadExternalTools(ws, []tools.ExternalToolSpec{bad}, nil)
+	require.Error(t, err)
+}
+
+func TestAgentRunsExternalTool(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses cat")
+	}
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	external, err := tools.LoadExternalTools(ws, []tools.ExternalToolSpec{echoToolSpec()}, nil)
+	require.NoError(t, err)
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "echo_args", Args: `{"text":"ping"}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", external, 5, ws, 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("echo something")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	msgs := reqs[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	require.Equal(t, "tool", last["role"])
+	require.Contains(t, last["content"], `"text":"ping"`)
+}
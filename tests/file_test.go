@@ -0,0 +1,501 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// =======================================
+// WriteTool: 新建 / 覆盖摘要
+// =======================================
+
+func TestWriteToolCreatedNote(t *testing.T) {
+	ws := t.TempDir()
+	w := tools.NewWriteTool(ws)
+
+	res, err := w.Execute(context.Background(), map[string]any{
+		"path":    "new.txt",
+		"content": "hello\n",
+	})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Created new file (6 bytes)")
+}
+
+func TestWriteToolOverwriteSummary(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("one\ntwo\nthree\n"), 0o644))
+
+	w := tools.NewWriteTool(ws)
+	res, err := w.Execute(context.Background(), map[string]any{
+		"path":    "a.txt",
+		"content": "one\nTWO\nthree\nfour\n",
+	})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	require.Contains(t, res.Content, "Replaced existing file (14 bytes -> 19 bytes)")
+	require.Contains(t, res.Content, "--- a/a.txt")
+	require.Contains(t, res.Content, "-two")
+	require.Contains(t, res.Content, "+TWO")
+	require.Contains(t, res.Content, "+four")
+
+	// 写入语义不变
+	data, _ := os.ReadFile(file)
+	require.Equal(t, "one\nTWO\nthree\nfour\n", string(data))
+}
+
+func TestWriteToolOverwriteLargeOmitsDiff(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "big.txt")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Repeat("old line\n", 500)), 0o644))
+
+	w := tools.NewWriteTool(ws)
+	res, _ := w.Execute(context.Background(), map[string]any{
+		"path":    "big.txt",
+		"content": strings.Repeat("new line\n", 500),
+	})
+	require.True(t, res.Success)
+	require.Contains(t, res.Content, "diff omitted")
+}
+
+func TestWriteToolOverwriteUnchanged(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "same.txt"), []byte("x"), 0o644))
+
+	res, _ := tools.NewWriteTool(ws).Execute(context.Background(), map[string]any{
+		"path":    "same.txt",
+		"content": "x",
+	})
+	require.True(t, res.Success)
+	require.Contains(t, res.Content, "Content unchanged")
+}
+
+// =======================================
+// WriteTool: 末尾换行与编码
+// =======================================
+
+// writeWith 用给定选项写入 content，返回文件字节
+func writeWith(t *testing.T, content string, opts ...tools.WriteOption) []byte {
+	t.Helper()
+	ws := t.TempDir()
+	res, err := tools.NewWriteTool(ws, opts...).Execute(context.Background(), map[string]any{
+		"path":    "out.txt",
+		"content": content,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	data, err := os.ReadFile(filepath.Join(ws, "out.txt"))
+	require.NoError(t, err)
+	return data
+}
+
+func TestWriteToolNewlinePolicies(t *testing.T) {
+	cases := []struct {
+		policy  tools.NewlinePolicy
+		content string
+		want    string
+	}{
+		{tools.NewlineLeave, "a\nb", "a\nb"},
+		{tools.NewlineLeave, "a\nb\n\n", "a\nb\n\n"},
+		{tools.NewlineEnsure, "a\nb", "a\nb\n"},
+		{tools.NewlineEnsure, "a\nb\n", "a\nb\n"},
+		{tools.NewlineEnsure, "a\r\nb", "a\r\nb\r\n"},
+		{tools.NewlineEnsure, "", ""},
+		{tools.NewlineStrip, "a\nb\n\n", "a\nb"},
+		{tools.NewlineStrip, "a\r\nb\r\n", "a\r\nb"},
+		{tools.NewlineStrip, "a\nb", "a\nb"},
+	}
+	for _, c := range cases {
+		got := writeWith(t, c.content, tools.WithNewlinePolicy(c.policy))
+		require.Equal(t, c.want, string(got), "policy %s, content %q", c.policy, c.content)
+	}
+
+	// 默认等同于 leave
+	require.Equal(t, "x", string(writeWith(t, "x")))
+
+	p, err := tools.ParseNewlinePolicy("")
+	require.NoError(t, err)
+	require.Equal(t, tools.NewlineLeave, p)
+	_, err = tools.ParseNewlinePolicy("sometimes")
+	require.Error(t, err)
+}
+
+func TestWriteToolEncodings(t *testing.T) {
+	// 默认 utf-8 原样写入（包括内容自带的 BOM）
+	require.Equal(t, "\ufeffhi", string(writeWith(t, "\ufeffhi")))
+
+	// utf-8-bom 只写一个 BOM
+	require.Equal(t, "\ufeffhé", string(writeWith(t, "hé", tools.WithEncoding(tools.EncodingUTF8BOM))))
+	require.Equal(t, "\ufeffhé", string(writeWith(t, "\ufeffhé", tools.WithEncoding(tools.EncodingUTF8BOM))))
+
+	require.Equal(t, []byte{0xFF, 0xFE, 'h', 0, 0xE9, 0}, writeWith(t, "hé", tools.WithEncoding(tools.EncodingUTF16LE)))
+	require.Equal(t, []byte{0xFE, 0xFF, 0, 'h', 0, 0xE9}, writeWith(t, "hé", tools.WithEncoding(tools.EncodingUTF16BE)))
+
+	// 换行策略先于编码生效
+	require.Equal(t, []byte{0xFF, 0xFE, 'a', 0, '\n', 0},
+		writeWith(t, "a", tools.WithEncoding(tools.EncodingUTF16LE), tools.WithNewlinePolicy(tools.NewlineEnsure)))
+
+	_, err := tools.ParseEncoding("latin-1")
+	require.Error(t, err)
+}
+
+// UTF-16 覆盖写入时按解码后的文本生成变更摘要
+func TestWriteToolUTF16OverwriteSummary(t *testing.T) {
+	ws := t.TempDir()
+	w := tools.NewWriteTool(ws, tools.WithEncoding(tools.EncodingUTF16LE))
+	for _, content := range []string{"one\ntwo\n", "one\nTWO\n"} {
+		res, err := w.Execute(context.Background(), map[string]any{"path": "u.txt", "content": content})
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		if content == "one\nTWO\n" {
+			require.Contains(t, res.Content, "-two")
+			require.Contains(t, res.Content, "+TWO")
+		}
+	}
+}
+
+// =======================================
+// EditTool: 无变化的替换
+// =======================================
+
+func TestEditToolRejectsNoOp(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "same.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello world\n"), 0o644))
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(file, past, past))
+
+	res, err := tools.NewEditTool(ws).Execute(context.Background(), map[string]any{
+		"path":    "same.txt",
+		"old_str": "hello",
+		"new_str": "hello",
+	})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Equal(t, "old_str and new_str are identical; no change made", res.Error)
+	require.Empty(t, res.Changes)
+
+	// 文件没有被重写
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	require.True(t, info.ModTime().Equal(past), "file was rewritten: mtime %v", info.ModTime())
+}
+
+// =======================================
+// EditTool: old_str 带有 read_file 的行号前缀
+// =======================================
+
+func TestEditToolHintsAtLineNumberPrefix(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "main.go")
+	src := "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"
+	require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+
+	// 从 read_file 的输出中原样复制的行
+	read, err := tools.NewReadTool(ws).Execute(context.Background(), map[string]any{"path": "main.go"})
+	require.NoError(t, err)
+	var copied []string
+	for _, line := range strings.Split(read.Content, "\n") {
+		if strings.Contains(line, "func main") || strings.Contains(line, "println") {
+			copied = append(copied, line)
+		}
+	}
+	require.Len(t, copied, 2)
+
+	edit := tools.NewEditTool(ws)
+	res, err := edit.Execute(context.Background(), map[string]any{
+		"path":    "main.go",
+		"old_str": strings.Join(copied, "\n"),
+		"new_str": "func main() {\n\tprintln(\"bye\")",
+	})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "line-number prefixes")
+	require.Contains(t, res.Error, "without them, old_str matches the file")
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, src, string(data), "prefixed old_str must not be applied")
+
+	// 普通的未匹配不附带提示
+	res, err = edit.Execute(context.Background(), map[string]any{
+		"path":    "main.go",
+		"old_str": "func other() {",
+		"new_str": "func other2() {",
+	})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.NotContains(t, res.Error, "Hint")
+}
+
+// =======================================
+// 额外的只读根目录
+// =======================================
+
+// makeMonorepo 构造 repo/{app（workspace）, shared/util.go, secret/key.txt}
+func makeMonorepo(t *testing.T) (ws, shared string) {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range map[string]string{
+		"app/main.go":    "package main\n",
+		"shared/util.go": "package shared\n",
+		"secret/key.txt": "hunter2\n",
+	} {
+		p := filepath.Join(repo, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return filepath.Join(repo, "app"), filepath.Join(repo, "shared")
+}
+
+func TestExtraReadRootAllowed(t *testing.T) {
+	ws, shared := makeMonorepo(t)
+	ctx := context.Background()
+	read := tools.NewReadTool(ws, tools.WithExtraReadRoots("../shared"))
+
+	for _, path := range []string{"../shared/util.go", filepath.Join(shared, "util.go")} {
+		res, err := read.Execute(ctx, map[string]any{"path": path})
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Contains(t, res.Content, "package shared")
+	}
+
+	res, _ := tools.NewListDirTool(ws, tools.WithExtraReadRoots(shared)).Execute(ctx, map[string]any{"path": "../shared"})
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "util.go")
+
+	// workspace 内照常可读
+	res, _ = read.Execute(ctx, map[string]any{"path": "main.go"})
+	require.True(t, res.Success, res.Error)
+}
+
+func TestPathOutsideReadRootsRejected(t *testing.T) {
+	ws, _ := makeMonorepo(t)
+	ctx := context.Background()
+
+	// 未配置额外根目录时，同级目录不可读
+	res, _ := tools.NewReadTool(ws).Execute(ctx, map[string]any{"path": "../shared/util.go"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace")
+
+	// 配置了 shared 也不能读其他同级目录
+	read := tools.NewReadTool(ws, tools.WithExtraReadRoots("../shared"))
+	res, _ = read.Execute(ctx, map[string]any{"path": "../secret/key.txt"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace and the allowed read roots")
+	require.NotContains(t, res.Content, "hunter2")
+
+	res, _ = tools.NewListDirTool(ws, tools.WithExtraReadRoots("../shared")).Execute(ctx, map[string]any{"path": "../secret"})
+	require.False(t, res.Success)
+
+	// 写入始终只限 workspace
+	res, _ = tools.NewWriteTool(ws).Execute(ctx, map[string]any{"path": "../shared/new.go", "content": "x"})
+	require.False(t, res.Success)
+	require.NoFileExists(t, filepath.Join(filepath.Dir(ws), "shared", "new.go"))
+}
+
+// =======================================
+// ReadTool: 分页读取
+// =======================================
+
+var nextOffsetRe = regexp.MustCompile(`Use offset=(\d+) to continue reading`)
+
+// 分页读取数千行的文件：每页在行边界截断并给出下一个 offset，逐页读完不重不漏
+func TestReadToolPagesThroughLargeFile(t *testing.T) {
+	ws := t.TempDir()
+	const total = 6000
+	var sb strings.Builder
+	for i := 1; i <= total; i++ {
+		fmt.Fprintf(&sb, "line %d: the quick brown fox jumps over the lazy dog\n", i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "big.txt"), []byte(sb.String()), 0o644))
+
+	tool := tools.NewReadTool(ws)
+	lineRe := regexp.MustCompile(`(?m)^\s*(\d+)\|line (\d+):`)
+
+	seen := 0
+	offset := 1
+	pages := 0
+	for {
+		res, err := tool.Execute(context.Background(), map[string]any{"path": "big.txt", "offset": float64(offset)})
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		pages++
+
+		for _, m := range lineRe.FindAllStringSubmatch(res.Content, -1) {
+			seen++
+			require.Equal(t, strconv.Itoa(seen), m[1])
+			require.Equal(t, m[1], m[2])
+		}
+
+		next := nextOffsetRe.FindStringSubmatch(res.Content)
+		if next == nil {
+			break
+		}
+		offset, _ = strconv.Atoi(next[1])
+		require.Equal(t, seen+1, offset)
+		require.Less(t, pages, 20)
+	}
+
+	require.Equal(t, total, seen)
+	require.Greater(t, pages, 1)
+}
+
+func TestReadToolOffsetLimitFromJSON(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "f.txt"), []byte("a\nb\nc\nd\ne\n"), 0o644))
+	tool := tools.NewReadTool(ws)
+
+	// JSON 数字为 float64
+	res, err := tool.Execute(context.Background(), map[string]any{"path": "f.txt", "offset": float64(2), "limit": float64(2)})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "     2|b\n     3|c")
+	require.NotContains(t, res.Content, "|a")
+	require.NotContains(t, res.Content, "|d")
+	require.Contains(t, res.Content, "Showing lines 2-3 of 5. Use offset=4")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "f.txt", "offset": float64(4)})
+	require.True(t, res.Success)
+	require.Contains(t, res.Content, "     5|e")
+	require.NotContains(t, res.Content, "offset=")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "f.txt", "offset": float64(9)})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "beyond end of file (5 lines)")
+}
+
+func TestReadToolContextPattern(t *testing.T) {
+	ws := t.TempDir()
+	var b strings.Builder
+	for i := 1; i <= 30; i++ {
+		switch i {
+		case 5, 8, 20:
+			fmt.Fprintf(&b, "func target%d() {}\n", i)
+		default:
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "big.go"), []byte(b.String()), 0o644))
+	tool := tools.NewReadTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{
+		"path": "big.go", "context_pattern": `func target\d+`, "context_lines": float64(2),
+	})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// 5 与 8 的窗口（3-7、6-10）重叠合并为一段，20 单独一段，前后与中间用省略标记
+	require.Equal(t, strings.Join([]string{
+		`3 matching lines for "func target\\d+" in big.go (30 lines); matches are marked with ":".`,
+		"   ...  (2 lines omitted)",
+		"     3|line 3",
+		"     4|line 4",
+		"     5:func target5() {}",
+		"     6|line 6",
+		"     7|line 7",
+		"     8:func target8() {}",
+		"     9|line 9",
+		"    10|line 10",
+		"   ...  (7 lines omitted)",
+		"    18|line 18",
+		"    19|line 19",
+		"    20:func target20() {}",
+		"    21|line 21",
+		"    22|line 22",
+		"   ...  (8 lines omitted)",
+	}, "\n"), res.Content)
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "big.go", "context_pattern": "nothing here"})
+	require.True(t, res.Success)
+	require.Contains(t, res.Content, "No lines in big.go match")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "big.go", "context_pattern": "("})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "invalid context_pattern")
+}
+
+func TestReadToolLongSingleLine(t *testing.T) {
+	ws := t.TempDir()
+	line := strings.Repeat("0123456789", 10_000) // 100KB 单行
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "min.js"), []byte(line+"\nnext\n"), 0o644))
+	tool := tools.NewReadTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"path": "min.js"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// 输出有界，软换行为带段号的短行
+	require.Less(t, len(res.Content), 25_000)
+	shown := strings.Split(res.Content, "\n")
+	require.True(t, strings.HasPrefix(shown[0], "   1.1|0123"), shown[0])
+	require.True(t, strings.HasPrefix(shown[1], "   1.2|0123"), shown[1])
+	for _, l := range shown {
+		require.LessOrEqual(t, len(l), 2010)
+	}
+	require.Contains(t, res.Content, "Line 1 has 100000 characters; showing characters 1-20000. Use offset=1 and column=20001")
+	require.NotContains(t, res.Content, "next")
+
+	// 用 column 续读到行尾后继续显示后面的行
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "min.js", "offset": float64(1), "column": float64(80001)})
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "  1.41|0123")
+	require.Contains(t, res.Content, "     2|next")
+	require.NotContains(t, res.Content, "column=")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "min.js", "column": float64(100001)})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "beyond end of line 1")
+}
+
+// =======================================
+// 缺失或类型错误的必需参数
+// =======================================
+
+func TestFileToolsRejectMissingOrInvalidArgs(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("hello\n"), 0o644))
+
+	cases := []struct {
+		name string
+		tool tools.Tool
+		args map[string]any
+		want string
+	}{
+		{"read missing path", tools.NewReadTool(ws), map[string]any{}, "missing required argument 'path'"},
+		{"read null path", tools.NewReadTool(ws), map[string]any{"path": nil}, "missing required argument 'path'"},
+		{"read numeric path", tools.NewReadTool(ws), map[string]any{"path": float64(3)}, "invalid required argument 'path': expected a string, got number"},
+		{"write missing path", tools.NewWriteTool(ws), map[string]any{"content": "x"}, "missing required argument 'path'"},
+		{"write missing content", tools.NewWriteTool(ws), map[string]any{"path": "a.txt"}, "missing required argument 'content'"},
+		{"write object content", tools.NewWriteTool(ws), map[string]any{"path": "a.txt", "content": map[string]any{"x": 1}}, "invalid required argument 'content': expected a string, got object"},
+		{"edit array path", tools.NewEditTool(ws), map[string]any{"path": []any{"a.txt"}, "old_str": "hello", "new_str": "bye"}, "invalid required argument 'path': expected a string, got array"},
+		{"edit missing old_str", tools.NewEditTool(ws), map[string]any{"path": "a.txt", "new_str": "bye"}, "missing required argument 'old_str'"},
+		{"edit boolean new_str", tools.NewEditTool(ws), map[string]any{"path": "a.txt", "old_str": "hello", "new_str": true}, "invalid required argument 'new_str': expected a string, got boolean"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.tool.Execute(context.Background(), tc.args)
+			require.NoError(t, err)
+			require.False(t, res.Success)
+			require.Equal(t, tc.want, res.Error)
+		})
+	}
+
+	// 失败的调用不修改文件
+	data, err := os.ReadFile(filepath.Join(ws, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", string(data))
+}
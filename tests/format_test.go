@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+const (
+	misformattedGo = "package main\nimport \"fmt\"\nfunc main(){\nfmt.Println( \"hi\" )\n}\n"
+	formattedGo    = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"
+)
+
+func requireGofmt(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt is not installed")
+	}
+}
+
+func TestFormatCodeFormatsMisformattedGo(t *testing.T) {
+	requireGofmt(t)
+	ws := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "pkg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte(misformattedGo), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "pkg", "ok.go"), []byte("package pkg\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "notes.txt"), []byte("left  alone\n"), 0o644))
+
+	tool := tools.NewFormatTool(ws)
+	require.True(t, tool.Available())
+
+	res, err := tool.Execute(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Formatted 2 file(s)")
+	require.Contains(t, res.Content, "Changed (1):\n  main.go")
+	require.Equal(t, []tools.ChangedFile{{Path: "main.go", Op: tools.ChangeModified}}, res.Changes)
+
+	data, err := os.ReadFile(filepath.Join(ws, "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, formattedGo, string(data))
+
+	// 已格式化时不再报告变化
+	res, err = tool.Execute(context.Background(), map[string]any{"path": "main.go"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "No files changed.")
+	require.Empty(t, res.Changes)
+}
+
+func TestFormatCodeRejectsBadInput(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "notes.txt"), []byte("x\n"), 0o644))
+	tool := tools.NewFormatTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"path": "../outside.go"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace")
+
+	res, err = tool.Execute(context.Background(), map[string]any{"path": "notes.txt"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "no formatter configured for notes.txt")
+}
+
+func TestFormatCodeCustomFormatters(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.py"), []byte("x=1\n"), 0o644))
+
+	// 不可用的格式化命令：报告跳过，不改动文件
+	tool := tools.NewFormatTool(ws, tools.WithFormatters(map[string][]string{
+		"go": {}, // 关闭默认的 gofmt
+		"py": {"gopilot-no-such-formatter"},
+	}))
+	require.Equal(t, []string{".py"}, tool.Extensions())
+	require.False(t, tool.Available())
+	res, err := tool.Execute(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "gopilot-no-such-formatter is not installed")
+
+	if isWindows() {
+		return
+	}
+	// 超时的格式化命令
+	tool = tools.NewFormatTool(ws,
+		tools.WithFormatters(map[string][]string{".py": {"sh", "-c", "sleep 5", "sh"}}),
+		tools.WithFormatTimeout(100*time.Millisecond),
+	)
+	start := time.Now()
+	res, err = tool.Execute(context.Background(), map[string]any{"path": "a.py"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "timed out after 100ms")
+	require.Less(t, time.Since(start), 3*time.Second)
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// initGitRepo 在临时目录初始化仓库并提交 files
+func initGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ws := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(ws, name), []byte(content), 0o644))
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", ws}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return ws
+}
+
+func TestGitDiffStagedAndUnstaged(t *testing.T) {
+	ws := initGitRepo(t, map[string]string{"a.txt": "one\n", "b.txt": "bee\n"})
+	diff := tools.NewGitDiffTool(ws)
+	ctx := context.Background()
+
+	res, err := diff.Execute(ctx, map[string]any{})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, "No uncommitted changes.", res.Content)
+
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("one\ntwo\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "b.txt"), []byte("BEE\n"), 0o644))
+	out, err := exec.Command("git", "-C", ws, "add", "b.txt").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	res, err = diff.Execute(ctx, map[string]any{})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Staged changes (git diff --cached):")
+	require.Contains(t, res.Content, "+BEE")
+	require.Contains(t, res.Content, "Unstaged changes (git diff):")
+	require.Contains(t, res.Content, "+two")
+
+	// 限定路径
+	res, err = diff.Execute(ctx, map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "+two")
+	require.NotContains(t, res.Content, "BEE")
+
+	res, _ = diff.Execute(ctx, map[string]any{"path": "../outside"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace")
+}
+
+func TestGitDiffNotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ws := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(ws))
+
+	res, err := tools.NewGitDiffTool(ws).Execute(context.Background(), map[string]any{})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "is not a git repository")
+}
+
+func TestGitShowReadsCommittedVersion(t *testing.T) {
+	ws := initGitRepo(t, map[string]string{"a.txt": "one\n"})
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("two\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "sub", "b.txt"), []byte("bee\n"), 0o644))
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "second"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", ws}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("three\n"), 0o644))
+
+	show := tools.NewGitShowTool(ws)
+	ctx := context.Background()
+
+	// 默认 HEAD：已提交的版本，而不是工作区中的修改
+	res, err := show.Execute(ctx, map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Regexp(t, `^a\.txt at HEAD \([0-9a-f]+\):\n\ntwo\n$`, res.Content)
+
+	res, err = show.Execute(ctx, map[string]any{"path": "a.txt", "revision": "HEAD~1"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "\n\none\n")
+
+	res, _ = show.Execute(ctx, map[string]any{"path": "sub/b.txt", "revision": "HEAD"})
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "bee")
+
+	// 上一个版本中还不存在的文件、目录、未知版本
+	res, _ = show.Execute(ctx, map[string]any{"path": "sub/b.txt", "revision": "HEAD~1"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "sub/b.txt does not exist at HEAD~1")
+
+	res, _ = show.Execute(ctx, map[string]any{"path": "sub"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "is a directory at HEAD")
+
+	res, _ = show.Execute(ctx, map[string]any{"path": "a.txt", "revision": "no-such-branch"})
+	require.False(t, res.Success)
+	require.Equal(t, `unknown revision "no-such-branch" in the workspace repository`, res.Error)
+
+	res, _ = show.Execute(ctx, map[string]any{"path": "a.txt", "revision": "--output=/tmp/x"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "invalid revision")
+
+	res, _ = show.Execute(ctx, map[string]any{"path": "../outside"})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "outside the workspace")
+
+	res, _ = show.Execute(ctx, map[string]any{})
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "missing required argument 'path'")
+}
+
+func TestGitShowNotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ws := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(ws))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("one\n"), 0o644))
+
+	res, err := tools.NewGitShowTool(ws).Execute(context.Background(), map[string]any{"path": "a.txt"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "is not a git repository")
+}
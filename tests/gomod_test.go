@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+func newGoModule(t *testing.T, gomod string) string {
+	t.Helper()
+	if !tools.GoAvailable() {
+		t.Skip("go is not installed")
+	}
+	// 不访问网络，也不受外部 GOFLAGS 影响
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOTOOLCHAIN", "local")
+
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "go.mod"), []byte(gomod), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	return ws
+}
+
+func TestGoModTidyReportsChanges(t *testing.T) {
+	// 缺少 go 指令：tidy 会补上
+	ws := newGoModule(t, "module example.com/demo\n")
+	tool := tools.NewGoModTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"action": "tidy"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Ran: go mod tidy")
+	require.Contains(t, res.Content, "go.mod: modified")
+	require.Contains(t, res.Content, "go.sum: unchanged")
+	require.Equal(t, []tools.ChangedFile{{Path: "go.mod", Op: tools.ChangeModified}}, res.Changes)
+
+	// 再次执行没有变化
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "tidy"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "go.mod: unchanged")
+	require.Empty(t, res.Changes)
+}
+
+func TestGoModGetFailureAndValidation(t *testing.T) {
+	ws := newGoModule(t, "module example.com/demo\n\ngo 1.21\n")
+	tool := tools.NewGoModTool(ws)
+	orig, err := os.ReadFile(filepath.Join(ws, "go.mod"))
+	require.NoError(t, err)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"action": "get", "package": "example.com/missing@v1.0.0"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "go get example.com/missing@v1.0.0 failed")
+	require.Contains(t, res.Error, "go.mod: unchanged")
+	require.Contains(t, res.Error, "GOPROXY=off")
+	data, err := os.ReadFile(filepath.Join(ws, "go.mod"))
+	require.NoError(t, err)
+	require.Equal(t, string(orig), string(data))
+
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "get"})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "package is required")
+
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "get", "package": "-u"})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "invalid package")
+
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "vendor"})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "unknown action")
+
+	require.NoError(t, os.Mkdir(filepath.Join(ws, "sub"), 0o755))
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "tidy", "path": "sub"})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "no go.mod in sub")
+}
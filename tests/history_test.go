@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/history"
+	"gopilot-cli/internal/agent/summarizer"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+func TestHistoryValidate(t *testing.T) {
+	sys := schema.Message{Role: "system", Content: "sys"}
+	user := schema.Message{Role: "user", Content: "hi"}
+
+	require.NoError(t, history.Validate([]schema.Message{sys, user}))
+	require.Error(t, history.Validate(nil))
+	require.Error(t, history.Validate([]schema.Message{user, sys}))
+	require.Error(t, history.Validate([]schema.Message{sys, user, sys}))
+}
+
+func TestHistoryPinSystem(t *testing.T) {
+	sys := schema.Message{Role: "system", Content: "sys"}
+	msgs := []schema.Message{
+		{Role: "user", Content: "a"},
+		{Role: "system", Content: "stray"},
+		{Role: "assistant", Content: "b"},
+	}
+
+	out := history.PinSystem(msgs, sys)
+	require.NoError(t, history.Validate(out))
+	require.Equal(t, sys, out[0])
+	require.Len(t, out, 3)
+	require.Equal(t, "stray", msgs[1].Content, "input must not be modified")
+}
+
+func TestCompactKeepsUserTurns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t,
+		toolReply("looking", mockCall{ID: "call_1", Name: "probe", Args: `{}`}),
+		textReply("first answer"),
+		textReply("second answer"),
+		textReply("after compact"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "probe"}}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	for _, task := range []string{"first task", "second task"} {
+		ag.AddUserMessage(task)
+		_, err = ag.Run(context.Background())
+		require.NoError(t, err)
+	}
+	// system, user, assistant(tool_calls), tool, assistant, user, assistant
+	require.Len(t, ag.History(), 7)
+
+	require.Equal(t, 4, ag.CompactToUserTurns())
+	out := ag.History()
+	require.NoError(t, history.Validate(out))
+	var roles, contents []string
+	for _, msg := range out {
+		roles = append(roles, msg.Role)
+		contents = append(contents, msg.Content)
+		require.Empty(t, msg.ToolCalls)
+	}
+	require.Equal(t, []string{"system", "user", "user"}, roles)
+	require.Equal(t, "first task", contents[1])
+	require.Equal(t, "second task", contents[2])
+
+	// 之后的请求不再携带孤立的工具调用或工具结果
+	ag.AddUserMessage("third task")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	reqs := m.Requests()
+	sent := sentMessages(reqs[len(reqs)-1])
+	require.Len(t, sent, 4)
+	for _, msg := range sent[1:] {
+		require.Equal(t, "user", msg[0])
+	}
+}
+
+// 摘要（唯一的裁剪路径）之后系统提示仍原样位于首位
+func TestSummarizerKeepsSystemPrompt(t *testing.T) {
+	m := newMockLLM(t, textReply("summary of round"))
+	sys := schema.Message{Role: "system", Content: "You are pinned. " + strings.Repeat("rules ", 50)}
+
+	msgs := []schema.Message{sys}
+	for i := 0; i < 3; i++ {
+		msgs = append(msgs,
+			schema.Message{Role: "user", Content: "task"},
+			schema.Message{Role: "assistant", Content: strings.Repeat("work ", 200)},
+			schema.Message{Role: "tool", Content: strings.Repeat("output ", 200), ToolCallID: "c"},
+		)
+	}
+
+	s := summarizer.NewSummarizer(m.client(), 100)
+	out, err := s.SummarizeMessages(context.Background(), msgs)
+	require.NoError(t, err)
+	require.Less(t, len(out), len(msgs))
+	require.NoError(t, history.Validate(out))
+	require.Equal(t, sys, out[0])
+
+	// 系统提示不在首位时拒绝摘要，原样返回
+	broken := append([]schema.Message{{Role: "user", Content: "x"}}, msgs...)
+	out, err = s.SummarizeMessages(context.Background(), broken)
+	require.Error(t, err)
+	require.Equal(t, broken, out)
+}
+
+// 开启保留后，代码块原样出现在摘要中，发给模型的文本只含占位符
+func TestSummarizerPreservesCodeBlocks(t *testing.T) {
+	m := newMockLLM(t, textReply("Agent wrote the handler ([code block 1]) and ran the tests."))
+	code := "```go\nfunc Handler(w http.ResponseWriter, r *http.Request) {\n\tw.WriteHeader(204)\n}\n```"
+
+	msgs := []schema.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "add a handler"},
+		{
+			Role:    "assistant",
+			Content: "Writing the handler:\n" + code + "\n" + strings.Repeat("prose ", 200),
+			ToolCalls: []schema.ToolCall{{
+				ID: "c1", Type: "function",
+				Function: schema.FunctionCall{Name: "write_file", Arguments: map[string]any{"path": "api/handler.go"}},
+			}},
+		},
+		{Role: "tool", Content: strings.Repeat("ok ", 200), ToolCallID: "c1"},
+	}
+
+	s := summarizer.NewSummarizer(m.client(), 100, summarizer.WithPreserveCode(true))
+	out, err := s.SummarizeMessages(context.Background(), msgs)
+	require.NoError(t, err)
+	require.Len(t, out, 3)
+
+	summary := out[2].Content
+	require.Contains(t, summary, "Agent wrote the handler")
+	require.Contains(t, summary, "[code block 1]\n"+code)
+	require.Contains(t, summary, "Files: api/handler.go")
+
+	// 模型只看到占位符
+	sent := m.Requests()[0]["messages"].([]any)[1].(map[string]any)["content"].(string)
+	require.NotContains(t, sent, "WriteHeader")
+	require.Contains(t, sent, "[code block 1]")
+
+	// 默认不保留
+	m2 := newMockLLM(t, textReply("plain summary"))
+	out, err = summarizer.NewSummarizer(m2.client(), 100).SummarizeMessages(context.Background(), msgs)
+	require.NoError(t, err)
+	require.Equal(t, "[Execution Summary]\n\nplain summary", out[2].Content)
+}
+
+// 超长的工具输出在送入摘要提示前截断，保留开头与结尾
+func TestSummarizerTruncatesLongMessages(t *testing.T) {
+	output := "BEGIN " + strings.Repeat("x", 5000) + " END"
+	msgs := []schema.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "run the build"},
+		{Role: "assistant", ToolCalls: []schema.ToolCall{{
+			ID: "c1", Type: "function", Function: schema.FunctionCall{Name: "bash", Arguments: map[string]any{"command": "make"}},
+		}}},
+		{Role: "tool", Content: output, ToolCallID: "c1"},
+	}
+
+	m := newMockLLM(t, textReply("built"))
+	_, err := summarizer.NewSummarizer(m.client(), 100, summarizer.WithMaxMessageChars(300)).
+		SummarizeMessages(context.Background(), msgs)
+	require.NoError(t, err)
+
+	sent := m.Requests()[0]["messages"].([]any)[1].(map[string]any)["content"].(string)
+	require.Contains(t, sent, "BEGIN ")
+	require.Contains(t, sent, " END")
+	require.Contains(t, sent, "[... 4710 characters truncated ...]")
+	require.Less(t, len(sent), 1500)
+
+	// 不限制时原样发送
+	m2 := newMockLLM(t, textReply("built"))
+	_, err = summarizer.NewSummarizer(m2.client(), 100).SummarizeMessages(context.Background(), msgs)
+	require.NoError(t, err)
+	sent = m2.Requests()[0]["messages"].([]any)[1].(map[string]any)["content"].(string)
+	require.Contains(t, sent, output)
+}
+
+func TestRunStopsWhenCancelledDuringSummarization(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "list_dir", Args: `{}`}),
+		textReply("listed"),
+		textReply("summary"),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 第 3 个请求是摘要：收到后取消，并一直阻塞到客户端放弃
+	m.hook = func(n int, r *http.Request) {
+		if n == 3 {
+			cancel()
+			<-r.Context().Done()
+		}
+	}
+
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewListDirTool(ws)}, 10, ws, 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("list the workspace")
+	_, err = ag.Run(ctx)
+	require.NoError(t, err)
+
+	// 再次运行时历史超过阈值，先触发摘要
+	require.NoError(t, ag.SetTokenLimit(10))
+	before := ag.History()
+	_, err = ag.Run(ctx)
+	require.ErrorIs(t, err, summarizer.ErrCancelled)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, agent.OutcomeCancelled, ag.LastOutcome())
+
+	// 没有带着未摘要的历史继续请求模型，历史保持不变
+	require.Len(t, m.Requests(), 3)
+	require.Equal(t, before, ag.History())
+}
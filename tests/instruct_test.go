@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// sentMessages 取出请求中发送的消息（role, content）
+func sentMessages(req map[string]any) [][2]string {
+	var out [][2]string
+	list, _ := req["messages"].([]any)
+	for _, item := range list {
+		msg, _ := item.(map[string]any)
+		role, _ := msg["role"].(string)
+		content, _ := msg["content"].(string)
+		out = append(out, [2]string{role, content})
+	}
+	return out
+}
+
+func TestInstructionSentOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "noop", Args: "{}"}),
+		textReply("short"),
+		textReply("again"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "noop"}}, 10, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	ag.SetInstruction("  be concise ")
+	require.Equal(t, "be concise", ag.PendingInstruction())
+
+	ag.AddUserMessage("explain")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	ag.AddUserMessage("more")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 3)
+
+	// 第一次请求：指令作为最后一条 system 消息发送
+	first := sentMessages(reqs[0])
+	last := first[len(first)-1]
+	require.Equal(t, "system", last[0])
+	require.Contains(t, last[1], "be concise")
+
+	// 之后的请求与会话历史中都不再包含该指令
+	for _, req := range reqs[1:] {
+		for _, msg := range sentMessages(req) {
+			require.NotContains(t, msg[1], "be concise")
+		}
+	}
+	for _, msg := range ag.History() {
+		require.NotContains(t, msg.Content, "be concise")
+	}
+	require.Empty(t, ag.PendingInstruction())
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+func TestBashStdinReadDoesNotHang(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash read")
+	}
+	// 读取 stdin 的命令立即读到 EOF，而不是等到超时
+	start := time.Now()
+	res, err := tools.NewBashTool().Execute(context.Background(), map[string]any{
+		"command": `read -p "Continue? [Y/n] " answer; echo "answer=[$answer]"`,
+		"timeout": 5,
+	})
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 3*time.Second)
+	require.Contains(t, res.Stdout, "answer=[]")
+}
+
+func TestBashSetsNonInteractiveEnv(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash variable expansion")
+	}
+	cmd := map[string]any{"command": `echo "$DEBIAN_FRONTEND $GIT_TERMINAL_PROMPT $GIT_EDITOR"`}
+
+	res, err := tools.NewBashTool().Execute(context.Background(), cmd)
+	require.NoError(t, err)
+	require.Equal(t, "noninteractive 0 true\n", res.Stdout)
+
+	t.Setenv("DEBIAN_FRONTEND", "")
+	t.Setenv("GIT_TERMINAL_PROMPT", "")
+	t.Setenv("GIT_EDITOR", "")
+	res, err = tools.NewBashTool(tools.WithNonInteractive(false)).Execute(context.Background(), cmd)
+	require.NoError(t, err)
+	require.Equal(t, "  \n", res.Stdout)
+}
+
+func TestBashTimeoutExplainsWaitingForInput(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash redirection")
+	}
+	bash := tools.NewBashTool()
+
+	// 打印提示后一直等待（如从终端读取回答）
+	res, err := bash.Execute(context.Background(), map[string]any{
+		"command": `printf 'Do you want to continue? [Y/n] '; exec sleep 30`,
+		"timeout": 1,
+	})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Contains(t, res.Error, "timed out after 1 seconds")
+	require.Contains(t, res.Error, `waiting for input (last output: "Do you want to continue? [Y/n]")`)
+	require.Contains(t, res.Error, "stdin is not connected")
+
+	// 没有任何输出
+	res, err = bash.Execute(context.Background(), map[string]any{"command": "exec sleep 30", "timeout": 1})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "produced no output and may be waiting for input")
+
+	// 一直有进度输出的慢命令只报告超时
+	res, err = bash.Execute(context.Background(), map[string]any{
+		"command": "echo building; exec sleep 30",
+		"timeout": 1,
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.Error, "command timed out after 1 seconds"))
+	require.NotContains(t, res.Error, "waiting for input")
+}
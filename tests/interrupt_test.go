@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// blockingTool 一直运行到 context 被取消，模拟卡住的前台命令
+type blockingTool struct {
+	started chan struct{}
+	once    sync.Once
+}
+
+func (t *blockingTool) Name() string        { return "slow_build" }
+func (t *blockingTool) Description() string { return "Runs until cancelled." }
+func (t *blockingTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (t *blockingTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	t.once.Do(func() { close(t.started) })
+	<-ctx.Done()
+	return &tools.ToolResult{Success: false, Stdout: "compiling package 1 of 40\n", Error: ctx.Err().Error()}, nil
+}
+
+func TestInterruptToolContinuesRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	slow := &blockingTool{started: make(chan struct{})}
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "slow_build", Args: `{}`}),
+		textReply("skipped the slow build"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{slow}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	_, ok := ag.InterruptTool()
+	require.False(t, ok, "nothing is running yet")
+
+	interrupted := make(chan string, 1)
+	go func() {
+		<-slow.started
+		name, _ := ag.InterruptTool()
+		interrupted <- name
+	}()
+
+	ag.AddUserMessage("build it")
+	done := make(chan struct{})
+	var out string
+	go func() {
+		defer close(done)
+		out, err = ag.Run(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not continue after the tool was interrupted")
+	}
+
+	// 只中断了工具：运行继续，模型收到中断结果后给出最终回答
+	require.NoError(t, err)
+	require.Equal(t, "slow_build", <-interrupted)
+	require.Equal(t, "skipped the slow build", out)
+	require.Equal(t, agent.OutcomeDone, ag.LastOutcome())
+	require.Len(t, m.Requests(), 2)
+
+	sent, _ := m.Requests()[1]["messages"].([]any)
+	msg := sentMessage(t, m.Requests()[1], len(sent)-1)
+	require.Equal(t, "tool", msg["role"])
+	require.Contains(t, msg["content"], "Error ["+tools.ErrorKindInterrupted+"]")
+	require.Contains(t, msg["content"], "interrupted by the user")
+	require.Contains(t, msg["content"], "compiling package 1 of 40")
+
+	_, ok = ag.InterruptTool()
+	require.False(t, ok, "nothing is running after the run")
+}
+
+// stuckTool 忽略 context，直到 release 被关闭才返回，模拟不响应取消的工具
+type stuckTool struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (t *stuckTool) Name() string        { return "stuck" }
+func (t *stuckTool) Description() string { return "Ignores cancellation." }
+func (t *stuckTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (t *stuckTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	t.once.Do(func() { close(t.started) })
+	<-t.release
+	return &tools.ToolResult{Success: true, Content: "finally"}, nil
+}
+
+func TestInterruptToolThatIgnoresCancellation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stuck := &stuckTool{started: make(chan struct{}), release: make(chan struct{})}
+	t.Cleanup(func() { close(stuck.release) })
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "stuck", Args: `{}`}),
+		textReply("gave up on it"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{stuck}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	second := make(chan bool, 1)
+	go func() {
+		<-stuck.started
+		ag.InterruptTool()
+		// 已中断过的工具不再报告可中断，调用方改为取消任务
+		_, ok := ag.InterruptTool()
+		second <- ok
+	}()
+
+	ag.AddUserMessage("run it")
+	done := make(chan struct{})
+	var out string
+	go func() {
+		defer close(done)
+		out, err = ag.Run(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run blocked on a tool that ignores cancellation")
+	}
+
+	require.NoError(t, err)
+	require.False(t, <-second)
+	require.Equal(t, "gave up on it", out)
+	sent, _ := m.Requests()[1]["messages"].([]any)
+	msg := sentMessage(t, m.Requests()[1], len(sent)-1)
+	require.Equal(t, "tool", msg["role"])
+	require.Contains(t, msg["content"], "Error ["+tools.ErrorKindInterrupted+"]")
+}
+
+func TestCancelRunWhileToolIgnoresCancellation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stuck := &stuckTool{started: make(chan struct{}), release: make(chan struct{})}
+	t.Cleanup(func() { close(stuck.release) })
+
+	m := newMockLLM(t, toolReply("", mockCall{ID: "call_1", Name: "stuck", Args: `{}`}))
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{stuck}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stuck.started
+		cancel()
+	}()
+
+	ag.AddUserMessage("run it")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = ag.Run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled run blocked on a tool that ignores cancellation")
+	}
+	require.Error(t, err)
+}
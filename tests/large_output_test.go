@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// runBashThroughAgent 让模型调用一次 bash，返回第二次请求中发给模型的工具结果
+func runBashThroughAgent(t *testing.T, command string, opts ...agent.Option) string {
+	t.Helper()
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "bash", Args: `{"command": "` + command + `"}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewBashTool()}, 5, t.TempDir(), 100000, opts...)
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	msgs := reqs[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	require.Equal(t, "tool", last["role"])
+	return last["content"].(string)
+}
+
+func TestLargeOutputPolicyThreshold(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses seq")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	type call struct{ chars, lines int }
+	var calls []call
+	decide := func(action agent.LargeOutputAction) agent.LargeOutputFunc {
+		return func(command string, chars, lines int) agent.LargeOutputAction {
+			require.Equal(t, "seq 1 500", command)
+			calls = append(calls, call{chars, lines})
+			return action
+		}
+	}
+
+	// seq 1 500 输出 1892 个字符、500 行
+	content := runBashThroughAgent(t, "seq 1 500", agent.WithLargeOutputPolicy(1000, decide(agent.LargeOutputTruncate)))
+	require.Equal(t, []call{{1892, 500}}, calls)
+	require.Contains(t, content, "[Output truncated: showing the first 30 of 500 lines (1892 characters)")
+	require.Contains(t, content, "\n30")
+	require.NotContains(t, content, "\n31")
+
+	content = runBashThroughAgent(t, "seq 1 500", agent.WithLargeOutputPolicy(1000, decide(agent.LargeOutputSummarize)))
+	require.Contains(t, content, "[Output summarized: 1892 characters, 500 lines, exit code 0.")
+	require.Contains(t, content, "\n20\n")
+	require.Contains(t, content, "(460 lines not shown)")
+	require.Contains(t, content, "\n500")
+	require.NotContains(t, content, "\n250\n")
+
+	content = runBashThroughAgent(t, "seq 1 500", agent.WithLargeOutputPolicy(1000, decide(agent.LargeOutputFeed)))
+	require.Contains(t, content, "\n250\n")
+
+	// 未超过阈值时不询问
+	calls = nil
+	content = runBashThroughAgent(t, "seq 1 500", agent.WithLargeOutputPolicy(5000, decide(agent.LargeOutputTruncate)))
+	require.Empty(t, calls)
+	require.Contains(t, content, "\n250\n")
+
+	_, err := agent.ParseLargeOutputAction("drop")
+	require.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestRunResultListsChangedFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "old.txt"), []byte("v1\n"), 0o644))
+
+	m := newMockLLM(t,
+		toolReply("",
+			mockCall{ID: "c1", Name: "write_file", Args: `{"path": "src/new.go", "content": "package src\n"}`},
+			mockCall{ID: "c2", Name: "edit_file", Args: `{"path": "old.txt", "old_str": "v1", "new_str": "v2"}`},
+			mockCall{ID: "c3", Name: "edit_file", Args: `{"path": "missing.txt", "old_str": "a", "new_str": "b"}`},
+		),
+		toolReply("",
+			mockCall{ID: "c4", Name: "edit_file", Args: `{"path": "src/new.go", "old_str": "src", "new_str": "pkg"}`},
+			mockCall{ID: "c5", Name: "read_file", Args: `{"path": "old.txt"}`},
+		),
+		textReply("done"),
+		textReply("nothing to do"),
+	)
+	toolList := []tools.Tool{tools.NewWriteTool(ws), tools.NewEditTool(ws), tools.NewReadTool(ws)}
+	ag, err := agent.NewAgent(m.client(), "sys", toolList, 5, ws, 100000)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("change things")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+
+	res := ag.LastResult()
+	require.Equal(t, out, res.Content)
+	require.Equal(t, agent.OutcomeDone, res.Outcome)
+	// 失败的编辑与只读工具不计入；先创建后编辑仍是 created
+	require.Equal(t, []tools.ChangedFile{
+		{Path: "old.txt", Op: tools.ChangeModified},
+		{Path: "src/new.go", Op: tools.ChangeCreated},
+	}, res.ChangedFiles)
+
+	// 每次 Run 重新开始记录
+	ag.AddUserMessage("anything else?")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, ag.LastResult().ChangedFiles)
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// 构造测试目录：
+//
+//	ws/
+//	├── .gitignore   (build/ 与 *.log)
+//	├── cmd/app/main.go
+//	├── build/out.bin
+//	├── debug.log
+//	└── go.mod
+func makeTreeFixture(t *testing.T) string {
+	t.Helper()
+	ws := t.TempDir()
+
+	files := map[string]string{
+		".gitignore":      "build/\n*.log\n",
+		"cmd/app/main.go": "package main\n",
+		"build/out.bin":   "bin",
+		"debug.log":       "log",
+		"go.mod":          "module x\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(ws, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, ".git", "objects"), 0o755))
+	return ws
+}
+
+func TestRenderTreeRespectsIgnore(t *testing.T) {
+	ws := makeTreeFixture(t)
+
+	tree, err := tools.RenderTree(ws, 3)
+	require.NoError(t, err)
+
+	require.Contains(t, tree, "cmd/")
+	require.Contains(t, tree, "main.go")
+	require.Contains(t, tree, "go.mod")
+	require.NotContains(t, tree, "build/")
+	require.NotContains(t, tree, "debug.log")
+	require.NotContains(t, tree, ".git/")
+
+	// 目录排在文件之前
+	require.Less(t, strings.Index(tree, "cmd/"), strings.Index(tree, "go.mod"))
+}
+
+func TestRenderTreeDepth(t *testing.T) {
+	ws := makeTreeFixture(t)
+
+	tree, err := tools.RenderTree(ws, 1)
+	require.NoError(t, err)
+	require.Contains(t, tree, "cmd/")
+	require.NotContains(t, tree, "app/")
+}
+
+func TestIgnoreMatcherNegation(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, ".gopilotignore"), []byte("*.txt\n!keep.txt\n/docs/internal\n"), 0o644))
+
+	m := tools.LoadIgnoreMatcher(ws)
+	require.True(t, m.Match("a.txt", false))
+	require.True(t, m.Match("sub/b.txt", false))
+	require.False(t, m.Match("keep.txt", false))
+	require.True(t, m.Match("docs/internal", true))
+	require.False(t, m.Match("other/docs/internal", true))
+}
+
+func TestListDirTool(t *testing.T) {
+	ws := makeTreeFixture(t)
+	tool := tools.NewListDirTool(ws)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"path": "cmd", "depth": float64(2)})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "app/")
+	require.Contains(t, res.Content, "main.go")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "missing"})
+	require.False(t, res.Success)
+}
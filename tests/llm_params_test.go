@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/config"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+var pingMessages = []schema.Message{{Role: "user", Content: "ping"}}
+
+func TestExtraParamsForwarded(t *testing.T) {
+	m := newMockLLM(t, textReply("pong"))
+	c := m.client(llm.WithExtraParams(map[string]any{
+		"seed":              42,
+		"frequency_penalty": 0.5,
+		"logit_bias":        map[string]any{"50256": -100},
+		"model":             "hijacked", // 保留字段被忽略
+	}))
+
+	_, err := c.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+
+	req := m.Requests()[0]
+	require.EqualValues(t, 42, req["seed"])
+	require.EqualValues(t, 0.5, req["frequency_penalty"])
+	require.EqualValues(t, -100, req["logit_bias"].(map[string]any)["50256"])
+	require.Equal(t, "mock-model", req["model"])
+	require.NotEmpty(t, req["messages"])
+}
+
+func TestExtraParamsFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+llm:
+  model: m
+  extra_params:
+    seed: 7
+    presence_penalty: 0.1
+`), 0o644))
+
+	cfg, err := config.LoadFromFile(path)
+	require.NoError(t, err)
+	require.EqualValues(t, 7, cfg.LLM.ExtraParams["seed"])
+
+	m := newMockLLM(t, textReply("pong"))
+	c := m.client(llm.WithExtraParams(cfg.LLM.ExtraParams))
+	_, err = c.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 7, m.Requests()[0]["seed"])
+}
+
+func TestSeedSentAndFingerprintParsed(t *testing.T) {
+	m := newMockLLM(t, mockReply{Body: completionBody("pong", nil, map[string]any{
+		"system_fingerprint": "fp_abc123",
+	})})
+	c := m.client(llm.WithSeed(1234))
+
+	resp, err := c.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, "fp_abc123", resp.SystemFingerprint)
+	require.EqualValues(t, 1234, m.Requests()[0]["seed"])
+
+	// 未设置 seed 时不发送该字段
+	m2 := newMockLLM(t, textReply("pong"))
+	resp, err = m2.client().Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Empty(t, resp.SystemFingerprint)
+	require.NotContains(t, m2.Requests()[0], "seed")
+}
+
+func TestPayloadDumpRedactsSecrets(t *testing.T) {
+	m := newMockLLM(t, textReply("pong"))
+	var dumps [][]byte
+	c := m.client(
+		llm.WithExtraParams(map[string]any{
+			"frequency_penalty": 0.5,
+			"metadata":          map[string]any{"api_key": "sk-secret"},
+		}),
+		llm.WithPayloadDump(func(p []byte) { dumps = append(dumps, p) }),
+	)
+
+	msgs := []schema.Message{{Role: "user", Content: "my key is test-key"}}
+	_, err := c.Generate(context.Background(), msgs, nil)
+	require.NoError(t, err)
+	require.Len(t, dumps, 1)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(dumps[0], &body))
+	require.Equal(t, "mock-model", body["model"])
+	require.Equal(t, 0.5, body["frequency_penalty"])
+	require.Equal(t, "[REDACTED]", body["metadata"].(map[string]any)["api_key"])
+
+	dump := string(dumps[0])
+	require.NotContains(t, dump, "sk-secret")
+	require.NotContains(t, dump, "test-key")
+	require.Contains(t, dump, "my key is [REDACTED]")
+}
+
+func TestLogprobsSentAndParsed(t *testing.T) {
+	body := `{
+		"id": "chatcmpl-mock", "object": "chat.completion", "created": 1, "model": "mock-model",
+		"choices": [{
+			"index": 0, "finish_reason": "stop",
+			"message": {"role": "assistant", "content": "Yes"},
+			"logprobs": {"content": [{
+				"token": "Yes", "logprob": -0.01, "bytes": [89, 101, 115],
+				"top_logprobs": [
+					{"token": "Yes", "logprob": -0.01, "bytes": [89, 101, 115]},
+					{"token": "No", "logprob": -4.6, "bytes": [78, 111]}
+				]
+			}], "refusal": null}
+		}]
+	}`
+	m := newMockLLM(t, mockReply{Body: body})
+	resp, err := m.client(llm.WithLogprobs(2)).Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+
+	req := m.Requests()[0]
+	require.Equal(t, true, req["logprobs"])
+	require.EqualValues(t, 2, req["top_logprobs"])
+
+	require.Len(t, resp.Logprobs, 1)
+	require.Equal(t, "Yes", resp.Logprobs[0].Token)
+	require.InDelta(t, -0.01, resp.Logprobs[0].Logprob, 1e-9)
+	require.Equal(t, []schema.TopLogprob{{Token: "Yes", Logprob: -0.01}, {Token: "No", Logprob: -4.6}}, resp.Logprobs[0].TopLogprobs)
+
+	// 未开启时不发送参数；服务端未返回 logprobs 时结果为空
+	m2 := newMockLLM(t, textReply("pong"))
+	resp, err = m2.client().Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m2.Requests()[0], "logprobs")
+	require.Nil(t, resp.Logprobs)
+
+	m3 := newMockLLM(t, textReply("pong"))
+	resp, err = m3.client(llm.WithLogprobs(0)).Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m3.Requests()[0], "top_logprobs")
+	require.Nil(t, resp.Logprobs)
+}
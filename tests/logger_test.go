@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/logger"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/tools"
+)
+
+// readLastEntryJSON 读取日志文件中最后一个条目的 JSON 部分
+func readLastEntryJSON(t *testing.T, l *logger.AgentLogger, marker string) string {
+	t.Helper()
+	data, err := os.ReadFile(l.GetLogFilePath())
+	require.NoError(t, err)
+
+	text := string(data)
+	idx := strings.LastIndex(text, marker)
+	require.GreaterOrEqual(t, idx, 0, "marker %q not found", marker)
+	return strings.TrimSpace(text[idx+len(marker):])
+}
+
+func TestLoggerCapsOversizedRequest(t *testing.T) {
+	const maxBytes = 4096
+
+	l, err := logger.NewAgentLogger(logger.WithMaxEntryBytes(maxBytes))
+	require.NoError(t, err)
+	require.NoError(t, l.StartNewRun())
+	defer func() {
+		os.Remove(l.GetLogFilePath())
+		l.Close()
+	}()
+
+	huge := strings.Repeat("pasted file line 你好\n", 50000) // ~1MB
+	msgs := []schema.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: huge},
+		{Role: "tool", Content: huge, ToolCallID: "call_1", Name: "read_file"},
+	}
+	require.NoError(t, l.LogRequest(msgs, nil))
+
+	entry := readLastEntryJSON(t, l, "LLM Request:")
+	require.LessOrEqual(t, len(entry), maxBytes)
+	require.Contains(t, entry, "[truncated")
+
+	// 截断后仍是合法 JSON，结构保留
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(entry), &parsed))
+	messages := parsed["messages"].([]any)
+	require.Len(t, messages, 3)
+	require.Equal(t, "sys", messages[0].(map[string]any)["content"])
+	require.Equal(t, "call_1", messages[2].(map[string]any)["tool_call_id"])
+}
+
+func TestLoggerCapIncludesTruncationMarker(t *testing.T) {
+	const maxBytes = 1024
+
+	l, err := logger.NewAgentLogger(logger.WithMaxEntryBytes(maxBytes))
+	require.NoError(t, err)
+	require.NoError(t, l.StartNewRun())
+	defer func() {
+		os.Remove(l.GetLogFilePath())
+		l.Close()
+	}()
+
+	// 内容长度在上限附近逐字节变化，截断标记本身也计入上限
+	for n := maxBytes - 200; n <= maxBytes+200; n++ {
+		content := strings.Repeat("界", n/3) + strings.Repeat("x", n%3)
+		require.NoError(t, l.LogResponse(content, "", nil, "stop"))
+		entry := readLastEntryJSON(t, l, "LLM Response:")
+		require.LessOrEqual(t, len(entry), maxBytes, "content of %d bytes", n)
+		require.True(t, json.Valid([]byte(entry)), "content of %d bytes", n)
+	}
+
+	// 字段截到最短仍放不下时截断整个条目
+	msgs := make([]schema.Message, 100)
+	for i := range msgs {
+		msgs[i] = schema.Message{Role: "user", Content: strings.Repeat("y", 200)}
+	}
+	require.NoError(t, l.LogRequest(msgs, nil))
+	entry := readLastEntryJSON(t, l, "LLM Request:")
+	require.LessOrEqual(t, len(entry), maxBytes)
+	require.Contains(t, entry, "[truncated")
+}
+
+func TestLoggerUncappedByDefault(t *testing.T) {
+	l, err := logger.NewAgentLogger()
+	require.NoError(t, err)
+	require.NoError(t, l.StartNewRun())
+	defer func() {
+		os.Remove(l.GetLogFilePath())
+		l.Close()
+	}()
+
+	content := strings.Repeat("x", 20000)
+	require.NoError(t, l.LogResponse(content, "", nil, "stop"))
+
+	entry := readLastEntryJSON(t, l, "LLM Response:")
+	require.Contains(t, entry, content)
+	require.NotContains(t, entry, "[truncated")
+}
+
+func TestLoggerTailMirrorsEntries(t *testing.T) {
+	var mirror strings.Builder
+	l, err := logger.NewAgentLogger(logger.WithTail(&mirror))
+	require.NoError(t, err)
+	require.NoError(t, l.StartNewRun())
+	defer func() {
+		os.Remove(l.GetLogFilePath())
+		l.Close()
+	}()
+
+	require.NoError(t, l.LogResponse("hello tail", "", nil, "stop"))
+	require.Contains(t, mirror.String(), "Agent Run Log")
+	require.Contains(t, mirror.String(), l.GetLogFilePath())
+	require.Contains(t, mirror.String(), "hello tail")
+
+	// 日志文件内容不受影响（不含 tail 专用的路径行）
+	data, err := os.ReadFile(l.GetLogFilePath())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "hello tail")
+	require.NotContains(t, string(data), "("+l.GetLogFilePath()+")")
+}
+
+func TestLoggerTailLines(t *testing.T) {
+	l, err := logger.NewAgentLogger()
+	require.NoError(t, err)
+
+	// 尚未开始运行：没有日志文件
+	_, err = l.Tail(5)
+	require.ErrorIs(t, err, logger.ErrNoLogFile)
+
+	require.NoError(t, l.StartNewRun())
+	defer func() {
+		os.Remove(l.GetLogFilePath())
+		l.Close()
+	}()
+	require.NoError(t, l.LogResponse("first", "", nil, ""))
+	require.NoError(t, l.LogResponse("last entry", "", nil, ""))
+
+	lines, err := l.Tail(3)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, "}", lines[2])
+	require.Contains(t, lines[1], "last entry")
+
+	lines, err = l.Tail(0)
+	require.NoError(t, err)
+	require.Empty(t, lines)
+}
+
+// 同时带 content 与 tool_calls 的响应：日志在同一条 RESPONSE 中记录两者，
+// 下一轮请求中的 assistant 消息也同时保留两者
+func TestAgentLogsContentWithToolCalls(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("Let me check the file first.", mockCall{ID: "call_1", Name: "noop", Args: `{"path": "a.txt"}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "noop"}}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+
+	// 日志：第一条 RESPONSE 同时包含 content 和 tool_calls
+	data, err := os.ReadFile(ag.LogFilePath())
+	require.NoError(t, err)
+	text := string(data)
+	start := strings.Index(text, "LLM Response:")
+	require.GreaterOrEqual(t, start, 0)
+	end := strings.Index(text[start:], strings.Repeat("-", 80))
+	require.Greater(t, end, 0)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(text[start+len("LLM Response:"):start+end])), &resp))
+	require.Equal(t, "Let me check the file first.", resp["content"])
+	calls := resp["tool_calls"].([]any)
+	require.Len(t, calls, 1)
+	fn := calls[0].(map[string]any)["function"].(map[string]any)
+	require.Equal(t, "noop", fn["name"])
+	require.Equal(t, map[string]any{"path": "a.txt"}, fn["arguments"])
+
+	// 第二次请求：assistant 消息带 content 与 tool_calls，随后是对应的 tool 结果
+	msgs := m.Requests()[1]["messages"].([]any)
+	require.Len(t, msgs, 4)
+	assistant := msgs[2].(map[string]any)
+	require.Equal(t, "assistant", assistant["role"])
+	require.Equal(t, "Let me check the file first.", assistant["content"])
+	sent := assistant["tool_calls"].([]any)
+	require.Len(t, sent, 1)
+	require.Equal(t, "call_1", sent[0].(map[string]any)["id"])
+	require.Equal(t, "call_1", msgs[3].(map[string]any)["tool_call_id"])
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+func TestMemoryPersistsAcrossStores(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	ctx := context.Background()
+
+	store, err := tools.NewMemoryStore(ws)
+	require.NoError(t, err)
+	require.Contains(t, store.Path(), wspath.WorkspaceHash(ws)+".json")
+
+	mem := tools.NewMemoryTool(store)
+	res, err := mem.Execute(ctx, map[string]any{"action": "set", "key": "test-command", "value": "go test ./..."})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// 新的 store（模拟下一个会话）能读到之前保存的内容
+	again, err := tools.NewMemoryStore(ws)
+	require.NoError(t, err)
+	res, _ = tools.NewMemoryTool(again).Execute(ctx, map[string]any{"action": "get", "key": "test-command"})
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, "go test ./...", res.Content)
+
+	summary, err := again.Summary()
+	require.NoError(t, err)
+	require.Equal(t, "- test-command: go test ./...", summary)
+
+	// 不同工作空间互不可见
+	other, err := tools.NewMemoryStore(t.TempDir())
+	require.NoError(t, err)
+	summary, err = other.Summary()
+	require.NoError(t, err)
+	require.Empty(t, summary)
+
+	res, _ = mem.Execute(ctx, map[string]any{"action": "delete", "key": "test-command"})
+	require.True(t, res.Success, res.Error)
+	res, _ = mem.Execute(ctx, map[string]any{"action": "get", "key": "test-command"})
+	require.False(t, res.Success)
+}
+
+func TestMemorySizeCap(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store, err := tools.NewMemoryStore(t.TempDir(), tools.WithMemoryMaxBytes(20))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Set("a", "0123456789"))
+	err = store.Set("b", "0123456789")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "memory is full")
+
+	// 覆盖已有 key 按替换后的大小计算
+	require.NoError(t, store.Set("a", strings.Repeat("x", 19)))
+}
+
+func TestMemoryConcurrentWrites(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	// 两个 store 指向同一文件，模拟两个会话同时写入，锁文件保证不丢更新
+	s1, err := tools.NewMemoryStore(ws)
+	require.NoError(t, err)
+	s2, err := tools.NewMemoryStore(ws)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := s1
+			if i%2 == 1 {
+				store = s2
+			}
+			require.NoError(t, store.Set(fmt.Sprintf("k%02d", i), "v"))
+		}()
+	}
+	wg.Wait()
+
+	all, err := s1.All()
+	require.NoError(t, err)
+	require.Len(t, all, 20)
+}
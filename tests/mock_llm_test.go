@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/retry"
+)
+
+//
+// ---------------------------------------------------------
+// Mock OpenAI-compatible endpoint
+// ---------------------------------------------------------
+//
+
+// mockReply 模拟的一次 chat completion 响应
+type mockReply struct {
+	Status int
+	Body   string
+	Header map[string]string // 额外的响应头（如 Retry-After）
+}
+
+// mockCall 模拟的工具调用
+type mockCall struct {
+	ID   string
+	Name string
+	Args string
+}
+
+// mockLLM 基于 httptest 的 OpenAI 兼容服务端。
+// 按顺序返回 replies，超出后重复最后一个；同时记录每次请求体。
+type mockLLM struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []mockReply
+	requests []map[string]any
+
+	// hook 在回复第 n 个请求（从 1 开始）之前调用，可用于阻塞或取消
+	hook func(n int, r *http.Request)
+}
+
+func newMockLLM(t *testing.T, replies ...mockReply) *mockLLM {
+	t.Helper()
+
+	m := &mockLLM{replies: replies}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockLLM) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req map[string]any
+	_ = json.Unmarshal(body, &req)
+
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	idx := len(m.requests) - 1
+	if idx >= len(m.replies) {
+		idx = len(m.replies) - 1
+	}
+	reply := mockReply{Status: http.StatusOK, Body: completionBody("", nil, nil)}
+	if idx >= 0 {
+		reply = m.replies[idx]
+	}
+	n := len(m.requests)
+	m.mu.Unlock()
+
+	if m.hook != nil {
+		m.hook(n, r)
+	}
+	if reply.Status == 0 {
+		reply.Status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	for k, v := range reply.Header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(reply.Status)
+	_, _ = w.Write([]byte(reply.Body))
+}
+
+// Requests 返回已收到的请求体副本
+func (m *mockLLM) Requests() []map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]map[string]any, len(m.requests))
+	copy(out, m.requests)
+	return out
+}
+
+// client 创建指向 mock 服务的 LLM 客户端（默认关闭重试，避免测试等待）
+func (m *mockLLM) client(opts ...llm.ClientOption) *llm.Client {
+	base := []llm.ClientOption{llm.WithRetryConfig(&retry.Config{Enabled: false})}
+	return llm.NewClient("test-key", m.URL, "mock-model", append(base, opts...)...)
+}
+
+//
+// ---------------------------------------------------------
+// Response builders
+// ---------------------------------------------------------
+//
+
+func textReply(content string) mockReply {
+	return mockReply{Status: http.StatusOK, Body: completionBody(content, nil, nil)}
+}
+
+func toolReply(content string, calls ...mockCall) mockReply {
+	return mockReply{Status: http.StatusOK, Body: completionBody(content, calls, nil)}
+}
+
+// completionBody 构造 chat completion 响应 JSON，extra 会合并到顶层字段
+func completionBody(content string, calls []mockCall, extra map[string]any) string {
+	message := map[string]any{
+		"role":    "assistant",
+		"content": content,
+	}
+	finish := "stop"
+	if len(calls) > 0 {
+		tcs := make([]map[string]any, len(calls))
+		for i, c := range calls {
+			tcs[i] = map[string]any{
+				"id":   c.ID,
+				"type": "function",
+				"function": map[string]any{
+					"name":      c.Name,
+					"arguments": c.Args,
+				},
+			}
+		}
+		message["tool_calls"] = tcs
+		finish = "tool_calls"
+	}
+
+	body := map[string]any{
+		"id":      "chatcmpl-mock",
+		"object":  "chat.completion",
+		"created": 1700000000,
+		"model":   "mock-model",
+		"choices": []map[string]any{
+			{
+				"index":         0,
+				"message":       message,
+				"finish_reason": finish,
+			},
+		},
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+
+	b, _ := json.Marshal(body)
+	return string(b)
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+)
+
+func TestContextWindow(t *testing.T) {
+	w, ok := llm.ContextWindow("gpt-4o-mini")
+	require.True(t, ok)
+	require.Equal(t, 128000, w)
+
+	// gpt-4.1 不应被 gpt-4 前缀吞掉
+	w, ok = llm.ContextWindow("GPT-4.1")
+	require.True(t, ok)
+	require.Equal(t, 1047576, w)
+
+	w, ok = llm.ContextWindow("openai/gpt-4")
+	require.True(t, ok)
+	require.Equal(t, 8192, w)
+
+	_, ok = llm.ContextWindow("my-local-model")
+	require.False(t, ok)
+}
+
+func TestClientSetModel(t *testing.T) {
+	m := newMockLLM(t, textReply("ok"))
+	c := m.client()
+	require.Equal(t, "mock-model", c.Model())
+
+	c.SetModel("other-model")
+	require.Equal(t, "other-model", c.Model())
+
+	_, err := c.Generate(context.Background(), nil, nil)
+	require.NoError(t, err)
+	reqs := m.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, "other-model", reqs[0]["model"])
+}
+
+func TestAgentTokenLimitAndReset(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t, textReply("done"))
+
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 3, t.TempDir(), 1000)
+	require.NoError(t, err)
+	require.Equal(t, 1000, ag.TokenLimit())
+
+	require.Error(t, ag.SetTokenLimit(0))
+	require.Error(t, ag.SetTokenLimit(-5))
+	require.Equal(t, 1000, ag.TokenLimit())
+
+	require.NoError(t, ag.SetTokenLimit(4096))
+	require.Equal(t, 4096, ag.TokenLimit())
+
+	ag.AddUserMessage("hi")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Greater(t, len(ag.History()), 1)
+
+	ag.Reset()
+	history := ag.History()
+	require.Len(t, history, 1)
+	require.Equal(t, "system", history[0].Role)
+	require.Equal(t, 4096, ag.TokenLimit(), "reset keeps runtime settings")
+}
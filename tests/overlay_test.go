@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+// overlayTools 共享同一个 overlay 的文件工具
+func overlayTools(t *testing.T, ws string) (*tools.Overlay, *tools.ReadTool, *tools.WriteTool, *tools.EditTool, *tools.CopyFileTool) {
+	t.Helper()
+	o, err := tools.NewOverlay(ws)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(o.Dir()) })
+	return o,
+		tools.NewReadTool(ws, tools.WithReadOverlay(o)),
+		tools.NewWriteTool(ws, tools.WithWriteOverlay(o)),
+		tools.NewEditTool(ws, tools.WithEditOverlay(o)),
+		tools.NewCopyFileTool(ws, tools.WithCopyOverlay(o))
+}
+
+func run(t *testing.T, tool tools.Tool, args map[string]any) *tools.ToolResult {
+	t.Helper()
+	res, err := tool.Execute(context.Background(), args)
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	return res
+}
+
+func TestOverlayIsolatesWorkspace(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main\n// TODO\n"), 0o644))
+	o, read, write, edit, cp := overlayTools(t, ws)
+
+	run(t, edit, map[string]any{"path": "main.go", "old_str": "// TODO", "new_str": "// done"})
+	run(t, write, map[string]any{"path": "pkg/new.go", "content": "package pkg\n"})
+	run(t, cp, map[string]any{"source": "pkg/new.go", "destination": "pkg/copy.go"})
+
+	// 真实 workspace 不变
+	data, err := os.ReadFile(filepath.Join(ws, "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package main\n// TODO\n", string(data))
+	require.NoFileExists(t, filepath.Join(ws, "pkg", "new.go"))
+	require.NoFileExists(t, filepath.Join(ws, "pkg", "copy.go"))
+
+	// read_file 看到修改后的版本
+	res := run(t, read, map[string]any{"path": "main.go"})
+	require.Contains(t, res.Content, "// done")
+	res = run(t, read, map[string]any{"path": "pkg/copy.go"})
+	require.Contains(t, res.Content, "package pkg")
+
+	require.Equal(t, []string{"main.go", "pkg/copy.go", "pkg/new.go"}, o.Changes())
+
+	// 在 overlay 中再次修改同一文件，基于已修改的版本
+	run(t, edit, map[string]any{"path": "main.go", "old_str": "// done", "new_str": "// done twice"})
+	res = run(t, read, map[string]any{"path": "main.go"})
+	require.Contains(t, res.Content, "// done twice")
+}
+
+func TestOverlayApply(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "run.sh"), []byte("echo old\n"), 0o755))
+	o, _, write, edit, _ := overlayTools(t, ws)
+
+	run(t, edit, map[string]any{"path": "run.sh", "old_str": "old", "new_str": "new"})
+	run(t, write, map[string]any{"path": "docs/README.md", "content": "# hi\n"})
+
+	applied, err := o.Apply()
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/README.md", "run.sh"}, applied)
+	require.Empty(t, o.Changes())
+
+	data, err := os.ReadFile(filepath.Join(ws, "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, "echo new\n", string(data))
+	info, err := os.Stat(filepath.Join(ws, "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm(), "existing file keeps its mode")
+	data, err = os.ReadFile(filepath.Join(ws, "docs", "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# hi\n", string(data))
+
+	entries, err := os.ReadDir(o.Dir())
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// 没有待定修改时关闭会删除影子目录
+	require.NoError(t, o.Close())
+	require.NoDirExists(t, o.Dir())
+}
+
+func TestOverlayDiscard(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("keep"), 0o644))
+	o, read, write, _, _ := overlayTools(t, ws)
+
+	run(t, write, map[string]any{"path": "a.txt", "content": "changed"})
+	run(t, write, map[string]any{"path": "b.txt", "content": "new"})
+
+	discarded, err := o.Discard()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "b.txt"}, discarded)
+	require.Empty(t, o.Changes())
+
+	res := run(t, read, map[string]any{"path": "a.txt"})
+	require.Contains(t, res.Content, "keep")
+	require.NoFileExists(t, filepath.Join(ws, "b.txt"))
+
+	// 有待定修改时关闭保留影子目录
+	run(t, write, map[string]any{"path": "c.txt", "content": "pending"})
+	require.NoError(t, o.Close())
+	require.FileExists(t, filepath.Join(o.Dir(), "c.txt"))
+}
+
+func TestOverlayFailedWriteKeepsWorkspaceVersion(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "sub", "a.txt"), []byte("original\n"), 0o644))
+	o, read, write, edit, _ := overlayTools(t, ws)
+
+	// 影子目录中同名的普通文件让 MkdirAll 失败
+	require.NoError(t, os.WriteFile(filepath.Join(o.Dir(), "sub"), nil, 0o644))
+	res, err := write.Execute(context.Background(), map[string]any{"path": "sub/a.txt", "content": "changed\n"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+
+	// 写入失败不记录修改，读取与编辑仍使用 workspace 中的文件
+	require.Empty(t, o.Changes())
+	res = run(t, read, map[string]any{"path": "sub/a.txt"})
+	require.Contains(t, res.Content, "original")
+	res, err = edit.Execute(context.Background(), map[string]any{"path": "sub/a.txt", "old_str": "original", "new_str": "edited"})
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.NotContains(t, res.Error, "File not found")
+	require.Empty(t, o.Changes())
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	wspath "gopilot-cli/internal/utils/path"
+)
+
+func TestWorkspaceHashEquivalentPaths(t *testing.T) {
+	root := t.TempDir()
+	ws := filepath.Join(root, "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(ws, "sub"), 0o755))
+	id := wspath.WorkspaceHash(ws)
+	require.Len(t, id, 16)
+
+	// 末尾斜杠、"." / ".." 片段
+	require.Equal(t, id, wspath.WorkspaceHash(ws+string(filepath.Separator)))
+	require.Equal(t, id, wspath.WorkspaceHash(ws+"/./"))
+	require.Equal(t, id, wspath.WorkspaceHash(filepath.Join(ws, "sub", "..")))
+
+	// 相对路径
+	t.Chdir(root)
+	require.Equal(t, id, wspath.WorkspaceHash("project"))
+	require.Equal(t, id, wspath.WorkspaceHash("./project/"))
+
+	// 符号链接
+	link := filepath.Join(root, "link")
+	require.NoError(t, os.Symlink(ws, link))
+	require.Equal(t, id, wspath.WorkspaceHash(link))
+
+	// 多次调用结果稳定
+	require.Equal(t, id, wspath.WorkspaceHash(ws))
+}
+
+func TestWorkspaceHashDistinguishesProjects(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "b")
+	nested := filepath.Join(a, "a")
+	for _, dir := range []string{a, b, nested} {
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+
+	ids := map[string]string{}
+	for _, dir := range []string{a, b, nested, root} {
+		id := wspath.WorkspaceHash(dir)
+		require.NotContains(t, ids, id, "%s collides with %s", dir, ids[id])
+		ids[id] = dir
+	}
+}
+
+func TestWorkspaceHashCaseSensitivity(t *testing.T) {
+	root := t.TempDir()
+	upper := filepath.Join(root, "Project")
+	require.NoError(t, os.Mkdir(upper, 0o755))
+	lower := filepath.Join(root, "project")
+
+	if err := os.Mkdir(lower, 0o755); err != nil {
+		// 不区分大小写的文件系统：两种写法是同一个目录，标识必须相同
+		require.True(t, os.IsExist(err), err)
+		require.Equal(t, wspath.WorkspaceHash(upper), wspath.WorkspaceHash(lower))
+		require.Equal(t, strings.ToLower(wspath.NormalizeWorkspace(upper)), wspath.NormalizeWorkspace(upper))
+		return
+	}
+	// 区分大小写的文件系统：是两个不同的项目，标识必须不同，且保留原有大小写
+	require.NotEqual(t, wspath.WorkspaceHash(upper), wspath.WorkspaceHash(lower))
+	require.Equal(t, "Project", filepath.Base(wspath.NormalizeWorkspace(upper)))
+}
+
+func TestWorkspaceSlugAndStateFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	ws := filepath.Join(t.TempDir(), "My Project_v2!")
+	require.NoError(t, os.Mkdir(ws, 0o755))
+
+	slug := wspath.WorkspaceSlug(ws)
+	require.Regexp(t, regexp.MustCompile(`^[a-z0-9-]+$`), slug)
+	require.True(t, strings.HasSuffix(slug, "-"+wspath.WorkspaceHash(ws)), slug)
+	require.True(t, strings.HasPrefix(slug, "my-project-v2-"), slug)
+
+	path, err := wspath.StateFile("sessions", ws+"/", ".json")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(home, ".gopilot", "sessions", wspath.WorkspaceHash(ws)+".json"), path)
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, wspath.CheckWritable(dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "probe file must be removed")
+
+	// 不存在的目录、普通文件都不可写
+	require.Error(t, wspath.CheckWritable(filepath.Join(dir, "missing")))
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	require.Error(t, wspath.CheckWritable(file))
+
+	// 只读目录（root 不受文件权限限制，无法模拟）
+	if os.Geteuid() == 0 {
+		t.Skip("running as root: permission bits do not make a directory read-only")
+	}
+	ro := filepath.Join(dir, "ro")
+	require.NoError(t, os.Mkdir(ro, 0o555))
+	t.Cleanup(func() { os.Chmod(ro, 0o755) })
+	err = wspath.CheckWritable(ro)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not writable")
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// sentToolNames 取出请求中发送的工具名
+func sentToolNames(req map[string]any) []string {
+	var names []string
+	list, _ := req["tools"].([]any)
+	for _, item := range list {
+		fn, _ := item.(map[string]any)["function"].(map[string]any)
+		name, _ := fn["name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPlanThenExecute(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	write := mockCall{ID: "call_w", Name: "write_file", Args: `{"path": "out.txt", "content": "hi"}`}
+
+	m := newMockLLM(t,
+		toolReply("", write), // 计划阶段尝试写文件，应被拒绝
+		textReply("1. write out.txt"),
+		toolReply("", write),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys",
+		[]tools.Tool{tools.NewReadTool(ws), tools.NewWriteTool(ws)}, 10, ws, 100000)
+	require.NoError(t, err)
+
+	// 计划阶段：只发送只读工具，写操作被拒绝
+	ag.AddUserMessage("create out.txt")
+	plan, err := ag.RunPlan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "1. write out.txt", plan)
+	require.Equal(t, plan, ag.Plan())
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	require.Equal(t, []string{"read_file"}, sentToolNames(reqs[0]))
+	require.NoFileExists(t, filepath.Join(ws, "out.txt"))
+
+	var refused bool
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" && msg.Name == "write_file" {
+			require.Contains(t, msg.Content, "planning phase")
+			refused = true
+		}
+	}
+	require.True(t, refused, "write_file should have been refused during planning")
+
+	// 执行阶段：开放全部工具
+	out, err := ag.ExecutePlan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+	require.Empty(t, ag.Plan())
+
+	reqs = m.Requests()
+	require.Len(t, reqs, 4)
+	require.ElementsMatch(t, []string{"read_file", "write_file"}, sentToolNames(reqs[2]))
+	data, err := os.ReadFile(filepath.Join(ws, "out.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(data))
+
+	// 没有计划时不能执行
+	_, err = ag.ExecutePlan(context.Background())
+	require.ErrorIs(t, err, agent.ErrNoPlan)
+}
+
+func TestDryPlanRunsNoTools(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	// 即使服务端忽略 tool_choice 返回了工具调用，也不执行
+	m := newMockLLM(t,
+		toolReply("1. create out.txt\n2. verify it", mockCall{ID: "call_w", Name: "write_file", Args: `{"path": "out.txt", "content": "hi"}`}),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys",
+		[]tools.Tool{tools.NewReadTool(ws), tools.NewWriteTool(ws)}, 10, ws, 100000)
+	require.NoError(t, err)
+	before := ag.History()
+
+	plan, err := ag.DryPlan(context.Background(), "create out.txt")
+	require.NoError(t, err)
+	require.Equal(t, "1. create out.txt\n2. verify it", plan)
+
+	reqs := m.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, "none", reqs[0]["tool_choice"])
+	require.Equal(t, []string{"read_file", "write_file"}, sentToolNames(reqs[0]))
+
+	require.NoFileExists(t, filepath.Join(ws, "out.txt"))
+	require.Equal(t, before, ag.History())
+	require.Empty(t, ag.Plan())
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// progressTool 执行期间报告 steps 条进度
+type progressTool struct {
+	namedTool
+	steps int
+}
+
+func (p progressTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	return p.ExecuteWithProgress(ctx, args, nil)
+}
+
+func (p progressTool) ExecuteWithProgress(ctx context.Context, args map[string]any, progress tools.ProgressFunc) (*tools.ToolResult, error) {
+	for i := 1; i <= p.steps; i++ {
+		if progress != nil {
+			progress(fmt.Sprintf("step %d/%d", i, p.steps))
+		}
+	}
+	return &tools.ToolResult{Success: true, Content: "built"}, nil
+}
+
+// progressLog 并发安全地收集 "tool: message"
+type progressLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *progressLog) add(tool, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, tool+": "+message)
+}
+
+func TestToolProgressEventsReachConsumer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "c1", Name: "build", Args: `{}`}, mockCall{ID: "c2", Name: "noop", Args: `{}`}),
+		textReply("done"),
+	)
+	var log progressLog
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{
+		progressTool{namedTool: namedTool{name: "build", desc: "slow build"}, steps: 3},
+		namedTool{name: "noop", desc: "does nothing"},
+	}, 5, t.TempDir(), 100000, agent.WithToolProgress(log.add))
+	require.NoError(t, err)
+
+	ag.AddUserMessage("build it")
+	captureStdout(t, func() {
+		_, err := ag.Run(context.Background())
+		require.NoError(t, err)
+	})
+	require.Equal(t, []string{"build: step 1/3", "build: step 2/3", "build: step 3/3"}, log.events,
+		"only the progress-reporting tool emits events")
+
+	// 结果照常发给模型
+	msgs := m.Requests()[1]["messages"].([]any)
+	require.Contains(t, fmt.Sprint(msgs), "built")
+}
+
+func TestToolProgressOptional(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "c1", Name: "build", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{
+		progressTool{namedTool: namedTool{name: "build", desc: "slow build"}, steps: 3},
+	}, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("build it")
+	captureStdout(t, func() {
+		_, err := ag.Run(context.Background())
+		require.NoError(t, err)
+	})
+	require.Contains(t, fmt.Sprint(m.Requests()[1]["messages"]), "built")
+}
+
+func TestBashForegroundReportsLines(t *testing.T) {
+	if isWindows() {
+		t.Skip("uses bash redirection")
+	}
+	var log progressLog
+	bash := tools.NewBashTool()
+	res, err := bash.ExecuteWithProgress(context.Background(), map[string]any{
+		"command": "echo one; sleep 0.1; echo two >&2; sleep 0.1; printf three",
+	}, func(message string) { log.add("bash", message) })
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Equal(t, []string{"bash: one", "bash: two", "bash: three"}, log.events,
+		"each line is reported, including the final line without a newline")
+	require.Equal(t, "one\nthree", res.Stdout)
+}
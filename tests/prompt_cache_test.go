@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+// cacheHistory 系统提示 + 一轮工具调用后的新问题
+var cacheHistory = []schema.Message{
+	{Role: "system", Content: "You are a helpful assistant."},
+	{Role: "user", Content: "first"},
+	{Role: "assistant", Content: "answer"},
+	{Role: "user", Content: "second"},
+}
+
+// contentParts 取出消息的内容块；content 为字符串时返回 nil
+func contentParts(msg map[string]any) []map[string]any {
+	raw, ok := msg["content"].([]any)
+	if !ok {
+		return nil
+	}
+	parts := make([]map[string]any, len(raw))
+	for i, p := range raw {
+		parts[i] = p.(map[string]any)
+	}
+	return parts
+}
+
+func TestPromptCachingMarksSystemAndLastUser(t *testing.T) {
+	m := newMockLLM(t, textReply("ok"))
+	_, err := m.client(llm.WithPromptCaching(llm.PromptCachingAnthropic)).Generate(context.Background(), cacheHistory, nil)
+	require.NoError(t, err)
+	req := m.Requests()[0]
+
+	system := contentParts(sentMessage(t, req, 0))
+	require.Len(t, system, 1)
+	require.Equal(t, "You are a helpful assistant.", system[0]["text"])
+	require.Equal(t, map[string]any{"type": "ephemeral"}, system[0]["cache_control"])
+
+	// 只有最后一条 user 消息是断点，更早的保持字符串形式
+	require.Equal(t, "first", sentMessage(t, req, 1)["content"])
+	last := contentParts(sentMessage(t, req, 3))
+	require.Len(t, last, 1)
+	require.Equal(t, "second", last[0]["text"])
+	require.Equal(t, map[string]any{"type": "ephemeral"}, last[0]["cache_control"])
+}
+
+func TestPromptCachingOffByDefault(t *testing.T) {
+	m := newMockLLM(t, textReply("ok"))
+	_, err := m.client().Generate(context.Background(), cacheHistory, nil)
+	require.NoError(t, err)
+	require.Equal(t, "You are a helpful assistant.", sentMessage(t, m.Requests()[0], 0)["content"])
+}
+
+// auto 只对需要显式标记的服务端（Claude）发送标记
+func TestPromptCachingAutoIsProviderAware(t *testing.T) {
+	m := newMockLLM(t, textReply("ok"), textReply("ok"))
+	client := m.client(llm.WithPromptCaching(llm.PromptCachingAuto))
+
+	_, err := client.Generate(context.Background(), cacheHistory, nil)
+	require.NoError(t, err)
+	require.IsType(t, "", sentMessage(t, m.Requests()[0], 0)["content"])
+
+	client.SetModel("claude-sonnet-4")
+	_, err = client.Generate(context.Background(), cacheHistory, nil)
+	require.NoError(t, err)
+	system := contentParts(sentMessage(t, m.Requests()[1], 0))
+	require.Len(t, system, 1)
+	require.Contains(t, system[0], "cache_control")
+
+	require.True(t, llm.ValidPromptCaching(""))
+	require.False(t, llm.ValidPromptCaching("always"))
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+)
+
+// oversizedPrompt 开头与结尾带标记、中间是大量填充内容的 system prompt
+func oversizedPrompt() string {
+	return "BEGIN RULES\n" + strings.Repeat("Always follow the project conventions described here.\n", 2000) + "END RULES"
+}
+
+func TestOversizedSystemPromptWarns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t)
+	prompt := oversizedPrompt()
+
+	var ag *agent.Agent
+	out := captureStdout(t, func() {
+		var err error
+		ag, err = agent.NewAgent(m.client(), prompt, nil, 5, t.TempDir(), 80000, agent.WithSystemPromptLimit(1000, false))
+		require.NoError(t, err)
+	})
+	require.Contains(t, out, "above agent.system_prompt_max_tokens (1000)")
+	require.Contains(t, out, "of the token limit (80000)")
+	require.True(t, strings.HasPrefix(ag.History()[0].Content, prompt), "without truncation the prompt is kept")
+}
+
+func TestOversizedSystemPromptTruncates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t)
+	prompt := oversizedPrompt()
+
+	var ag *agent.Agent
+	out := captureStdout(t, func() {
+		var err error
+		ag, err = agent.NewAgent(m.client(), prompt, nil, 5, t.TempDir(), 80000, agent.WithSystemPromptLimit(1000, true))
+		require.NoError(t, err)
+	})
+	require.Contains(t, out, "System prompt truncated from ~")
+
+	system := ag.History()[0].Content
+	require.Less(t, len(system), len(prompt)/5)
+	require.True(t, strings.HasPrefix(system, "BEGIN RULES"))
+	require.Contains(t, system, "Current workspace:", "the end of the prompt (workspace info) is kept")
+	require.Contains(t, system, "[Content truncated:")
+
+	// 截断后的版本在 Reset 后仍然生效
+	ag.Reset()
+	require.Equal(t, system, ag.History()[0].Content)
+}
+
+func TestSmallSystemPromptIsUntouched(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t)
+	out := captureStdout(t, func() {
+		ag, err := agent.NewAgent(m.client(), "short prompt", nil, 5, t.TempDir(), 80000, agent.WithSystemPromptLimit(1000, true))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(ag.History()[0].Content, "short prompt"))
+	})
+	require.NotContains(t, out, "System prompt")
+}
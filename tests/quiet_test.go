@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestQuietModePrintsOnlyTheAnswer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "a.txt"), []byte("alpha\n"), 0o644))
+
+	run := func(opts ...agent.Option) string {
+		m := newMockLLM(t,
+			toolReply("Let me look at the file.", mockCall{ID: "call_1", Name: "read_file", Args: `{"path":"a.txt"}`}),
+			toolReply("", mockCall{ID: "call_2", Name: "write_file", Args: `{"path":"b.txt","content":"beta\n"}`}),
+			textReply("The file says alpha."),
+		)
+		toolList := []tools.Tool{tools.NewReadTool(ws), tools.NewWriteTool(ws)}
+		ag, err := agent.NewAgent(m.client(), "sys", toolList, 5, ws, 100000, opts...)
+		require.NoError(t, err)
+		ag.AddUserMessage("what is in a.txt?")
+		return captureStdout(t, func() {
+			_, err := ag.Run(context.Background())
+			require.NoError(t, err)
+		})
+	}
+
+	verbose := run()
+	require.Contains(t, verbose, "Step 1/5")
+	require.Contains(t, verbose, "Tool Call:")
+	require.Contains(t, verbose, "Files changed")
+
+	require.Equal(t, "The file says alpha.\n", run(agent.WithQuiet(true)))
+}
+
+func TestStepHeaderShowsTokenEstimate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "big.txt"), []byte(strings.Repeat("lorem ipsum dolor sit amet\n", 400)), 0o644))
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "read_file", Args: `{"path":"big.txt"}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tools.NewReadTool(ws)}, 5, ws, 80000)
+	require.NoError(t, err)
+	ag.AddUserMessage("read big.txt")
+	out := captureStdout(t, func() {
+		_, err := ag.Run(context.Background())
+		require.NoError(t, err)
+	})
+
+	// 每个 Step 框标题都带上下文 token 估算与摘要阈值
+	re := regexp.MustCompile(`Step (\d)/5\S* \S*· ~([\d.]+)(k?)/80k tokens`)
+	matches := re.FindAllStringSubmatch(out, -1)
+	require.Len(t, matches, 2, out)
+
+	tokens := func(m []string) float64 {
+		v, err := strconv.ParseFloat(m[2], 64)
+		require.NoError(t, err)
+		if m[3] == "k" {
+			v *= 1000
+		}
+		return v
+	}
+	require.Greater(t, tokens(matches[0]), float64(0))
+	// 读取文件后上下文明显增长
+	require.Greater(t, tokens(matches[1]), tokens(matches[0])+1000)
+}
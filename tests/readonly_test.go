@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestReadOnlyModeRejectsWriteTool(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_w", Name: "write_file", Args: `{"path": "out.txt", "content": "hi"}`}),
+		textReply("cannot write"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys",
+		[]tools.Tool{tools.NewReadTool(ws), tools.NewWriteTool(ws), tools.NewBashTool()}, 5, ws, 100000,
+		agent.WithReadOnly(true))
+	require.NoError(t, err)
+	require.True(t, ag.ReadOnly())
+
+	ag.AddUserMessage("create out.txt")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "cannot write", out)
+	require.NoFileExists(t, filepath.Join(ws, "out.txt"))
+
+	// 只发送只读工具
+	reqs := m.Requests()
+	require.Len(t, reqs, 2)
+	require.Equal(t, []string{"read_file"}, sentToolNames(reqs[0]))
+
+	// 被拒绝的调用带有机器可读的错误类别
+	var refused bool
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" && msg.Name == "write_file" {
+			require.Contains(t, msg.Content, "Error ["+tools.ErrorKindReadOnly+"]")
+			require.Contains(t, msg.Content, "read-only mode")
+			refused = true
+		}
+	}
+	require.True(t, refused, "write_file should have been refused in read-only mode")
+}
+
+func TestToolsDeclareWhetherTheyMutate(t *testing.T) {
+	ws := t.TempDir()
+	watcher := tools.NewFileWatcher(ws)
+	defer watcher.Close()
+
+	readOnly := []tools.Tool{
+		tools.NewReadTool(ws),
+		tools.NewListDirTool(ws),
+		tools.NewStatsTool(ws),
+		tools.NewGitDiffTool(ws),
+		tools.NewBashOutputTool(),
+		tools.NewWatchFileTool(watcher),
+		tools.NewPollFileChangesTool(watcher),
+		tools.NewRunLogsTool(t.TempDir()),
+		tools.NewAskUserTool(),
+	}
+	for _, tool := range readOnly {
+		require.True(t, tools.IsReadOnly(tool), tool.Name())
+	}
+
+	mutating := []tools.Tool{
+		tools.NewWriteTool(ws),
+		tools.NewEditTool(ws),
+		tools.NewCopyFileTool(ws),
+		tools.NewBashTool(),
+		tools.NewBashKillTool(),
+		tools.NewFormatTool(ws),
+		tools.NewGoModTool(ws),
+	}
+	for _, tool := range mutating {
+		require.False(t, tools.IsReadOnly(tool), tool.Name())
+	}
+
+	// 外部工具默认视为会修改，除非声明为只读
+	ext, err := tools.NewExternalTool(ws, tools.ExternalToolSpec{Name: "lint", Command: []string{"true"}})
+	require.NoError(t, err)
+	require.False(t, tools.IsReadOnly(ext))
+	ext, err = tools.NewExternalTool(ws, tools.ExternalToolSpec{Name: "lint", Command: []string{"true"}, ReadOnly: true})
+	require.NoError(t, err)
+	require.True(t, tools.IsReadOnly(ext))
+}
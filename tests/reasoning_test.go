@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/llm"
+)
+
+// 修改推理强度作用于之后的每次请求
+func TestReasoningEffortChangesLive(t *testing.T) {
+	m := newMockLLM(t, textReply("a"), textReply("b"), textReply("c"), textReply("d"))
+	client := m.client()
+	ctx := context.Background()
+
+	_, err := client.Generate(ctx, pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m.Requests()[0], "reasoning_effort")
+
+	require.NoError(t, client.SetReasoningEffort("high"))
+	_, err = client.Generate(ctx, pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, "high", m.Requests()[1]["reasoning_effort"])
+
+	require.NoError(t, client.SetReasoningEffort("on"))
+	require.Equal(t, llm.DefaultReasoningLevel, client.ReasoningEffort())
+	_, err = client.Generate(ctx, pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, "medium", m.Requests()[2]["reasoning_effort"])
+
+	require.NoError(t, client.SetReasoningEffort("off"))
+	_, err = client.Generate(ctx, pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m.Requests()[3], "reasoning_effort")
+
+	require.Error(t, client.SetReasoningEffort("extreme"))
+	require.Empty(t, client.ReasoningEffort())
+}
+
+// extra_params 中的 reasoning_effort 成为初始值，之后仍可修改
+func TestReasoningEffortFromExtraParams(t *testing.T) {
+	m := newMockLLM(t, textReply("a"))
+	client := m.client(llm.WithExtraParams(map[string]any{"reasoning_effort": "low"}))
+	require.Equal(t, "low", client.ReasoningEffort())
+
+	require.NoError(t, client.SetReasoningEffort("off"))
+	_, err := client.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.NotContains(t, m.Requests()[0], "reasoning_effort")
+}
+
+// 服务端拒绝 reasoning_effort 时返回明确的错误
+func TestReasoningEffortRejected(t *testing.T) {
+	m := newMockLLM(t, mockReply{
+		Status: http.StatusBadRequest,
+		Body:   `{"error":{"message":"Unrecognized request argument supplied: reasoning_effort","type":"invalid_request_error","param":null}}`,
+	})
+	client := m.client(llm.WithReasoningEffort("high"))
+
+	_, err := client.Generate(context.Background(), pingMessages, nil)
+	var rejected *llm.ReasoningUnsupportedError
+	require.ErrorAs(t, err, &rejected)
+	require.Equal(t, "high", rejected.Level)
+	require.Contains(t, err.Error(), "/think off")
+
+	require.True(t, llm.SupportsReasoningEffort("openai/o3-mini"))
+	require.False(t, llm.SupportsReasoningEffort("gpt-4o"))
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// namedTool 仅有名字的空工具
+type namedTool struct {
+	name string
+	desc string
+}
+
+func (n namedTool) Name() string               { return n.name }
+func (n namedTool) Description() string        { return n.desc }
+func (n namedTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (n namedTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	return &tools.ToolResult{Success: true}, nil
+}
+
+func registryNames(reg *tools.ToolRegistry) []string {
+	var names []string
+	for _, t := range reg.List() {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+func TestToolRegistryPreservesOrder(t *testing.T) {
+	want := []string{"zeta", "alpha", "mid", "beta", "omega", "gamma"}
+
+	reg := tools.NewToolRegistry()
+	for _, name := range want {
+		reg.Register(namedTool{name: name})
+	}
+
+	// map 迭代顺序随机，多次调用都应得到注册顺序
+	for i := 0; i < 20; i++ {
+		require.Equal(t, want, registryNames(reg))
+	}
+
+	schemas := reg.ToOpenAISchemas()
+	require.Len(t, schemas, len(want))
+	for i, s := range schemas {
+		require.Equal(t, want[i], s["function"].(map[string]any)["name"])
+	}
+}
+
+func TestToolRegistryReplaceKeepsPosition(t *testing.T) {
+	reg := tools.NewToolRegistry()
+	reg.Register(namedTool{name: "a", desc: "old"})
+	reg.Register(namedTool{name: "b"})
+	reg.Register(namedTool{name: "a", desc: "new"})
+
+	require.Equal(t, []string{"a", "b"}, registryNames(reg))
+	tool, ok := reg.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "new", tool.Description())
+}
+
+// schemaTool 参数 schema 可自定义的空工具
+type schemaTool struct {
+	namedTool
+	params map[string]any
+}
+
+func (s schemaTool) Parameters() map[string]any { return s.params }
+
+func TestValidateToolRejectsBrokenSchemas(t *testing.T) {
+	cases := []struct {
+		name   string
+		tool   tools.Tool
+		errMsg string
+	}{
+		{"missing type", schemaTool{namedTool{name: "no_type"}, map[string]any{"properties": map[string]any{}}},
+			`tool "no_type": parameters schema must have "type": "object"`},
+		{"nil schema", schemaTool{namedTool{name: "nil_schema"}, nil}, `tool "nil_schema": parameters schema is nil`},
+		{"unknown required", schemaTool{namedTool{name: "bad_required"}, map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"path": map[string]any{"type": "string"}},
+			"required":   []string{"path", "mode"},
+		}}, `tool "bad_required": parameters.required lists "mode", which is not in properties`},
+		{"required not array", schemaTool{namedTool{name: "required_str"}, map[string]any{
+			"type": "object", "required": "path",
+		}}, `parameters.required must be an array of strings`},
+		{"bad property type", schemaTool{namedTool{name: "bad_prop"}, map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"n": map[string]any{"type": "int"}},
+		}}, `tool "bad_prop": parameters.properties.n.type has unknown type int`},
+		{"property not object", schemaTool{namedTool{name: "prop_str"}, map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"n": "string"},
+		}}, `parameters.properties.n must be an object`},
+		{"bad name", namedTool{name: "has space"}, `invalid tool name "has space"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tools.ValidateTool(tc.tool)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.errMsg)
+		})
+	}
+}
+
+func TestNewAgentFailsFastOnBrokenToolSchema(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	broken := schemaTool{namedTool{name: "broken"}, map[string]any{"type": "objekt"}}
+
+	_, err := agent.NewAgent(newMockLLM(t).client(), "sys",
+		[]tools.Tool{namedTool{name: "fine"}, broken}, 5, t.TempDir(), 100000)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `tool "broken"`)
+}
+
+func TestBuiltinToolSchemasAreValid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+	store, err := tools.NewMemoryStore(ws)
+	require.NoError(t, err)
+	watcher := tools.NewFileWatcher(ws)
+	defer watcher.Close()
+
+	for _, tool := range []tools.Tool{
+		tools.NewAskUserTool(), tools.NewBashTool(), tools.NewBashOutputTool(), tools.NewBashKillTool(),
+		tools.NewReadTool(ws), tools.NewWriteTool(ws), tools.NewEditTool(ws), tools.NewCopyFileTool(ws), tools.NewGitDiffTool(ws), tools.NewGoModTool(ws), tools.NewFormatTool(ws), tools.NewRunLogsTool(ws),
+		tools.NewListDirTool(ws), tools.NewStatsTool(ws), tools.NewMemoryTool(store),
+		tools.NewWatchFileTool(watcher), tools.NewPollFileChangesTool(watcher),
+	} {
+		require.NoError(t, tools.ValidateTool(tool), tool.Name())
+	}
+}
+
+// 被配置关闭的工具与真正未知的工具返回不同的说明
+func TestDisabledToolCallExplainsWhy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("",
+			mockCall{ID: "call_1", Name: "bash", Args: `{"command":"ls"}`},
+			mockCall{ID: "call_2", Name: "teleport", Args: `{}`},
+		),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000, agent.WithDisabledTools("bash"))
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+
+	results := map[string]string{}
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			results[msg.ToolCallID] = msg.Content
+		}
+	}
+	require.Contains(t, results["call_1"], "Tool bash is disabled by configuration")
+	require.NotContains(t, results["call_1"], "Unknown tool")
+	require.Contains(t, results["call_2"], "Unknown tool: teleport")
+}
+
+func TestNewAgentFailsWhenWorkspaceCannotBeCreated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	m := newMockLLM(t)
+	_, err := agent.NewAgent(m.client(), "sys", nil, 5, filepath.Join(file, "ws"), 100000)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot create workspace")
+}
+
+// 只读工作空间中移除的写工具：模型收到的说明包含原因
+func TestUnavailableToolCallExplainsReason(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "write_file", Args: `{"path":"a.txt","content":"x"}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000,
+		agent.WithUnavailableTools("unavailable because the workspace is on a read-only filesystem", "write_file"))
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	var toolMsg string
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			toolMsg = msg.Content
+		}
+	}
+	require.Contains(t, toolMsg, "Tool write_file is unavailable because the workspace is on a read-only filesystem")
+}
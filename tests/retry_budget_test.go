@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/retry"
+	"gopilot-cli/internal/tools"
+)
+
+// badRequest 不会被 SDK 自身重试的失败响应
+var badRequest = mockReply{Status: http.StatusBadRequest, Body: `{"error": {"message": "flaky"}}`}
+
+// flakyReplies 每一步都先失败一次：step1 失败→成功(工具调用)，step2 失败→失败→成功(结束)
+func flakyReplies() []mockReply {
+	return []mockReply{
+		badRequest,
+		toolReply("", mockCall{ID: "call_1", Name: "noop", Args: "{}"}),
+		badRequest,
+		badRequest,
+		textReply("done"),
+	}
+}
+
+func newFlakyAgent(t *testing.T, m *mockLLM, opts ...agent.Option) *agent.Agent {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	client := m.client(llm.WithRetryConfig(&retry.Config{
+		Enabled:         true,
+		MaxRetries:      3,
+		InitialDelay:    time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		ExponentialBase: 2,
+	}))
+	ag, err := agent.NewAgent(client, "sys", []tools.Tool{namedTool{name: "noop"}}, 10, t.TempDir(), 100000, opts...)
+	require.NoError(t, err)
+	return ag
+}
+
+func TestRetryBudgetSharedAcrossSteps(t *testing.T) {
+	m := newMockLLM(t, flakyReplies()...)
+	ag := newFlakyAgent(t, m, agent.WithRetryBudget(2))
+
+	ag.AddUserMessage("go")
+	_, err := ag.Run(context.Background())
+
+	// step1 用掉 1 次、step2 用掉 1 次后预算耗尽，第二次失败直接返回
+	var exhausted *retry.ExhaustedError
+	require.True(t, errors.As(err, &exhausted), "err = %v", err)
+	require.True(t, exhausted.BudgetExhausted)
+	require.Contains(t, err.Error(), "retry budget for this run exhausted")
+	require.Len(t, m.Requests(), 4)
+
+	// 下一次 Run 重新获得完整预算
+	ag.AddUserMessage("again")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+}
+
+func TestRetryBudgetOffByDefault(t *testing.T) {
+	m := newMockLLM(t, flakyReplies()...)
+	ag := newFlakyAgent(t, m)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+	require.Len(t, m.Requests(), 5)
+}
+
+func TestRetryBudgetTake(t *testing.T) {
+	b := retry.NewBudget(2)
+	require.True(t, b.Take())
+	require.True(t, b.Take())
+	require.False(t, b.Take())
+	require.Equal(t, 0, b.Remaining())
+
+	require.Nil(t, retry.BudgetFromContext(context.Background()))
+	ctx := retry.WithBudget(context.Background(), b)
+	require.Same(t, b, retry.BudgetFromContext(ctx))
+}
@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/retry"
+)
+
+// classError 携带类别的测试错误
+type classError struct {
+	class      retry.Class
+	retryAfter time.Duration
+}
+
+func (e classError) Error() string { return string(e.class) }
+
+func classifyTestError(err error) (retry.Class, time.Duration) {
+	var ce classError
+	if errors.As(err, &ce) {
+		return ce.class, ce.retryAfter
+	}
+	return retry.ClassOther, 0
+}
+
+// policyConfig 默认策略不重试，5xx 与 429 各自单独配置
+func policyConfig() *retry.Config {
+	return &retry.Config{
+		Enabled:         true,
+		MaxRetries:      0,
+		InitialDelay:    time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		ExponentialBase: 2,
+		Classifier:      classifyTestError,
+		Policies: map[retry.Class]retry.Policy{
+			retry.ClassServer: {MaxRetries: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, ExponentialBase: 2},
+			retry.ClassRateLimit: {MaxRetries: 1, InitialDelay: time.Hour, MaxDelay: time.Hour, ExponentialBase: 2,
+				RespectRetryAfter: true},
+		},
+	}
+}
+
+// failing 依次返回 errs 中的错误，之后成功
+func failing(errs ...error) (func() (string, error), *int) {
+	calls := 0
+	return func() (string, error) {
+		calls++
+		if calls <= len(errs) {
+			return "", errs[calls-1]
+		}
+		return "ok", nil
+	}, &calls
+}
+
+func TestRetryPolicyPerClassLimits(t *testing.T) {
+	server := classError{class: retry.ClassServer}
+	limited := classError{class: retry.ClassRateLimit, retryAfter: time.Millisecond}
+
+	// 5xx 与 429 分别计数：2 次 5xx + 1 次 429 都在各自上限内
+	fn, calls := failing(server, limited, server)
+	out, err := retry.Do(context.Background(), policyConfig(), fn, nil)
+	require.NoError(t, err)
+	require.Equal(t, "ok", out)
+	require.Equal(t, 4, *calls)
+
+	// 第 3 次 5xx 超出 server 策略的上限
+	fn, calls = failing(server, server, server)
+	_, err = retry.Do(context.Background(), policyConfig(), fn, nil)
+	var exhausted *retry.ExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	require.Equal(t, 3, *calls)
+
+	// 未配置策略的类别走默认策略（此处不重试）
+	fn, calls = failing(errors.New("bad request"))
+	_, err = retry.Do(context.Background(), policyConfig(), fn, nil)
+	require.Error(t, err)
+	require.Equal(t, 1, *calls)
+}
+
+func TestRetryPolicyRespectsRetryAfter(t *testing.T) {
+	cfg := policyConfig()
+
+	// 429 按 Retry-After 等待（而不是 1 小时的退避），但不超过 MaxDelay
+	require.Equal(t, 3*time.Second, cfg.Delay(classError{class: retry.ClassRateLimit, retryAfter: 3 * time.Second}, 0))
+	require.Equal(t, time.Hour, cfg.Delay(classError{class: retry.ClassRateLimit, retryAfter: 5 * time.Hour}, 0))
+	require.Equal(t, time.Hour, cfg.Delay(classError{class: retry.ClassRateLimit}, 0))
+	require.Equal(t, time.Millisecond, cfg.Delay(classError{class: retry.ClassServer, retryAfter: time.Minute}, 0))
+}
+
+func TestClassifyErrorFromResponses(t *testing.T) {
+	cases := []struct {
+		reply      mockReply
+		class      retry.Class
+		retryAfter time.Duration
+	}{
+		{mockReply{Status: http.StatusServiceUnavailable, Body: `{"error": {"message": "down"}}`}, retry.ClassServer, 0},
+		{mockReply{Status: http.StatusTooManyRequests, Body: `{"error": {"message": "slow down"}}`,
+			Header: map[string]string{"Retry-After": "7"}}, retry.ClassRateLimit, 7 * time.Second},
+		{badRequest, retry.ClassOther, 0},
+	}
+	for _, tc := range cases {
+		m := newMockLLM(t, tc.reply)
+		// 配置了类别策略时关闭 SDK 自带的重试，每个错误只请求一次
+		c := m.client(llm.WithRetryConfig(&retry.Config{
+			Policies: map[retry.Class]retry.Policy{retry.ClassServer: {}},
+		}))
+		_, err := c.Generate(context.Background(), pingMessages, nil)
+		require.Error(t, err)
+		require.Len(t, m.Requests(), 1)
+
+		class, after := llm.ClassifyError(err)
+		require.Equal(t, tc.class, class, "status %d", tc.reply.Status)
+		require.Equal(t, tc.retryAfter, after)
+	}
+
+	// 连接失败归为网络错误
+	m := newMockLLM(t, textReply("unused"))
+	c := m.client(llm.WithRetryConfig(&retry.Config{
+		Policies: map[retry.Class]retry.Policy{retry.ClassNetwork: {}},
+	}))
+	m.Close()
+	_, err := c.Generate(context.Background(), pingMessages, nil)
+	require.Error(t, err)
+	class, _ := llm.ClassifyError(err)
+	require.Equal(t, retry.ClassNetwork, class)
+}
+
+func TestRetryPoliciesEndToEnd(t *testing.T) {
+	m := newMockLLM(t,
+		mockReply{Status: http.StatusBadGateway, Body: `{"error": {"message": "bad gateway"}}`},
+		mockReply{Status: http.StatusTooManyRequests, Body: `{"error": {"message": "slow down"}}`,
+			Header: map[string]string{"Retry-After": "0.01"}},
+		textReply("pong"),
+	)
+	cfg := policyConfig()
+	cfg.Classifier = llm.ClassifyError
+
+	var seen []retry.Class
+	c := m.client(
+		llm.WithRetryConfig(cfg),
+		llm.WithRetryCallback(func(err error, attempt int) {
+			class, _ := llm.ClassifyError(err)
+			seen = append(seen, class)
+		}),
+	)
+
+	start := time.Now()
+	resp, err := c.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, "pong", resp.Content)
+	require.Len(t, m.Requests(), 3)
+	require.Equal(t, []retry.Class{retry.ClassServer, retry.ClassRateLimit}, seen)
+	require.Less(t, time.Since(start), 10*time.Second, "429 should wait for Retry-After, not the 1h backoff")
+}
+
+func TestModelNotFoundIsReportedAndNotRetried(t *testing.T) {
+	replies := []mockReply{
+		{Status: http.StatusNotFound, Body: `{"error": {"message": "The model ` + "`mock-model`" + ` does not exist", "code": "model_not_found"}}`},
+		{Status: http.StatusBadRequest, Body: `{"error": {"message": "Invalid model name: mock-model"}}`},
+	}
+	for _, reply := range replies {
+		m := newMockLLM(t, reply, textReply("unused"))
+		// 重试开启且对所有错误生效，模型不存在仍然只请求一次
+		c := m.client(llm.WithRetryConfig(&retry.Config{
+			Enabled: true, MaxRetries: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, ExponentialBase: 2,
+		}))
+		_, err := c.Generate(context.Background(), pingMessages, nil)
+		require.Error(t, err)
+		require.Len(t, m.Requests(), 1, "status %d", reply.Status)
+
+		var notFound *llm.ModelNotFoundError
+		require.ErrorAs(t, err, &notFound)
+		require.Equal(t, "mock-model", notFound.Model)
+		require.Contains(t, err.Error(), `model "mock-model" is not available at `+m.URL)
+		require.Contains(t, err.Error(), "check llm.model")
+	}
+
+	// 其他 400 错误不受影响
+	m := newMockLLM(t, badRequest)
+	_, err := m.client().Generate(context.Background(), pingMessages, nil)
+	var notFound *llm.ModelNotFoundError
+	require.False(t, errors.As(err, &notFound))
+}
+
+func TestDelayGrowsEvenWithInvalidExponentialBase(t *testing.T) {
+	for _, base := range []float64{1.0, 0.5, 0, -2} {
+		rc := &retry.Config{
+			Enabled:         true,
+			MaxRetries:      5,
+			InitialDelay:    time.Second,
+			MaxDelay:        10 * time.Second,
+			ExponentialBase: base,
+		}
+		err := errors.New("boom")
+		require.Equal(t, time.Second, rc.Delay(err, 0), "base %g", base)
+		require.Equal(t, 2*time.Second, rc.Delay(err, 1), "base %g", base)
+		require.Equal(t, 4*time.Second, rc.Delay(err, 2), "base %g", base)
+		require.Equal(t, 10*time.Second, rc.Delay(err, 10), "base %g", base)
+		require.Equal(t, 10*time.Second, rc.Delay(err, 10000), "base %g", base)
+	}
+
+	require.False(t, retry.ValidBase(1.0))
+	require.False(t, retry.ValidBase(0.5))
+	require.True(t, retry.ValidBase(1.5))
+
+	// 合法的基数按配置计算
+	rc := &retry.Config{InitialDelay: time.Second, MaxDelay: time.Minute, ExponentialBase: 3}
+	require.Equal(t, 9*time.Second, rc.Delay(errors.New("boom"), 2))
+}
+
+func TestSetLimitsTakesEffectOnLiveClient(t *testing.T) {
+	down := mockReply{Status: http.StatusServiceUnavailable, Body: `{"error": {"message": "down"}}`}
+	m := newMockLLM(t, down, down, textReply("pong"))
+	c := m.client(llm.WithRetryConfig(&retry.Config{Enabled: false}))
+
+	rc := c.RetryConfig()
+	require.Error(t, rc.SetLimits(-1, time.Millisecond, time.Millisecond))
+	require.Error(t, rc.SetLimits(2, 0, time.Millisecond))
+	require.Error(t, rc.SetLimits(2, 10*time.Millisecond, time.Millisecond))
+	require.False(t, rc.Enabled, "rejected limits must not change the config")
+
+	require.NoError(t, rc.SetLimits(2, time.Millisecond, 5*time.Millisecond))
+	require.True(t, rc.Enabled)
+	require.Equal(t, 2, rc.MaxRetries)
+	require.Equal(t, 5*time.Millisecond, rc.MaxDelay)
+
+	resp, err := c.Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Equal(t, "pong", resp.Content)
+	require.Len(t, m.Requests(), 3)
+}
+
+func TestSetLimitsKeepsPerClassPolicies(t *testing.T) {
+	cfg := policyConfig()
+	before := maps.Clone(cfg.Policies)
+	require.NoError(t, cfg.SetLimits(5, 2*time.Millisecond, time.Second))
+	require.True(t, cfg.Enabled)
+	require.Equal(t, 5, cfg.MaxRetries)
+	require.Equal(t, time.Second, cfg.MaxDelay)
+	require.Equal(t, before, cfg.Policies, "per-class policies are not overwritten")
+
+	require.NoError(t, cfg.SetLimits(0, time.Millisecond, time.Second))
+	require.False(t, cfg.Enabled, "0 retries disables retrying")
+}
+
+func TestSetClassLimitsMergesIntoPolicies(t *testing.T) {
+	cfg := policyConfig()
+	server := cfg.Policies[retry.ClassServer]
+
+	require.NoError(t, cfg.SetClassLimits(retry.ClassRateLimit, 8, time.Second, time.Minute))
+	require.Equal(t, server, cfg.Policies[retry.ClassServer], "other classes are kept")
+	rl := cfg.Policies[retry.ClassRateLimit]
+	require.Equal(t, 8, rl.MaxRetries)
+	require.Equal(t, time.Minute, rl.MaxDelay)
+	require.True(t, rl.RespectRetryAfter, "fields not set by SetClassLimits are kept")
+
+	// 尚无策略的类别以默认策略为基础创建
+	require.NoError(t, cfg.SetClassLimits(retry.ClassNetwork, 4, time.Millisecond, time.Second))
+	require.Len(t, cfg.Policies, 3)
+	require.Equal(t, retry.Policy{MaxRetries: 4, InitialDelay: time.Millisecond, MaxDelay: time.Second, ExponentialBase: 2},
+		cfg.Policies[retry.ClassNetwork])
+
+	require.Error(t, cfg.SetClassLimits(retry.ClassNetwork, 1, time.Second, time.Millisecond))
+	require.Error(t, (&retry.Config{}).SetClassLimits(retry.ClassServer, 1, time.Second, time.Second),
+		"per-class policies need a classifier")
+}
+
+func TestRetryPolicyBackoffCountsPerClass(t *testing.T) {
+	network := classError{class: retry.ClassNetwork}
+	server := classError{class: retry.ClassServer}
+	cfg := policyConfig()
+	cfg.Policies[retry.ClassNetwork] = retry.Policy{MaxRetries: 3, InitialDelay: time.Millisecond, MaxDelay: time.Second, ExponentialBase: 10}
+	cfg.Policies[retry.ClassServer] = retry.Policy{MaxRetries: 1, InitialDelay: 2 * time.Millisecond, MaxDelay: time.Minute, ExponentialBase: 10}
+
+	var delays []time.Duration
+	onRetry := func(err error, attempt int) {
+		delays = append(delays, cfg.Delay(err, attempt-1))
+	}
+
+	// 3 次网络错误之后的 5xx 从 server 策略自己的 InitialDelay 开始退避
+	fn, calls := failing(network, network, network, server)
+	start := time.Now()
+	_, err := retry.Do(context.Background(), cfg, fn, onRetry)
+	require.NoError(t, err)
+	require.Equal(t, 5, *calls)
+	require.Equal(t, []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, 2 * time.Millisecond}, delays)
+	require.Less(t, time.Since(start), time.Second)
+}
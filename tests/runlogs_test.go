@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/logger"
+	"gopilot-cli/internal/tools"
+)
+
+// newLogFixture 创建含三个运行日志与若干无关文件的日志目录
+func newLogFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	for i, name := range []string{"agent_run_20250301_120000.log", "agent_run_20250302_090000.log", "agent_run_20250303_180000.log"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("Agent Run Log\n"+name+"\n"+strings.Repeat("x", i*100)), 0o644))
+		mtime := base.Add(time.Duration(i) * 24 * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "outside.log"), []byte("secret"), 0o644))
+	return dir
+}
+
+func TestRunLogsListNewestFirst(t *testing.T) {
+	dir := newLogFixture(t)
+	tool := tools.NewRunLogsTool(dir)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"action": "list"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	lines := strings.Split(res.Content, "\n")
+	require.Len(t, lines, 3, "only agent_run_*.log files are listed")
+	require.Contains(t, lines[0], "agent_run_20250303_180000.log")
+	require.Contains(t, lines[0], "2025-03-03 12:00:00")
+	require.Contains(t, lines[0], "244 B")
+	require.Contains(t, lines[2], "agent_run_20250301_120000.log")
+
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "list", "limit": 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(res.Content, "\n")+1)
+
+	empty, err := tools.NewRunLogsTool(filepath.Join(dir, "missing")).Execute(context.Background(), map[string]any{"action": "list"})
+	require.NoError(t, err)
+	require.True(t, empty.Success)
+	require.Contains(t, empty.Content, "No run logs")
+}
+
+func TestRunLogsReadIsConfinedToLogDir(t *testing.T) {
+	dir := newLogFixture(t)
+	tool := tools.NewRunLogsTool(dir)
+
+	res, err := tool.Execute(context.Background(), map[string]any{"action": "read", "name": "agent_run_20250302_090000.log"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Agent Run Log\nagent_run_20250302_090000.log")
+
+	res, err = tool.Execute(context.Background(), map[string]any{"action": "read", "name": "latest"})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.Content, "agent_run_20250303_180000.log:"))
+
+	for _, name := range []string{"../outside.log", "notes.txt", "/etc/passwd", "agent_run_20990101_000000.log", ""} {
+		res, err := tool.Execute(context.Background(), map[string]any{"action": "read", "name": name})
+		require.NoError(t, err)
+		require.False(t, res.Success, name)
+		require.NotContains(t, res.Content, "secret")
+	}
+}
+
+func TestLoggerWritesIntoListedDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	l, err := logger.NewAgentLogger()
+	require.NoError(t, err)
+	require.NoError(t, l.StartNewRun())
+	require.NoError(t, l.Close())
+
+	dir, err := logger.Dir()
+	require.NoError(t, err)
+	logs, err := tools.ListRunLogs(dir, 0)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, dir, filepath.Dir(logs[0].Path))
+}
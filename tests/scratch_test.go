@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func TestScratchDirLifecycle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	scratch, err := tools.NewScratchDir(ws)
+	require.NoError(t, err)
+	require.DirExists(t, scratch.Path())
+	require.Equal(t, filepath.Join(ws, filepath.FromSlash(scratch.Rel())), scratch.Path())
+	require.FileExists(t, filepath.Join(ws, ".gopilot", "tmp", ".gitignore"))
+
+	m := newMockLLM(t)
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, ws, 100000, agent.WithScratchDir(scratch))
+	require.NoError(t, err)
+	require.Contains(t, ag.History()[0].Content, scratch.Rel())
+
+	require.NoError(t, os.WriteFile(filepath.Join(scratch.Path(), "notes.txt"), []byte("x"), 0o644))
+	require.NoError(t, ag.Close())
+	require.NoDirExists(t, scratch.Path())
+	// 没有其他会话时不留下 .gopilot 目录
+	require.NoDirExists(t, filepath.Join(ws, ".gopilot"))
+}
+
+// 异常退出留下的目录在下一次创建时被清理，仍在运行的会话的目录保留
+func TestScratchDirCleansStaleDirs(t *testing.T) {
+	ws := t.TempDir()
+	root := filepath.Join(ws, ".gopilot", "tmp")
+	stale := filepath.Join(root, "999999999-crashed")
+	live := filepath.Join(root, strconv.Itoa(os.Getpid())+"-other")
+	require.NoError(t, os.MkdirAll(stale, 0o755))
+	require.NoError(t, os.MkdirAll(live, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(stale, "leftover.txt"), []byte("x"), 0o644))
+
+	scratch, err := tools.NewScratchDir(ws)
+	require.NoError(t, err)
+	require.NoDirExists(t, stale)
+	require.DirExists(t, live)
+
+	// 其他会话的目录还在时 .gopilot/tmp 保留
+	require.NoError(t, scratch.Close())
+	require.NoDirExists(t, scratch.Path())
+	require.DirExists(t, live)
+}
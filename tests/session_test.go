@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/schema"
+	"gopilot-cli/internal/session"
+	"gopilot-cli/internal/tools"
+)
+
+func TestSessionStoreRoundTrip(t *testing.T) {
+	store := session.NewStore(filepath.Join(t.TempDir(), "sessions"))
+
+	infos, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+
+	messages := []schema.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "look", Images: []schema.Image{{Name: "a.png", MIMEType: "image/png", Data: pngHeader}}},
+		{Role: "assistant", ToolCalls: []schema.ToolCall{{
+			ID: "call_1", Type: "function",
+			Function: schema.FunctionCall{Name: "read_file", Arguments: map[string]any{"path": "a.go"}},
+		}}},
+		{Role: "tool", ToolCallID: "call_1", Name: "read_file", Content: "package a"},
+	}
+	path, err := store.Save(&session.Session{Name: "older", Workspace: "/ws", Model: "m1", Messages: messages[:1],
+		SavedAt: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	require.FileExists(t, path)
+	_, err = store.Save(&session.Session{Name: "task-1", Workspace: "/ws", Model: "m2", Messages: messages})
+	require.NoError(t, err)
+
+	sess, err := store.Load("task-1")
+	require.NoError(t, err)
+	require.Equal(t, "/ws", sess.Workspace)
+	require.Equal(t, "m2", sess.Model)
+	require.Equal(t, messages, sess.Messages)
+	require.False(t, sess.SavedAt.IsZero())
+
+	infos, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, "task-1", infos[0].Name)
+	require.Equal(t, 4, infos[0].Messages)
+	require.Equal(t, "older", infos[1].Name)
+
+	// 同名保存覆盖
+	_, err = store.Save(&session.Session{Name: "task-1", Workspace: "/ws", Messages: messages[:2]})
+	require.NoError(t, err)
+	sess, err = store.Load("task-1")
+	require.NoError(t, err)
+	require.Len(t, sess.Messages, 2)
+}
+
+func TestSessionStoreErrors(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+
+	for _, name := range []string{"", "../escape", "a/b", ".hidden", "with space"} {
+		_, err := store.Save(&session.Session{Name: name})
+		require.Error(t, err, "name %q", name)
+	}
+
+	_, err := store.Load("missing")
+	require.True(t, errors.Is(err, fs.ErrNotExist), "got %v", err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir(), "broken.json"), []byte("{not json"), 0o644))
+	_, err = store.Load("broken")
+	require.ErrorContains(t, err, "corrupted")
+	infos, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
+
+func TestAgentLoadHistoryKeepsCurrentSystemPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t, textReply("continuing"))
+	ag, err := agent.NewAgent(m.client(), "current prompt", nil, 5, t.TempDir(), 100000)
+	require.NoError(t, err)
+	ag.AddUserMessage("unrelated")
+
+	ag.LoadHistory([]schema.Message{
+		{Role: "system", Content: "old prompt"},
+		{Role: "user", Content: "build the parser"},
+		{Role: "assistant", Content: "parser done"},
+	})
+	history := ag.History()
+	require.Len(t, history, 3)
+	require.True(t, strings.HasPrefix(history[0].Content, "current prompt"), history[0].Content)
+	require.Equal(t, "build the parser", history[1].Content)
+
+	ag.AddUserMessage("now add tests")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "parser done", sentMessage(t, m.Requests()[0], 2)["content"])
+	require.Equal(t, "now add tests", sentMessage(t, m.Requests()[0], 3)["content"])
+}
+
+func TestSessionStoreLatestPerWorkspace(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	wsA, wsB := t.TempDir(), t.TempDir()
+
+	_, err := store.Latest(wsA)
+	require.True(t, errors.Is(err, fs.ErrNotExist), "got %v", err)
+
+	autosave := session.AutosaveName(wsA)
+	require.NoError(t, session.ValidateName(autosave))
+	require.NotEqual(t, autosave, session.AutosaveName(wsB))
+
+	now := time.Now()
+	_, err = store.Save(&session.Session{Name: "a-named", Workspace: wsA, SavedAt: now.Add(-2 * time.Hour)})
+	require.NoError(t, err)
+	_, err = store.Save(&session.Session{Name: autosave, Workspace: wsA + string(filepath.Separator), SavedAt: now.Add(-time.Hour)})
+	require.NoError(t, err)
+	_, err = store.Save(&session.Session{Name: "b-newest", Workspace: wsB, SavedAt: now})
+	require.NoError(t, err)
+
+	sess, err := store.Latest(wsA)
+	require.NoError(t, err)
+	require.Equal(t, autosave, sess.Name)
+	sess, err = store.Latest(wsB)
+	require.NoError(t, err)
+	require.Equal(t, "b-newest", sess.Name)
+}
+
+func TestSessionStoreOverwriteConflict(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	wsA, wsB := t.TempDir(), t.TempDir()
+
+	require.Empty(t, store.OverwriteConflict("wip", wsA), "new name")
+	_, err := store.Save(&session.Session{Name: "wip", Workspace: wsA})
+	require.NoError(t, err)
+
+	// 同一工作空间覆盖自己的会话不需要确认，其他工作空间的同名会话需要
+	require.Empty(t, store.OverwriteConflict("wip", wsA+string(filepath.Separator)))
+	require.Contains(t, store.OverwriteConflict("wip", wsB), wsA)
+
+	// 自动保存的名称保留给 autosave
+	require.Contains(t, store.OverwriteConflict(session.AutosaveName(wsA), wsA), "reserved")
+	require.Contains(t, store.OverwriteConflict("autosave-mine", wsA), "reserved")
+}
+
+func TestBackgroundShellsSurviveSessionRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	res, err := tools.NewBashTool().Execute(ctx, map[string]any{
+		"command":           "echo first-line; sleep 30",
+		"run_in_background": true,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	id := res.BashID
+
+	// 只保存本测试的进程，其他测试登记的进程不受影响
+	var snaps []tools.ShellSnapshot
+	require.Eventually(t, func() bool {
+		for _, snap := range tools.SnapshotBackgroundShells() {
+			if snap.ID == id && len(snap.Unread) > 0 {
+				require.Equal(t, "running", snap.Status)
+				snaps = []tools.ShellSnapshot{snap}
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+
+	store := session.NewStore(t.TempDir())
+	_, err = store.Save(&session.Session{Name: "with-shells", Workspace: "/ws", Shells: snaps})
+	require.NoError(t, err)
+
+	// 模拟退出：进程被终止并从登记中移除
+	_, err = tools.NewBashKillTool().Execute(ctx, map[string]any{"bash_id": id})
+	require.NoError(t, err)
+	require.NotContains(t, tools.BackgroundShellIDs(), id)
+
+	sess, err := store.Load("with-shells")
+	require.NoError(t, err)
+	require.Equal(t, 1, tools.RestoreBackgroundShells(sess.Shells))
+	require.Contains(t, tools.BackgroundShellIDs(), id)
+	require.Zero(t, tools.RestoreBackgroundShells(sess.Shells), "IDs already registered are skipped")
+
+	out, err := tools.NewBashOutputTool().Execute(ctx, map[string]any{"bash_id": id})
+	require.NoError(t, err)
+	require.True(t, out.Success, out.Error)
+	require.Contains(t, out.Stdout, "first-line")
+	require.Contains(t, out.Stdout, "restored from a previous session")
+	require.Equal(t, -1, out.ExitCode)
+
+	killed, err := tools.NewBashKillTool().Execute(ctx, map[string]any{"bash_id": id})
+	require.NoError(t, err)
+	require.True(t, killed.Success, killed.Error)
+	require.NotContains(t, tools.BackgroundShellIDs(), id)
+}
+
+func TestSessionStorePruneAutosaves(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	wsA, wsB := t.TempDir(), t.TempDir()
+	now := time.Now()
+
+	save := func(name, ws string, age time.Duration) {
+		t.Helper()
+		_, err := store.Save(&session.Session{Name: name, Workspace: ws, SavedAt: now.Add(-age)})
+		require.NoError(t, err)
+	}
+	save(session.AutosaveName(wsA), wsA, time.Hour)
+	save("autosave-a-old-copy", wsA, 2*time.Hour)         // 同一工作空间较旧的副本
+	save(session.AutosaveName(wsB), wsB, 60*24*time.Hour) // 超过期限
+	save("named", wsB, 90*24*time.Hour)                   // /save 保存的会话不受影响
+
+	removed, err := store.PruneAutosaves(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"autosave-a-old-copy", session.AutosaveName(wsB)}, removed)
+
+	infos, err := store.List()
+	require.NoError(t, err)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	require.Equal(t, []string{session.AutosaveName(wsA), "named"}, names)
+	require.FileExists(t, store.Path("named"))
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// bigOutputTool 返回 n 行输出
+type bigOutputTool struct{ n int }
+
+func (t bigOutputTool) Name() string               { return "big" }
+func (t bigOutputTool) Description() string        { return "Prints a lot" }
+func (t bigOutputTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t bigOutputTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	var b strings.Builder
+	for i := 1; i <= t.n; i++ {
+		fmt.Fprintf(&b, "log line %d\n", i)
+	}
+	return &tools.ToolResult{Success: true, Content: b.String()}, nil
+}
+
+func runBigTool(t *testing.T, ws string, n int, opts ...agent.Option) string {
+	t.Helper()
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "big", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{bigOutputTool{n: n}}, 5, ws, 100000, opts...)
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
+func TestLargeToolResultIsSpilledToFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	content := runBigTool(t, ws, 5000, agent.WithToolResultSpill(1000))
+	require.Contains(t, content, "5000 lines")
+	require.Contains(t, content, "log line 1\n")
+	require.Contains(t, content, "log line 5000")
+	require.NotContains(t, content, "log line 2500\n")
+	require.Less(t, len(content), 2000)
+
+	path := regexp.MustCompile(`saved to (\S+)\.`).FindStringSubmatch(content)
+	require.NotNil(t, path, content)
+	require.True(t, strings.HasPrefix(path[1], ".gopilot/spill/big_"), path[1])
+
+	data, err := os.ReadFile(filepath.Join(ws, path[1]))
+	require.NoError(t, err)
+	require.Equal(t, 5000, strings.Count(string(data), "\n"))
+	require.FileExists(t, filepath.Join(ws, ".gopilot", "spill", ".gitignore"))
+
+	// 模型可以用 read_file 按需读取保存的输出
+	res, err := tools.NewReadTool(ws).Execute(context.Background(), map[string]any{"path": path[1], "offset": 2500, "limit": 1})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "log line 2500")
+}
+
+func TestSmallToolResultStaysInline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	content := runBigTool(t, ws, 3, agent.WithToolResultSpill(1000))
+	require.Equal(t, "log line 1\nlog line 2\nlog line 3\n", content)
+	require.NoDirExists(t, filepath.Join(ws, ".gopilot", "spill"))
+
+	// 未开启时即使很大也原样放入上下文
+	content = runBigTool(t, ws, 5000)
+	require.Contains(t, content, "log line 2500\n")
+}
+
+func TestSpilledToolResultsAreRemovedOnClose(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "big", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{bigOutputTool{n: 5000}}, 5, ws, 100000,
+		agent.WithToolResultSpill(1000))
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+
+	// 其他会话留下的文件不受影响
+	other := filepath.Join(ws, ".gopilot", "spill", "other.txt")
+	require.NoError(t, os.WriteFile(other, []byte("x"), 0o644))
+
+	files, err := filepath.Glob(filepath.Join(ws, ".gopilot", "spill", "big_*.txt"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	require.NoError(t, ag.Close())
+	require.NoFileExists(t, files[0])
+	require.FileExists(t, other)
+
+}
+
+func TestSpillDirectoryRemovedWhenEmpty(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "big", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{bigOutputTool{n: 5000}}, 5, ws, 100000,
+		agent.WithToolResultSpill(1000))
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+	_, err = ag.Run(context.Background())
+	require.NoError(t, err)
+	require.DirExists(t, filepath.Join(ws, ".gopilot", "spill"))
+
+	require.NoError(t, ag.Close())
+	require.NoDirExists(t, filepath.Join(ws, ".gopilot"))
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	terminal "gopilot-cli/internal/utils/terminal"
+)
+
+// syncBuffer 可并发写入的缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSpinnerStopsCleanly(t *testing.T) {
+	var out syncBuffer
+	s := terminal.StartSpinner(&out, "Thinking...", 5*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		s.Stop() // 重复调用不会阻塞或 panic
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	// Stop 返回后不再写入，最后一次写入清除了 spinner 所在行
+	written := out.String()
+	require.Contains(t, written, "Thinking... (0s)")
+	require.True(t, strings.HasSuffix(written, "\r\033[K"))
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, written, out.String())
+
+	var nilSpinner *terminal.Spinner
+	nilSpinner.Stop()
+}
+
+func TestProgressIndicatorRunsOnlyDuringLLMCall(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var out syncBuffer
+	m := newMockLLM(t, textReply("done"))
+	m.hook = func(n int, r *http.Request) { time.Sleep(250 * time.Millisecond) }
+
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000, agent.WithProgressIndicator(&out))
+	require.NoError(t, err)
+	ag.AddUserMessage("hi")
+	got, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", got)
+
+	written := out.String()
+	require.Contains(t, written, "Thinking...")
+	require.True(t, strings.HasSuffix(written, "\r\033[K"))
+	time.Sleep(250 * time.Millisecond)
+	require.Equal(t, written, out.String(), "spinner kept writing after the response arrived")
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/tools"
+)
+
+func TestWorkspaceStats(t *testing.T) {
+	ws := makeTreeFixture(t)
+	files := map[string]string{
+		"cmd/app/util.go": "package main\n\nfunc f() {}", // 3 行，最后一行无换行
+		"img.png":         "\x89PNG\x00\x00data",         // 二进制：计文件数不计行数
+		".git/HEAD":       "ref: refs/heads/main\n",      // .git 始终忽略
+		"docs/big.md":     strings.Repeat("line\n", 400),
+	}
+	for name, content := range files {
+		p := filepath.Join(ws, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	tool := tools.NewStatsTool(ws)
+	res, err := tool.Execute(context.Background(), map[string]any{"top": float64(2)})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	// .gitignore、main.go、util.go、go.mod、img.png、big.md；build/ 与 *.log 被忽略
+	require.Contains(t, res.Content, "Files: 6 (binary: 1)  Lines: 407")
+	require.Regexp(t, `\.md\s+1\s+400\s`, res.Content)
+	require.Regexp(t, `\.go\s+2\s+4\s`, res.Content)
+	require.Regexp(t, `\.png\s+1\s+0\s`, res.Content)
+	require.NotContains(t, res.Content, ".log")
+	require.NotContains(t, res.Content, "HEAD")
+
+	// 最大的文件排在最前，按 top 截取
+	largest := res.Content[strings.Index(res.Content, "Largest files"):]
+	require.Contains(t, largest, "docs/big.md")
+	require.Equal(t, 2, strings.Count(largest, "\n"))
+
+	// 限定子目录，且不允许越出 workspace
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "cmd"})
+	require.True(t, res.Success, res.Error)
+	require.Contains(t, res.Content, "Files: 2 (binary: 0)  Lines: 4")
+	require.Contains(t, res.Content, "app/util.go")
+
+	res, _ = tool.Execute(context.Background(), map[string]any{"path": "../"})
+	require.False(t, res.Success)
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func runUntilStepLimit(t *testing.T, maxSteps int, opts ...agent.Option) string {
+	t.Helper()
+	var replies []mockReply
+	for i := 0; i < maxSteps; i++ {
+		replies = append(replies, toolReply("", mockCall{ID: "call", Name: "noop", Args: `{}`}))
+	}
+	m := newMockLLM(t, replies...)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "noop", desc: "does nothing"}}, maxSteps, t.TempDir(), 100000, opts...)
+	require.NoError(t, err)
+	ag.AddUserMessage("loop")
+	return captureStdout(t, func() {
+		_, err := ag.Run(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, agent.OutcomeStepLimit, ag.LastOutcome())
+	})
+}
+
+func TestStepWarningFiresAtThreshold(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	out := runUntilStepLimit(t, 5, agent.WithStepWarning(0.8))
+	require.Equal(t, 1, strings.Count(out, "approaching the step limit"))
+	require.Contains(t, out, "Step 4 of 5: approaching the step limit; the run stops after step 5")
+
+	// 警告出现在第 4 步的 Step 框之后、第 5 步之前
+	warn := strings.Index(out, "approaching the step limit")
+	require.Greater(t, warn, strings.Index(out, "Step 4/5"))
+	require.Less(t, warn, strings.Index(out, "Step 5/5"))
+
+	// 比例向上取整：10 步的 75% 在第 8 步提示
+	out = runUntilStepLimit(t, 10, agent.WithStepWarning(0.75))
+	require.Contains(t, out, "Step 8 of 10: approaching")
+
+	// 未开启时不提示
+	out = runUntilStepLimit(t, 5)
+	require.NotContains(t, out, "approaching the step limit")
+}
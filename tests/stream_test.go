@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+// streamReply 把 chunks 编码为 SSE 响应（每个 chunk 一个 data 行，以 [DONE] 结束）
+func streamReply(chunks ...map[string]any) mockReply {
+	var b strings.Builder
+	for _, c := range chunks {
+		c["id"] = "chatcmpl-mock"
+		c["object"] = "chat.completion.chunk"
+		c["created"] = 1700000000
+		c["model"] = "mock-model"
+		if _, ok := c["choices"]; !ok {
+			c["choices"] = []any{}
+		}
+		data, _ := json.Marshal(c)
+		b.WriteString("data: " + string(data) + "\n\n")
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return mockReply{
+		Status: http.StatusOK,
+		Body:   b.String(),
+		Header: map[string]string{"Content-Type": "text/event-stream"},
+	}
+}
+
+// deltaChunk 第一个候选的一段增量
+func deltaChunk(delta map[string]any, finish string) map[string]any {
+	choice := map[string]any{"index": 0, "delta": delta}
+	if finish != "" {
+		choice["finish_reason"] = finish
+	}
+	return map[string]any{"choices": []any{choice}}
+}
+
+func TestGenerateStreamEmitsDeltasAndAssemblesToolCalls(t *testing.T) {
+	m := newMockLLM(t, streamReply(
+		deltaChunk(map[string]any{"role": "assistant", "reasoning_content": "hmm"}, ""),
+		deltaChunk(map[string]any{"content": "Let me "}, ""),
+		deltaChunk(map[string]any{"content": "check."}, ""),
+		deltaChunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index": 0, "id": "call_1", "type": "function",
+			"function": map[string]any{"name": "read_file", "arguments": `{"path":`},
+		}}}, ""),
+		deltaChunk(map[string]any{"tool_calls": []any{map[string]any{
+			"index": 0, "function": map[string]any{"arguments": ` "main.go"}`},
+		}}}, ""),
+		deltaChunk(map[string]any{}, "tool_calls"),
+		map[string]any{"usage": map[string]any{"prompt_tokens": 12, "completion_tokens": 7, "total_tokens": 19}},
+	))
+
+	var deltas []llm.StreamDelta
+	resp, err := m.client().GenerateStream(context.Background(), []schema.Message{{Role: "user", Content: "hi"}}, nil, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []llm.StreamDelta{
+		{Thinking: "hmm"},
+		{Content: "Let me "},
+		{Content: "check."},
+	}, deltas)
+	require.Equal(t, "Let me check.", resp.Content)
+	require.Equal(t, "hmm", resp.Thinking)
+	require.Equal(t, "tool_calls", resp.FinishReason)
+	require.Len(t, resp.ToolCalls, 1)
+	require.Equal(t, "call_1", resp.ToolCalls[0].ID)
+	require.Equal(t, "read_file", resp.ToolCalls[0].Function.Name)
+	require.Equal(t, map[string]any{"path": "main.go"}, resp.ToolCalls[0].Function.Arguments)
+	require.NotNil(t, resp.Usage)
+	require.Equal(t, 19, resp.Usage.TotalTokens)
+
+	req := m.Requests()[0]
+	require.Equal(t, true, req["stream"])
+	require.Equal(t, map[string]any{"include_usage": true}, req["stream_options"])
+}
+
+func TestAgentStreamingPrintsReplyOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	m := newMockLLM(t, streamReply(
+		deltaChunk(map[string]any{"role": "assistant", "content": "streamed "}, ""),
+		deltaChunk(map[string]any{"content": "answer"}, "stop"),
+	))
+	ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000, agent.WithStreaming(true))
+	require.NoError(t, err)
+	ag.AddUserMessage("go")
+
+	var result string
+	out := captureStdout(t, func() {
+		result, err = ag.Run(context.Background())
+	})
+	require.NoError(t, err)
+	require.Equal(t, "streamed answer", result)
+	require.Equal(t, 1, strings.Count(out, "streamed answer"))
+	require.Equal(t, 1, strings.Count(out, "Assistant:"))
+	require.Equal(t, true, m.Requests()[0]["stream"])
+}
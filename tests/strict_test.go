@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/tools"
+)
+
+// sentFunction 取出请求中第 i 个工具的 function 定义
+func sentFunction(t *testing.T, req map[string]any, i int) map[string]any {
+	t.Helper()
+	toolDefs, ok := req["tools"].([]any)
+	require.True(t, ok, "request has no tools")
+	require.Greater(t, len(toolDefs), i)
+	return toolDefs[i].(map[string]any)["function"].(map[string]any)
+}
+
+func TestStrictToolsSchemaSent(t *testing.T) {
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadTool(t.TempDir()))
+
+	m := newMockLLM(t, textReply("pong"))
+	_, err := m.client(llm.WithStrictTools(true)).Generate(context.Background(), pingMessages, registry)
+	require.NoError(t, err)
+
+	fn := sentFunction(t, m.Requests()[0], 0)
+	require.Equal(t, "read_file", fn["name"])
+	require.Equal(t, true, fn["strict"])
+
+	params := fn["parameters"].(map[string]any)
+	require.Equal(t, false, params["additionalProperties"])
+	// 原有 required 在前，其余属性按名称追加
+	require.Equal(t, []any{"path", "column", "context_lines", "context_pattern", "limit", "offset"}, params["required"])
+
+	props := params["properties"].(map[string]any)
+	require.Equal(t, "string", props["path"].(map[string]any)["type"])
+	require.Equal(t, []any{"integer", "null"}, props["offset"].(map[string]any)["type"])
+	require.Equal(t, []any{"integer", "null"}, props["limit"].(map[string]any)["type"])
+}
+
+func TestStrictToolsOffByDefault(t *testing.T) {
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadTool(t.TempDir()))
+
+	m := newMockLLM(t, textReply("pong"))
+	_, err := m.client().Generate(context.Background(), pingMessages, registry)
+	require.NoError(t, err)
+
+	fn := sentFunction(t, m.Requests()[0], 0)
+	require.NotContains(t, fn, "strict")
+	params := fn["parameters"].(map[string]any)
+	require.NotContains(t, params, "additionalProperties")
+	require.Equal(t, []any{"path"}, params["required"])
+}
+
+func TestStrictSchemaNested(t *testing.T) {
+	original := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"mode": map[string]any{"type": "string", "enum": []string{"a", "b"}},
+			"edits": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"old": map[string]any{"type": "string"},
+						"new": map[string]any{"type": "string"},
+					},
+					"required": []string{"old"},
+				},
+			},
+		},
+		"required": []string{"edits"},
+	}
+
+	strict := tools.StrictSchema(original)
+	require.Equal(t, false, strict["additionalProperties"])
+	require.Equal(t, []string{"edits", "mode"}, strict["required"])
+
+	props := strict["properties"].(map[string]any)
+	mode := props["mode"].(map[string]any)
+	require.Equal(t, []any{"string", "null"}, mode["type"])
+	require.Equal(t, []any{"a", "b", nil}, mode["enum"])
+
+	item := props["edits"].(map[string]any)["items"].(map[string]any)
+	require.Equal(t, false, item["additionalProperties"])
+	require.Equal(t, []string{"old", "new"}, item["required"])
+	require.Equal(t, []any{"string", "null"}, item["properties"].(map[string]any)["new"].(map[string]any)["type"])
+
+	// 原 schema 不被修改
+	require.NotContains(t, original, "additionalProperties")
+	require.Equal(t, []string{"edits"}, original["required"])
+	require.Equal(t, "string", original["properties"].(map[string]any)["mode"].(map[string]any)["type"])
+}
+
+// 严格模式下模型以 null 表示未提供可选参数，解析时应当丢弃
+func TestNullArgumentsDropped(t *testing.T) {
+	m := newMockLLM(t, toolReply("",
+		mockCall{ID: "call_1", Name: "read_file", Args: `{"path": "a.txt", "offset": null, "limit": null}`},
+	))
+
+	resp, err := m.client().Generate(context.Background(), pingMessages, nil)
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 1)
+	require.Equal(t, map[string]any{"path": "a.txt"}, resp.ToolCalls[0].Function.Arguments)
+}
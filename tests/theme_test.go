@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/agent/colors"
+	"gopilot-cli/internal/tools"
+)
+
+// captureStdout 运行 fn 并返回其间写入 os.Stdout 的内容
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestMonoThemeEmitsNoEscapeCodes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { require.NoError(t, colors.Apply("default", nil)) })
+
+	run := func() string {
+		m := newMockLLM(t,
+			toolReply("checking", mockCall{ID: "call_1", Name: "probe", Args: `{"x": 1}`}),
+			textReply("all done"),
+		)
+		ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{namedTool{name: "probe"}}, 5, t.TempDir(), 100000)
+		require.NoError(t, err)
+		ag.AddUserMessage("go")
+		return captureStdout(t, func() {
+			_, err = ag.Run(context.Background())
+		})
+	}
+
+	require.NoError(t, colors.Apply("default", nil))
+	require.Contains(t, run(), "\x1b[")
+
+	require.NoError(t, colors.Apply("mono", nil))
+	require.False(t, colors.Enabled())
+	out := run()
+	require.Contains(t, out, "all done")
+	require.NotContains(t, out, "\x1b[")
+}
+
+func TestThemeOverrides(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, colors.Apply("default", nil)) })
+
+	require.NoError(t, colors.Apply("light", map[string]string{"cyan": "blue", "dim": "none", "red": "38;5;160"}))
+	require.Equal(t, "\x1b[34m", colors.CYAN)
+	require.Equal(t, "", colors.DIM)
+	require.Equal(t, "\x1b[38;5;160m", colors.RED)
+	require.Equal(t, "\x1b[38;5;130m", colors.BRIGHT_YELLOW)
+	require.True(t, colors.Enabled())
+
+	err := colors.Apply("solarized", nil)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "default, light, mono"), err.Error())
+	require.Error(t, colors.Apply("default", map[string]string{"orange": "33"}))
+	require.Error(t, colors.Apply("default", map[string]string{"red": "\x1b[31m"}))
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+// thinkingReply 带 reasoning_content 的最终回复
+func thinkingReply(content, thinking string) mockReply {
+	var body map[string]any
+	_ = json.Unmarshal([]byte(completionBody(content, nil, nil)), &body)
+	body["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)["reasoning_content"] = thinking
+	b, _ := json.Marshal(body)
+	return mockReply{Status: http.StatusOK, Body: string(b)}
+}
+
+// sentMessage 取出请求中的第 i 条消息
+func sentMessage(t *testing.T, req map[string]any, i int) map[string]any {
+	t.Helper()
+	msgs, _ := req["messages"].([]any)
+	require.Greater(t, len(msgs), i)
+	return msgs[i].(map[string]any)
+}
+
+func TestThinkingNotResentByDefault(t *testing.T) {
+	history := []schema.Message{
+		{Role: "user", Content: "ping"},
+		{Role: "assistant", Content: "pong", Thinking: "the user said ping"},
+		{Role: "user", Content: "again"},
+	}
+
+	m := newMockLLM(t, textReply("pong"))
+	_, err := m.client().Generate(context.Background(), history, nil)
+	require.NoError(t, err)
+	require.NotContains(t, sentMessage(t, m.Requests()[0], 1), "reasoning_content")
+
+	m = newMockLLM(t, textReply("pong"))
+	_, err = m.client(llm.WithResendThinking(true)).Generate(context.Background(), history, nil)
+	require.NoError(t, err)
+	msg := sentMessage(t, m.Requests()[0], 1)
+	require.Equal(t, "assistant", msg["role"])
+	require.Equal(t, "the user said ping", msg["reasoning_content"])
+}
+
+func TestThinkingStorage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	run := func(opts ...agent.Option) []schema.Message {
+		m := newMockLLM(t, thinkingReply("done", "let me think"))
+		ag, err := agent.NewAgent(m.client(), "sys", nil, 5, t.TempDir(), 100000, opts...)
+		require.NoError(t, err)
+		ag.AddUserMessage("go")
+		out, err := ag.Run(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "done", out)
+		return ag.History()
+	}
+
+	// 默认只显示，不保存到历史
+	h := run()
+	require.Equal(t, "done", h[len(h)-1].Content)
+	require.Empty(t, h[len(h)-1].Thinking)
+
+	h = run(agent.WithThinking(false, true))
+	require.Equal(t, "let me think", h[len(h)-1].Thinking)
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// failingTool 除非参数 ok 为 true，否则总是失败（模拟没有权限的环境）
+type failingTool struct{}
+
+func (failingTool) Name() string        { return "touch" }
+func (failingTool) Description() string { return "Touches a file." }
+func (failingTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}}
+}
+
+func (failingTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	if ok, _ := args["ok"].(bool); ok {
+		return &tools.ToolResult{Success: true, Content: "touched"}, nil
+	}
+	return &tools.ToolResult{Success: false, Error: "permission denied: /workspace/out.txt\n(details)"}, nil
+}
+
+func runWithFailures(t *testing.T, limit int, replies ...mockReply) (*agent.Agent, *mockLLM, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m := newMockLLM(t, replies...)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{failingTool{}}, 20, t.TempDir(), 100000,
+		agent.WithMaxConsecutiveToolFailures(limit))
+	require.NoError(t, err)
+	ag.AddUserMessage("touch out.txt")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	return ag, m, out
+}
+
+func TestConsecutiveToolFailuresAbortEarly(t *testing.T) {
+	fail := toolReply("", mockCall{ID: "call_1", Name: "touch", Args: `{}`})
+	var replies []mockReply
+	for i := 0; i < 20; i++ {
+		replies = append(replies, fail)
+	}
+
+	ag, m, out := runWithFailures(t, 3, replies...)
+
+	// 第 4 次连续失败后结束，而不是用完 20 步
+	require.Len(t, m.Requests(), 4)
+	require.Equal(t, agent.OutcomeToolFailures, ag.LastOutcome())
+	require.Contains(t, out, "Aborted: the last 4 tool calls all failed (agent.max_consecutive_tool_failures is 3)")
+	require.Contains(t, out, "- touch (4×): permission denied: /workspace/out.txt\n")
+	require.NotContains(t, out, "(details)")
+
+	result := ag.LastResult()
+	require.Equal(t, 4, result.ToolFailures)
+	require.Equal(t, agent.ExitToolFailures, result.ExitCode(nil, 0))
+}
+
+func TestConsecutiveToolFailuresResetOnSuccess(t *testing.T) {
+	fail := toolReply("", mockCall{ID: "call_1", Name: "touch", Args: `{}`})
+	ok := toolReply("", mockCall{ID: "call_2", Name: "touch", Args: `{"ok": true}`})
+
+	ag, m, out := runWithFailures(t, 3, fail, fail, fail, ok, fail, fail, fail, textReply("done"))
+
+	require.Len(t, m.Requests(), 8)
+	require.Equal(t, agent.OutcomeDone, ag.LastOutcome())
+	require.Equal(t, "done", out)
+}
+
+func TestConsecutiveToolFailuresUnlimitedByDefault(t *testing.T) {
+	fail := toolReply("", mockCall{ID: "call_1", Name: "touch", Args: `{}`})
+	ag, m, _ := runWithFailures(t, 0, fail, fail, fail, fail, fail, textReply("gave up"))
+
+	require.Len(t, m.Requests(), 6)
+	require.Equal(t, agent.OutcomeDone, ag.LastOutcome())
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// stampTool 记录每次被调用的时间
+type stampTool struct {
+	name  string
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (t *stampTool) Name() string               { return t.name }
+func (t *stampTool) Description() string        { return "Records call times" }
+func (t *stampTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *stampTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, time.Now())
+	return &tools.ToolResult{Success: true, Content: "ok"}, nil
+}
+
+func TestToolRateLimitSpacesCalls(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	limited := &stampTool{name: "limited"}
+	free := &stampTool{name: "free"}
+	m := newMockLLM(t,
+		toolReply("",
+			mockCall{ID: "call_1", Name: "limited", Args: `{}`},
+			mockCall{ID: "call_2", Name: "free", Args: `{}`},
+			mockCall{ID: "call_3", Name: "limited", Args: `{}`},
+			mockCall{ID: "call_4", Name: "free", Args: `{}`},
+			mockCall{ID: "call_5", Name: "limited", Args: `{}`},
+		),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{limited, free}, 5, t.TempDir(), 100000,
+		agent.WithToolRateLimits(map[string]float64{"limited": 10}))
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+
+	// 10 次/秒：相邻两次调用至少间隔约 100ms，且调用都被执行而不是拒绝
+	require.Len(t, limited.calls, 3)
+	for i := 1; i < len(limited.calls); i++ {
+		require.GreaterOrEqual(t, limited.calls[i].Sub(limited.calls[i-1]), 90*time.Millisecond)
+	}
+	require.Len(t, free.calls, 2)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+// slowTool 一直等到被取消；hint 为它声明的超时（0 表示未声明）
+type slowTool struct {
+	hint      time.Duration
+	cancelled chan struct{}
+}
+
+func (t *slowTool) Name() string               { return "slow" }
+func (t *slowTool) Description() string        { return "Waits until cancelled" }
+func (t *slowTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *slowTool) TimeoutHint() time.Duration { return t.hint }
+func (t *slowTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	<-ctx.Done()
+	close(t.cancelled)
+	return nil, ctx.Err()
+}
+
+func runSlowTool(t *testing.T, tool *slowTool, opts ...agent.Option) string {
+	t.Helper()
+	m := newMockLLM(t,
+		toolReply("", mockCall{ID: "call_1", Name: "slow", Args: `{}`}),
+		textReply("done"),
+	)
+	ag, err := agent.NewAgent(m.client(), "sys", []tools.Tool{tool}, 5, t.TempDir(), 100000, opts...)
+	require.NoError(t, err)
+
+	ag.AddUserMessage("go")
+	out, err := ag.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "done", out)
+
+	var toolMsg string
+	for _, msg := range ag.History() {
+		if msg.Role == "tool" {
+			toolMsg = msg.Content
+		}
+	}
+	return toolMsg
+}
+
+func TestToolTimeoutHintCancelsTool(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// 工具声明的超时优先于全局默认值
+	tool := &slowTool{hint: 50 * time.Millisecond, cancelled: make(chan struct{})}
+	start := time.Now()
+	toolMsg := runSlowTool(t, tool, agent.WithToolTimeout(time.Hour))
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.Contains(t, toolMsg, "tool slow timed out after 50ms")
+
+	select {
+	case <-tool.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool context was not cancelled")
+	}
+}
+
+func TestToolTimeoutFallsBackToDefault(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tool := &slowTool{cancelled: make(chan struct{})}
+	toolMsg := runSlowTool(t, tool, agent.WithToolTimeout(50*time.Millisecond))
+	require.Contains(t, toolMsg, "tool slow timed out after 50ms")
+
+	require.Equal(t, 15*time.Minute, tools.ToolTimeout(tools.NewBashTool(), time.Minute))
+	require.Equal(t, time.Minute, tools.ToolTimeout(panicTool{}, time.Minute))
+}
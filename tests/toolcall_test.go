@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/llm"
+	"gopilot-cli/internal/schema"
+)
+
+// 片段交错乱序到达：不同 index 的片段互相穿插
+func TestMergeToolCallFragments_OutOfOrder(t *testing.T) {
+	frags := []llm.ToolCallFragment{
+		{Index: 1, ID: "call_b", Name: "bash", Arguments: `{"comm`},
+		{Index: 0, ID: "call_a", Name: "read_file", Arguments: `{"pa`},
+		{Index: 1, Arguments: `and": "ls -la"}`},
+		{Index: 0, Arguments: `th": "main.go"}`},
+	}
+
+	calls, err := llm.MergeToolCallFragments(frags)
+	require.NoError(t, err)
+	require.Len(t, calls, 2)
+
+	require.Equal(t, "call_a", calls[0].ID)
+	require.Equal(t, "read_file", calls[0].Function.Name)
+	require.Equal(t, "main.go", calls[0].Function.Arguments["path"])
+
+	require.Equal(t, "call_b", calls[1].ID)
+	require.Equal(t, "bash", calls[1].Function.Name)
+	require.Equal(t, "ls -la", calls[1].Function.Arguments["command"])
+}
+
+// 在 token 中间、甚至 UTF-8 多字节字符中间切分的参数
+func TestMergeToolCallFragments_SplitMidToken(t *testing.T) {
+	full := `{"path": "docs/说明.md", "content": "你好，世界"}`
+	raw := []byte(full)
+
+	// 每 3 字节切一段，必然会切断中文字符
+	var frags []llm.ToolCallFragment
+	for i := 0; i < len(raw); i += 3 {
+		end := i + 3
+		if end > len(raw) {
+			end = len(raw)
+		}
+		f := llm.ToolCallFragment{Index: 0, Arguments: string(raw[i:end])}
+		if i == 0 {
+			f.ID = "call_1"
+			f.Name = "write_file"
+		}
+		frags = append(frags, f)
+	}
+
+	calls, err := llm.MergeToolCallFragments(frags)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	require.Equal(t, "docs/说明.md", calls[0].Function.Arguments["path"])
+	require.Equal(t, "你好，世界", calls[0].Function.Arguments["content"])
+}
+
+// 没有 index 的片段按 id 归并
+func TestMergeToolCallFragments_ByID(t *testing.T) {
+	frags := []llm.ToolCallFragment{
+		{Index: -1, ID: "x", Name: "a", Arguments: `{"n":`},
+		{Index: -1, ID: "y", Name: "b", Arguments: `{}`},
+		{Index: -1, ID: "x", Arguments: `1}`},
+	}
+
+	calls, err := llm.MergeToolCallFragments(frags)
+	require.NoError(t, err)
+	require.Len(t, calls, 2)
+	require.Equal(t, "x", calls[0].ID)
+	require.Equal(t, float64(1), calls[0].Function.Arguments["n"])
+	require.Equal(t, "y", calls[1].ID)
+}
+
+// 没有 index 的调用按到达顺序排在所有带 index 的调用之后，不与真实 index 冲突
+func TestMergeToolCallFragments_MixedIndex(t *testing.T) {
+	frags := []llm.ToolCallFragment{
+		{Index: -1, ID: "x", Name: "a", Arguments: `{}`},
+		{Index: 1, ID: "c1", Name: "c", Arguments: `{}`},
+		{Index: -1, ID: "y", Name: "b", Arguments: `{}`},
+		{Index: 0, ID: "c0", Name: "c", Arguments: `{}`},
+	}
+
+	calls, err := llm.MergeToolCallFragments(frags)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(calls))
+	for _, c := range calls {
+		ids = append(ids, c.ID)
+	}
+	require.Equal(t, []string{"c0", "c1", "x", "y"}, ids)
+}
+
+func TestMergeToolCallFragments_EmptyAndInvalid(t *testing.T) {
+	calls, err := llm.MergeToolCallFragments([]llm.ToolCallFragment{
+		{Index: 0, ID: "c", Name: "bash_output"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, calls[0].Function.Arguments)
+	require.Empty(t, calls[0].Function.Arguments)
+
+	_, err = llm.MergeToolCallFragments([]llm.ToolCallFragment{
+		{Index: 0, ID: "c", Name: "bash", Arguments: `{"command": "ls"`},
+	})
+	require.Error(t, err)
+}
+
+// parseResponse 对空参数 / 非法参数降级为空 map，而不是 nil
+func TestParseResponse_RobustArguments(t *testing.T) {
+	mock := newMockLLM(t, toolReply("",
+		mockCall{ID: "call_1", Name: "bash_output", Args: ""},
+		mockCall{ID: "call_2", Name: "bash", Args: `{"command": `},
+	))
+
+	resp, err := mock.client().Generate(context.Background(),
+		[]schema.Message{{Role: "user", Content: "hi"}}, nil)
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 2)
+
+	for _, tc := range resp.ToolCalls {
+		require.NotNil(t, tc.Function.Arguments, tc.Function.Name)
+		require.Empty(t, tc.Function.Arguments, tc.Function.Name)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	tw "gopilot-cli/internal/utils/terminal"
 )
@@ -267,3 +268,36 @@ func TestReal_Banner(t *testing.T) {
 		t.Errorf("expected 46")
 	}
 }
+
+// ------------------------
+// Tool result preview (agent uses width 300)
+// ------------------------
+
+func TestTruncate_CJKNearBoundary(t *testing.T) {
+	// 298 个 ASCII + 若干中文：第 300 列正好落在中文字符中间
+	text := strings.Repeat("a", 298) + "你好世界，这是一段很长的中文输出"
+	ellipsis := "\033[2m...\033[0m"
+
+	r := tw.TruncateWithEllipsis(text, 300, ellipsis)
+
+	if !utf8.ValidString(r) {
+		t.Fatalf("truncated text is not valid UTF-8: %q", r)
+	}
+	if tw.CalculateDisplayWidth(r) > 300 {
+		t.Errorf("width overflow: %d", tw.CalculateDisplayWidth(r))
+	}
+	if !strings.HasSuffix(r, ellipsis) {
+		t.Errorf("expected styled ellipsis suffix")
+	}
+	// 297 列可用：298 个 ASCII 中只保留 297 个，中文整体被丢弃而不是被切半
+	if strings.ContainsRune(r, '你') {
+		t.Errorf("wide rune should not fit in remaining width")
+	}
+}
+
+func TestTruncate_CJKShortUnchanged(t *testing.T) {
+	text := strings.Repeat("中", 150) // 宽度正好 300
+	if tw.TruncateWithEllipsis(text, 300, "...") != text {
+		t.Errorf("text within width should be unchanged")
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gopilot-cli/internal/agent"
+	"gopilot-cli/internal/tools"
+)
+
+func pollChanges(t *testing.T, poll *tools.PollFileChangesTool, args map[string]any) string {
+	t.Helper()
+	res, err := poll.Execute(context.Background(), args)
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+	return res.Content
+}
+
+func TestWatchFileReportsChanges(t *testing.T) {
+	ws := t.TempDir()
+	file := filepath.Join(ws, "out.log")
+	require.NoError(t, os.WriteFile(file, []byte("line1\nline2\n"), 0o644))
+
+	watcher := tools.NewFileWatcher(ws)
+	watch := tools.NewWatchFileTool(watcher)
+	poll := tools.NewPollFileChangesTool(watcher)
+	t.Cleanup(func() { _ = watch.Close() })
+
+	res, err := watch.Execute(context.Background(), map[string]any{"path": "out.log"})
+	require.NoError(t, err)
+	require.True(t, res.Success, res.Error)
+
+	require.Contains(t, pollChanges(t, poll, nil), "No changes")
+
+	require.NoError(t, os.WriteFile(file, []byte("line1\nline2 changed\nline3\n"), 0o644))
+	content := pollChanges(t, poll, map[string]any{})
+	require.Contains(t, content, "out.log: modified")
+	require.Contains(t, content, "-line2")
+	require.Contains(t, content, "+line2 changed")
+
+	// 已检查过的变化不会重复报告
+	require.Contains(t, pollChanges(t, poll, nil), "No changes")
+
+	require.NoError(t, os.Remove(file))
+	require.Contains(t, pollChanges(t, poll, map[string]any{"path": "out.log"}), "out.log: deleted")
+}
+
+func TestWatchFileNotYetCreated(t *testing.T) {
+	ws := t.TempDir()
+	watcher := tools.NewFileWatcher(ws)
+	t.Cleanup(func() { _ = watcher.Close() })
+
+	_, err := watcher.Watch("build/result.txt")
+	require.Error(t, err, "parent directory must exist to be watched")
+
+	_, err = watcher.Watch("result.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "result.txt"), []byte("PASS\n"), 0o644))
+
+	changes, err := watcher.Poll("")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "created", changes[0].Status)
+	require.Equal(t, "PASS\n", changes[0].Diff)
+}
+
+func TestWatchFileConfinementAndCap(t *testing.T) {
+	ws := t.TempDir()
+	watcher := tools.NewFileWatcher(ws)
+	t.Cleanup(func() { _ = watcher.Close() })
+
+	_, err := watcher.Watch("../outside.txt")
+	require.Error(t, err)
+	_, err = watcher.Watch(filepath.Join(t.TempDir(), "x.txt"))
+	require.Error(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err := watcher.Watch(fmt.Sprintf("f%d.txt", i))
+		require.NoError(t, err)
+	}
+	_, err = watcher.Watch("one-too-many.txt")
+	require.ErrorContains(t, err, "too many watched files")
+
+	require.NoError(t, watcher.Unwatch("f0.txt"))
+	_, err = watcher.Watch("one-too-many.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, watcher.Close())
+	require.NoError(t, watcher.Close(), "close is idempotent")
+	_, err = watcher.Watch("after-close.txt")
+	require.Error(t, err)
+}
+
+func TestAgentCloseReleasesWatchers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ws := t.TempDir()
+
+	watcher := tools.NewFileWatcher(ws)
+	m := newMockLLM(t, textReply("ok"))
+	ag, err := agent.NewAgent(m.client(), "sys",
+		[]tools.Tool{tools.NewWatchFileTool(watcher), tools.NewPollFileChangesTool(watcher)},
+		3, ws, 100000)
+	require.NoError(t, err)
+
+	_, err = watcher.Watch("a.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, ag.Close())
+	_, err = watcher.Watch("b.txt")
+	require.ErrorContains(t, err, "closed")
+}